@@ -11,6 +11,8 @@ if [ -z "$1" ]; then
 fi
 
 VERSION=$1
+COMMIT=$(git rev-parse HEAD)
+DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
 OUTPUT_DIR="dist/$VERSION"
 
 # Create the output directory if it doesn't exist
@@ -35,7 +37,7 @@ for OS in "${platforms[@]}"; do
     fi
 
     # Set environment variables to target the OS and architecture
-    GOOS=$OS GOARCH=$ARCH go build -ldflags "-X main.version=$VERSION" -o "./$OUTPUT_DIR/protoc-gen-go-jsonschema-$OS-$ARCH$EXT" ./cmd/protoc-gen-go-jsonschema
+    GOOS=$OS GOARCH=$ARCH go build -ldflags "-X main.version=$VERSION -X main.commit=$COMMIT -X main.date=$DATE" -o "./$OUTPUT_DIR/protoc-gen-go-jsonschema-$OS-$ARCH$EXT" ./cmd/protoc-gen-go-jsonschema
 
     echo "Built protoc-gen-go-jsonschema@$VERSION for $OS/$ARCH"
   done
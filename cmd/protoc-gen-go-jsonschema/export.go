@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// exportProgram writes the generated schemas of the messages given as arguments, by full
+// name, or of every message, to stdout as a JSON object keyed by full name.
+var exportProgram = template.Must(template.New("main.go").Parse(`package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/jsonschema-go/jsonschema"
+{{range .Packages}}
+	{{.Alias}} "{{.ImportPath}}"
+{{- end}}
+)
+
+var schemas = map[string]func() *jsonschema.Schema{
+{{- range .Messages}}
+	"{{.Name}}": (&{{.Alias}}.{{.GoName}}{}).JsonSchema,
+{{- end}}
+}
+
+func main() {
+	names := os.Args[1:]
+	if len(names) == 0 {
+		for name := range schemas {
+			names = append(names, name)
+		}
+	}
+	out := make(map[string]*jsonschema.Schema)
+	for _, name := range names {
+		schema, ok := schemas[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no schema for message %q\n", name)
+			os.Exit(2)
+		}
+		out[name] = schema()
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+`))
+
+// exportManifest indexes the files written by the export subcommand.
+type exportManifest struct {
+	// Messages maps message full names to the files of their schemas.
+	Messages map[string]string `json:"messages"`
+
+	// Definitions maps definition names to their files, with -split.
+	Definitions map[string]string `json:"definitions,omitempty"`
+}
+
+// runExport implements the export subcommand: it writes the schemas generated for the
+// messages of a descriptor set as JSON files, one bundled <message>.json per message, or
+// with -split the root documents and one file per definition under defs/ referencing each
+// other by relative paths (see schemautil.Split), plus a manifest.json index. Like
+// validate, the generated code is built in a temporary module with the go toolchain on
+// PATH. It returns the exit code: 0 on success and 2 on errors.
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: protoc-gen-go-jsonschema export --descriptor_set <file> --out <dir> [--split] [--definitions] [--opt name=value]... [message full name]...")
+		flags.PrintDefaults()
+	}
+	descriptorSet := flags.String("descriptor_set", "", "Descriptor set (protoc --descriptor_set_out --include_imports) with the messages")
+	out := flags.String("out", "", "Directory to write the schema files and manifest.json to")
+	split := flags.Bool("split", false, "Write each definition to its own file under defs/, referenced by relative paths, instead of bundling $defs")
+	definitions := flags.Bool("definitions", false, "Repeat the bundled $defs under definitions, for consumers that only read the draft-07 keyword")
+	opts := pluginOptions(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *descriptorSet == "" || *out == "" {
+		flags.Usage()
+		return 2
+	}
+
+	schemas, err := exportSchemas(*descriptorSet, *opts, flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	files, err := exportFiles(schemas, *split, *definitions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for name, data := range files {
+		path := filepath.Join(*out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	fmt.Printf("exported %d message schemas in %d files to %s\n", len(schemas), len(files), *out)
+	return 0
+}
+
+// exportSchemas builds the code generated for the descriptor set and returns the schemas
+// of the messages, or of every message if messages is empty, keyed by full name.
+func exportSchemas(descriptorSet string, opts plugin.Options, messages []string) (map[string]*jsonschema.Schema, error) {
+	files, _, err := generateSchemaModule(descriptorSet, opts, exportProgram)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "protoc-gen-go-jsonschema-export-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	exporter, err := buildSchemaModule(dir, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(exporter, messages...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+	var schemas map[string]*jsonschema.Schema
+	if err := json.Unmarshal(stdout.Bytes(), &schemas); err != nil {
+		return nil, fmt.Errorf("reading the exported schemas: %w", err)
+	}
+	return schemas, nil
+}
+
+// exportFiles returns the contents of the files the export subcommand writes for the
+// schemas, keyed by slash-separated path. With split, definitions shared by several
+// messages are written once; differing definitions of the same name are an error. With
+// definitions, documents with $defs repeat them under definitions (see
+// schemautil.MarshalWithDefinitions).
+func exportFiles(schemas map[string]*jsonschema.Schema, split, definitions bool) (map[string][]byte, error) {
+	manifest := exportManifest{Messages: make(map[string]string)}
+	if split {
+		manifest.Definitions = make(map[string]string)
+	}
+	files := make(map[string][]byte)
+
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		root := name + ".json"
+		manifest.Messages[name] = root
+		docs := map[string]*jsonschema.Schema{root: schemas[name]}
+		if split {
+			docs = schemautil.Split(schemas[name], root)
+		}
+		for path, doc := range docs {
+			compact, err := json.Marshal(doc)
+			if definitions {
+				compact, err = schemautil.MarshalWithDefinitions(doc)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, compact, "", "  "); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			data := append(indented.Bytes(), '\n')
+			if previous, ok := files[path]; ok && !bytes.Equal(previous, data) {
+				return nil, fmt.Errorf("%s: messages generate differing documents for the same file", path)
+			}
+			files[path] = data
+			if def, ok := strings.CutPrefix(path, schemautil.SplitDefsDir+"/"); ok {
+				manifest.Definitions[strings.TrimSuffix(def, ".json")] = path
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files["manifest.json"] = append(data, '\n')
+	return files, nil
+}
@@ -3,16 +3,25 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"runtime/debug"
+	"strings"
 
 	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
-// version can be set at build time via ldflags
-var version string
+// version, commit and date can be set at build time via ldflags
+var (
+	version string
+	commit  string
+	date    string
+)
 
 func getVersion() string {
 	// If version was set via ldflags, use it
@@ -28,15 +37,102 @@ func getVersion() string {
 	return "development"
 }
 
+// getBuildInfo returns the commit and date the binary was built from: the ldflags values,
+// or else the VCS settings Go records when building from a checkout. Either may be empty.
+func getBuildInfo() (revision, built string) {
+	revision, built = commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && revision == "":
+				revision = setting.Value
+			case setting.Key == "vcs.time" && built == "":
+				built = setting.Value
+			}
+		}
+	}
+	return revision, built
+}
+
+// printVersion prints the version, followed by the commit and build date when known.
+func printVersion(w io.Writer) {
+	line := getVersion()
+	if revision, built := getBuildInfo(); revision != "" || built != "" {
+		var details []string
+		if revision != "" {
+			details = append(details, "commit "+revision)
+		}
+		if built != "" {
+			details = append(details, "built "+built)
+		}
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	fmt.Fprintln(w, line)
+}
+
+// printParams prints the plugin parameters documented by plugin.Params.
+func printParams(w io.Writer) {
+	fmt.Fprintln(w, "Plugin parameters, passed as --go-jsonschema_opt=<name>=<value> (comma-separated or repeated):")
+	for _, p := range plugin.Params() {
+		fmt.Fprintln(w)
+		header := fmt.Sprintf("  %s (%s", p.Name, p.Type)
+		if p.Default != "" {
+			header += ", default " + p.Default
+		}
+		fmt.Fprintln(w, header+")")
+		fmt.Fprintf(w, "      %s\n", p.Usage)
+		fmt.Fprintf(w, "      Example: --go-jsonschema_opt=%s\n", p.Example)
+	}
+}
+
+// subcommands maps the subcommands of the binary, given as its first argument, to their
+// implementations, which parse the remaining arguments with their own flags and return
+// the exit code.
+var subcommands = map[string]func(args []string) int{
+	"validate": runValidate,
+	"export":   runExport,
+	"serve":    runServe,
+	"migrate":  runMigrate,
+	"selftest": runSelfTest,
+}
+
 func main() {
 	var flags flag.FlagSet
+	var opts plugin.Options
+	opts.RegisterFlags(&flags)
 
 	// Get the flags
-	showVersion := flag.Bool("version", false, "Print the version of protoc-gen-go-jsonschema")
+	showVersion := flag.Bool("version", false, "Print the version of protoc-gen-go-jsonschema with its commit and build date")
+	helpParams := flag.Bool("help-params", false, "Print the plugin parameters with their types, defaults and examples")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: protoc-gen-go-jsonschema [-version | -help-params]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       protoc-gen-go-jsonschema <validate | export | serve | migrate | selftest> [flags]")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		run, ok := subcommands[flag.Arg(0)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown subcommand %q\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+		if flag.NFlag() > 0 {
+			fmt.Fprintf(os.Stderr, "flags given before the %s subcommand are not supported\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(run(flag.Args()[1:]))
+	}
+
 	if *showVersion {
-		fmt.Printf("%s\n", getVersion())
+		printVersion(os.Stdout)
+		os.Exit(0)
+	}
+
+	if *helpParams {
+		printParams(os.Stdout)
 		os.Exit(0)
 	}
 
@@ -47,7 +143,92 @@ func main() {
 	version := getVersion()
 
 	options.Run(func(p *protogen.Plugin) error {
-		p.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
-		return plugin.Generate(p, version)
+		return plugin.GenerateWithOptions(p, version, opts)
 	})
 }
+
+// pluginOptions registers the repeatable --opt flag of a subcommand, which sets a plugin
+// parameter (name=value, or name alone for true) on the returned options.
+func pluginOptions(flags *flag.FlagSet) *plugin.Options {
+	var params flag.FlagSet
+	opts := new(plugin.Options)
+	opts.RegisterFlags(&params)
+	flags.Func("opt", "Plugin parameter (name=value) the schemas are generated with; may be repeated", func(value string) error {
+		name, v, ok := strings.Cut(value, "=")
+		if !ok {
+			v = "true"
+		}
+		return params.Set(name, v)
+	})
+	return opts
+}
+
+// runMigrate implements the migrate subcommand: it prints the migrations reported by
+// plugin.Migrate for every file in a descriptor set and returns the exit code: 0 when no
+// edits are needed, 1 when some are, and 2 on errors.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: protoc-gen-go-jsonschema migrate --descriptor_set <file> [--opt name=value]...")
+		flags.PrintDefaults()
+	}
+	descriptorSet := flags.String("descriptor_set", "", "Descriptor set (protoc --descriptor_set_out --include_source_info) to scan")
+	opts := pluginOptions(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *descriptorSet == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	fds, err := readDescriptorSet(*descriptorSet)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Every file is scanned. Files without a go_package get a placeholder import path,
+	// since protogen requires one for files to generate and no Go code is emitted.
+	req := &pluginpb.CodeGeneratorRequest{ProtoFile: fds.File}
+	var params []string
+	for _, f := range fds.File {
+		req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+		if f.GetOptions().GetGoPackage() == "" {
+			params = append(params, fmt.Sprintf("M%s=%s", f.GetName(), path.Join("migrate", path.Dir(f.GetName()))))
+		}
+	}
+	req.Parameter = proto.String(strings.Join(params, ","))
+	p, err := protogen.Options{}.New(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	migrations, err := plugin.Migrate(p, *opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, m := range migrations {
+		fmt.Println(m)
+	}
+	if len(migrations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readDescriptorSet reads the FileDescriptorSet at path, as written by protoc
+// --descriptor_set_out.
+func readDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &fds, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// schemaModulePath is the module path of the temporary schema modules built by serve,
+// validate and export. Every proto file of the descriptor set is generated into a package
+// below it: its go_package (or else its directory) prefixed by the module path.
+const schemaModulePath = "schemas.example"
+
+// pluginModulePath is the module path of the plugin, whose schemautil package generated
+// code imports with options such as draft=draft-07, prune, coerce_tool_args and target.
+const pluginModulePath = "github.com/alis-exchange/protoc-gen-go-jsonschema"
+
+// schemaModuleSanthoshVersion is the github.com/santhosh-tekuri/jsonschema/v6 version
+// schema modules require when their program validates with it.
+const schemaModuleSanthoshVersion = "v6.0.3"
+
+// Patterns reading the package and the receivers of generated JsonSchema methods from
+// generated code.
+var (
+	modulePackageClause    = regexp.MustCompile(`(?m)^package (\w+)`)
+	moduleAccessorReceiver = regexp.MustCompile(`(?m)^func \(x \*(\w+)\) JsonSchema\(\)`)
+)
+
+// modulePackage is a generated Go package of a schema module.
+type modulePackage struct {
+	Alias      string
+	ImportPath string
+	Name       string
+	Types      []string
+}
+
+// moduleMessage is a message with a generated JsonSchema method in a schema module.
+type moduleMessage struct {
+	Name   string
+	Alias  string
+	GoName string
+}
+
+// schemaModuleData is the data the main program template of a schema module is executed
+// with: the generated packages, imported by Alias, and the messages with a JsonSchema
+// method, by full name.
+type schemaModuleData struct {
+	Packages []*modulePackage
+	Messages []moduleMessage
+}
+
+// generateSchemaModule runs the plugin on every file of the descriptor set at path, with
+// each file's Go package placed below schemaModulePath, and returns the files of a module
+// keyed by path: the generated code, stand-in types for the messages with a JsonSchema
+// method, main.go from program and go.mod. It also returns the number of messages with a
+// JsonSchema method, and fails if there are none.
+func generateSchemaModule(descriptorSet string, opts plugin.Options, program *template.Template) (map[string]string, int, error) {
+	fds, err := readDescriptorSet(descriptorSet)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{ProtoFile: fds.File}
+	var params []string
+	for _, f := range fds.File {
+		req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+		importPath := path.Dir(f.GetName())
+		if goPackage := f.GetOptions().GetGoPackage(); goPackage != "" {
+			importPath = goPackage
+		}
+		params = append(params, fmt.Sprintf("M%s=%s", f.GetName(), path.Join(schemaModulePath, importPath)))
+	}
+	req.Parameter = proto.String(strings.Join(params, ","))
+	p, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := plugin.GenerateWithOptions(p, getVersion(), opts); err != nil {
+		return nil, 0, err
+	}
+	resp := p.Response()
+	if resp.GetError() != "" {
+		return nil, 0, errors.New(resp.GetError())
+	}
+
+	// Full names of the messages, by Go import path and type name.
+	names := make(map[string]string)
+	var walk func(msgs []*protogen.Message)
+	walk = func(msgs []*protogen.Message) {
+		for _, msg := range msgs {
+			names[path.Join(string(msg.GoIdent.GoImportPath), msg.GoIdent.GoName)] = string(msg.Desc.FullName())
+			walk(msg.Messages)
+		}
+	}
+	for _, f := range p.Files {
+		walk(f.Messages)
+	}
+
+	files := make(map[string]string)
+	packages := make(map[string]*modulePackage)
+	for _, f := range resp.File {
+		name := strings.TrimPrefix(f.GetName(), schemaModulePath+"/")
+		files[name] = f.GetContent()
+		importPath := path.Join(schemaModulePath, path.Dir(name))
+		pkg := packages[importPath]
+		if pkg == nil {
+			pkg = &modulePackage{ImportPath: importPath}
+			packages[importPath] = pkg
+		}
+		if m := modulePackageClause.FindStringSubmatch(f.GetContent()); m != nil {
+			pkg.Name = m[1]
+		}
+		for _, m := range moduleAccessorReceiver.FindAllStringSubmatch(f.GetContent(), -1) {
+			pkg.Types = append(pkg.Types, m[1])
+		}
+	}
+
+	var data schemaModuleData
+	importPaths := make([]string, 0, len(packages))
+	for importPath := range packages {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+	for i, importPath := range importPaths {
+		pkg := packages[importPath]
+		if len(pkg.Types) == 0 {
+			continue
+		}
+		pkg.Alias = fmt.Sprintf("p%d", i)
+		sort.Strings(pkg.Types)
+		var stubs strings.Builder
+		fmt.Fprintf(&stubs, "package %s\n\n// Stand-ins for the protoc-gen-go types the generated methods are declared on.\n", pkg.Name)
+		for _, t := range pkg.Types {
+			fmt.Fprintf(&stubs, "\ntype %s struct{}\n", t)
+			data.Messages = append(data.Messages, moduleMessage{Name: names[path.Join(importPath, t)], Alias: pkg.Alias, GoName: t})
+		}
+		files[path.Join(strings.TrimPrefix(importPath, schemaModulePath+"/"), "schema_module_types.go")] = stubs.String()
+		data.Packages = append(data.Packages, pkg)
+	}
+	if len(data.Messages) == 0 {
+		return nil, 0, fmt.Errorf("%s: no message schemas were generated; set the json_schema generate option on the messages", descriptorSet)
+	}
+
+	var main bytes.Buffer
+	if err := program.Execute(&main, data); err != nil {
+		return nil, 0, err
+	}
+	files["main.go"] = main.String()
+	requires := "\tgithub.com/google/jsonschema-go " + selfTestJSONSchemaVersion + "\n" +
+		"\tgithub.com/santhosh-tekuri/jsonschema/v6 " + schemaModuleSanthoshVersion + "\n"
+	if importsPluginModule(files) {
+		version, err := pluginModuleVersion()
+		if err != nil {
+			return nil, 0, err
+		}
+		requires += "\t" + pluginModulePath + " " + version + "\n"
+	}
+	files["go.mod"] = "module " + schemaModulePath + "\n\ngo 1.22\n\nrequire (\n" + requires + ")\n"
+	return files, len(data.Messages), nil
+}
+
+// importsPluginModule reports whether a file of a schema module imports a package of the
+// plugin module.
+func importsPluginModule(files map[string]string) bool {
+	for _, content := range files {
+		if strings.Contains(content, "\""+pluginModulePath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginModuleVersion returns the version of the plugin module the binary was built from,
+// which schema modules importing its packages require so that they match the generated
+// code. It fails for binaries not built from a published module version, such as
+// development builds of a checkout, whose packages the go toolchain cannot download.
+func pluginModuleVersion() (string, error) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Path != pluginModulePath || !strings.HasPrefix(info.Main.Version, "v") || strings.Contains(info.Main.Version, "+") {
+		version := "unknown"
+		if ok && info.Main.Version != "" {
+			version = info.Main.Version
+		}
+		return "", fmt.Errorf("the generated code imports %s/schemautil, which requires a protoc-gen-go-jsonschema binary installed from a published version "+
+			"(this one is %s); install one with go install %s/cmd/protoc-gen-go-jsonschema@<version>, "+
+			"or drop the options that use it (draft=draft-07, prune, coerce_tool_args, target)", pluginModulePath, version, pluginModulePath)
+	}
+	return info.Main.Version, nil
+}
+
+// buildSchemaModule writes the module files into dir and builds its program with the go
+// toolchain, returning the path of the binary.
+func buildSchemaModule(dir string, files map[string]string) (string, error) {
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	binary := filepath.Join(dir, "schemas")
+	for _, args := range [][]string{{"mod", "tidy"}, {"build", "-o", binary, "."}} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOWORK=off")
+		var stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = io.Discard, &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("go %s: %v\n%s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return binary, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	optionsPb "open.alis.services/protobuf/alis/open/options/v1"
+)
+
+// selfTestJSONSchemaVersion is the github.com/google/jsonschema-go version the self-test
+// module requires to resolve the generated schema.
+const selfTestJSONSchemaVersion = "v0.4.2"
+
+// selfTestProgram resolves the schema generated for the built-in descriptor and checks
+// that it accepts a valid instance and rejects an invalid one.
+const selfTestProgram = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"selftest.example/selftestv1"
+)
+
+func main() {
+	schema, err := (&selftestv1.Item{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Resolve:", err)
+		os.Exit(1)
+	}
+	valid := map[string]any{
+		"name":     "root",
+		"status":   1,
+		"tags":     []any{"a"},
+		"children": []any{map[string]any{"name": "child", "status": 0, "tags": []any{}, "children": []any{}}},
+	}
+	if err := schema.Validate(valid); err != nil {
+		fmt.Fprintln(os.Stderr, "Validate(valid item):", err)
+		os.Exit(1)
+	}
+	if err := schema.Validate(map[string]any{"name": 1}); err == nil {
+		fmt.Fprintln(os.Stderr, "Validate accepted an invalid item")
+		os.Exit(1)
+	}
+}
+`
+
+// runSelfTest implements the selftest subcommand, which takes no arguments: it checks that
+// the plugin works in the current environment with selfTest, reporting to stdout.
+func runSelfTest(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: protoc-gen-go-jsonschema selftest")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	return selfTest(os.Stdout)
+}
+
+// selfTest checks that the plugin works in the current environment, for debugging CI
+// images: it prints the versions the binary was built with, generates code for a built-in
+// descriptor and, with the go toolchain on PATH, compiles that code and resolves the
+// schema with github.com/google/jsonschema-go. It returns the exit code: 0 when every
+// check passes and 1 otherwise.
+func selfTest(w io.Writer) int {
+	printSelfTestVersions(w)
+
+	content, err := selfTestGenerate()
+	if err != nil {
+		fmt.Fprintf(w, "FAIL generate: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(w, "ok   generate: selftest/v1/selftest.proto (%d bytes)\n", len(content))
+
+	goVersion, err := exec.Command("go", "version").Output()
+	if err != nil {
+		fmt.Fprintf(w, "FAIL go toolchain: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(w, "ok   go toolchain: %s\n", strings.TrimSpace(string(goVersion)))
+
+	jsonschemaVersion, err := selfTestResolve(content)
+	if err != nil {
+		fmt.Fprintf(w, "FAIL resolve: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(w, "ok   resolve: selftest.v1.Item with github.com/google/jsonschema-go %s\n", jsonschemaVersion)
+	return 0
+}
+
+// printSelfTestVersions prints the plugin version and the Go and module versions the
+// binary was built with.
+func printSelfTestVersions(w io.Writer) {
+	fmt.Fprint(w, "protoc-gen-go-jsonschema ")
+	printVersion(w)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "built with %s\n", info.GoVersion)
+	for _, dep := range info.Deps {
+		switch dep.Path {
+		case "google.golang.org/protobuf", "open.alis.services/protobuf":
+			version := dep.Version
+			if dep.Replace != nil {
+				version += " => " + dep.Replace.Path + " " + dep.Replace.Version
+			}
+			fmt.Fprintf(w, "  %s %s\n", dep.Path, strings.TrimSpace(version))
+		}
+	}
+}
+
+// selfTestDescriptor returns the built-in file the self-test generates code for: an enum
+// and a message with scalar, enum, repeated and recursive fields, generated through the
+// file-level json_schema option.
+func selfTestDescriptor() *descriptorpb.FileDescriptorProto {
+	fileOpts := &descriptorpb.FileOptions{GoPackage: proto.String("selftest.example/selftestv1;selftestv1")}
+	proto.SetExtension(fileOpts, optionsPb.E_File, &optionsPb.FileOptions{
+		JsonSchema: &optionsPb.FileOptions_JsonSchema{Generate: true},
+	})
+
+	field := func(name string, number int32, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    label.Enum(),
+			Type:     typ.Enum(),
+			JsonName: proto.String(name),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("selftest/v1/selftest.proto"),
+		Package: proto.String("selftest.v1"),
+		Syntax:  proto.String("proto3"),
+		Options: fileOpts,
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATUS_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("STATUS_ACTIVE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("status", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".selftest.v1.Status"),
+				field("tags", 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("children", 4, repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".selftest.v1.Item"),
+			},
+		}},
+	}
+}
+
+// selfTestGenerate runs the plugin on the built-in descriptor and returns the content of
+// the generated schema file.
+func selfTestGenerate() (string, error) {
+	file := selfTestDescriptor()
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := plugin.GenerateWithOptions(p, getVersion(), plugin.Options{}); err != nil {
+		return "", err
+	}
+	for _, f := range p.Response().File {
+		if f.GetName() == "selftest.example/selftestv1/selftest_jsonschema.pb.go" {
+			return f.GetContent(), nil
+		}
+	}
+	return "", errors.New("no schema file was generated")
+}
+
+// selfTestResolve compiles the generated code in a temporary module with the go toolchain
+// and runs selfTestProgram against it. It returns the jsonschema-go version the module
+// resolved.
+func selfTestResolve(content string) (string, error) {
+	dir, err := os.MkdirTemp("", "protoc-gen-go-jsonschema-selftest-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"go.mod":                               "module selftest.example\n\ngo 1.21\n\nrequire github.com/google/jsonschema-go " + selfTestJSONSchemaVersion + "\n",
+		"main.go":                              selfTestProgram,
+		"selftestv1/selftest_jsonschema.pb.go": content,
+		"selftestv1/types.go":                  "package selftestv1\n\ntype Item struct{}\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOWORK=off")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("go %s: %v\n%s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	if _, err := run("mod", "tidy"); err != nil {
+		return "", err
+	}
+	if _, err := run("run", "."); err != nil {
+		return "", err
+	}
+	return run("list", "-m", "-f", "{{.Version}}", "github.com/google/jsonschema-go")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"text/template"
+)
+
+// serveProgram is the playground server: it serves servePage and validates payloads
+// against the generated schemas, which are registered by full message name.
+var serveProgram = template.Must(template.New("main.go").Funcs(template.FuncMap{
+	"page": func() string { return "`" + servePage + "`" },
+}).Parse(`package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/google/jsonschema-go/jsonschema"
+{{range .Packages}}
+	{{.Alias}} "{{.ImportPath}}"
+{{- end}}
+)
+
+var schemas = map[string]func() *jsonschema.Schema{
+{{- range .Messages}}
+	"{{.Name}}": (&{{.Alias}}.{{.GoName}}{}).JsonSchema,
+{{- end}}
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	flag.Parse()
+
+	http.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+	})
+	http.HandleFunc("GET /messages", func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(schemas))
+		for name := range schemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusOK, names)
+	})
+	http.HandleFunc("GET /schema", func(w http.ResponseWriter, r *http.Request) {
+		schema, ok := schemas[r.URL.Query().Get("message")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown message"})
+			return
+		}
+		writeJSON(w, http.StatusOK, schema())
+	})
+	http.HandleFunc("POST /validate", func(w http.ResponseWriter, r *http.Request) {
+		schema, ok := schemas[r.URL.Query().Get("message")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown message"})
+			return
+		}
+		var instance any
+		if err := json.NewDecoder(r.Body).Decode(&instance); err != nil {
+			writeJSON(w, http.StatusOK, map[string]any{"valid": false, "error": "invalid JSON: " + err.Error()})
+			return
+		}
+		resolved, err := schema().Resolve(nil)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "resolve: " + err.Error()})
+			return
+		}
+		if err := resolved.Validate(instance); err != nil {
+			writeJSON(w, http.StatusOK, map[string]any{"valid": false, "error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"valid": true})
+	})
+
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+const page = {{page}}
+`))
+
+// servePage is the playground UI: a message picker, the message's schema, and a payload
+// editor showing the validation result.
+const servePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>protoc-gen-go-jsonschema playground</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; }
+main { display: flex; gap: 1.5em; }
+section { flex: 1; min-width: 0; }
+textarea, pre { box-sizing: border-box; width: 100%; height: 32em; font-family: monospace; overflow: auto; }
+pre { background: #f5f5f5; margin: 0; padding: 0.5em; }
+#result { white-space: pre-wrap; }
+.valid { color: #1a7f37; }
+.invalid { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>JSON Schema playground</h1>
+<p><label>Message <select id="message"></select></label> <button id="validate">Validate</button></p>
+<p id="result"></p>
+<main>
+<section><h2>Payload</h2><textarea id="payload" spellcheck="false">{}</textarea></section>
+<section><h2>Schema</h2><pre id="schema"></pre></section>
+</main>
+<script>
+const message = document.getElementById("message");
+const result = document.getElementById("result");
+async function showSchema() {
+  const r = await fetch("schema?message=" + encodeURIComponent(message.value));
+  document.getElementById("schema").textContent = await r.text();
+  result.textContent = "";
+}
+async function validate() {
+  const r = await fetch("validate?message=" + encodeURIComponent(message.value), {method: "POST", body: document.getElementById("payload").value});
+  const body = await r.json();
+  result.className = body.valid ? "valid" : "invalid";
+  result.textContent = body.valid ? "Valid" : body.error;
+}
+message.addEventListener("change", showSchema);
+document.getElementById("validate").addEventListener("click", validate);
+fetch("messages").then(r => r.json()).then(names => {
+  for (const name of names) {
+    message.add(new Option(name, name));
+  }
+  showSchema();
+});
+</script>
+</body>
+</html>
+`
+
+// runServe implements the serve subcommand: it serves a playground for the messages of a
+// descriptor set until interrupted. The plugin generates their schemas in memory, the
+// generated code is built into a server in a temporary module with the go toolchain on
+// PATH, and the server listens on --addr with a UI for validating JSON payloads against
+// any generated message. It returns the exit code: 0 when interrupted, 1 when the server
+// fails, and 2 on errors before serving.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: protoc-gen-go-jsonschema serve --descriptor_set <file> [--addr host:port] [--opt name=value]...")
+		flags.PrintDefaults()
+	}
+	descriptorSet := flags.String("descriptor_set", "", "Descriptor set (protoc --descriptor_set_out --include_imports) with the messages")
+	addr := flags.String("addr", "localhost:8080", "Address the playground listens on")
+	opts := pluginOptions(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *descriptorSet == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	files, messages, err := generateSchemaModule(*descriptorSet, *opts, serveProgram)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	dir, err := os.MkdirTemp("", "protoc-gen-go-jsonschema-serve-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer os.RemoveAll(dir)
+
+	server, err := buildSchemaModule(dir, files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cmd := exec.CommandContext(ctx, server, "-addr", *addr)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("Serving %d message schemas from %s on http://%s\n", messages, *descriptorSet, *addr)
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
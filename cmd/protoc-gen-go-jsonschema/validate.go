@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"text/template"
+)
+
+// validateProgram validates the JSON values of data files against the generated schema of
+// a message, given by full name: every value of a file is a record, so that a file may
+// hold a single JSON document or newline-delimited JSON. Each failure is reported with the
+// file, the line its record starts on, and the JSON Pointer of the invalid value.
+var validateProgram = template.Must(template.New("main.go").Parse(`package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	santhosh "github.com/santhosh-tekuri/jsonschema/v6"
+{{range .Packages}}
+	{{.Alias}} "{{.ImportPath}}"
+{{- end}}
+)
+
+var schemas = map[string]func() *jsonschema.Schema{
+{{- range .Messages}}
+	"{{.Name}}": (&{{.Alias}}.{{.GoName}}{}).JsonSchema,
+{{- end}}
+}
+
+func main() {
+	message := flag.String("message", "", "full name of the message to validate against")
+	flag.Parse()
+
+	schema, ok := schemas[*message]
+	if !ok {
+		names := make([]string, 0, len(schemas))
+		for name := range schemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "no schema for message %q; messages: %s\n", *message, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+	compiled, err := compile(schema())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *message, err)
+		os.Exit(2)
+	}
+
+	var records, failed int
+	for _, name := range flag.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			line := lineAt(data, dec.InputOffset())
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err == io.EOF {
+				break
+			} else if err != nil {
+				records++
+				failed++
+				fmt.Printf("%s:%d: invalid JSON: %v\n", name, line, err)
+				break
+			}
+			records++
+			instance, err := santhosh.UnmarshalJSON(bytes.NewReader(raw))
+			if err == nil {
+				err = compiled.Validate(instance)
+			}
+			if err == nil {
+				continue
+			}
+			failed++
+			var verr *santhosh.ValidationError
+			if !errors.As(err, &verr) {
+				fmt.Printf("%s:%d: %v\n", name, line, err)
+				continue
+			}
+			for _, unit := range leaves(verr.DetailedOutput()) {
+				fmt.Printf("%s:%d: at %q: %s\n", name, line, unit.InstanceLocation, unit.Error)
+			}
+		}
+	}
+
+	fmt.Printf("%d of %d records failed validation against %s\n", failed, records, *message)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// compile compiles the schema for validation, asserting formats.
+func compile(schema *jsonschema.Schema) (*santhosh.Schema, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := santhosh.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	c := santhosh.NewCompiler()
+	c.AssertFormat()
+	if err := c.AddResource("urn:schema", doc); err != nil {
+		return nil, err
+	}
+	return c.Compile("urn:schema")
+}
+
+// leaves returns the output units of the failures the unit is made of, skipping the
+// "validation failed" units of the keywords and references wrapping them.
+func leaves(unit *santhosh.OutputUnit) []*santhosh.OutputUnit {
+	if len(unit.Errors) == 0 {
+		return []*santhosh.OutputUnit{unit}
+	}
+	var out []*santhosh.OutputUnit
+	for i := range unit.Errors {
+		out = append(out, leaves(&unit.Errors[i])...)
+	}
+	return out
+}
+
+// lineAt returns the line the next value after offset starts on.
+func lineAt(data []byte, offset int64) int {
+	rest := bytes.TrimLeft(data[offset:], " \t\r\n")
+	return 1 + bytes.Count(data[:len(data)-len(rest)], []byte("\n"))
+}
+`))
+
+// runValidate implements the validate subcommand: it validates the JSON values of data
+// files, each file a single JSON document or newline-delimited JSON, against the schema
+// generated for a message of a descriptor set, and reports every failure with its file,
+// line and JSON Pointer. Like serve, the generated code is built in a temporary module
+// with the go toolchain on PATH. It returns the exit code: 0 when every record is valid,
+// 1 when some are not, and 2 on errors.
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: protoc-gen-go-jsonschema validate --descriptor_set <file> --message <full name> [--opt name=value]... <data file>...")
+		flags.PrintDefaults()
+	}
+	descriptorSet := flags.String("descriptor_set", "", "Descriptor set (protoc --descriptor_set_out --include_imports) with the message")
+	message := flags.String("message", "", "Full name of the message to validate records against, such as users.v1.User")
+	opts := pluginOptions(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *descriptorSet == "" || *message == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	files, _, err := generateSchemaModule(*descriptorSet, *opts, validateProgram)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	dir, err := os.MkdirTemp("", "protoc-gen-go-jsonschema-validate-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer os.RemoveAll(dir)
+
+	validator, err := buildSchemaModule(dir, files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cmd := exec.Command(validator, append([]string{"-message", *message, "--"}, flags.Args()...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
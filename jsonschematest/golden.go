@@ -0,0 +1,117 @@
+// Package jsonschematest provides golden-file helpers for testing code generated by
+// protoc-gen-go-jsonschema and the schemas it returns.
+//
+// Generated files and schemas are compared after the same normalization the plugin's
+// own tests use, so a regeneration with a newer plugin version or at another time does
+// not fail a snapshot test:
+//
+//	func TestUserSchema(t *testing.T) {
+//		jsonschematest.AssertSchemaGolden(t, (&examplev1.User{}).JsonSchema(), "testdata/user.schema.json", *update)
+//	}
+//
+// Pass update as true (typically from a -update test flag) to write the golden file
+// instead of comparing against it.
+package jsonschematest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// VolatileLines are the markers of generated-file lines removed by Normalize. The lines
+// record when and by which plugin version a file was generated, and the hash of its
+// descriptor (which covers comments and source locations), so they differ between
+// otherwise identical outputs.
+var VolatileLines = []string{
+	"Generated on:",
+	"Plugin version:",
+	"const JsonSchemaGenVersion_",
+	"const JsonSchemaDescriptorHash_",
+}
+
+// Normalize returns the content without the lines containing any of VolatileLines.
+func Normalize(content string) string {
+	lines := strings.Split(content, "\n")
+	normalized := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if isVolatileLine(line) {
+			continue
+		}
+		normalized = append(normalized, line)
+	}
+	return strings.Join(normalized, "\n")
+}
+
+// isVolatileLine reports whether the line contains one of VolatileLines.
+func isVolatileLine(line string) bool {
+	for _, marker := range VolatileLines {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertGolden compares actual against the golden file at goldenPath after Normalize.
+// If update is true, it writes actual to the golden file (creating its directory)
+// instead of comparing.
+func AssertGolden(t testing.TB, actual, goldenPath string, update bool) {
+	t.Helper()
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			t.Fatalf("Failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, []byte(actual), 0o644); err != nil {
+			t.Fatalf("Failed to update golden file %s: %v", goldenPath, err)
+		}
+		t.Logf("Updated golden file: %s", goldenPath)
+		return
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s: %v\nRun with -update to create it", goldenPath, err)
+	}
+
+	if Normalize(actual) != Normalize(string(expected)) {
+		t.Errorf("Output does not match golden file %s.\nRun with -update to update it.\n\nExpected:\n%s\n\nActual:\n%s",
+			goldenPath, string(expected), actual)
+	}
+}
+
+// AssertSchemaGolden compares the schema against the golden file at goldenPath using
+// SchemaJSON, so golden files ignore the differences schemautil.Canonical ignores.
+// If update is true, it writes the golden file instead of comparing.
+func AssertSchemaGolden(t testing.TB, schema *jsonschema.Schema, goldenPath string, update bool) {
+	t.Helper()
+
+	actual, err := SchemaJSON(schema)
+	if err != nil {
+		t.Fatalf("Failed to encode schema for golden file %s: %v", goldenPath, err)
+	}
+	AssertGolden(t, actual, goldenPath, update)
+}
+
+// SchemaJSON returns the indented form of schemautil.Canonical, ending in a newline:
+// sorted keys, normalized $ref values and no schemautil.VolatileKeys, so identical
+// schemas always produce identical golden files.
+func SchemaJSON(schema *jsonschema.Schema) (string, error) {
+	canonical, err := schemautil.Canonical(schema)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, canonical, "", "  "); err != nil {
+		return "", err
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// composeComments returns the title and description documenting a schema built from
+// several descriptors, such as the response stream of a method, listed from the most
+// specific (the method) to the least specific (its service). Each descriptor's comments
+// are split as by getTitleAndDescription, and the precedence is fixed:
+//
+//   - the title is the first title found, in descriptor order;
+//   - the description is the override, if set, or else the first descriptor's
+//     description, followed by the descriptions of the others as separate paragraphs;
+//     empty and repeated paragraphs are skipped.
+//
+// The override replaces the first descriptor's description only, so the context given by
+// the enclosing descriptors is kept.
+func (gr *Generator) composeComments(override string, descs ...protoreflect.Descriptor) (title, description string) {
+	var paragraphs []string
+	add := func(paragraph string) {
+		paragraph = strings.TrimSpace(paragraph)
+		for _, p := range paragraphs {
+			if p == paragraph {
+				return
+			}
+		}
+		if paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+
+	for i, desc := range descs {
+		t, d := gr.getTitleAndDescription(desc)
+		if title == "" {
+			title = t
+		}
+		if i == 0 && override != "" {
+			d = override
+		}
+		add(d)
+	}
+	return title, strings.Join(paragraphs, "\n\n")
+}
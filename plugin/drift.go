@@ -0,0 +1,274 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// schemaDefinition is the property set of a message schema found in generated code.
+type schemaDefinition struct {
+	// name is the $defs key of the message, e.g. "users.v1.User".
+	name string
+
+	// properties lists the property keys in the order the code assigns them.
+	properties []string
+
+	// types maps property keys to the JSON types of their schema literals (Type, or the
+	// Types union). Properties assigned a reference call or a schema without a type
+	// keyword have no entry.
+	types map[string][]string
+}
+
+// getFileDrift returns the schema drift lines for the generated file with the given name:
+// how the message schemas it is about to define differ from those of the previously
+// generated file in the drift_dir directory. It returns nil if there is no previous file.
+//
+// The schemas are rendered once into a skipped file so that both versions are compared
+// from generated code.
+func (gr *Generator) getFileDrift(gen *protogen.Plugin, file *protogen.File, filename string, localMessages []*protogen.Message, localEnums []*protogen.Enum, googleTypeMessages []*protogen.Message) ([]string, error) {
+	previous, err := gr.opts.readPreviousFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Desc.Path(), err)
+	}
+	if previous == nil {
+		return nil, nil
+	}
+	previousDefs, err := parseSchemaDefinitions(filename, previous)
+	if err != nil {
+		return nil, fmt.Errorf("%s: drift_dir: parsing previously generated %s: %w", file.Desc.Path(), filename, err)
+	}
+
+	draft := gen.NewGeneratedFile(filename, file.GoImportPath)
+	draft.Skip()
+	gr.emitFileHeader(draft, file)
+	if err := gr.emitFileSchemas(draft, file, localMessages, localEnums, googleTypeMessages); err != nil {
+		return nil, err
+	}
+	content, err := draft.Content()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Desc.Path(), err)
+	}
+	currentDefs, err := parseSchemaDefinitions(filename, content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Desc.Path(), err)
+	}
+	return schemaDrift(previousDefs, currentDefs), nil
+}
+
+// readPreviousFile returns the content of the previously generated file with the given
+// name in the drift_dir directory, or nil if there is none.
+func (o Options) readPreviousFile(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(o.DriftDir, filepath.FromSlash(filename)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("drift_dir: %w", err)
+	}
+	return content, nil
+}
+
+// parseSchemaDefinitions extracts the message schemas defined by generated Go code, in
+// source order: each <Message>_JsonSchema_WithDefs function that registers a
+// schema variable under a defs key contributes the keys of its
+// schema.Properties assignments, including those of the chunked property helpers it
+// calls, less the keys it deletes. Enum definitions are not included.
+func parseSchemaDefinitions(filename string, src []byte) ([]schemaDefinition, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := make(map[string]*ast.FuncDecl)
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Body != nil {
+			funcs[fn.Name.Name] = fn
+		}
+	}
+
+	var defs []schemaDefinition
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !strings.HasSuffix(fn.Name.Name, "_JsonSchema_WithDefs") {
+			continue
+		}
+		def := schemaDefinition{types: make(map[string][]string)}
+		var visit func(stmts []ast.Stmt)
+		visit = func(stmts []ast.Stmt) {
+			for _, stmt := range stmts {
+				switch stmt := stmt.(type) {
+				case *ast.ExprStmt:
+					call, ok := stmt.X.(*ast.CallExpr)
+					if !ok {
+						continue
+					}
+					name, ok := call.Fun.(*ast.Ident)
+					if !ok {
+						continue
+					}
+					switch {
+					case name.Name == "delete" && len(call.Args) == 2 && isSchemaProperties(call.Args[0]):
+						// delete(schema.Properties, "name")
+						if key, ok := stringLiteral(call.Args[1]); ok {
+							def.properties = slices.DeleteFunc(def.properties, func(p string) bool { return p == key })
+							delete(def.types, key)
+						}
+					case funcs[name.Name] != nil && len(call.Args) == 2:
+						// <Message>_jsonSchemaProperties1(defs, schema)
+						visit(funcs[name.Name].Body.List)
+					}
+				case *ast.AssignStmt:
+					if len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+						continue
+					}
+					index, ok := stmt.Lhs[0].(*ast.IndexExpr)
+					if !ok {
+						continue
+					}
+					key, ok := stringLiteral(index.Index)
+					if !ok {
+						continue
+					}
+					switch x := index.X.(type) {
+					case *ast.Ident:
+						// defs["users.v1.User"] = schema
+						if rhs, ok := stmt.Rhs[0].(*ast.Ident); ok && x.Name == "defs" && rhs.Name == "schema" {
+							def.name = key
+						}
+					case *ast.SelectorExpr:
+						// schema.Properties["name"] = ...
+						if isSchemaProperties(x) {
+							def.properties = append(def.properties, key)
+							if types := schemaLiteralTypes(stmt.Rhs[0]); len(types) > 0 {
+								def.types[key] = types
+							}
+						}
+					}
+				}
+			}
+		}
+		visit(fn.Body.List)
+		if def.name != "" {
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// isSchemaProperties reports whether expr is schema.Properties.
+func isSchemaProperties(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	recv, ok := sel.X.(*ast.Ident)
+	return ok && recv.Name == "schema" && sel.Sel.Name == "Properties"
+}
+
+// schemaLiteralTypes returns the JSON types of a &jsonschema.Schema{...} literal: its
+// Type, or the elements of its Types. It returns nil for other expressions and literals
+// without either keyword.
+func schemaLiteralTypes(expr ast.Expr) []string {
+	unary, ok := expr.(*ast.UnaryExpr)
+	if !ok || unary.Op != token.AND {
+		return nil
+	}
+	lit, ok := unary.X.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "Type":
+			if t, ok := stringLiteral(kv.Value); ok {
+				return []string{t}
+			}
+		case "Types":
+			union, ok := kv.Value.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			var types []string
+			for _, e := range union.Elts {
+				if t, ok := stringLiteral(e); ok {
+					types = append(types, t)
+				}
+			}
+			return types
+		}
+	}
+	return nil
+}
+
+// stringLiteral returns the value of expr if it is a string literal.
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// schemaDrift summarizes how the message schemas in current differ from those in
+// previous, one line per changed message in the order of current followed by removed
+// messages, e.g. "users.v1.User: added nickname; removed legacy_id". It returns nil if
+// the property sets are the same; property order is ignored.
+func schemaDrift(previous, current []schemaDefinition) []string {
+	var lines []string
+	seen := make(map[string]bool, len(current))
+	for _, cur := range current {
+		seen[cur.name] = true
+		i := slices.IndexFunc(previous, func(d schemaDefinition) bool { return d.name == cur.name })
+		if i < 0 {
+			lines = append(lines, fmt.Sprintf("%s: new message", cur.name))
+			continue
+		}
+		var changes []string
+		if added := missingFrom(cur.properties, previous[i].properties); len(added) > 0 {
+			changes = append(changes, "added "+strings.Join(added, ", "))
+		}
+		if removed := missingFrom(previous[i].properties, cur.properties); len(removed) > 0 {
+			changes = append(changes, "removed "+strings.Join(removed, ", "))
+		}
+		if len(changes) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", cur.name, strings.Join(changes, "; ")))
+		}
+	}
+	for _, prev := range previous {
+		if !seen[prev.name] {
+			lines = append(lines, fmt.Sprintf("%s: removed message", prev.name))
+		}
+	}
+	return lines
+}
+
+// missingFrom returns the elements of a that are not in b, in order.
+func missingFrom(a, b []string) []string {
+	var missing []string
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
@@ -20,6 +20,9 @@
 //     (or standalone function for Google types: google_protobuf_Timestamp_JsonSchema())
 //   - <MessageName>_JsonSchema_WithDefs() - Internal function for recursive schema building
 //
+// Non-Google messages also get MCPInputSchema(), which returns the same schema with an
+// object-typed root for consumers (such as MCP tool inputs) that reject a bare $ref root.
+//
 // # Type Mapping
 //
 // Protocol Buffer types are mapped to JSON Schema types following the proto3 JSON mapping:
@@ -44,15 +47,28 @@
 package plugin
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 	optionsPb "open.alis.services/protobuf/alis/open/options/v1"
 )
 
@@ -76,178 +92,2776 @@ const (
 	jsString  = "string"  // JSON string type - used for strings and bytes
 )
 
-// isGoogleType checks if a message is from a Google package (google.*).
-// This includes well-known types (google.protobuf.*), common types (google.type.*),
-// API types (google.api.*), IAM types (google.iam.*), and any other google.* packages.
-// These are treated specially because we cannot add methods to imported types,
-// so we generate standalone functions with file prefixes instead.
-func isGoogleType(msg *protogen.Message) bool {
-	return strings.HasPrefix(string(msg.Desc.FullName()), "google.")
+// bytesEncodingPatterns maps content_encoding option values for bytes fields to the
+// pattern that validates the encoded string. Standard base64 (the encoding/json
+// default) has no entry and is described by contentEncoding alone.
+var bytesEncodingPatterns = map[string]string{
+	"base64url": "^[A-Za-z0-9_-]*={0,2}$",
+	"hex":       "^([0-9a-fA-F]{2})*$",
+	"base16":    "^([0-9a-fA-F]{2})*$",
+}
+
+// digestFormatPatterns maps digest format presets (the format field option) to
+// exact-length patterns for the encoded digest, so protos don't need to repeat
+// the regex. The preset name is still emitted as the schema's format.
+var digestFormatPatterns = map[string]string{
+	"md5-hex":       "^[0-9a-fA-F]{32}$",
+	"md5-base64":    "^[A-Za-z0-9+/]{22}==$",
+	"sha1-hex":      "^[0-9a-fA-F]{40}$",
+	"sha1-base64":   "^[A-Za-z0-9+/]{27}=$",
+	"sha256-hex":    "^[0-9a-fA-F]{64}$",
+	"sha256-base64": "^[A-Za-z0-9+/]{43}=$",
+	"sha512-hex":    "^[0-9a-fA-F]{128}$",
+	"sha512-base64": "^[A-Za-z0-9+/]{86}==$",
+}
+
+// wrapperValueTypes maps the google.protobuf wrapper messages to the JSON type of their
+// value, which protojson writes in place of the wrapper (well_known_types=protojson).
+var wrapperValueTypes = map[protoreflect.FullName]string{
+	"google.protobuf.DoubleValue": jsNumber,
+	"google.protobuf.FloatValue":  jsNumber,
+	"google.protobuf.Int64Value":  jsInteger,
+	"google.protobuf.UInt64Value": jsInteger,
+	"google.protobuf.Int32Value":  jsInteger,
+	"google.protobuf.UInt32Value": jsInteger,
+	"google.protobuf.BoolValue":   jsBoolean,
+	"google.protobuf.StringValue": jsString,
+	"google.protobuf.BytesValue":  jsString,
+}
+
+// googleTypeConstraint is the canonical value constraint of a google.type field.
+type googleTypeConstraint struct {
+	// minimum and maximum are the inclusive bounds of a numeric field.
+	minimum, maximum *float64
+
+	// pattern restricts the value of a string field.
+	pattern string
+}
+
+// bounds returns the constraint of a numeric field within [minimum, maximum].
+func bounds(minimum, maximum float64) googleTypeConstraint {
+	return googleTypeConstraint{minimum: &minimum, maximum: &maximum}
+}
+
+// googleTypeConstraints maps google.type fields, by full name, to the value ranges and
+// formats documented on them in googleapis, which the protos cannot express. They are
+// applied to the fields of the google.type schemas (see getScalarSchemaConfig); Money's
+// units and nanos are covered by the money preset.
+var googleTypeConstraints = map[protoreflect.FullName]googleTypeConstraint{
+	"google.type.Color.red":                         bounds(0, 1),
+	"google.type.Color.green":                       bounds(0, 1),
+	"google.type.Color.blue":                        bounds(0, 1),
+	"google.type.Date.year":                         bounds(0, 9999),
+	"google.type.Date.month":                        bounds(0, 12),
+	"google.type.Date.day":                          bounds(0, 31),
+	"google.type.DateTime.year":                     bounds(0, 9999),
+	"google.type.DateTime.month":                    bounds(0, 12),
+	"google.type.DateTime.day":                      bounds(0, 31),
+	"google.type.DateTime.hours":                    bounds(0, 24), // 24 is allowed for closing times
+	"google.type.DateTime.minutes":                  bounds(0, 59),
+	"google.type.DateTime.seconds":                  bounds(0, 60), // 60 is allowed for leap seconds
+	"google.type.DateTime.nanos":                    bounds(0, 999999999),
+	"google.type.Decimal.value":                     {pattern: `^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`},
+	"google.type.Fraction.denominator":              {minimum: &[]float64{1}[0]},
+	"google.type.LatLng.latitude":                   bounds(-90, 90),
+	"google.type.LatLng.longitude":                  bounds(-180, 180),
+	"google.type.Money.currency_code":               {pattern: `^[A-Z]{3}$`},
+	"google.type.PhoneNumber.e164_number":           {pattern: `^\+[1-9][0-9]{1,14}$`},
+	"google.type.PhoneNumber.ShortCode.region_code": {pattern: `^[A-Z]{2}$`},
+	"google.type.PostalAddress.region_code":         {pattern: `^[A-Z]{2}$`},
+	"google.type.TimeOfDay.hours":                   bounds(0, 24),
+	"google.type.TimeOfDay.minutes":                 bounds(0, 59),
+	"google.type.TimeOfDay.seconds":                 bounds(0, 60),
+	"google.type.TimeOfDay.nanos":                   bounds(0, 999999999),
+}
+
+// anyTypeURLPrefix is the prefix of the type URLs protojson writes in the "@type" property
+// of google.protobuf.Any values.
+const anyTypeURLPrefix = "type.googleapis.com/"
+
+// Patterns of the protojson string forms of well-known types (well_known_types=protojson).
+const (
+	// durationPattern matches seconds with up to nine fractional digits and an "s" suffix.
+	durationPattern = `^-?[0-9]+(\.[0-9]{1,9})?s$`
+
+	// fieldMaskPattern matches comma-separated paths of lowerCamelCase field names; the
+	// empty mask is the empty string.
+	fieldMaskPattern = `^([a-z][a-zA-Z0-9]*(\.[a-z][a-zA-Z0-9]*)*(,[a-z][a-zA-Z0-9]*(\.[a-z][a-zA-Z0-9]*)*)*)?$`
+
+	// timestampUTCPattern matches RFC 3339 date-times in UTC, with up to nine fractional
+	// digits and a "Z" suffix (timestamp_utc parameter).
+	timestampUTCPattern = `^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]{1,9})?Z$`
+)
+
+// jsonschemaPackage is the import path of the JSON Schema library used by generated code.
+// Generated code only sets Schema fields that exist with the same types in every
+// supported minor version of the library (v0.3 and v0.4), so it needs no adapter layer;
+// fields added in v0.4 (ItemsArray, PropertyOrder, DependencySchemas, ...) are not used.
+const jsonschemaPackage = protogen.GoImportPath("github.com/google/jsonschema-go/jsonschema")
+
+// schemautilPackage is the import path of this module's runtime helpers used by generated code.
+const schemautilPackage = protogen.GoImportPath("github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil")
+
+// santhoshPackage is the import path of the validator targeted by schema_lib=santhosh.
+const santhoshPackage = protogen.GoImportPath("github.com/santhosh-tekuri/jsonschema/v6")
+
+// invopopPackage is the import path of the schema structs targeted by schema_lib=invopop.
+const invopopPackage = protogen.GoImportPath("github.com/invopop/jsonschema")
+
+// openapi3Package is the import path of the kin-openapi schemas targeted by
+// schema_lib=openapi3.
+const openapi3Package = protogen.GoImportPath("github.com/getkin/kin-openapi/openapi3")
+
+// syncPackage is the standard library package of the sync.OnceValue functions behind the
+// accessors emitted by cached_accessors.
+const syncPackage = protogen.GoImportPath("sync")
+
+// maxFieldsPerFunction is the number of fields above which a message's properties are
+// populated in chunked helper functions of at most this many fields each, keeping
+// generated functions within the complexity limits of common linters.
+const maxFieldsPerFunction = 50
+
+// generatedLocalNames are the identifiers declared inside generated _JsonSchema_WithDefs
+// functions. An imported package with one of these names would be shadowed where the
+// helper references it.
+var generatedLocalNames = map[string]bool{
+	"defs":   true,
+	"schema": true,
+}
+
+// Concurrency guarantees documented on generated functions. Apart from the sync.OnceValue
+// variables of cached_accessors, generated code keeps no package-level state: every
+// accessor builds its schema from scratch on each call.
+const (
+	concurrencyDoc         = "// It is safe for concurrent use; each call returns a new schema that the caller may modify."
+	withDefsConcurrencyDoc = "// It writes to defs, so concurrent calls must not share a defs map."
+	cachedConcurrencyDoc   = "// It is safe for concurrent use; every call returns the same value, which callers must not modify."
+)
+
+// isGoogleType checks if a message is from a Google package (google.*).
+// This includes well-known types (google.protobuf.*), common types (google.type.*),
+// API types (google.api.*), IAM types (google.iam.*), and any other google.* packages.
+// These are treated specially because we cannot add methods to imported types,
+// so we generate standalone functions with file prefixes instead. Messages of the
+// packages listed by owned_google_packages are ordinary messages.
+func (gr *Generator) isGoogleType(msg *protogen.Message) bool {
+	return strings.HasPrefix(string(msg.Desc.FullName()), "google.") && !gr.isOwnedGooglePackage(msg.Desc.ParentFile().Package())
+}
+
+// isGoogleEnum checks if an enum is from a Google package (google.*).
+// Google enums are always declared outside the generated files, so they are emitted inline,
+// except for those of the packages listed by owned_google_packages.
+func (gr *Generator) isGoogleEnum(enum *protogen.Enum) bool {
+	return strings.HasPrefix(string(enum.Desc.FullName()), "google.") && !gr.isOwnedGooglePackage(enum.Desc.ParentFile().Package())
+}
+
+// isStandaloneType reports whether the schema functions of a message are standalone
+// functions registered per package (see getGoogleHelpers) rather than methods and helpers
+// of its declaring package: Google types and the messages inlined by external_schemas.
+func (gr *Generator) isStandaloneType(msg *protogen.Message) bool {
+	return gr.isGoogleType(msg) || gr.externalMessages[msg.Desc.FullName()]
+}
+
+// isOwnedGooglePackage reports whether pkg is, or is a sub-package of, one of the
+// packages listed by owned_google_packages.
+func (gr *Generator) isOwnedGooglePackage(pkg protoreflect.FullName) bool {
+	for _, owned := range gr.ownedGooglePackages {
+		if pkg == owned || strings.HasPrefix(string(pkg), string(owned)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldBehaviorNumber is the extension number of google.api.field_behavior, which is read
+// from the raw field options since the plugin does not link the googleapis Go packages.
+const fieldBehaviorNumber protowire.Number = 1052
+
+// Values of google.api.FieldBehavior mapped to JSON Schema annotations.
+const (
+	fieldBehaviorOutputOnly protoreflect.EnumNumber = 3
+	fieldBehaviorInputOnly  protoreflect.EnumNumber = 4
+	fieldBehaviorIdentifier protoreflect.EnumNumber = 8
+)
+
+// fieldBehaviors returns the google.api.field_behavior values of a field, whether the
+// options were parsed with the extension registered or kept it as unknown fields.
+func fieldBehaviors(field *protogen.Field) []protoreflect.EnumNumber {
+	m := field.Desc.Options().ProtoReflect()
+	if !m.IsValid() {
+		return nil
+	}
+	var values []protoreflect.EnumNumber
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsExtension() && fd.Number() == fieldBehaviorNumber && fd.IsList() && fd.Kind() == protoreflect.EnumKind {
+			for i := range v.List().Len() {
+				values = append(values, v.List().Get(i).Enum())
+			}
+		}
+		return true
+	})
+	b := m.GetUnknown()
+	for len(b) > 0 {
+		number, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+		switch {
+		case number == fieldBehaviorNumber && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			values = append(values, protoreflect.EnumNumber(v))
+		case number == fieldBehaviorNumber && typ == protowire.BytesType:
+			var packed []byte
+			packed, n = protowire.ConsumeBytes(b)
+			for len(packed) > 0 {
+				v, k := protowire.ConsumeVarint(packed)
+				if k < 0 {
+					break
+				}
+				values = append(values, protoreflect.EnumNumber(v))
+				packed = packed[k:]
+			}
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, b)
+		}
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+	}
+	return values
+}
+
+// Extension numbers of the google.api resource annotations, which are read from the raw
+// options like google.api.field_behavior.
+const (
+	// resourceReferenceNumber is google.api.resource_reference on fields.
+	resourceReferenceNumber protowire.Number = 1055
+
+	// resourceNumber is google.api.resource on messages and
+	// google.api.resource_definition on files.
+	resourceNumber protowire.Number = 1053
+)
+
+// isResourceField reports whether a field holds messages annotated with
+// google.api.resource, as a singular, repeated or map value field.
+func isResourceField(field *protogen.Field) bool {
+	message := field.Message
+	if field.Desc.IsMap() {
+		message = field.Message.Fields[1].Message
+	}
+	return message != nil && len(optionPayloads(message.Desc.Options(), resourceNumber)) > 0
+}
+
+// resourceType returns the type of a message's google.api.resource annotation and the
+// title resource_titles gives its schema: the resource's singular name, capitalized, or
+// else the last segment of its type. Both are "" if the message is not a resource.
+func resourceType(message *protogen.Message) (resourceType, title string) {
+	resources := optionPayloads(message.Desc.Options(), resourceNumber)
+	if len(resources) == 0 {
+		return "", ""
+	}
+	resource := resources[len(resources)-1]
+	resourceType = lastEncodedString(resource, 1)
+	title = lastEncodedString(resource, 6)
+	if title == "" {
+		title = resourceType[strings.LastIndex(resourceType, "/")+1:]
+	}
+	if title != "" {
+		title = strings.ToUpper(title[:1]) + title[1:]
+	}
+	return resourceType, title
+}
+
+// optionPayloads returns the encoded values of a message-typed extension of options,
+// whether the options were parsed with the extension registered or kept it as unknown
+// fields.
+func optionPayloads(opts proto.Message, number protowire.Number) [][]byte {
+	if opts == nil || !opts.ProtoReflect().IsValid() {
+		return nil
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	return encodedBytes(b, number)
+}
+
+// encodedBytes returns the length-delimited values of a field number in an encoded
+// message, in order.
+func encodedBytes(b []byte, number protowire.Number) [][]byte {
+	var values [][]byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+		if num == number && typ == protowire.BytesType {
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				values = append(values, v)
+			}
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+	}
+	return values
+}
+
+// resourcePathVariable matches the variables of a resource name pattern, e.g. "{book}".
+var resourcePathVariable = regexp.MustCompile(`\{[^}]*\}`)
+
+// resourceNamePattern returns the regular expression matching the resource names of
+// the given patterns: variables match one path segment, or any number of segments for
+// "{name=**}" variables, and the rest is literal.
+// Example: ["users/{user}"] -> "^users/[^/]+$"
+func resourceNamePattern(patterns []string) string {
+	exprs := make([]string, len(patterns))
+	for i, p := range patterns {
+		var b strings.Builder
+		last := 0
+		for _, loc := range resourcePathVariable.FindAllStringIndex(p, -1) {
+			b.WriteString(regexp.QuoteMeta(p[last:loc[0]]))
+			if strings.HasSuffix(p[loc[0]:loc[1]], "=**}") {
+				b.WriteString(".+")
+			} else {
+				b.WriteString("[^/]+")
+			}
+			last = loc[1]
+		}
+		b.WriteString(regexp.QuoteMeta(p[last:]))
+		exprs[i] = b.String()
+	}
+	if len(exprs) == 1 {
+		return "^" + exprs[0] + "$"
+	}
+	return "^(" + strings.Join(exprs, "|") + ")$"
+}
+
+// isDeprecated reports whether a field, message, enum or enum value is marked
+// deprecated = true in proto.
+func isDeprecated(desc protoreflect.Descriptor) bool {
+	opts, ok := desc.Options().(interface{ GetDeprecated() bool })
+	return ok && opts.GetDeprecated()
+}
+
+// fieldDefault returns the JSON text of a field's default value: the value of the
+// default parameter, or else the field's explicit default ([default = ...] on proto2 and
+// editions fields), encoded like the field's values in the schema: enum names with the
+// enum_names parameter and bytes in the field's content encoding. It reports false when
+// the field has neither or the default is an infinite or NaN float, which has no JSON
+// number.
+func (gr *Generator) fieldDefault(field *protogen.Field) (string, bool) {
+	if value, ok := gr.defaults[field.Desc.FullName()]; ok {
+		return value, true
+	}
+	desc := field.Desc
+	if !desc.HasDefault() || desc.IsList() || desc.IsMap() {
+		return "", false
+	}
+	var value any
+	switch desc.Kind() {
+	case protoreflect.EnumKind:
+		enumValue := desc.DefaultEnumValue()
+		value = int32(enumValue.Number())
+		if gr.opts.EnumNames {
+			value = gr.enumValueNames(desc.Enum())[enumValue.Index()]
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := desc.Default().Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", false
+		}
+		bits := 64
+		if desc.Kind() == protoreflect.FloatKind {
+			bits = 32
+		}
+		value = json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+	case protoreflect.BytesKind:
+		b := desc.Default().Bytes()
+		switch getFieldJsonSchemaOptions(field).GetContentEncoding() {
+		case "base64url":
+			value = base64.URLEncoding.EncodeToString(b)
+		case "hex", "base16":
+			value = hex.EncodeToString(b)
+		default:
+			value = base64.StdEncoding.EncodeToString(b)
+		}
+	default:
+		value = desc.Default().Interface()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// fieldEnum returns the enum referenced by a field, or nil if the field is not enum-typed.
+// For map fields, the enum of the map value (field 2 of the synthetic map entry) is returned.
+func fieldEnum(field *protogen.Field) *protogen.Enum {
+	if field.Desc.IsMap() {
+		for _, f := range field.Message.Fields {
+			if f.Desc.Number() == 2 { // Field number 2 is always the value in map entries
+				return f.Enum
+			}
+		}
+		return nil
+	}
+	return field.Enum
+}
+
+// isMessageKind reports whether kind is a message kind. Besides MessageKind this includes
+// GroupKind, which descriptors report for proto2 groups and for message fields with
+// features.message_encoding = DELIMITED in editions files; both have the JSON form of
+// the message.
+func isMessageKind(kind protoreflect.Kind) bool {
+	return kind == protoreflect.MessageKind || kind == protoreflect.GroupKind
+}
+
+// fieldMessage returns the message referenced by a field, or nil if the field is not
+// message-typed. For map fields, the message of the map value is returned.
+func fieldMessage(field *protogen.Field) *protogen.Message {
+	if field.Desc.IsMap() {
+		for _, f := range field.Message.Fields {
+			if f.Desc.Number() == 2 { // Field number 2 is always the value in map entries
+				return f.Message
+			}
+		}
+		return nil
+	}
+	return field.Message
+}
+
+// googleTypeFunctionName converts a Google type's full name to a valid Go function name with a file prefix.
+// The prefix is that of the file owning the helper in its Go package (see getGoogleHelpers), which
+// keeps names distinct from helpers emitted by files generated in other runs.
+// Example: "google.protobuf.Timestamp" with prefix "admin" -> "admin_google_protobuf_Timestamp"
+func googleTypeFunctionName(msg *protogen.Message, filePrefix string) string {
+	fullName := string(msg.Desc.FullName())
+	baseName := strings.ReplaceAll(fullName, ".", "_")
+	if filePrefix != "" {
+		return filePrefix + "_" + baseName
+	}
+	return baseName
+}
+
+// fileNamePrefix extracts a prefix from the proto file path for use in Google type function names.
+// Example: "users/v1/admin.proto" -> "admin"
+func fileNamePrefix(file *protogen.File) string {
+	// Get the base name without extension
+	path := file.Desc.Path()
+	base := strings.TrimSuffix(path, ".proto")
+	// Get just the file name part (after the last /)
+	if idx := strings.LastIndex(base, "/"); idx >= 0 {
+		base = base[idx+1:]
+	}
+	return base
+}
+
+// -----------------------------------------------------------------------------
+// Core Types
+// -----------------------------------------------------------------------------
+
+// Generator is the main entry point for the protoc-gen-go-jsonschema plugin.
+// It coordinates the overall code generation process by:
+//   - Processing plugin options to determine which messages to generate
+//   - Creating output files with proper headers and imports
+//   - Delegating message-level generation to MessageSchemaGenerator
+//
+// Generator holds only run-wide, read-only state; per-message state is held in
+// MessageSchemaGenerator.
+type Generator struct {
+	// Version is the plugin version used to generate this file
+	Version string
+
+	// opts holds the plugin parameters for this run.
+	opts Options
+
+	// index holds the declarations of the files in this run by full name, for the
+	// resolvers of parameters that name them. Built once per plugin run by
+	// newRequestIndex.
+	index *requestIndex
+
+	// requiredMessages is the set of (non-Google) message full names that some file
+	// generated in this run references, directly or as a forced dependency. Each
+	// declaring file in the run emits these messages even if its own options don't
+	// target them, so cross-file $refs always resolve. Computed once per plugin run
+	// by getRequiredMessages.
+	requiredMessages map[string]bool
+
+	// sharedEnums is the set of enum full names that are emitted once as shared
+	// definitions (<Enum>_JsonSchema_WithDefs) in the file that declares them.
+	// Fields referencing these enums use a $ref instead of repeating the values.
+	// Computed once per plugin run by getSharedEnums.
+	sharedEnums map[string]bool
+
+	// googleHelpers is the package-level symbol registry for Google type helpers.
+	// Within each Go package, every referenced Google type is emitted once, by a
+	// single owning file, under a single function name that all files in the
+	// package reference. Computed once per plugin run by getGoogleHelpers.
+	googleHelpers googleHelperRegistry
+
+	// anyTypes maps google.protobuf.Any fields, by full name, to the messages their values
+	// may hold (the any_types parameter). Computed once per plugin run by getAnyTypes.
+	anyTypes map[protoreflect.FullName][]*protogen.Message
+
+	// targets lists the schema profiles selected by the target parameter, as returned by
+	// Options.targets.
+	targets []string
+
+	// schemaBaseURI is the base of the $id of definitions (the schema_base_uri parameter,
+	// as returned by Options.schemaBaseURI), or "" for "#/$defs/" references.
+	schemaBaseURI string
+
+	// ownedGooglePackages are the google.* packages whose messages are generated like
+	// ordinary messages (the owned_google_packages parameter, as returned by
+	// Options.ownedGooglePackages).
+	ownedGooglePackages []protoreflect.FullName
+
+	// externalMessages is the set of full names of messages declared outside the run
+	// whose schemas are emitted as standalone functions of the referencing packages (the
+	// external_schemas parameter). Computed once per plugin run by getExternalMessages.
+	externalMessages map[protoreflect.FullName]bool
+
+	// externalMarkers maps generated files, by path, to the version markers of the files
+	// outside the run whose schemas they reference (with external_schemas=auto). Computed
+	// once per plugin run by getExternalMarkers.
+	externalMarkers map[string][]externalMarker
+
+	// closedObjects is the set of message full names whose schemas reject undeclared
+	// properties (the closed_objects parameter). Computed once per plugin run by
+	// getClosedObjects.
+	closedObjects map[protoreflect.FullName]bool
+
+	// additionalProperties maps messages, by full name, to the additionalProperties value
+	// of their schemas (the additional_properties parameter). Computed once per plugin run
+	// by getAdditionalProperties.
+	additionalProperties map[protoreflect.FullName]string
+
+	// presets maps messages, by full name, to the preset applied to their schemas (the
+	// preset parameter). Computed once per plugin run by getPresets.
+	presets map[protoreflect.FullName]string
+
+	// celConstraints maps messages, by full name, to the CEL expressions emitted as the
+	// x-constraints of their schemas (the cel_constraint parameter). Computed once per
+	// plugin run by getCELConstraints.
+	celConstraints map[protoreflect.FullName][]string
+
+	// timestampBounds maps Timestamp fields, by full name, to the x-formatMinimum and
+	// x-formatMaximum keywords of their strings (the timestamp_bounds parameter).
+	// Computed once per plugin run by getTimestampBounds.
+	timestampBounds map[protoreflect.FullName]map[string]any
+
+	// unevaluatedProperties is the set of message full names whose schemas set
+	// unevaluatedProperties to false (the unevaluated_properties parameter). Computed once
+	// per plugin run by getUnevaluatedProperties.
+	unevaluatedProperties map[protoreflect.FullName]bool
+
+	// recursionDepths maps messages, by full name, to the depth inlining converters expand
+	// them to within themselves (the max_recursion_depth parameter). Computed once per
+	// plugin run by getRecursionDepths.
+	recursionDepths map[protoreflect.FullName]int
+
+	// discriminatedOneofs maps oneofs, by full name, to the discriminator property of the
+	// discriminated union representing them (the discriminated_oneof parameter). Computed
+	// once per plugin run by getDiscriminatedOneofs.
+	discriminatedOneofs map[protoreflect.FullName]string
+
+	// enumAliases maps enum fields, by full name, to the aliases accepted for their values
+	// (the enum_alias parameter), in enum value order. Computed once per plugin run by
+	// getEnumAliases.
+	enumAliases map[protoreflect.FullName][]enumAlias
+
+	// identifiers is the set of fields listed by the identifier parameter, by full name.
+	// Computed once per plugin run by getIdentifiers; see isIdentifier.
+	identifiers map[protoreflect.FullName]bool
+
+	// resourcePatterns maps string fields annotated with google.api.resource_reference, and
+	// identifier fields of messages annotated with google.api.resource, by full name, to
+	// the pattern of the resource's names. Computed once per plugin run by
+	// getResourcePatterns.
+	resourcePatterns map[protoreflect.FullName]string
+
+	// validation maps fields annotated with buf.validate.field, by full name, to the
+	// keywords translated from their protovalidate rules. Computed once per plugin run by
+	// getValidateRules.
+	validation map[protoreflect.FullName]*validateRules
+
+	// coercions maps scalar fields, by full name, to the JSON types a gateway may coerce to
+	// their type (the coerce parameter). Computed once per plugin run by getCoercions.
+	coercions map[protoreflect.FullName][]string
+
+	// methodDescriptions maps methods, by full name, to the descriptions replacing their
+	// comments in service schemas (the method_description parameter). Computed once per
+	// plugin run by getMethodDescriptions.
+	methodDescriptions map[protoreflect.FullName]string
+
+	// oneofTitles and oneofDescriptions map oneofs, by full name, to the title and
+	// description of their oneOf constraint or discriminated union property (the
+	// oneof_title and oneof_description parameters). Computed once per plugin run by
+	// getOneofTexts.
+	oneofTitles       map[protoreflect.FullName]string
+	oneofDescriptions map[protoreflect.FullName]string
+
+	// durationSeconds is the set of Duration fields, by full name, whose values are
+	// numbers of seconds (the duration_seconds parameter with field names). Computed once
+	// per plugin run by getDurationSeconds.
+	durationSeconds map[protoreflect.FullName]bool
+
+	// multipleOfs maps numeric fields, by full name, to the step their values must be a
+	// multiple of (the multiple_of parameter). Computed once per plugin run by
+	// getMultipleOfs.
+	multipleOfs map[protoreflect.FullName]float64
+
+	// extensions maps fields, by full name, to the extension keywords emitted on their
+	// schemas (the extension parameter). Computed once per plugin run by getExtensions.
+	extensions map[protoreflect.FullName]map[string]any
+
+	// elementExtensions maps repeated and map fields, by full name, to the extension
+	// keywords emitted on the schemas of their elements or values (the extension
+	// parameter with an [items] or [values] target). Computed once per plugin run by
+	// getExtensions.
+	elementExtensions map[protoreflect.FullName]map[string]any
+
+	// defaults maps fields, by full name, to the JSON text of the default values set by
+	// the default parameter. Computed once per plugin run by getDefaults.
+	defaults map[protoreflect.FullName]string
+
+	// allowedValues maps enum fields, by full name, to the numbers of the values the
+	// allowed_values parameter restricts them to. Computed once per plugin run by
+	// getAllowedValues.
+	allowedValues map[protoreflect.FullName][]int32
+
+	// consts maps fields, by full name, to the Go literals of the values set by the const
+	// parameter. Computed once per plugin run by getConsts.
+	consts map[protoreflect.FullName]string
+
+	// rawSchemas maps fields, by full name, to the JSON merge patches applied in order to
+	// their schemas (the raw_schema parameter), those with a target wrapped in the keyword
+	// of their subschema. Computed once per plugin run by getRawSchemas.
+	rawSchemas map[protoreflect.FullName][]string
+
+	// vocabularies maps the URIs of the vocabularies declared by the vocabulary parameter
+	// to their keywords. Computed once per plugin run by Options.vocabularies.
+	vocabularies map[string][]string
+}
+
+// googleHelperRegistry records, per Go package, the function name of each Google type
+// helper and the file that emits it.
+type googleHelperRegistry struct {
+	// names maps Go package -> Google type full name -> function base name.
+	names map[protogen.GoImportPath]map[protoreflect.FullName]string
+	// owned maps proto file path -> Google types that file emits, in first-reference order.
+	owned map[string][]*protogen.Message
+}
+
+// -----------------------------------------------------------------------------
+// Generator Methods
+// -----------------------------------------------------------------------------
+
+// generateFile creates a Go source file containing JSON Schema generation code
+// for all targeted messages in the given proto file.
+//
+// The generation process:
+//  1. Checks file-level options to determine the default generation behavior
+//  2. Collects all messages that should generate schemas (respecting options)
+//  3. Creates the output file with standard headers and imports
+//  4. Generates schema code for each target message
+//
+// Returns nil if no messages in the file require schema generation.
+func (gr *Generator) generateFile(gen *protogen.Plugin, file *protogen.File) (*protogen.GeneratedFile, error) {
+	// Collect the non-Google messages whose schema functions this file defines.
+	localMessages := gr.getLocalMessages(file)
+
+	// Google types referenced anywhere in this Go package are generated as standalone
+	// functions by a single owning file, so each helper is defined exactly once per package.
+	googleTypeMessages := gr.googleHelpers.owned[file.Desc.Path()]
+
+	// Shared enum definitions are emitted by the file that declares the enum,
+	// regardless of which file in the run references it.
+	localEnums := gr.getSharedEnumsInFile(file)
+
+	// Skip file generation entirely if no local messages, shared enums or Google types need schemas.
+	// This avoids creating empty or import-only files.
+	if len(localMessages) == 0 && len(localEnums) == 0 && len(googleTypeMessages) == 0 {
+		return nil, nil
+	}
+
+	// --- Create Output File ---
+	// Generate filename following the pattern: <original>_jsonschema.pb.go
+	filename := file.GeneratedFilenamePrefix + "_jsonschema.pb.go"
+
+	// With drift_dir, summarize the schema changes against the previously generated file.
+	var drift []string
+	if gr.opts.DriftDir != "" {
+		var err error
+		if drift, err = gr.getFileDrift(gen, file, filename, localMessages, localEnums, googleTypeMessages); err != nil {
+			return nil, err
+		}
+	}
+
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	gr.emitFileHeader(g, file, drift...)
+	if err := gr.emitFileSchemas(g, file, localMessages, localEnums, googleTypeMessages); err != nil {
+		return nil, err
+	}
+
+	// With verify, check the generated schemas against the descriptors.
+	if gr.opts.Verify {
+		if err := gr.verifyFile(g, file, filename, append(slices.Clone(localMessages), googleTypeMessages...)); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// emitFileSchemas writes the schema functions of the given messages, shared enums and
+// Google types to g.
+func (gr *Generator) emitFileSchemas(g *protogen.GeneratedFile, file *protogen.File, localMessages []*protogen.Message, localEnums []*protogen.Enum, googleTypeMessages []*protogen.Message) error {
+	// --- Generate Message Schemas (LOCAL MESSAGES AND REFERENCED GOOGLE TYPES) ---
+	// Process each local message, creating a fresh MessageSchemaGenerator
+	// for each to ensure clean visited state tracking.
+	// Cross-package messages are referenced (not generated) via QualifiedGoIdent.
+	// Google types are generated as standalone functions by the file that owns them in the package.
+	gr.emitFileMarkers(g, file)
+
+	var inferredFormats []string
+	for _, msg := range localMessages {
+		sg := &MessageSchemaGenerator{
+			gr:      gr,
+			gen:     g,
+			visited: make(map[string]bool),
+			file:    file,
+		}
+		if err := sg.generateMessage(msg); err != nil {
+			return err
+		}
+		inferredFormats = append(inferredFormats, sg.inferredFormats...)
+		g.P()
+		for _, field := range msg.Fields {
+			if aliases := gr.enumAliases[field.Desc.FullName()]; len(aliases) > 0 && !getFieldJsonSchemaOptions(field).GetIgnore() {
+				sg.generateEnumAliasNormalizer(field, aliases)
+				g.P()
+			}
+		}
+	}
+
+	// Generate shared enum definitions declared in this file.
+	for _, enum := range localEnums {
+		sg := &MessageSchemaGenerator{
+			gr:      gr,
+			gen:     g,
+			visited: make(map[string]bool),
+			file:    file,
+		}
+		sg.generateEnumJSONSchema(enum)
+		g.P()
+		sg.generateEnumValuesHelper(enum)
+		g.P()
+		if gr.opts.StripEnumPrefix || gr.rewritesEnumCase() {
+			sg.generateEnumValueMapping(enum)
+			g.P()
+		}
+	}
+
+	// Generate Google type schemas as standalone functions
+	for _, msg := range googleTypeMessages {
+		sg := &MessageSchemaGenerator{
+			gr:      gr,
+			gen:     g,
+			visited: make(map[string]bool),
+			file:    file,
+		}
+		if err := sg.generateMessage(msg); err != nil {
+			return err
+		}
+		g.P()
+	}
+
+	// With infer_formats, report the inferred formats for review.
+	if len(inferredFormats) > 0 {
+		g.P("// Formats inferred from field names (infer_formats). Set the format option or a")
+		g.P("// protovalidate string rule on a field to confirm or override its format:")
+		for _, line := range inferredFormats {
+			g.P(fmt.Sprintf("//   - %s", line))
+		}
+	}
+
+	return nil
+}
+
+// getLocalMessages returns the non-Google messages whose schema functions are defined
+// by the given file's generated code: the file's target messages declared in the file
+// itself, followed by messages declared here that other files in the run require.
+func (gr *Generator) getLocalMessages(file *protogen.File) []*protogen.Message {
+	// Collect messages that should generate schemas, including their dependencies.
+	// This includes cross-package messages to ensure the defs map is complete.
+	targetMessages := gr.getFileMessages(file)
+
+	// --- CRITICAL: Filter to only messages DEFINED in THIS proto file ---
+	//
+	// Why: When multiple proto files share the same Go package and import each other
+	// (or import the same shared protos), we would generate duplicate function
+	// definitions if we filter by Go package instead of proto file.
+	//
+	// Solution: Only generate schema functions for messages defined in THIS proto file.
+	// Messages from other proto files (even in the same Go package) are just referenced
+	// by their _WithDefs function name - they will be generated in their own file.
+	// Cross-package messages are automatically referenced via QualifiedGoIdent.
+	// Google types are generated by the file that owns them in the package (see getGoogleHelpers).
+	var localMessages []*protogen.Message
+	for _, msg := range targetMessages {
+		// Include only messages DEFINED in this proto file (not just same Go package)
+		// Note: Use Path() not FullName() - FullName() returns the package name for files
+		if msg.Desc.ParentFile().Path() == file.Desc.Path() {
+			localMessages = append(localMessages, msg)
+		}
+	}
+
+	// Messages declared here that other files in the run require (e.g. forced
+	// dependencies this file's options don't target) are emitted here as well,
+	// so the other files' references resolve.
+	for _, msg := range gr.getRequiredMessagesInFile(file) {
+		if !containsMessage(localMessages, msg) {
+			localMessages = append(localMessages, msg)
+		}
+	}
+	return localMessages
+}
+
+// emitFileHeader writes the header, package clause and import registration shared by
+// all files generated for the given proto file. Drift lines, if any, are listed in the
+// header as the schema changes since the previous generation.
+func (gr *Generator) emitFileHeader(g *protogen.GeneratedFile, file *protogen.File, drift ...string) {
+	gr.emitFilePreamble(g, file, drift...)
+
+	// Imports are managed by protogen as QualifiedGoIdent is used during code generation.
+	// The jsonschema package is registered first so that it keeps its name and any
+	// referenced package with a clashing name gets a distinct alias (e.g. jsonschema1).
+	g.QualifiedGoIdent(jsonschemaPackage.Ident("Schema"))
+	g.P()
+}
+
+// fileMarkerSuffix returns the suffix of the marker constants of a proto file: its path
+// without the .proto extension, with characters other than letters and digits replaced
+// by underscores (users/v1/user.proto gives users_v1_user).
+func fileMarkerSuffix(path string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.TrimSuffix(path, ".proto"))
+}
+
+// fileVersionMarker returns the name of the constant holding the plugin version in the
+// schemas generated for a proto file, which cross-package references check for.
+func fileVersionMarker(path string) string {
+	return "JsonSchemaGenVersion_" + fileMarkerSuffix(path)
+}
+
+// emitFileMarkers writes the marker constants of the schemas generated for file: the
+// plugin version and the SHA-256 hash of the file descriptor they were generated from,
+// so that tools and other packages can check that compatible generated code is present.
+// With external_schemas=auto it also writes a blank constant referring to the version
+// marker of each file outside the run whose schemas this file references, so that a
+// missing package fails to compile on the marker rather than on a schema helper.
+func (gr *Generator) emitFileMarkers(g *protogen.GeneratedFile, file *protogen.File) {
+	suffix := fileMarkerSuffix(file.Desc.Path())
+	encoded, _ := proto.MarshalOptions{Deterministic: true}.Marshal(file.Proto)
+	sum := sha256.Sum256(encoded)
+
+	g.P(fmt.Sprintf("// %s is the version of protoc-gen-go-jsonschema that generated the", fileVersionMarker(file.Desc.Path())))
+	g.P(fmt.Sprintf("// schemas of %s.", file.Desc.Path()))
+	g.P(fmt.Sprintf("const %s = \"%s\"", fileVersionMarker(file.Desc.Path()), gr.Version))
+	g.P()
+	g.P(fmt.Sprintf("// JsonSchemaDescriptorHash_%s is the SHA-256 hash of the %s", suffix, file.Desc.Path()))
+	g.P("// descriptor the schemas were generated from.")
+	g.P(fmt.Sprintf("const JsonSchemaDescriptorHash_%s = \"%s\"", suffix, hex.EncodeToString(sum[:])))
+	g.P()
+	for _, marker := range gr.externalMarkers[file.Desc.Path()] {
+		g.P(fmt.Sprintf("// The schemas of %s are referenced, so they must have been generated.", marker.path))
+		g.P(fmt.Sprintf("const _ = %s", g.QualifiedGoIdent(marker.ident)))
+		g.P()
+	}
+}
+
+// emitFilePreamble writes the header and package clause of emitFileHeader, for files that
+// do not refer to the jsonschema package.
+func (gr *Generator) emitFilePreamble(g *protogen.GeneratedFile, file *protogen.File, drift ...string) {
+	// Write file header with generation metadata.
+	// This helps identify generated files and track their source.
+	{
+		g.P("// Code generated by https://github.com/alis-exchange/protoc-gen-go-jsonschema. DO NOT EDIT.")
+		g.P("// ")
+		g.P(fmt.Sprintf("// Source: %s", file.Desc.Path()))
+		g.P(fmt.Sprintf("// Plugin version: %s", gr.Version))
+		g.P("// ")
+		g.P(fmt.Sprintf("// Generated on: %s UTC", time.Now().UTC().Format("2006-01-02 15:04:05")))
+		if len(drift) > 0 {
+			g.P("// ")
+			g.P("// Schema changes since the previous generation:")
+			for _, line := range drift {
+				g.P(fmt.Sprintf("//   - %s", line))
+			}
+		}
+	}
+
+	// Write package declaration matching the proto's go_package option.
+	g.P()
+	g.P(fmt.Sprintf("package %s", file.GoPackageName))
+}
+
+// generateDraft07File creates <file>_jsonschema_draft07.pb.go, which gives each message
+// whose schema functions the file defines a JsonSchemaDraft07() accessor. The accessors
+// convert the draft 2020-12 schema at runtime with schemautil.ToDraft07, so both drafts
+// are always consistent. Google types get no draft-07 accessor.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateDraft07File(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_draft07.pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	toDraft07 := g.QualifiedGoIdent(schemautilPackage.Ident("ToDraft07"))
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// JsonSchemaDraft07 returns the JSON schema for the %s message in JSON Schema draft-07 form.", msg.Desc.Name()))
+		g.P(concurrencyDoc)
+		g.P(fmt.Sprintf("func (x *%s) JsonSchemaDraft07() *jsonschema.Schema {", msg.GoIdent.GoName))
+		g.P(fmt.Sprintf("return %s(x.JsonSchema())", toDraft07))
+		g.P("}")
+		g.P()
+	}
+	return g
+}
+
+// generatePruneFile creates <file>_jsonschema_prune.pb.go, which gives each message whose
+// schema functions the file defines a PruneToSchema() method removing the properties the
+// schema does not declare with schemautil.Prune. Google types get no method.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generatePruneFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_prune.pb.go", file.GoImportPath)
+	gr.emitFilePreamble(g, file)
+	g.P()
+
+	prune := g.QualifiedGoIdent(schemautilPackage.Ident("Prune"))
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// PruneToSchema returns a copy of data, decoded JSON for the %s message, without the", msg.Desc.Name()))
+		g.P("// properties its JSON schema does not declare, at any depth. It does not validate data.")
+		g.P(fmt.Sprintf("func (x *%s) PruneToSchema(data map[string]any) map[string]any {", msg.GoIdent.GoName))
+		g.P(fmt.Sprintf("return %s(x.JsonSchema(), data)", prune))
+		g.P("}")
+		g.P()
+	}
+	return g
+}
+
+// generateCachedFile creates <file>_jsonschema_cached.pb.go, which gives each message
+// whose schema functions the file defines JsonSchemaCached() and JsonSchemaResolved()
+// methods. Package-level sync.OnceValue and sync.OnceValues functions build the schema
+// and resolve it on the first call, so concurrent callers share one result. Google types
+// get no methods.
+//
+// The definitions of the cached schemas are built lazily, one at a time: the file keeps
+// a map of constructor functions, one per definition the schemas use (see
+// cachedDefinitions), each building its definition once, on first access, and sharing it
+// with every cached schema of the file that needs it. A cached schema assembles its $defs
+// from the constructors of the definitions it reaches, so no definition is built before
+// a schema using it is first accessed, or built twice.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateCachedFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_cached.pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	onceValue := g.QualifiedGoIdent(syncPackage.Ident("OnceValue"))
+	onceValues := g.QualifiedGoIdent(syncPackage.Ident("OnceValues"))
+	prefix := fileNamePrefix(file)
+	defsVar := fmt.Sprintf("_%s_jsonSchemaDefs", prefix)
+	buildFunc := fmt.Sprintf("_%s_jsonSchemaDef", prefix)
+	defsOfFunc := fmt.Sprintf("_%s_jsonSchemaDefsOf", prefix)
+
+	sg := &MessageSchemaGenerator{gr: gr, gen: g, visited: make(map[string]bool), file: file}
+	builders, reached := gr.cachedDefinitions(sg, messages)
+
+	g.P(fmt.Sprintf("// %s maps the definitions of this file's cached schemas to functions", defsVar))
+	g.P("// building them once, on first access. A function builds its definition alone, with")
+	g.P("// references to the definitions listed after it, or with its dependencies when it")
+	g.P("// lists none.")
+	g.P(fmt.Sprintf("var %s = map[string]func() map[string]*jsonschema.Schema{", defsVar))
+	for _, name := range slices.Sorted(maps.Keys(builders)) {
+		b := builders[name]
+		args := []string{b.helper}
+		for _, reference := range b.references {
+			args = append(args, strconv.Quote(string(reference)))
+		}
+		g.P(fmt.Sprintf("%q: %s(func() map[string]*jsonschema.Schema { return %s(%s) }),", name, onceValue, buildFunc, strings.Join(args, ", ")))
+	}
+	g.P("}")
+	g.P()
+	g.P(fmt.Sprintf("// %s returns the definitions build adds to a map holding placeholders", buildFunc))
+	g.P("// for references, the definitions it only references.")
+	g.P(fmt.Sprintf("func %s(build func(map[string]*jsonschema.Schema) *jsonschema.Schema, references ...string) map[string]*jsonschema.Schema {", buildFunc))
+	g.P("defs := make(map[string]*jsonschema.Schema, len(references)+1)")
+	g.P("for _, name := range references {")
+	g.P("defs[name] = nil")
+	g.P("}")
+	g.P("_ = build(defs)")
+	g.P("for _, name := range references {")
+	g.P("delete(defs, name)")
+	g.P("}")
+	g.P("return defs")
+	g.P("}")
+	g.P()
+	g.P(fmt.Sprintf("// %s returns a new map holding the named definitions, built on first access.", defsOfFunc))
+	g.P(fmt.Sprintf("func %s(names ...string) map[string]*jsonschema.Schema {", defsOfFunc))
+	g.P("defs := make(map[string]*jsonschema.Schema, len(names))")
+	g.P("for _, name := range names {")
+	g.P(fmt.Sprintf("for key, def := range %s[name]() {", defsVar))
+	g.P("if _, ok := defs[key]; !ok {")
+	g.P("defs[key] = def")
+	g.P("}")
+	g.P("}")
+	g.P("}")
+	g.P("return defs")
+	g.P("}")
+	g.P()
+
+	for _, msg := range messages {
+		name := msg.GoIdent.GoName
+		schemaVar := fmt.Sprintf("_%s_jsonSchemaCached", name)
+		resolvedVar := fmt.Sprintf("_%s_jsonSchemaResolved", name)
+		g.P(fmt.Sprintf("// %s and %s build the schemas returned by the cached %s accessors once.", schemaVar, resolvedVar, msg.Desc.Name()))
+		g.P("var (")
+		g.P(fmt.Sprintf("%s = %s(func() *jsonschema.Schema {", schemaVar, onceValue))
+		g.P(fmt.Sprintf("defs := %s(", defsOfFunc))
+		for _, def := range reached[msg.Desc.FullName()] {
+			g.P(fmt.Sprintf("%q,", def))
+		}
+		g.P(")")
+		gr.emitRoot(g, msg, true)
+		g.P("})")
+		g.P(fmt.Sprintf("%s = %s(func() (*jsonschema.Resolved, error) { return %s().Resolve(nil) })", resolvedVar, onceValues, schemaVar))
+		g.P(")")
+		g.P()
+		g.P(fmt.Sprintf("// JsonSchemaCached returns the JSON schema for the %s message, built on the first call.", msg.Desc.Name()))
+		g.P(cachedConcurrencyDoc)
+		g.P(fmt.Sprintf("func (x *%s) JsonSchemaCached() *jsonschema.Schema {", name))
+		g.P(fmt.Sprintf("return %s()", schemaVar))
+		g.P("}")
+		g.P()
+		g.P(fmt.Sprintf("// JsonSchemaResolved returns the JSON schema for the %s message resolved for validation,", msg.Desc.Name()))
+		g.P("// resolved on the first call; the error of a failed resolution is returned by every call.")
+		g.P(cachedConcurrencyDoc)
+		g.P(fmt.Sprintf("func (x *%s) JsonSchemaResolved() (*jsonschema.Resolved, error) {", name))
+		g.P(fmt.Sprintf("return %s()", resolvedVar))
+		g.P("}")
+		g.P()
+	}
+	return g
+}
+
+// definitionBuilder is how the cached schemas of a file build a definition: with the
+// _JsonSchema_WithDefs helper of its message or enum, leaving out the definitions it
+// references.
+type definitionBuilder struct {
+	// helper is the helper's name qualified in the file, e.g. "Address_JsonSchema_WithDefs".
+	helper string
+	// references lists the definitions the helper references, in order. It is empty for
+	// definitions whose helpers are defined in another Go package, generated from options
+	// this run does not know, so that they are built with their dependencies.
+	references []protoreflect.FullName
+}
+
+// cachedDefinitions returns how the cached schemas of messages, emitted into the file of
+// sg, build their definitions, keyed by full name, and the sorted full names of the
+// definitions each message's schema reaches. The references of a definition are those
+// a dry run of its schema generation records (see discardedCode); the messages whose
+// dry run fails are built with their dependencies, which is also correct, only eager.
+func (gr *Generator) cachedDefinitions(sg *MessageSchemaGenerator, messages []*protogen.Message) (map[protoreflect.FullName]definitionBuilder, map[protoreflect.FullName][]protoreflect.FullName) {
+	builders := make(map[protoreflect.FullName]definitionBuilder)
+	var build func(name protoreflect.FullName, def definition)
+	build = func(name protoreflect.FullName, def definition) {
+		if _, ok := builders[name]; ok {
+			return
+		}
+		if def.enum != nil {
+			builders[name] = definitionBuilder{helper: strings.TrimSuffix(sg.enumReferenceName(def.enum), "(defs)")}
+			return
+		}
+		b := definitionBuilder{helper: strings.TrimSuffix(sg.referenceName(def.message), "(defs)")}
+		builders[name] = b
+		if !gr.isStandaloneType(def.message) && def.message.GoIdent.GoImportPath != sg.file.GoImportPath {
+			return
+		}
+		dryRun := &MessageSchemaGenerator{
+			gr:         gr,
+			gen:        discardedCode{},
+			visited:    make(map[string]bool),
+			file:       sg.file,
+			references: make(map[protoreflect.FullName]definition),
+		}
+		if err := dryRun.generateMessage(def.message); err != nil || dryRun.err != nil {
+			return
+		}
+		delete(dryRun.references, name)
+		b.references = slices.Sorted(maps.Keys(dryRun.references))
+		builders[name] = b
+		for _, reference := range b.references {
+			build(reference, dryRun.references[reference])
+		}
+	}
+
+	reached := make(map[protoreflect.FullName][]protoreflect.FullName, len(messages))
+	for _, msg := range messages {
+		build(msg.Desc.FullName(), definition{message: msg})
+		seen := map[protoreflect.FullName]bool{msg.Desc.FullName(): true}
+		queue := []protoreflect.FullName{msg.Desc.FullName()}
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			for _, reference := range builders[name].references {
+				if !seen[reference] {
+					seen[reference] = true
+					queue = append(queue, reference)
+				}
+			}
+		}
+		reached[msg.Desc.FullName()] = slices.Sorted(maps.Keys(seen))
+	}
+	return builders, reached
+}
+
+// discardedCode is the codeWriter of dry runs of schema generation, which only record
+// references: it drops the generated code and imports nothing.
+type discardedCode struct{}
+
+// P drops a line of code.
+func (discardedCode) P(...any) {}
+
+// QualifiedGoIdent returns the unqualified name of ident, without importing its package.
+func (discardedCode) QualifiedGoIdent(ident protogen.GoIdent) string {
+	return ident.GoName
+}
+
+// generateToolArgsFile creates <file>_jsonschema_toolargs.pb.go, which gives each message
+// whose schema functions the file defines a CoerceToolArgs() method converting mistyped
+// tool call arguments with schemautil.CoerceToolArgs against MCPInputSchema(). Google
+// types get no method.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateToolArgsFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_toolargs.pb.go", file.GoImportPath)
+	gr.emitFilePreamble(g, file)
+	g.P()
+
+	coerce := g.QualifiedGoIdent(schemautilPackage.Ident("CoerceToolArgs"))
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// CoerceToolArgs returns a copy of args, tool call arguments for the %s message, with", msg.Desc.Name()))
+		g.P("// values of the wrong JSON type converted to the types of its MCP input schema where")
+		g.P("// possible, such as numeric strings for integers or single values for arrays. It")
+		g.P("// reports the values it cannot convert; validate the result against MCPInputSchema().")
+		g.P(fmt.Sprintf("func (x *%s) CoerceToolArgs(args map[string]any) (map[string]any, error) {", msg.GoIdent.GoName))
+		g.P(fmt.Sprintf("return %s(x.MCPInputSchema(), args)", coerce))
+		g.P("}")
+		g.P()
+	}
+	return g
+}
+
+// targetProfile describes the accessors generated for a value of the target parameter.
+type targetProfile struct {
+	// method is the name of the accessor, e.g. "JsonSchemaOpenAI".
+	method string
+	// convert is the schemautil function converting the root schema.
+	convert string
+	// root is the generated method returning the schema to convert.
+	root string
+	// fallible reports whether convert returns an error along with the schema.
+	fallible bool
+	// doc completes the accessor comment after "returns the JSON schema for the <message>
+	// message".
+	doc []string
+}
+
+// targetProfiles maps the supported values of the target parameter to their accessors.
+var targetProfiles = map[string]targetProfile{
+	targetMCP: {
+		method: "JsonSchemaMCP", convert: "ToMCP", root: "MCPInputSchema", fallible: true,
+		doc: []string{"as an MCP tool input schema, without $comment or keywords outside JSON Schema."},
+	},
+	targetOpenAI: {
+		method: "JsonSchemaOpenAI", convert: "ToOpenAI", root: "MCPInputSchema", fallible: true,
+		doc: []string{
+			"in the form OpenAI structured outputs accept in strict mode. It fails if the message",
+			"contains maps or free-form values, which strict mode cannot express.",
+		},
+	},
+	targetGemini: {
+		method: "JsonSchemaGemini", convert: "ToGemini", root: "MCPInputSchema", fallible: true,
+		doc: []string{
+			"in the OpenAPI subset accepted by Gemini function declarations, with every reference",
+			"inlined. It fails if the message is recursive, since recursion cannot be inlined.",
+		},
+	},
+	targetClaude: {
+		method: "JsonSchemaClaude", convert: "ToClaude", root: "MCPInputSchema", fallible: true,
+		doc: []string{
+			"as a Claude tool input schema: the MCP form without the root oneOf, anyOf and",
+			"allOf constraints that tool input schemas do not support.",
+		},
+	},
+	targetPlain: {
+		method: "JsonSchemaPlain", convert: "ToPlain", root: "JsonSchema",
+		doc: []string{"with JSON Schema keywords only, without $comment or generator extensions."},
+	},
+}
+
+// generateTargetFile creates <file>_jsonschema_<target>.pb.go, which gives each message
+// whose schema functions the file defines the accessor of the given target profile (see
+// targetProfiles). The accessors convert the generated schema with the matching schemautil
+// function. Google types get no accessor.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateTargetFile(gen *protogen.Plugin, file *protogen.File, target string) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+	profile := targetProfiles[target]
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_"+target+".pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	convert := g.QualifiedGoIdent(schemautilPackage.Ident(profile.convert))
+	results := "*" + g.QualifiedGoIdent(jsonschemaPackage.Ident("Schema"))
+	if profile.fallible {
+		results = "(" + results + ", error)"
+	}
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// %s returns the JSON schema for the %s message", profile.method, msg.Desc.Name()))
+		for _, line := range profile.doc {
+			g.P("// " + line)
+		}
+		g.P(concurrencyDoc)
+		g.P(fmt.Sprintf("func (x *%s) %s() %s {", msg.GoIdent.GoName, profile.method, results))
+		g.P(fmt.Sprintf("return %s(x.%s())", convert, profile.root))
+		g.P("}")
+		g.P()
+	}
+	return g
+}
+
+// generateStreamFile creates <file>_jsonschema_stream.pb.go, which gives each
+// server-streaming method of the file's services a <Service>_<Method>_StreamJsonSchema()
+// function describing its response stream in the framing selected by the stream_framing
+// parameter: with "ndjson" the schema of one line, which is a response message, and with
+// "array" an array of response messages. The schemas are documented by the method and
+// service comments, or the method_description parameter (see composeComments). Methods whose response message has no schema in
+// this run (e.g. Google types, or messages of files not generated) are skipped.
+//
+// Returns nil if the file has no such methods, and an error if a referenced package's
+// import alias is shadowed (see qualifiedGoIdent).
+func (gr *Generator) generateStreamFile(gen *protogen.Plugin, file *protogen.File) (*protogen.GeneratedFile, error) {
+	var methods []*protogen.Method
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if method.Desc.IsStreamingServer() && gr.hasMessageSchema(gen, method.Output) {
+				methods = append(methods, method)
+			}
+		}
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_stream.pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	sg := &MessageSchemaGenerator{gr: gr, gen: g, visited: make(map[string]bool), file: file}
+	for _, method := range methods {
+		g.P(fmt.Sprintf("// %s_%s_StreamJsonSchema returns the JSON schema for the response stream of", method.Parent.GoName, method.GoName))
+		g.P(fmt.Sprintf("// the server-streaming %s method, framed as", method.Desc.FullName()))
+		if gr.opts.StreamFraming == streamFramingArray {
+			g.P(fmt.Sprintf("// a JSON array of %s messages.", method.Output.Desc.Name()))
+		} else {
+			g.P(fmt.Sprintf("// newline-delimited JSON: each line is one %s message.", method.Output.Desc.Name()))
+		}
+		g.P(concurrencyDoc)
+		g.P(fmt.Sprintf("func %s_%s_StreamJsonSchema() *jsonschema.Schema {", method.Parent.GoName, method.GoName))
+		g.P("defs := make(map[string]*jsonschema.Schema)")
+		g.P("return &jsonschema.Schema{")
+		title, description := gr.composeComments(gr.methodDescriptions[method.Desc.FullName()], method.Desc, method.Parent.Desc)
+		if title != "" {
+			g.P(fmt.Sprintf(`Title: "%s",`, gr.escapeGoString(title)))
+		}
+		if description != "" {
+			g.P(fmt.Sprintf(`Description: "%s",`, gr.escapeGoString(description)))
+		}
+		if gr.opts.StreamFraming == streamFramingArray {
+			g.P(`Type: "array",`)
+			g.P(fmt.Sprintf("Items: %s,", sg.referenceName(method.Output)))
+		} else {
+			g.P(fmt.Sprintf("Ref: %s.Ref,", sg.referenceName(method.Output)))
+		}
+		g.P("Defs: defs,")
+		g.P(fmt.Sprintf(`Extra: map[string]any{"x-stream-framing": "%s", "x-stream-method": "/%s/%s"},`, gr.opts.StreamFraming, method.Parent.Desc.FullName(), method.Desc.Name()))
+		g.P("}")
+		g.P("}")
+		g.P()
+	}
+	return g, sg.err
+}
+
+// hasMessageSchema reports whether the run defines the schema functions of msg, which is
+// the case for the non-Google messages emitted by their declaring file when that file is
+// generated.
+func (gr *Generator) hasMessageSchema(gen *protogen.Plugin, msg *protogen.Message) bool {
+	if gr.isStandaloneType(msg) {
+		return false
+	}
+	file, ok := gen.FilesByPath[msg.Desc.ParentFile().Path()]
+	if !ok || !file.Generate {
+		return false
+	}
+	return containsMessage(gr.getLocalMessages(file), msg)
+}
+
+// generateSanthoshFile creates <file>_jsonschema_santhosh.pb.go, which gives each message
+// whose schema functions the file defines a JsonSchemaSanthosh() accessor. The accessors
+// marshal the generated schema to JSON and compile it with the santhosh-tekuri validator,
+// so both libraries always see the same schema. Google types get no accessor.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateSanthoshFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_santhosh.pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	compiledSchema := g.QualifiedGoIdent(santhoshPackage.Ident("Schema"))
+	compileFuncName := fileNamePrefix(file) + "_compileSanthosh"
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// JsonSchemaSanthosh returns the JSON schema for the %s message compiled with", msg.Desc.Name()))
+		g.P("// github.com/santhosh-tekuri/jsonschema/v6.")
+		g.P("// It is safe for concurrent use; each call compiles a new schema, so callers that")
+		g.P("// validate repeatedly should keep the result.")
+		g.P(fmt.Sprintf("func (x *%s) JsonSchemaSanthosh() (*%s, error) {", msg.GoIdent.GoName, compiledSchema))
+		g.P(fmt.Sprintf("return %s(%q, x.JsonSchema())", compileFuncName, msg.Desc.FullName()))
+		g.P("}")
+		g.P()
+	}
+
+	g.P(fmt.Sprintf("// %s compiles schema with github.com/santhosh-tekuri/jsonschema/v6 as the", compileFuncName))
+	g.P("// resource urn:protoc-gen-go-jsonschema:<name>.")
+	g.P(fmt.Sprintf("func %s(name string, schema *jsonschema.Schema) (*%s, error) {", compileFuncName, compiledSchema))
+	g.P(fmt.Sprintf("data, err := %s(schema)", g.QualifiedGoIdent(protogen.GoImportPath("encoding/json").Ident("Marshal"))))
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P(fmt.Sprintf("doc, err := %s(%s(data))", g.QualifiedGoIdent(santhoshPackage.Ident("UnmarshalJSON")), g.QualifiedGoIdent(protogen.GoImportPath("bytes").Ident("NewReader"))))
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P(`url := "urn:protoc-gen-go-jsonschema:" + name`)
+	g.P(fmt.Sprintf("compiler := %s()", g.QualifiedGoIdent(santhoshPackage.Ident("NewCompiler"))))
+	g.P("if err := compiler.AddResource(url, doc); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return compiler.Compile(url)")
+	g.P("}")
+	return g
+}
+
+// generateInvopopFile creates <file>_jsonschema_invopop.pb.go, which gives each message
+// whose schema functions the file defines a JsonSchemaInvopop() accessor. The accessors
+// convert the generated schema to invopop/jsonschema structs through its JSON form, so both
+// libraries always see the same schema. Google types get no accessor.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateInvopopFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_invopop.pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	invopopSchema := g.QualifiedGoIdent(invopopPackage.Ident("Schema"))
+	convertFuncName := fileNamePrefix(file) + "_toInvopop"
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// JsonSchemaInvopop returns the JSON schema for the %s message as", msg.Desc.Name()))
+		g.P("// github.com/invopop/jsonschema structs.")
+		g.P(concurrencyDoc)
+		g.P(fmt.Sprintf("func (x *%s) JsonSchemaInvopop() (*%s, error) {", msg.GoIdent.GoName, invopopSchema))
+		g.P(fmt.Sprintf("return %s(x.JsonSchema())", convertFuncName))
+		g.P("}")
+		g.P()
+	}
+
+	jsonPackage := protogen.GoImportPath("encoding/json")
+	jsonMarshal := g.QualifiedGoIdent(jsonPackage.Ident("Marshal"))
+	jsonUnmarshal := g.QualifiedGoIdent(jsonPackage.Ident("Unmarshal"))
+	splitFuncName := fileNamePrefix(file) + "_splitTypeUnions"
+	g.P(fmt.Sprintf("// %s converts schema to github.com/invopop/jsonschema structs through its JSON form.", convertFuncName))
+	g.P(fmt.Sprintf("func %s(schema *jsonschema.Schema) (*%s, error) {", convertFuncName, invopopSchema))
+	g.P(fmt.Sprintf("data, err := %s(schema)", jsonMarshal))
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("var v any")
+	g.P(fmt.Sprintf("if err := %s(data, &v); err != nil {", jsonUnmarshal))
+	g.P("return nil, err")
+	g.P("}")
+	g.P(fmt.Sprintf("%s(v)", splitFuncName))
+	g.P(fmt.Sprintf("if data, err = %s(v); err != nil {", jsonMarshal))
+	g.P("return nil, err")
+	g.P("}")
+	g.P(fmt.Sprintf("out := &%s{}", invopopSchema))
+	g.P(fmt.Sprintf("if err := %s(data, out); err != nil {", jsonUnmarshal))
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return out, nil")
+	g.P("}")
+	g.P()
+
+	// Type unions (e.g. nullable wrapper types) have no invopop representation other
+	// than one branch per type.
+	g.P(fmt.Sprintf("// %s rewrites type unions in the JSON form of a schema into anyOf branches", splitFuncName))
+	g.P("// (or an allOf entry holding them, if the schema has an anyOf), since invopop/jsonschema")
+	g.P("// schemas have a single type.")
+	g.P(fmt.Sprintf("func %s(v any) {", splitFuncName))
+	g.P("switch v := v.(type) {")
+	g.P("case map[string]any:")
+	g.P("for _, child := range v {")
+	g.P(fmt.Sprintf("%s(child)", splitFuncName))
+	g.P("}")
+	g.P(`types, ok := v["type"].([]any)`)
+	g.P("if !ok {")
+	g.P("return")
+	g.P("}")
+	g.P(`delete(v, "type")`)
+	g.P("branches := make([]any, len(types))")
+	g.P("for i, t := range types {")
+	g.P(`branches[i] = map[string]any{"type": t}`)
+	g.P("}")
+	g.P(`if _, ok := v["anyOf"]; ok {`)
+	g.P(`allOf, _ := v["allOf"].([]any)`)
+	g.P(`v["allOf"] = append(allOf, map[string]any{"anyOf": branches})`)
+	g.P("} else {")
+	g.P(`v["anyOf"] = branches`)
+	g.P("}")
+	g.P("case []any:")
+	g.P("for _, child := range v {")
+	g.P(fmt.Sprintf("%s(child)", splitFuncName))
+	g.P("}")
+	g.P("}")
+	g.P("}")
+	return g
+}
+
+// generateOpenAPI3File creates <file>_jsonschema_openapi3.pb.go, which gives each message
+// whose schema functions the file defines a JsonSchemaOpenAPI3() accessor. The accessors
+// convert the generated schema to kin-openapi schemas struct by struct (no JSON round
+// trip): $defs become component schemas and references point to #/components/schemas/.
+// Google types get no accessor.
+//
+// Returns nil if the file defines no message schemas.
+func (gr *Generator) generateOpenAPI3File(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	messages := gr.getLocalMessages(file)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_jsonschema_openapi3.pb.go", file.GoImportPath)
+	gr.emitFileHeader(g, file)
+
+	schemaRef := g.QualifiedGoIdent(openapi3Package.Ident("SchemaRef"))
+	schemas := g.QualifiedGoIdent(openapi3Package.Ident("Schemas"))
+	convertFuncName := fileNamePrefix(file) + "_toOpenAPI3"
+	for _, msg := range messages {
+		g.P(fmt.Sprintf("// JsonSchemaOpenAPI3 returns the JSON schema for the %s message as kin-openapi", msg.Desc.Name()))
+		g.P("// schemas: a reference to the message's component schema and the component schemas")
+		g.P("// to register under components.schemas.")
+		g.P(concurrencyDoc)
+		g.P(fmt.Sprintf("func (x *%s) JsonSchemaOpenAPI3() (*%s, %s) {", msg.GoIdent.GoName, schemaRef, schemas))
+		g.P(fmt.Sprintf("return %s(%q, x.JsonSchema())", convertFuncName, msg.Desc.FullName()))
+		g.P("}")
+		g.P()
+	}
+
+	// The converter body is written against placeholder qualifiers that are replaced
+	// with the import names protogen assigns. Only the constant source goes through the
+	// replacer, so message and package names are never rewritten.
+	qualifier := func(path protogen.GoImportPath, ident string) string {
+		return strings.TrimSuffix(g.QualifiedGoIdent(path.Ident(ident)), ident)
+	}
+	replacer := strings.NewReplacer(
+		"JSONSCHEMA.", qualifier(jsonschemaPackage, "Schema"),
+		"OPENAPI3.", qualifier(openapi3Package, "Schema"),
+		"STRINGS.", qualifier("strings", "TrimPrefix"),
+		"JSON.", qualifier("encoding/json", "Unmarshal"),
+		"REFLECT.", qualifier("reflect", "DeepEqual"),
+		"CONVERT", convertFuncName,
+	)
+	g.P(replacer.Replace(openAPI3ConverterSource))
+	return g
+}
+
+// openAPI3ConverterSource is the per-file converter emitted by generateOpenAPI3File.
+// JSONSCHEMA., OPENAPI3., STRINGS., JSON. and REFLECT. stand for package qualifiers and
+// CONVERT for the function name.
+const openAPI3ConverterSource = `// CONVERT converts schema, the generated schema of the named message, to
+// kin-openapi schemas. $defs become component schemas, references point to
+// #/components/schemas/ (a reference with sibling keywords moves into allOf), and the
+// returned reference points to the message's schema.
+func CONVERT(name string, schema *JSONSCHEMA.Schema) (*OPENAPI3.SchemaRef, OPENAPI3.Schemas) {
+	defs := make(map[string]*JSONSCHEMA.Schema, len(schema.Defs))
+	for key, def := range schema.Defs {
+		defs[key] = def
+	}
+	if def := defs[name]; def == nil || def.Ref == "#" {
+		// The root is the message's own object schema (object_root).
+		root := *schema
+		root.Defs = nil
+		defs[name] = &root
+	}
+
+	components := make(OPENAPI3.Schemas, len(defs))
+	for key := range defs {
+		components[key] = OPENAPI3.NewSchemaRef("", &OPENAPI3.Schema{})
+	}
+	reference := func(key string) *OPENAPI3.SchemaRef {
+		return OPENAPI3.NewSchemaRef("#/components/schemas/"+key, components[key].Value)
+	}
+	size := func(v *int) *uint64 {
+		if v == nil {
+			return nil
+		}
+		u := uint64(*v)
+		return &u
+	}
+
+	var convert func(s *JSONSCHEMA.Schema) *OPENAPI3.SchemaRef
+	convertList := func(in []*JSONSCHEMA.Schema) OPENAPI3.SchemaRefs {
+		if in == nil {
+			return nil
+		}
+		out := make(OPENAPI3.SchemaRefs, len(in))
+		for i, s := range in {
+			out[i] = convert(s)
+		}
+		return out
+	}
+	convertMap := func(in map[string]*JSONSCHEMA.Schema) OPENAPI3.Schemas {
+		if in == nil {
+			return nil
+		}
+		out := make(OPENAPI3.Schemas, len(in))
+		for key, s := range in {
+			out[key] = convert(s)
+		}
+		return out
+	}
+	convert = func(s *JSONSCHEMA.Schema) *OPENAPI3.SchemaRef {
+		if s == nil {
+			return nil
+		}
+		var ref *OPENAPI3.SchemaRef
+		switch key := STRINGS.TrimPrefix(s.Ref, "#/$defs/"); {
+		case s.Ref == "":
+		case s.Ref == "#":
+			ref = reference(name)
+		case key != s.Ref && components[key] != nil:
+			ref = reference(key)
+		default:
+			ref = OPENAPI3.NewSchemaRef(s.Ref, nil)
+		}
+		if ref != nil {
+			siblings := *s
+			siblings.Ref = ""
+			if REFLECT.DeepEqual(siblings, JSONSCHEMA.Schema{}) {
+				return ref
+			}
+			// A reference cannot carry sibling keywords, so it moves into allOf.
+			out := convert(&siblings)
+			out.Value.AllOf = append(OPENAPI3.SchemaRefs{ref}, out.Value.AllOf...)
+			return out
+		}
+		out := &OPENAPI3.Schema{
+			Title:             s.Title,
+			Description:       s.Description,
+			Deprecated:        s.Deprecated,
+			ReadOnly:          s.ReadOnly,
+			WriteOnly:         s.WriteOnly,
+			Examples:          s.Examples,
+			Enum:              s.Enum,
+			MultipleOf:        s.MultipleOf,
+			Min:               s.Minimum,
+			Max:               s.Maximum,
+			ExclusiveMin:      OPENAPI3.ExclusiveBound{Value: s.ExclusiveMinimum},
+			ExclusiveMax:      OPENAPI3.ExclusiveBound{Value: s.ExclusiveMaximum},
+			MaxLength:         size(s.MaxLength),
+			Pattern:           s.Pattern,
+			Format:            s.Format,
+			PrefixItems:       convertList(s.PrefixItems),
+			Items:             convert(s.Items),
+			MaxItems:          size(s.MaxItems),
+			UniqueItems:       s.UniqueItems,
+			Contains:          convert(s.Contains),
+			MinContains:       size(s.MinContains),
+			MaxContains:       size(s.MaxContains),
+			MaxProps:          size(s.MaxProperties),
+			Required:          s.Required,
+			DependentRequired: s.DependentRequired,
+			Properties:        convertMap(s.Properties),
+			PatternProperties: convertMap(s.PatternProperties),
+			PropertyNames:     convert(s.PropertyNames),
+			AllOf:             convertList(s.AllOf),
+			AnyOf:             convertList(s.AnyOf),
+			OneOf:             convertList(s.OneOf),
+			Not:               convert(s.Not),
+			If:                convert(s.If),
+			Then:              convert(s.Then),
+			Else:              convert(s.Else),
+			DependentSchemas:  convertMap(s.DependentSchemas),
+			ContentEncoding:   s.ContentEncoding,
+			ContentMediaType:  s.ContentMediaType,
+			SchemaID:          s.ID,
+			Comment:           s.Comment,
+			Anchor:            s.Anchor,
+		}
+		switch {
+		case s.Type != "":
+			out.Type = &OPENAPI3.Types{s.Type}
+		case len(s.Types) > 0:
+			types := OPENAPI3.Types(s.Types)
+			out.Type = &types
+		}
+		if s.Const != nil {
+			out.Const = *s.Const
+		}
+		if len(s.Default) > 0 {
+			var value any
+			if err := JSON.Unmarshal(s.Default, &value); err == nil {
+				out.Default = value
+			}
+		}
+		if s.MinLength != nil {
+			out.MinLength = uint64(*s.MinLength)
+		}
+		if s.MinItems != nil {
+			out.MinItems = uint64(*s.MinItems)
+		}
+		if s.MinProperties != nil {
+			out.MinProps = uint64(*s.MinProperties)
+		}
+		switch {
+		case s.AdditionalProperties == nil:
+		case REFLECT.DeepEqual(*s.AdditionalProperties, JSONSCHEMA.Schema{}):
+			// An empty schema allows any value and renders as true.
+			allowed := true
+			out.AdditionalProperties = OPENAPI3.AdditionalProperties{Has: &allowed}
+		case REFLECT.DeepEqual(*s.AdditionalProperties, JSONSCHEMA.Schema{Not: &JSONSCHEMA.Schema{}}):
+			// The false schema forbids additional properties.
+			allowed := false
+			out.AdditionalProperties = OPENAPI3.AdditionalProperties{Has: &allowed}
+		default:
+			out.AdditionalProperties = OPENAPI3.AdditionalProperties{Schema: convert(s.AdditionalProperties)}
+		}
+		if s.UnevaluatedProperties != nil {
+			out.UnevaluatedProperties = OPENAPI3.BoolSchema{Schema: convert(s.UnevaluatedProperties)}
+		}
+		if s.UnevaluatedItems != nil {
+			out.UnevaluatedItems = OPENAPI3.BoolSchema{Schema: convert(s.UnevaluatedItems)}
+		}
+		if len(s.Extra) > 0 {
+			out.Extensions = make(map[string]any, len(s.Extra))
+			for key, value := range s.Extra {
+				out.Extensions[key] = value
+			}
+		}
+		return OPENAPI3.NewSchemaRef("", out)
+	}
+
+	for key, def := range defs {
+		*components[key].Value = *convert(def).Value
+	}
+	return reference(name), components
+}`
+
+// isInlinedMessage reports whether fields of the given message type are emitted as an
+// inline schema instead of a $ref to the message's generated schema function.
+// Inlined messages are not collected as dependencies, so no schema function is
+// generated for them unless something else references them.
+//
+// google.protobuf.Struct, Value and ListValue are always inlined as free-form JSON, the
+// form their payloads take; the other well-known types depend on the plugin options.
+func (gr *Generator) isInlinedMessage(msg *protogen.Message) bool {
+	protojson := gr.opts.WellKnownTypes == wellKnownTypesProtojson
+	switch msg.Desc.FullName() {
+	case "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue":
+		return true
+	case "google.protobuf.Duration":
+		return gr.opts.allDurationSeconds() || protojson
+	case "google.protobuf.Timestamp", "google.protobuf.FieldMask":
+		return protojson
+	}
+	if _, ok := wrapperValueTypes[msg.Desc.FullName()]; ok {
+		return protojson
+	}
+	return false
+}
+
+// isInlinedField reports whether the values of a message-typed field, whose message (or
+// map value message) is msg, are emitted as an inline schema: those of isInlinedMessage
+// and Duration fields listed by the duration_seconds parameter.
+func (gr *Generator) isInlinedField(field *protogen.Field, msg *protogen.Message) bool {
+	return gr.isInlinedMessage(msg) || gr.durationSeconds[field.Desc.FullName()]
+}
+
+// oneofFullName returns the full name of the (non-synthetic) oneof containing field, or
+// "" if it is in none.
+func oneofFullName(field *protogen.Field) protoreflect.FullName {
+	if field.Oneof == nil || field.Oneof.Desc.IsSynthetic() {
+		return ""
+	}
+	return field.Oneof.Desc.FullName()
+}
+
+// isOptionalField reports whether a singular field is declared optional: with the
+// optional keyword in proto2 and proto3 files, or with explicit presence (the default
+// field_presence feature) in editions files. Implicit-presence and LEGACY_REQUIRED
+// fields in editions files are not optional, like unlabeled proto3 and required proto2
+// fields.
+func isOptionalField(field *protogen.Field) bool {
+	if field.Desc.ParentFile().Syntax() == protoreflect.Editions {
+		return field.Desc.HasPresence() && field.Desc.Cardinality() != protoreflect.Required
+	}
+	return field.Desc.HasOptionalKeyword()
+}
+
+// getFileMessages collects the messages that should generate schemas for a proto file,
+// including their dependencies.
+//
+// File-level options determine whether all messages generate schemas by default;
+// individual messages can override this with their own options.
+func (gr *Generator) getFileMessages(file *protogen.File) []*protogen.Message {
+	generateAll := false
+	if opts := getFileJsonSchemaOptions(file); opts != nil {
+		generateAll = opts.GetGenerate()
+	}
+
+	// The visited map prevents processing the same message twice.
+	return gr.getMessages(file.Messages, generateAll, make(map[string]bool))
+}
+
+// getSharedEnums determines which enums are emitted as shared definitions for this plugin run.
+//
+// An enum is shared when it is referenced by a field of any message targeted by a file
+// being generated in this run, and the enum itself is declared in one of those files.
+// The declaring file then emits a single <Enum>_JsonSchema_WithDefs helper, and every
+// referencing file in the package (or in other packages of the same run) uses a $ref
+// to it instead of duplicating the enum values.
+//
+// Enums declared in files outside the run (including Google enums) cannot be guaranteed
+// to have a helper, so they keep the inline representation.
+func (gr *Generator) getSharedEnums(gen *protogen.Plugin) map[string]bool {
+	generating := make(map[string]bool)
+	for _, f := range gen.Files {
+		if f.Generate {
+			generating[f.Desc.Path()] = true
+		}
+	}
+
+	shared := make(map[string]bool)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		for _, msg := range gr.getFileMessages(f) {
+			for _, field := range msg.Fields {
+				if getFieldJsonSchemaOptions(field).GetIgnore() {
+					continue
+				}
+				enum := fieldEnum(field)
+				if enum == nil || gr.isGoogleEnum(enum) {
+					continue
+				}
+				if generating[enum.Desc.ParentFile().Path()] {
+					shared[string(enum.Desc.FullName())] = true
+				}
+			}
+		}
+	}
+	return shared
+}
+
+// getGoogleHelpers builds the package-level registry of Google type helpers for this run.
+// It must run after requiredMessages is set, since a file also emits the messages other
+// files of the run require from it.
+//
+// Files sharing a go_package are generated into the same Go package, so a Google type
+// referenced by several of them must still be defined only once. The first file in the
+// run that references a Google type owns its helper; the function name is derived from
+// that file's name (see googleTypeFunctionName) and reused by every other file in the
+// package. Name clashes (e.g. two files named common.proto in one package referencing
+// different types that flatten to the same name) are resolved with a numeric suffix.
+func (gr *Generator) getGoogleHelpers(gen *protogen.Plugin) googleHelperRegistry {
+	registry := googleHelperRegistry{
+		names: make(map[protogen.GoImportPath]map[protoreflect.FullName]string),
+		owned: make(map[string][]*protogen.Message),
+	}
+	taken := make(map[protogen.GoImportPath]map[string]bool)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		if registry.names[f.GoImportPath] == nil {
+			registry.names[f.GoImportPath] = make(map[protoreflect.FullName]string)
+			taken[f.GoImportPath] = make(map[string]bool)
+		}
+		names := registry.names[f.GoImportPath]
+		// Messages that other files require from f (e.g. map values forced from another
+		// package) are emitted by f, so their Google types need helpers in f's package.
+		messages := gr.getFileMessages(f)
+		messages = append(messages, gr.getMessagesWithForce(gr.getRequiredMessagesInFile(f), true, true, make(map[string]bool))...)
+		for _, msg := range messages {
+			if !gr.isStandaloneType(msg) {
+				continue
+			}
+			if _, ok := names[msg.Desc.FullName()]; ok {
+				continue
+			}
+			name := googleTypeFunctionName(msg, fileNamePrefix(f))
+			for i := 2; taken[f.GoImportPath][name]; i++ {
+				name = fmt.Sprintf("%s_%d", googleTypeFunctionName(msg, fileNamePrefix(f)), i)
+			}
+			taken[f.GoImportPath][name] = true
+			names[msg.Desc.FullName()] = name
+			registry.owned[f.Desc.Path()] = append(registry.owned[f.Desc.Path()], msg)
+		}
+	}
+	return registry
+}
+
+// getAnyTypes resolves the any_types parameter into the messages allowed in each listed
+// google.protobuf.Any field. Fields and types must be declared in the files of the
+// request. Well-known types are rejected, since protojson wraps their special JSON form
+// in a "value" property inside Any, and so are messages whose schemas reject undeclared
+// properties (see typePropertyRejection), so it runs after the parameters closing them
+// are resolved.
+func (gr *Generator) getAnyTypes() (map[protoreflect.FullName][]*protogen.Message, error) {
+	names, err := gr.opts.anyTypes()
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	fieldNames := make([]protoreflect.FullName, 0, len(names))
+	for name := range names {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Slice(fieldNames, func(i, j int) bool { return fieldNames[i] < fieldNames[j] })
+
+	allowed := make(map[protoreflect.FullName][]*protogen.Message, len(names))
+	for _, fieldName := range fieldNames {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid any_types parameter: field %s not found", fieldName)
+		}
+		if msg := fieldMessage(field); msg == nil || msg.Desc.FullName() != "google.protobuf.Any" {
+			return nil, fmt.Errorf("invalid any_types parameter: field %s is not a google.protobuf.Any field", fieldName)
+		}
+		for _, typeName := range names[fieldName] {
+			msg := gr.index.messages[typeName]
+			switch {
+			case msg == nil:
+				return nil, fmt.Errorf("invalid any_types parameter: message %s (listed for %s) not found", typeName, fieldName)
+			case msg.Desc.ParentFile().Package() == "google.protobuf":
+				return nil, fmt.Errorf("invalid any_types parameter: well-known type %s (listed for %s) is not supported", typeName, fieldName)
+			}
+			if param := gr.typePropertyRejection(typeName); param != "" {
+				return nil, fmt.Errorf("invalid any_types parameter: the schema of message %s (listed for %s) rejects the \"@type\" property of Any values, because of the %s parameter", typeName, fieldName, param)
+			}
+			allowed[fieldName] = append(allowed[fieldName], msg)
+		}
+	}
+	return allowed, nil
+}
+
+// typePropertyRejection returns the parameter that makes the schema of a message reject
+// an undeclared "@type" property, or "" if the schema accepts one. Such a message cannot
+// be listed by any_types: its schema is applied to the Any value, "@type" included, and
+// additionalProperties and unevaluatedProperties do not see the "@type" property declared
+// next to the reference.
+func (gr *Generator) typePropertyRejection(name protoreflect.FullName) string {
+	if gr.unevaluatedProperties[name] {
+		return "unevaluated_properties"
+	}
+	if value, ok := gr.additionalProperties[name]; ok {
+		if value == "true" || value == "string" {
+			return ""
+		}
+		return "additional_properties"
+	}
+	if gr.closedObjects[name] {
+		return "closed_objects"
+	}
+	return ""
+}
+
+// getClosedObjects resolves the closed_objects parameter to the full names of the
+// messages whose schemas reject undeclared properties. Files and messages must be part
+// of the request.
+func (gr *Generator) getClosedObjects() (map[protoreflect.FullName]bool, error) {
+	return selectMessages(gr.index, "closed_objects", gr.opts.ClosedObjects)
+}
+
+// getUnevaluatedProperties resolves the unevaluated_properties parameter to the full
+// names of the messages whose schemas set unevaluatedProperties to false. Files and
+// messages must be part of the request.
+func (gr *Generator) getUnevaluatedProperties() (map[protoreflect.FullName]bool, error) {
+	return selectMessages(gr.index, "unevaluated_properties", gr.opts.UnevaluatedProperties)
+}
+
+// selectMessages resolves the values of a parameter naming messages, each all, a proto
+// file path (the messages declared in it, including nested ones) or a message full name,
+// to the full names of the messages. Files and messages must be part of the request.
+func selectMessages(index *requestIndex, param string, values []string) (map[protoreflect.FullName]bool, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	selected := make(map[protoreflect.FullName]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "all" {
+			for name := range index.messages {
+				selected[name] = true
+			}
+			return selected, nil
+		}
+		if msgs, ok := index.files[value]; ok {
+			for _, msg := range msgs {
+				selected[msg.Desc.FullName()] = true
+			}
+			continue
+		}
+		if index.messages[protoreflect.FullName(value)] == nil {
+			return nil, fmt.Errorf("invalid %s parameter: %q is not all, a proto file or a message of the request", param, value)
+		}
+		selected[protoreflect.FullName(value)] = true
+	}
+	return selected, nil
+}
+
+// getRecursionDepths resolves the max_recursion_depth parameter. Messages must be part
+// of the request.
+func (gr *Generator) getRecursionDepths() (map[protoreflect.FullName]int, error) {
+	depths, err := gr.opts.maxRecursionDepths()
+	if err != nil || len(depths) == 0 {
+		return nil, err
+	}
+
+	for name := range depths {
+		if gr.index.messages[name] == nil {
+			return nil, fmt.Errorf("invalid max_recursion_depth parameter: message %s not found", name)
+		}
+	}
+	return depths, nil
+}
+
+// getPresets resolves the preset parameter. Messages must be part of the request and have
+// the fields their preset constrains.
+func (gr *Generator) getPresets() (map[protoreflect.FullName]string, error) {
+	presets, err := gr.opts.presets()
+	if err != nil || len(presets) == 0 {
+		return nil, err
+	}
+
+	for name := range presets {
+		message, ok := gr.index.messages[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid preset parameter: message %s not found", name)
+		}
+		if units, _ := moneyPresetFields(message); units == nil {
+			return nil, fmt.Errorf("invalid preset parameter: message %s has no singular int64 units and int32 nanos fields for the %s preset", name, presetMoney)
+		}
+	}
+	return presets, nil
+}
+
+// getCELConstraints resolves the cel_constraint parameter. Messages must be part of the
+// request, and expressions must compile as schemautil.EvaluateConstraints compiles them.
+func (gr *Generator) getCELConstraints() (map[protoreflect.FullName][]string, error) {
+	constraints, err := gr.opts.celConstraints()
+	if err != nil || len(constraints) == 0 {
+		return nil, err
+	}
+
+	for name, expressions := range constraints {
+		if gr.index.messages[name] == nil {
+			return nil, fmt.Errorf("invalid cel_constraint parameter: message %s not found", name)
+		}
+		for _, expression := range expressions {
+			if err := schemautil.CompileConstraint(expression); err != nil {
+				return nil, fmt.Errorf("invalid cel_constraint parameter: expression %q of message %s: %v", expression, name, err)
+			}
+		}
+	}
+	return constraints, nil
+}
+
+// getTimestampBounds resolves the timestamp_bounds parameter into the x-formatMinimum and
+// x-formatMaximum keywords of fields' Timestamp strings. Fields must be
+// google.protobuf.Timestamp fields of the request: singular, repeated or map values.
+func (gr *Generator) getTimestampBounds() (map[protoreflect.FullName]map[string]any, error) {
+	bounds, err := gr.opts.timestampBounds()
+	if err != nil || len(bounds) == 0 {
+		return nil, err
+	}
+
+	keywords := make(map[protoreflect.FullName]map[string]any, len(bounds))
+	for name, b := range bounds {
+		field := gr.index.fields[name]
+		if field == nil {
+			return nil, fmt.Errorf("invalid timestamp_bounds parameter: field %s not found", name)
+		}
+		if msg := fieldMessage(field); msg == nil || msg.Desc.FullName() != "google.protobuf.Timestamp" {
+			return nil, fmt.Errorf("invalid timestamp_bounds parameter: field %s is not a google.protobuf.Timestamp field", name)
+		}
+		keywords[name] = make(map[string]any)
+		if b[0] != "" {
+			keywords[name][schemautil.FormatMinimumKeyword] = b[0]
+		}
+		if b[1] != "" {
+			keywords[name][schemautil.FormatMaximumKeyword] = b[1]
+		}
+	}
+	return keywords, nil
+}
+
+// getAdditionalProperties resolves the additional_properties parameter. Messages must be
+// part of the request.
+func (gr *Generator) getAdditionalProperties() (map[protoreflect.FullName]string, error) {
+	values, err := gr.opts.additionalProperties()
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	for name := range values {
+		if gr.index.messages[name] == nil {
+			return nil, fmt.Errorf("invalid additional_properties parameter: message %s not found", name)
+		}
+	}
+	return values, nil
+}
+
+// getDiscriminatedOneofs resolves the discriminated_oneof parameter. Oneofs must be part
+// of the request, and neither their property nor the discriminator may clash with the
+// properties of the message or of the oneof's members.
+func (gr *Generator) getDiscriminatedOneofs() (map[protoreflect.FullName]string, error) {
+	discriminators, err := gr.opts.discriminatedOneofs()
+	if err != nil || len(discriminators) == 0 {
+		return nil, err
+	}
+
+	for name, discriminator := range discriminators {
+		oneof, ok := gr.index.oneofs[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid discriminated_oneof parameter: oneof %s not found", name)
+		}
+		for _, field := range oneof.Parent.Fields {
+			if field.Oneof != oneof && gr.getFieldName(field) == gr.getOneofName(oneof) {
+				return nil, fmt.Errorf("invalid discriminated_oneof parameter: oneof %s has the same property name as field %s", name, field.Desc.FullName())
+			}
+		}
+		for _, field := range oneof.Fields {
+			if gr.getFieldName(field) == discriminator {
+				return nil, fmt.Errorf("invalid discriminated_oneof parameter: discriminator %q of oneof %s is the property name of its field %s", discriminator, name, field.Desc.FullName())
+			}
+		}
+	}
+	return discriminators, nil
+}
+
+// getOneofTexts resolves the oneof_title and oneof_description parameters. Oneofs must be
+// part of the request.
+func (gr *Generator) getOneofTexts() (titles, descriptions map[protoreflect.FullName]string, err error) {
+	if titles, err = gr.opts.oneofTitles(); err != nil {
+		return nil, nil, err
+	}
+	if descriptions, err = gr.opts.oneofDescriptions(); err != nil {
+		return nil, nil, err
+	}
+	if len(titles) == 0 && len(descriptions) == 0 {
+		return nil, nil, nil
+	}
+
+	for name := range titles {
+		if gr.index.oneofs[name] == nil {
+			return nil, nil, fmt.Errorf("invalid oneof_title parameter: oneof %s not found", name)
+		}
+	}
+	for name := range descriptions {
+		if gr.index.oneofs[name] == nil {
+			return nil, nil, fmt.Errorf("invalid oneof_description parameter: oneof %s not found", name)
+		}
+	}
+	return titles, descriptions, nil
+}
+
+// getMethodDescriptions resolves the method_description parameter. Methods must be part
+// of the request.
+func (gr *Generator) getMethodDescriptions() (map[protoreflect.FullName]string, error) {
+	descriptions, err := gr.opts.methodDescriptions()
+	if err != nil || len(descriptions) == 0 {
+		return nil, err
+	}
+
+	for name := range descriptions {
+		if gr.index.methods[name] == nil {
+			return nil, fmt.Errorf("invalid method_description parameter: method %s not found", name)
+		}
+	}
+	return descriptions, nil
+}
+
+// getEnumAliases resolves the enum_alias parameter. Fields must be enum fields (singular,
+// repeated or map values) of the request, values must belong to their enum, and aliases
+// must differ from the enum's value names and from each other.
+func (gr *Generator) getEnumAliases() (map[protoreflect.FullName][]enumAlias, error) {
+	names, err := gr.opts.enumAliases()
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	if !gr.opts.EnumNames {
+		return nil, fmt.Errorf("invalid enum_alias parameter: it requires enum_names")
+	}
+
+	resolved := make(map[protoreflect.FullName][]enumAlias, len(names))
+	for fieldName, values := range names {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid enum_alias parameter: field %s not found", fieldName)
+		}
+		enum := fieldEnum(field)
+		if enum == nil {
+			return nil, fmt.Errorf("invalid enum_alias parameter: field %s is not an enum field", fieldName)
+		}
+		schemaNames := gr.enumValueNames(enum.Desc)
+		taken := make(map[string]bool)
+		for _, name := range schemaNames {
+			taken[name] = true
+		}
+		for valueName := range values {
+			if enum.Desc.Values().ByName(valueName) == nil {
+				return nil, fmt.Errorf("invalid enum_alias parameter: enum %s of field %s has no value %s", enum.Desc.FullName(), fieldName, valueName)
+			}
+		}
+		for i, value := range enum.Values {
+			aliases := values[value.Desc.Name()]
+			for _, alias := range aliases {
+				if taken[alias] {
+					return nil, fmt.Errorf("invalid enum_alias parameter: alias %q of %s for field %s is already a value name or alias", alias, value.Desc.Name(), fieldName)
+				}
+				taken[alias] = true
+			}
+			if len(aliases) > 0 {
+				resolved[fieldName] = append(resolved[fieldName], enumAlias{name: schemaNames[i], aliases: aliases})
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// getCoercions resolves the coerce parameter. Fields must be scalar or enum fields
+// (singular, repeated or map values) of the request.
+func (gr *Generator) getCoercions() (map[protoreflect.FullName][]string, error) {
+	coercions, err := gr.opts.coercions()
+	if err != nil || len(coercions) == 0 {
+		return nil, err
+	}
+
+	for fieldName := range coercions {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid coerce parameter: field %s not found", fieldName)
+		}
+		if fieldMessage(field) != nil {
+			return nil, fmt.Errorf("invalid coerce parameter: field %s is not a scalar field", fieldName)
+		}
+	}
+	return coercions, nil
+}
+
+// getDurationSeconds resolves the field names of the duration_seconds parameter. Fields
+// must be google.protobuf.Duration fields (singular, repeated or map values) of the
+// request.
+func (gr *Generator) getDurationSeconds() (map[protoreflect.FullName]bool, error) {
+	durationSeconds := gr.opts.durationSecondsFields()
+	if len(durationSeconds) == 0 {
+		return nil, nil
+	}
+
+	for fieldName := range durationSeconds {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid duration_seconds parameter: field %s not found", fieldName)
+		}
+		if msg := fieldMessage(field); msg == nil || msg.Desc.FullName() != "google.protobuf.Duration" {
+			return nil, fmt.Errorf("invalid duration_seconds parameter: field %s is not a google.protobuf.Duration field", fieldName)
+		}
+	}
+	return durationSeconds, nil
+}
+
+// getMultipleOfs resolves the multiple_of parameter. Fields must be numeric fields
+// (singular, repeated or map values) of the request.
+func (gr *Generator) getMultipleOfs() (map[protoreflect.FullName]float64, error) {
+	multipleOfs, err := gr.opts.multipleOfs()
+	if err != nil || len(multipleOfs) == 0 {
+		return nil, err
+	}
+
+	for fieldName := range multipleOfs {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid multiple_of parameter: field %s not found", fieldName)
+		}
+		kind := field.Desc.Kind()
+		if field.Desc.IsMap() {
+			kind = field.Desc.MapValue().Kind()
+		}
+		switch kind {
+		case protoreflect.BoolKind, protoreflect.EnumKind, protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+			return nil, fmt.Errorf("invalid multiple_of parameter: field %s is not a numeric field", fieldName)
+		}
+	}
+	return multipleOfs, nil
+}
+
+// getAllowedValues resolves the allowed_values parameter into enum value numbers. Fields
+// must be enum fields (singular, repeated or map values) of the request, and each value a
+// proto name, schema name (see enumValueNames) or number of one of the enum's values.
+func (gr *Generator) getAllowedValues() (map[protoreflect.FullName][]int32, error) {
+	allowedValues, err := gr.opts.allowedValues()
+	if err != nil || len(allowedValues) == 0 {
+		return nil, err
+	}
+
+	numbers := make(map[protoreflect.FullName][]int32, len(allowedValues))
+	for fieldName, values := range allowedValues {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid allowed_values parameter: field %s not found", fieldName)
+		}
+		enum := fieldEnum(field)
+		if enum == nil {
+			return nil, fmt.Errorf("invalid allowed_values parameter: field %s is not an enum field", fieldName)
+		}
+		names := gr.enumValueNames(enum.Desc)
+		for _, value := range values {
+			index := slices.IndexFunc(enum.Values, func(v *protogen.EnumValue) bool {
+				return string(v.Desc.Name()) == value || names[v.Desc.Index()] == value || strconv.Itoa(int(v.Desc.Number())) == value
+			})
+			if index < 0 {
+				return nil, fmt.Errorf("invalid allowed_values parameter: %s is not a value of enum %s (field %s)", value, enum.Desc.FullName(), fieldName)
+			}
+			if number := int32(enum.Values[index].Desc.Number()); !slices.Contains(numbers[fieldName], number) {
+				numbers[fieldName] = append(numbers[fieldName], number)
+			}
+		}
+	}
+	return numbers, nil
+}
+
+// getExtensions resolves the extension parameter into the keywords of fields' own schemas
+// and those of their elements or values. Fields must be fields of the request, and
+// targets must match them (see resolveFieldTarget).
+func (gr *Generator) getExtensions() (fieldExtensions, elementExtensions map[protoreflect.FullName]map[string]any, err error) {
+	extensions, err := gr.opts.extensions()
+	if err != nil || len(extensions) == 0 {
+		return nil, nil, err
+	}
+
+	fieldExtensions = make(map[protoreflect.FullName]map[string]any)
+	elementExtensions = make(map[protoreflect.FullName]map[string]any)
+	for name, keywords := range extensions {
+		field, target, err := gr.resolveFieldTarget("extension", name)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved := fieldExtensions
+		if target != "" {
+			resolved = elementExtensions
+		}
+		resolved[field.Desc.FullName()] = keywords
+	}
+	return fieldExtensions, elementExtensions, nil
+}
+
+// resolveFieldTarget looks up the field a name of the extension or raw_schema parameter
+// designates, with its target (see splitFieldTarget). [items] needs a repeated field and
+// [values] a map field.
+func (gr *Generator) resolveFieldTarget(param string, name protoreflect.FullName) (*protogen.Field, string, error) {
+	fieldName, target := splitFieldTarget(name)
+	field := gr.index.fields[fieldName]
+	if field == nil {
+		return nil, "", fmt.Errorf("invalid %s parameter: field %s not found", param, fieldName)
+	}
+	switch {
+	case target == fieldTargetItems && !field.Desc.IsList():
+		return nil, "", fmt.Errorf("invalid %s parameter: %s: field %s is not a repeated field", param, name, fieldName)
+	case target == fieldTargetValues && !field.Desc.IsMap():
+		return nil, "", fmt.Errorf("invalid %s parameter: %s: field %s is not a map field", param, name, fieldName)
+	}
+	return field, target, nil
+}
+
+// getDefaults resolves the default parameter. Fields must be fields of the request, and
+// each value must decode as the field's value in its containing message's proto JSON
+// form.
+func (gr *Generator) getDefaults() (map[protoreflect.FullName]string, error) {
+	defaults, err := gr.opts.defaults()
+	if err != nil || len(defaults) == 0 {
+		return nil, err
+	}
+
+	for fieldName, value := range defaults {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid default parameter: field %s not found", fieldName)
+		}
+		if err := checkFieldJSON(field, value); err != nil {
+			return nil, fmt.Errorf("invalid default parameter: %s is not a valid value of field %s: %v", value, fieldName, err)
+		}
+	}
+	return defaults, nil
+}
+
+// checkFieldJSON reports whether value decodes as the field's value in its containing
+// message's proto JSON form. Required fields of the message are not needed.
+func checkFieldJSON(field *protogen.Field, value string) error {
+	data := fmt.Sprintf("{%q:%s}", field.Desc.JSONName(), value)
+	return protojson.UnmarshalOptions{AllowPartial: true}.Unmarshal([]byte(data), dynamicpb.NewMessage(field.Desc.ContainingMessage()))
+}
+
+// getConsts resolves the const parameter like getDefaults, and returns the Go literals of
+// the values keyed by field full name. Enum values, given by name or number, are
+// rewritten as the field's schema lists them: names with the enum_names parameter and
+// numbers otherwise.
+func (gr *Generator) getConsts() (map[protoreflect.FullName]string, error) {
+	consts, err := gr.opts.consts()
+	if err != nil || len(consts) == 0 {
+		return nil, err
+	}
+
+	literals := make(map[protoreflect.FullName]string, len(consts))
+	for fieldName, value := range consts {
+		field := gr.index.fields[fieldName]
+		if field == nil {
+			return nil, fmt.Errorf("invalid const parameter: field %s not found", fieldName)
+		}
+		if err := checkFieldJSON(field, value); err != nil {
+			return nil, fmt.Errorf("invalid const parameter: %s is not a valid value of field %s: %v", value, fieldName, err)
+		}
+		var decoded any
+		if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+			return nil, fmt.Errorf("invalid const parameter: %s is not a valid value of field %s: %v", value, fieldName, err)
+		}
+		desc := field.Desc
+		if desc.IsMap() {
+			desc = desc.MapValue()
+		}
+		if desc.Kind() == protoreflect.EnumKind && desc.Enum().FullName() != "google.protobuf.NullValue" {
+			if decoded, err = gr.constEnumValues(desc.Enum(), decoded); err != nil {
+				return nil, fmt.Errorf("invalid const parameter: %s is not a valid value of field %s: %v", value, fieldName, err)
+			}
+		}
+		literals[fieldName] = goAnyLiteral(decoded)
+	}
+	return literals, nil
+}
+
+// constEnumValues rewrites the enum values of a decoded const value (a single value, or
+// the elements of a list or the values of a map) as the schema lists the values of enum.
+func (gr *Generator) constEnumValues(enum protoreflect.EnumDescriptor, value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		for i, e := range v {
+			var err error
+			if v[i], err = gr.constEnumValues(enum, e); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]any:
+		for k, e := range v {
+			var err error
+			if v[k], err = gr.constEnumValues(enum, e); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+
+	var enumValue protoreflect.EnumValueDescriptor
+	switch v := value.(type) {
+	case string:
+		enumValue = enum.Values().ByName(protoreflect.Name(v))
+	case float64:
+		enumValue = enum.Values().ByNumber(protoreflect.EnumNumber(v))
+	}
+	if enumValue == nil {
+		return nil, fmt.Errorf("%v is not a value of enum %s", value, enum.FullName())
+	}
+	if gr.opts.EnumNames {
+		return gr.enumValueNames(enum)[enumValue.Index()], nil
+	}
+	return float64(enumValue.Number()), nil
 }
 
-// googleTypeFunctionName converts a Google type's full name to a valid Go function name with a file prefix.
-// The prefix ensures uniqueness when multiple files in the same package import the same Google types.
-// Example: "google.protobuf.Timestamp" with prefix "admin" -> "admin_google_protobuf_Timestamp"
-func googleTypeFunctionName(msg *protogen.Message, filePrefix string) string {
-	fullName := string(msg.Desc.FullName())
-	baseName := strings.ReplaceAll(fullName, ".", "_")
-	if filePrefix != "" {
-		return filePrefix + "_" + baseName
-	}
-	return baseName
+// containerValueKeywords are the string and numeric keywords that do not apply to the
+// array or object schema of a repeated or map field, only to its elements or values.
+var containerValueKeywords = []string{
+	"minLength", "maxLength", "pattern", "format", "contentEncoding", "contentMediaType",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf",
 }
 
-// fileNamePrefix extracts a prefix from the proto file path for use in Google type function names.
-// Example: "users/v1/admin.proto" -> "admin"
-func fileNamePrefix(file *protogen.File) string {
-	// Get the base name without extension
-	path := file.Desc.Path()
-	base := strings.TrimSuffix(path, ".proto")
-	// Get just the file name part (after the last /)
-	if idx := strings.LastIndex(base, "/"); idx >= 0 {
-		base = base[idx+1:]
+// getRawSchemas resolves the raw_schema parameter into the patches of each field, the
+// patch of the field's own schema first. Fields must be fields of the request, and
+// targets must match them (see resolveFieldTarget). Patches with a target are wrapped in
+// an items or additionalProperties keyword, and patches of the own schema of a repeated or
+// map field must not set string or numeric keywords, which only apply to the elements.
+func (gr *Generator) getRawSchemas() (map[protoreflect.FullName][]string, error) {
+	rawSchemas, err := gr.opts.rawSchemas()
+	if err != nil || len(rawSchemas) == 0 {
+		return nil, err
 	}
-	return base
+
+	// Sorting puts a field's own patch before those of its targets.
+	names := slices.Sorted(maps.Keys(rawSchemas))
+	patches := make(map[protoreflect.FullName][]string, len(rawSchemas))
+	for _, name := range names {
+		field, target, err := gr.resolveFieldTarget("raw_schema", name)
+		if err != nil {
+			return nil, err
+		}
+		patch := rawSchemas[name]
+		switch target {
+		case fieldTargetItems:
+			patch = `{"items":` + patch + `}`
+		case fieldTargetValues:
+			patch = `{"additionalProperties":` + patch + `}`
+		default:
+			if field.Desc.IsList() || field.Desc.IsMap() {
+				var keywords map[string]json.RawMessage
+				if err := json.Unmarshal([]byte(patch), &keywords); err != nil {
+					return nil, fmt.Errorf("invalid raw_schema parameter: %s: %v", name, err)
+				}
+				suffix := "[" + fieldTargetItems + "]"
+				if field.Desc.IsMap() {
+					suffix = "[" + fieldTargetValues + "]"
+				}
+				for _, keyword := range containerValueKeywords {
+					if _, ok := keywords[keyword]; ok {
+						return nil, fmt.Errorf("invalid raw_schema parameter: %s does not apply to the container schema of field %s; patch its elements with %s%s", keyword, name, name, suffix)
+					}
+				}
+			}
+		}
+		patches[field.Desc.FullName()] = append(patches[field.Desc.FullName()], patch)
+	}
+	return patches, nil
 }
 
-// -----------------------------------------------------------------------------
-// Core Types
-// -----------------------------------------------------------------------------
+// getIdentifiers resolves the identifier parameter. Fields must be singular string fields
+// of the request.
+func (gr *Generator) getIdentifiers() (map[protoreflect.FullName]bool, error) {
+	if len(gr.opts.Identifiers) == 0 {
+		return nil, nil
+	}
 
-// Generator is the main entry point for the protoc-gen-go-jsonschema plugin.
-// It coordinates the overall code generation process by:
-//   - Processing plugin options to determine which messages to generate
-//   - Creating output files with proper headers and imports
-//   - Delegating message-level generation to MessageSchemaGenerator
-//
-// Generator is stateless; all state is passed through method parameters or
-// held in MessageSchemaGenerator for per-message generation.
-type Generator struct {
-	// Version is the plugin version used to generate this file
-	Version string
+	identifiers := make(map[protoreflect.FullName]bool)
+	for _, value := range gr.opts.Identifiers {
+		name := protoreflect.FullName(strings.TrimPrefix(strings.TrimSpace(value), "."))
+		field := gr.index.fields[name]
+		if field == nil {
+			return nil, fmt.Errorf("invalid identifier parameter: field %s not found", name)
+		}
+		if field.Desc.Kind() != protoreflect.StringKind || field.Desc.IsList() {
+			return nil, fmt.Errorf("invalid identifier parameter: field %s is not a singular string field", name)
+		}
+		identifiers[name] = true
+	}
+	return identifiers, nil
 }
 
-// -----------------------------------------------------------------------------
-// Generator Methods
-// -----------------------------------------------------------------------------
+// isIdentifier reports whether a field is the identifier of its resource message: it has
+// the google.api.field_behavior IDENTIFIER or is listed by the identifier parameter.
+func (gr *Generator) isIdentifier(field *protogen.Field) bool {
+	return gr.identifiers[field.Desc.FullName()] || slices.Contains(fieldBehaviors(field), fieldBehaviorIdentifier)
+}
 
-// generateFile creates a Go source file containing JSON Schema generation code
-// for all targeted messages in the given proto file.
-//
-// The generation process:
-//  1. Checks file-level options to determine the default generation behavior
-//  2. Collects all messages that should generate schemas (respecting options)
-//  3. Creates the output file with standard headers and imports
-//  4. Generates schema code for each target message
-//
-// Returns nil if no messages in the file require schema generation.
-func (gr *Generator) generateFile(gen *protogen.Plugin, file *protogen.File) (*protogen.GeneratedFile, error) {
-	// --- Determine Generation Scope ---
-	// Check file-level options to see if all messages should generate schemas by default.
-	// Individual messages can override this with their own options.
-	generateAll := false
-	if opts := getFileJsonSchemaOptions(file); opts != nil {
-		generateAll = opts.GetGenerate()
+// getResourcePatterns resolves the google.api.resource_reference annotations of the string
+// fields of the request into the patterns of their resource names. Resource types are
+// looked up in the google.api.resource annotations of messages and the
+// google.api.resource_definition annotations of files in the request; references to other
+// types, to any type ("*") or by child_type are left unconstrained. Identifier fields get
+// the pattern of their own message's google.api.resource annotation.
+func (gr *Generator) getResourcePatterns(gen *protogen.Plugin) map[protoreflect.FullName]string {
+	types := make(map[string][]string)
+	addResources := func(payloads [][]byte) {
+		for _, resource := range payloads {
+			typeName := lastEncodedString(resource, 1)
+			for _, pattern := range encodedBytes(resource, 2) {
+				if typeName != "" && !slices.Contains(types[typeName], string(pattern)) {
+					types[typeName] = append(types[typeName], string(pattern))
+				}
+			}
+		}
+	}
+	var fields []*protogen.Field
+	identifierTypes := make(map[protoreflect.FullName]string)
+	for _, f := range gen.Files {
+		addResources(optionPayloads(f.Desc.Options(), resourceNumber))
+		for _, msg := range gr.index.files[f.Desc.Path()] {
+			resources := optionPayloads(msg.Desc.Options(), resourceNumber)
+			addResources(resources)
+			fields = append(fields, msg.Fields...)
+			for _, field := range msg.Fields {
+				if len(resources) > 0 && gr.isIdentifier(field) {
+					identifierTypes[field.Desc.FullName()] = lastEncodedString(resources[len(resources)-1], 1)
+				}
+			}
+		}
 	}
 
-	// Collect messages that should generate schemas, including their dependencies.
-	// The visited map prevents processing the same message twice.
-	// This includes cross-package messages to ensure the defs map is complete.
-	targetMessages := gr.getMessages(file.Messages, generateAll, make(map[string]bool))
-
-	// --- CRITICAL: Filter to only messages DEFINED in THIS proto file ---
-	//
-	// Why: When multiple proto files share the same Go package and import each other
-	// (or import the same shared protos), we would generate duplicate function
-	// definitions if we filter by Go package instead of proto file.
-	//
-	// Solution: Only generate schema functions for messages defined in THIS proto file.
-	// Messages from other proto files (even in the same Go package) are just referenced
-	// by their _WithDefs function name - they will be generated in their own file.
-	// Cross-package messages are automatically referenced via QualifiedGoIdent.
-	// Google types are generated in the file where they're referenced (with file prefix).
-	var localMessages []*protogen.Message
-	var googleTypeMessages []*protogen.Message
-	for _, msg := range targetMessages {
-		// Include only messages DEFINED in this proto file (not just same Go package)
-		// Note: Use Path() not FullName() - FullName() returns the package name for files
-		if msg.Desc.ParentFile().Path() == file.Desc.Path() {
-			localMessages = append(localMessages, msg)
-		} else if isGoogleType(msg) {
-			// Include Google types that are referenced (they'll be generated as standalone functions)
-			googleTypeMessages = append(googleTypeMessages, msg)
+	patterns := make(map[protoreflect.FullName]string)
+	for _, field := range fields {
+		if field.Desc.Kind() != protoreflect.StringKind || field.Desc.IsMap() {
+			continue
+		}
+		for _, reference := range optionPayloads(field.Desc.Options(), resourceReferenceNumber) {
+			if p := types[lastEncodedString(reference, 1)]; len(p) > 0 {
+				patterns[field.Desc.FullName()] = resourceNamePattern(p)
+			}
+		}
+		if typeName, ok := identifierTypes[field.Desc.FullName()]; ok && len(types[typeName]) > 0 {
+			patterns[field.Desc.FullName()] = resourceNamePattern(types[typeName])
 		}
 	}
+	return patterns
+}
 
-	// Skip file generation entirely if no local messages or Google types need schemas.
-	// This avoids creating empty or import-only files.
-	if len(localMessages) == 0 && len(googleTypeMessages) == 0 {
-		return nil, nil
+// lastEncodedString returns the value of a singular string field number in an encoded
+// message, or "" if it is not set. The last value wins, as in proto parsing.
+func lastEncodedString(b []byte, number protowire.Number) string {
+	values := encodedBytes(b, number)
+	if len(values) == 0 {
+		return ""
 	}
+	return string(values[len(values)-1])
+}
 
-	// --- Create Output File ---
-	// Generate filename following the pattern: <original>_jsonschema.pb.go
-	filename := file.GeneratedFilenamePrefix + "_jsonschema.pb.go"
-	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+// getExternalMessages resolves the external_schemas parameter to the messages declared
+// in files outside the run whose schemas the referencing packages emit themselves: with
+// "inline" every such message, and with "auto" those the declaring file's options do not
+// target, since its package cannot define their helpers. It also returns a warning for
+// each message "auto" falls back on. Google types are always emitted by the referencing
+// packages.
+func (gr *Generator) getExternalMessages(gen *protogen.Plugin) (map[protoreflect.FullName]bool, []string) {
+	mode := gr.opts.ExternalSchemas
+	if mode != externalSchemasAuto && mode != externalSchemasInline {
+		return nil, nil
+	}
 
-	// Write file header with generation metadata.
-	// This helps identify generated files and track their source.
-	{
-		g.P("// Code generated by https://github.com/alis-exchange/protoc-gen-go-jsonschema. DO NOT EDIT.")
-		g.P("// ")
-		g.P(fmt.Sprintf("// Source: %s", file.Desc.Path()))
-		g.P(fmt.Sprintf("// Plugin version: %s", gr.Version))
-		g.P("// ")
-		g.P(fmt.Sprintf("// Generated on: %s UTC", time.Now().UTC().Format("2006-01-02 15:04:05")))
+	external := make(map[protoreflect.FullName]bool)
+	var warnings []string
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		for _, msg := range gr.getFileMessages(f) {
+			if gr.isGoogleType(msg) || external[msg.Desc.FullName()] {
+				continue
+			}
+			declaring := gen.FilesByPath[msg.Desc.ParentFile().Path()]
+			if declaring == nil || declaring.Generate {
+				continue
+			}
+			if mode == externalSchemasAuto {
+				if containsMessage(gr.getFileMessages(declaring), msg) {
+					continue
+				}
+				warnings = append(warnings, fmt.Sprintf(
+					"%s: %s does not generate the schema of message %s, so it is inlined into the schemas of this file's package",
+					f.Desc.Path(), declaring.Desc.Path(), msg.Desc.FullName()))
+			}
+			external[msg.Desc.FullName()] = true
+		}
 	}
+	return external, warnings
+}
 
-	// Write package declaration matching the proto's go_package option.
-	g.P()
-	g.P(fmt.Sprintf("package %s", file.GoPackageName))
+// externalMarker is the version marker of a proto file outside the run.
+type externalMarker struct {
+	// path is the path of the proto file.
+	path string
 
-	// Write imports. Additional imports may be added automatically by protogen
-	// when QualifiedGoIdent is used during code generation.
-	{
-		g.P("import (")
-		g.P("\"github.com/google/jsonschema-go/jsonschema\"")
-		g.P(")")
-		g.P()
+	// ident is the marker constant in the file's Go package.
+	ident protogen.GoIdent
+}
+
+// getExternalMarkers returns, for each generated file, the version markers of the files
+// outside the run whose schemas it references rather than inlines, in the order they are
+// first referenced. Only external_schemas=auto checks for them, since packages generated
+// before the markers were introduced lack them.
+func (gr *Generator) getExternalMarkers(gen *protogen.Plugin) map[string][]externalMarker {
+	if gr.opts.ExternalSchemas != externalSchemasAuto {
+		return nil
 	}
 
-	// --- Generate Message Schemas (LOCAL MESSAGES AND REFERENCED GOOGLE TYPES) ---
-	// Process each local message, creating a fresh MessageSchemaGenerator
-	// for each to ensure clean visited state tracking.
-	// Cross-package messages are referenced (not generated) via QualifiedGoIdent.
-	// Google types are generated as standalone functions in the file where they're referenced.
-	prefix := fileNamePrefix(file)
-	for _, msg := range localMessages {
-		sg := &MessageSchemaGenerator{
-			gr:         gr,
-			gen:        g,
-			visited:    make(map[string]bool),
-			filePrefix: prefix,
+	markers := make(map[string][]externalMarker)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
 		}
-		if err := sg.generateMessageJSONSchema(msg); err != nil {
-			return nil, err
+		seen := make(map[string]bool)
+		for _, msg := range gr.getFileMessages(f) {
+			declaring := gen.FilesByPath[msg.Desc.ParentFile().Path()]
+			if declaring == nil || declaring.Generate || gr.isStandaloneType(msg) || seen[declaring.Desc.Path()] {
+				continue
+			}
+			seen[declaring.Desc.Path()] = true
+			markers[f.Desc.Path()] = append(markers[f.Desc.Path()], externalMarker{
+				path:  declaring.Desc.Path(),
+				ident: declaring.GoImportPath.Ident(fileVersionMarker(declaring.Desc.Path())),
+			})
 		}
-		g.P()
 	}
+	return markers
+}
 
-	// Generate Google type schemas as standalone functions
-	for _, msg := range googleTypeMessages {
-		sg := &MessageSchemaGenerator{
-			gr:         gr,
-			gen:        g,
-			visited:    make(map[string]bool),
-			filePrefix: prefix,
+// getRequiredMessages determines which messages must have generated schema functions
+// for the files generated in this run to compile.
+//
+// A file references the _JsonSchema_WithDefs helper of every message it targets,
+// including dependencies forced from other files. A message declared in another file
+// of the run is emitted by that file (see getRequiredMessagesInFile). A message declared
+// in a file outside the run is only available if that file's own options target it
+// (and it was generated separately); otherwise the reference would be undefined, so an
+// error naming the message and both files is returned instead.
+func (gr *Generator) getRequiredMessages(gen *protogen.Plugin) (map[string]bool, error) {
+	required := make(map[string]bool)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
 		}
-		if err := sg.generateMessageJSONSchema(msg); err != nil {
-			return nil, err
+		for _, msg := range gr.getFileMessages(f) {
+			if gr.isStandaloneType(msg) {
+				continue
+			}
+			required[string(msg.Desc.FullName())] = true
+
+			declaring := gen.FilesByPath[msg.Desc.ParentFile().Path()]
+			if declaring == nil || declaring == f || declaring.Generate {
+				continue
+			}
+			if !containsMessage(gr.getFileMessages(declaring), msg) {
+				return nil, fmt.Errorf(
+					"%s: message %s is required by the schemas generated for this file, but %s does not generate its schema; "+
+						"add %s to the protoc run, set (alis.open.options.v1.message).json_schema.generate = true on %s or set external_schemas=auto",
+					f.Desc.Path(), msg.Desc.FullName(), declaring.Desc.Path(), declaring.Desc.Path(), msg.Desc.Name())
+			}
 		}
-		g.P()
 	}
+	return required, nil
+}
 
-	return g, nil
+// getRequiredMessagesInFile returns the messages declared in the given file (top-level
+// and nested) that other files in the run require, in declaration order.
+func (gr *Generator) getRequiredMessagesInFile(file *protogen.File) []*protogen.Message {
+	var results []*protogen.Message
+	var walk func(messages []*protogen.Message)
+	walk = func(messages []*protogen.Message) {
+		for _, msg := range messages {
+			if msg.Desc.IsMapEntry() {
+				continue
+			}
+			if gr.requiredMessages[string(msg.Desc.FullName())] {
+				results = append(results, msg)
+			}
+			walk(msg.Messages)
+		}
+	}
+	walk(file.Messages)
+	return results
+}
+
+// containsMessage reports whether messages contains msg.
+func containsMessage(messages []*protogen.Message, msg *protogen.Message) bool {
+	for _, m := range messages {
+		if m.Desc.FullName() == msg.Desc.FullName() {
+			return true
+		}
+	}
+	return false
+}
+
+// getSharedEnumsInFile returns the shared enums declared in the given file
+// (top-level and nested), in declaration order.
+func (gr *Generator) getSharedEnumsInFile(file *protogen.File) []*protogen.Enum {
+	var results []*protogen.Enum
+	var walk func(enums []*protogen.Enum, messages []*protogen.Message)
+	walk = func(enums []*protogen.Enum, messages []*protogen.Message) {
+		for _, enum := range enums {
+			if gr.sharedEnums[string(enum.Desc.FullName())] {
+				results = append(results, enum)
+			}
+		}
+		for _, msg := range messages {
+			walk(msg.Enums, msg.Messages)
+		}
+	}
+	walk(file.Enums, file.Messages)
+	return results
 }
 
 // getMessages recursively collects all messages that should generate JSON Schema code.
@@ -313,24 +2927,19 @@ func (gr *Generator) getMessagesWithForce(messages []*protogen.Message, defaultG
 				// We force 'true' here because dependencies are required regardless
 				// of their own options.
 				for _, field := range message.Fields {
-					if field.Desc.Kind() == protoreflect.MessageKind {
-						// For map fields, we need to collect the value type, not the synthetic map entry
-						if field.Desc.IsMap() {
-							mapValue := field.Desc.MapValue()
-							if mapValue.Kind() == protoreflect.MessageKind {
-								// Find the value message from the synthetic map entry
-								for _, f := range field.Message.Fields {
-									if f.Desc.Number() == 2 && f.Message != nil { // Field 2 is the value
-										depMessages := gr.getMessagesWithForce([]*protogen.Message{f.Message}, true, true, visited)
-										results = append(results, depMessages...)
-										break
-									}
-								}
-							}
-						} else {
-							depMessages := gr.getMessagesWithForce([]*protogen.Message{field.Message}, true, true, visited)
-							results = append(results, depMessages...)
-						}
+					// Any fields restricted by any_types reference the allowed messages instead.
+					if allowed := gr.anyTypes[field.Desc.FullName()]; len(allowed) > 0 {
+						depMessages := gr.getMessagesWithForce(allowed, true, true, visited)
+						results = append(results, depMessages...)
+						continue
+					}
+					// For map fields, fieldMessage returns the value type, not the synthetic map
+					// entry. The value's own dependencies and nested messages are forced in
+					// turn, including when it is declared in another package: its declaring file
+					// then emits it as a required message (see getRequiredMessages).
+					if dep := fieldMessage(field); dep != nil && !gr.isInlinedField(field, dep) {
+						depMessages := gr.getMessagesWithForce([]*protogen.Message{dep}, true, true, visited)
+						results = append(results, depMessages...)
 					}
 				}
 			}
@@ -373,16 +2982,34 @@ type MessageSchemaGenerator struct {
 
 	// gen is the output file writer where generated Go code is written.
 	// All schema code for this message (and its dependencies) is written here.
-	gen *protogen.GeneratedFile
+	gen codeWriter
 
 	// visited tracks which messages have already been processed to prevent
 	// infinite recursion with circular message references and to avoid
 	// generating duplicate schema definitions.
 	visited map[string]bool
 
-	// filePrefix is used to generate unique Google type function names when multiple
-	// files in the same package import the same Google types. Derived from the proto file name.
-	filePrefix string
+	// file is the proto file being generated. Google type helper names are resolved
+	// against its Go package in the run's googleHelpers registry.
+	file *protogen.File
+
+	// err records the first error found while building references (e.g. an import
+	// alias that generated code would shadow). Returned by generateMessageJSONSchema.
+	err error
+
+	// inferredFormats lists the formats inferred from field names with infer_formats, as
+	// "<field full name>: <format>", in emission order.
+	inferredFormats []string
+
+	// references, when not nil, records the shared definitions the generated code
+	// references, keyed by full name (see cachedDefinitions).
+	references map[protoreflect.FullName]definition
+}
+
+// definition is a message or an enum with a shared definition in $defs.
+type definition struct {
+	message *protogen.Message
+	enum    *protogen.Enum
 }
 
 // schemaFieldConfig holds configuration for generating a JSON Schema field.
@@ -407,6 +3034,18 @@ type schemaFieldConfig struct {
 	// description is the schema description, derived from proto comments.
 	description string
 
+	// types is a union of JSON Schema types (e.g., ["string", "null"]) emitted instead of
+	// typeName, which then holds the union's primary type.
+	types []string
+
+	// anyTypes lists the messages a google.protobuf.Any value may hold (the any_types
+	// parameter), emitted by emitAnyTypes.
+	anyTypes []anyType
+
+	// anyType indicates the value may be any JSON value, so no type keyword is emitted
+	// (google.protobuf.Value).
+	anyType bool
+
 	// typeName is the JSON Schema type (e.g., "string", "object", "array").
 	// Empty when using a $ref to another schema.
 	typeName string
@@ -418,6 +3057,10 @@ type schemaFieldConfig struct {
 	// Used for custom field patterns.
 	pattern string
 
+	// minimum and maximum are inclusive numeric bounds, used unless the field options set
+	// bounds (canonical google.type constraints).
+	minimum, maximum *float64
+
 	// propertyNamesPattern is a regex pattern for validating map keys.
 	// Used when map keys are integers or booleans (serialized as strings in JSON).
 	propertyNamesPattern string
@@ -425,6 +3068,23 @@ type schemaFieldConfig struct {
 	// enumValues contains the allowed integer values for enum fields.
 	enumValues []int32
 
+	// enumNames contains the allowed value names for enum fields when the enum_names
+	// parameter is set. When set, it is emitted instead of enumValues.
+	enumNames []string
+
+	// enumOneOf contains one const branch per enum value when the enum_oneof parameter is
+	// set. When set, it is emitted as oneOf instead of enumNames or enumValues.
+	enumOneOf []enumConst
+
+	// enumAliases lists the alternative strings accepted for enum values (the enum_alias
+	// parameter). When set, the enum values are emitted by emitEnumAliases.
+	enumAliases []enumAlias
+
+	// enumRef is the Go function call that registers a shared enum definition
+	// and returns its $ref (e.g., "UserStatus_JsonSchema_WithDefs(defs)").
+	// When set, it is emitted instead of the inline enumValues list.
+	enumRef string
+
 	// isBytes indicates if the field is a bytes type, requiring base64 contentEncoding.
 	isBytes bool
 
@@ -433,6 +3093,15 @@ type schemaFieldConfig struct {
 	// or fully qualified for cross-package references.
 	messageRef string
 
+	// nullable indicates the field also accepts null (nullable_optional on an optional
+	// field). Only set on the field's own schema, never on nested element schemas.
+	nullable bool
+
+	// rules holds the keywords translated from buf.validate.field rules, used where the
+	// field options do not set the keyword. On nested configs, the rules of the items or
+	// map values.
+	rules *validateRules
+
 	// nested holds the schema configuration for container element types:
 	//   - For arrays (repeated fields): describes the Items schema
 	//   - For maps: describes the AdditionalProperties schema (map values)
@@ -462,14 +3131,19 @@ type schemaFieldConfig struct {
 //   - Value constraints: format, pattern, contentEncoding, min/max, minLength/maxLength
 func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *protogen.Field) {
 	opts := getFieldJsonSchemaOptions(field)
+	behaviors := fieldBehaviors(field)
+	identifier := sg.gr.isIdentifier(field)
+	readOnly := slices.Contains(behaviors, fieldBehaviorOutputOnly) || identifier
+	writeOnly := slices.Contains(behaviors, fieldBehaviorInputOnly)
+	deprecated := isDeprecated(field.Desc)
 
 	// --- Optimization: Direct Message Reference ---
-	// If this is a simple message reference with no custom options, we can emit
-	// a direct function call instead of creating a new schema object.
+	// If this is a simple message reference with no custom options or annotations, we
+	// can emit a direct function call instead of creating a new schema object.
 	// This produces cleaner generated code like: schema.Properties["user"] = User_JsonSchema_WithDefs(defs)
 	{
 		if cfg.messageRef != "" && cfg.typeName == "" && cfg.nested == nil {
-			if opts == nil {
+			if opts == nil && !sg.gr.opts.PresenceMetadata && !cfg.nullable && !readOnly && !writeOnly && !deprecated && len(sg.gr.extensions[field.Desc.FullName()]) == 0 && sg.gr.consts[field.Desc.FullName()] == "" {
 				sg.gen.P(fmt.Sprintf(`schema.Properties["%s"] = %s`, cfg.fieldName, cfg.messageRef))
 				return
 			}
@@ -479,11 +3153,24 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 	// --- Begin Schema Object ---
 	sg.gen.P(fmt.Sprintf(`schema.Properties["%s"] = &jsonschema.Schema{`, cfg.fieldName))
 
-	// Emit type if specified (not set for pure $ref schemas).
-	if cfg.typeName != "" {
+	// Emit type if specified (not set for pure $ref schemas). Nullable fields add null to
+	// the type, except for shared enums, whose type comes with the reference.
+	switch {
+	case len(cfg.types) > 0:
+		sg.emitTypes(cfg.types)
+	case cfg.nullable && cfg.enumRef != "":
+		// The type comes with the reference, in an anyOf with null.
+	case cfg.nullable && cfg.typeName != "":
+		sg.emitTypes([]string{cfg.typeName, jsNull})
+	case cfg.typeName != "":
 		sg.gen.P(fmt.Sprintf(`Type: "%s",`, cfg.typeName))
 	}
 
+	// Message references with options keep the reference next to the option keywords.
+	if cfg.messageRef != "" && cfg.nested == nil {
+		sg.emitRef(cfg.messageRef, cfg.nullable)
+	}
+
 	// --- Metadata Fields ---
 	// Title and description from proto comments, with option overrides.
 	{
@@ -497,40 +3184,114 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 		if opts.GetDescription() != "" {
 			desc = opts.GetDescription()
 		}
+		// Enums emitted inline have no definition to carry their values' descriptions.
+		if enum := fieldEnum(field); enum != nil && sg.enumReferenceName(enum) == "" {
+			desc = sg.gr.withEnumValueDescriptions(desc, enum.Desc)
+		}
 		sg.gen.P(fmt.Sprintf(`Description: "%s",`, sg.gr.escapeGoString(desc)))
 	}
 
+	// Server-managed (OUTPUT_ONLY and identifier) and request-only (INPUT_ONLY) fields,
+	// from google.api.field_behavior and the identifier parameter.
+	if readOnly {
+		sg.gen.P(`ReadOnly: true,`)
+	}
+	if writeOnly {
+		sg.gen.P(`WriteOnly: true,`)
+	}
+	if deprecated {
+		sg.gen.P(`Deprecated: true,`)
+	}
+
+	// Default values from the default parameter or the explicit defaults of proto2 and
+	// editions fields, as raw string literals where the JSON text allows.
+	if value, ok := sg.gr.fieldDefault(field); ok {
+		literal := strconv.Quote(value)
+		if strconv.CanBackquote(value) {
+			literal = "`" + value + "`"
+		}
+		sg.gen.P(fmt.Sprintf(`Default: %s(%s),`, sg.gen.QualifiedGoIdent(protogen.GoImportPath("encoding/json").Ident("RawMessage")), literal))
+	}
+
+	// The const parameter fixes repeated and map fields as a whole, on the container
+	// schema; singular fields get theirs with the value constraints.
+	paramConst, hasParamConst := sg.gr.consts[field.Desc.FullName()]
+	if hasParamConst && (field.Desc.IsList() || field.Desc.IsMap()) {
+		if cfg.nullable {
+			sg.gen.P(fmt.Sprintf(`Enum: []any{%s, nil},`, paramConst))
+		} else {
+			sg.gen.P(fmt.Sprintf(`Const: jsonschema.Ptr[any](%s),`, paramConst))
+		}
+	}
+
+	if len(cfg.anyTypes) > 0 {
+		sg.emitAnyTypes(cfg.anyTypes, cfg.nullable)
+	}
+
 	// --- Container Constraints ---
 	// These apply to the root schema for arrays (minItems, maxItems, uniqueItems)
-	// and maps (minProperties, maxProperties).
+	// and maps (minProperties, maxProperties), from the options or else the
+	// buf.validate repeated and map rules.
 	{
-		if opts.GetMinItems() != 0 {
+		rules := cfg.rules
+		if rules == nil {
+			rules = &validateRules{}
+		}
+		switch {
+		case opts.GetMinItems() != 0:
 			sg.gen.P(fmt.Sprintf(`MinItems: &[]int{%d}[0],`, opts.GetMinItems()))
+		case rules.minItems != nil:
+			sg.gen.P(fmt.Sprintf(`MinItems: &[]int{%d}[0],`, *rules.minItems))
 		}
-		if opts.GetMaxItems() != 0 {
+		switch {
+		case opts.GetMaxItems() != 0:
 			sg.gen.P(fmt.Sprintf(`MaxItems: &[]int{%d}[0],`, opts.GetMaxItems()))
+		case rules.maxItems != nil:
+			sg.gen.P(fmt.Sprintf(`MaxItems: &[]int{%d}[0],`, *rules.maxItems))
 		}
-		if opts.GetUniqueItems() {
+		if opts.GetUniqueItems() || rules.uniqueItems {
 			sg.gen.P(`UniqueItems: true,`)
 		}
-		if opts.GetMinProperties() != 0 {
+		switch {
+		case opts.GetMinProperties() != 0:
 			sg.gen.P(fmt.Sprintf(`MinProperties: &[]int{%d}[0],`, opts.GetMinProperties()))
+		case rules.minProperties != nil:
+			sg.gen.P(fmt.Sprintf(`MinProperties: &[]int{%d}[0],`, *rules.minProperties))
 		}
-		if opts.GetMaxProperties() != 0 {
+		switch {
+		case opts.GetMaxProperties() != 0:
 			sg.gen.P(fmt.Sprintf(`MaxProperties: &[]int{%d}[0],`, opts.GetMaxProperties()))
+		case rules.maxProperties != nil:
+			sg.gen.P(fmt.Sprintf(`MaxProperties: &[]int{%d}[0],`, *rules.maxProperties))
 		}
 	}
 
 	// emitValueConstraints is a closure that generates value-level validation constraints.
 	// It's used for both root schemas (scalar fields) and nested schemas (array items, map values).
-	// The closure captures 'opts' to allow option overrides at the appropriate level.
+	// The closure captures 'opts' to allow option overrides at the appropriate level; the
+	// buf.validate rules of the config (c.rules) apply where the options set no keyword.
+	singularConst := hasParamConst && !field.Desc.IsList() && !field.Desc.IsMap()
 	emitValueConstraints := func(c schemaFieldConfig) {
+		rules := c.rules
+		if rules == nil {
+			rules = &validateRules{}
+		}
+
 		// --- String Format ---
 		// Semantic validation hint (e.g., "date-time", "email", "uri").
 		{
 			format := c.format
 			if opts.GetFormat() != "" {
 				format = opts.GetFormat()
+			} else if rules.format != "" {
+				format = rules.format
+			} else if format == "" && sg.gr.opts.InferFormats {
+				if format = inferFormat(field); format != "" {
+					inferred := fmt.Sprintf("%s: %s", field.Desc.FullName(), format)
+					if !slices.Contains(sg.inferredFormats, inferred) {
+						sg.inferredFormats = append(sg.inferredFormats, inferred)
+					}
+				}
 			}
 			if format != "" {
 				sg.gen.P(fmt.Sprintf(`Format: "%s",`, sg.gr.escapeGoString(format)))
@@ -538,11 +3299,19 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 		}
 
 		// --- String Pattern ---
-		// Regex pattern for string validation.
+		// Regex pattern for string validation. Digest format presets expand into
+		// exact-length patterns, and bytes fields with a non-default content
+		// encoding get the encoding's alphabet as their pattern.
 		{
 			pattern := c.pattern
 			if opts.GetPattern() != "" {
 				pattern = opts.GetPattern()
+			} else if rules.pattern != "" {
+				pattern = rules.pattern
+			} else if preset, ok := digestFormatPatterns[opts.GetFormat()]; ok && pattern == "" {
+				pattern = preset
+			} else if c.isBytes && pattern == "" {
+				pattern = bytesEncodingPatterns[opts.GetContentEncoding()]
 			}
 			if pattern != "" {
 				sg.gen.P(fmt.Sprintf(`Pattern: "%s",`, sg.gr.escapeGoString(pattern)))
@@ -586,6 +3355,12 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 				sg.gen.P(fmt.Sprintf(`ExclusiveMinimum: &[]float64{%g}[0],`, minVal))
 			case minVal != 0:
 				sg.gen.P(fmt.Sprintf(`Minimum: &[]float64{%g}[0],`, minVal))
+			case rules.exclusiveMinimum != nil:
+				sg.gen.P(fmt.Sprintf(`ExclusiveMinimum: &[]float64{%g}[0],`, *rules.exclusiveMinimum))
+			case rules.minimum != nil:
+				sg.gen.P(fmt.Sprintf(`Minimum: &[]float64{%g}[0],`, *rules.minimum))
+			case c.minimum != nil:
+				sg.gen.P(fmt.Sprintf(`Minimum: &[]float64{%g}[0],`, *c.minimum))
 			}
 
 			switch {
@@ -593,27 +3368,122 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 				sg.gen.P(fmt.Sprintf(`ExclusiveMaximum: &[]float64{%g}[0],`, maxVal))
 			case maxVal != 0:
 				sg.gen.P(fmt.Sprintf(`Maximum: &[]float64{%g}[0],`, maxVal))
+			case rules.exclusiveMaximum != nil:
+				sg.gen.P(fmt.Sprintf(`ExclusiveMaximum: &[]float64{%g}[0],`, *rules.exclusiveMaximum))
+			case rules.maximum != nil:
+				sg.gen.P(fmt.Sprintf(`Maximum: &[]float64{%g}[0],`, *rules.maximum))
+			case c.maximum != nil:
+				sg.gen.P(fmt.Sprintf(`Maximum: &[]float64{%g}[0],`, *c.maximum))
 			}
 		}
 
+		// --- Multiple Of ---
+		// The step the multiple_of parameter sets for the field's numbers.
+		if step, ok := sg.gr.multipleOfs[field.Desc.FullName()]; ok {
+			sg.gen.P(fmt.Sprintf(`MultipleOf: &[]float64{%g}[0],`, step))
+		}
+
 		// --- String Length Constraints ---
 		{
-			if opts.GetMinLength() != 0 {
+			switch {
+			case opts.GetMinLength() != 0:
 				sg.gen.P(fmt.Sprintf(`MinLength: &[]int{%d}[0],`, opts.GetMinLength()))
+			case rules.minLength != nil:
+				sg.gen.P(fmt.Sprintf(`MinLength: &[]int{%d}[0],`, *rules.minLength))
 			}
-			if opts.GetMaxLength() != 0 {
+			switch {
+			case opts.GetMaxLength() != 0:
 				sg.gen.P(fmt.Sprintf(`MaxLength: &[]int{%d}[0],`, opts.GetMaxLength()))
+			case rules.maxLength != nil:
+				sg.gen.P(fmt.Sprintf(`MaxLength: &[]int{%d}[0],`, *rules.maxLength))
 			}
 		}
 
 		// --- Enum Values ---
-		// For enum fields, emit the allowed values.
-		if len(c.enumValues) > 0 {
+		// For enum fields, reference the shared enum definition when available,
+		// otherwise emit the allowed values inline. Values the allowed_values parameter, or
+		// else the enum in/not_in rules, exclude are left out of inline lists.
+		allowed := sg.gr.enumSubset(rules, fieldEnum(field))
+		if numbers, ok := sg.gr.allowedValues[field.Desc.FullName()]; ok {
+			allowed = sg.gr.enumLiterals(fieldEnum(field), func(number int32) bool { return slices.Contains(numbers, number) })
+		}
+		if singularConst && c.nullable && fieldEnum(field) != nil {
+			// The const parameter narrows nullable enums to its value and null.
+			allowed = []string{paramConst}
+		}
+		keep := func(literal string) bool { return allowed == nil || slices.Contains(allowed, literal) }
+		if len(c.enumAliases) > 0 {
+			sg.emitEnumAliases(c)
+		} else if c.enumRef != "" {
+			sg.emitRef(c.enumRef, c.nullable)
+		} else if len(c.enumOneOf) > 0 {
+			sg.emitEnumOneOf(c.enumOneOf, c.nullable)
+		} else if len(c.enumNames) > 0 {
+			sg.gen.P(`Enum: []any{`)
+			for _, name := range c.enumNames {
+				if keep(strconv.Quote(name)) {
+					sg.gen.P(fmt.Sprintf(`%q,`, name))
+				}
+			}
+			if c.nullable {
+				sg.gen.P(`nil,`)
+			}
+			sg.gen.P(`},`)
+		} else if len(c.enumValues) > 0 {
 			sg.gen.P(`Enum: []any{`)
 			for _, enumValue := range c.enumValues {
-				sg.gen.P(fmt.Sprintf(`%d,`, enumValue))
+				if keep(strconv.Itoa(int(enumValue))) {
+					sg.gen.P(fmt.Sprintf(`%d,`, enumValue))
+				}
+			}
+			if c.nullable {
+				sg.gen.P(`nil,`)
 			}
 			sg.gen.P(`},`)
+		} else if len(rules.enum) > 0 {
+			// String in rules.
+			sg.gen.P(`Enum: []any{`)
+			for _, value := range rules.enum {
+				sg.gen.P(fmt.Sprintf(`"%s",`, sg.gr.escapeGoString(value)))
+			}
+			if c.nullable {
+				sg.gen.P(`nil,`)
+			}
+			sg.gen.P(`},`)
+		}
+
+		// A shared definition, oneOf or alias union keeps every value, so the subset is an
+		// Enum next to it, with the aliases of the allowed values.
+		if allowed != nil && (len(c.enumAliases) > 0 || c.enumRef != "" || len(c.enumOneOf) > 0) {
+			literals := slices.Clone(allowed)
+			for _, a := range c.enumAliases {
+				if keep(strconv.Quote(a.name)) {
+					for _, alias := range a.aliases {
+						literals = append(literals, strconv.Quote(alias))
+					}
+				}
+			}
+			if c.nullable {
+				literals = append(literals, "nil")
+			}
+			sg.gen.P(fmt.Sprintf(`Enum: []any{%s},`, strings.Join(literals, ", ")))
+		}
+
+		// --- Constant Value ---
+		// The value the const parameter, or else const rules, fix the field to. Nullable
+		// fields also accept null, as an enum, except enum fields, whose Enum keyword
+		// lists their values.
+		literal, enumConst := sg.gr.constLiteral(rules, fieldEnum(field)), rules.enumConst != nil
+		if singularConst {
+			literal, enumConst = paramConst, fieldEnum(field) != nil
+		}
+		if literal != "" {
+			switch {
+			case !c.nullable:
+				sg.gen.P(fmt.Sprintf(`Const: jsonschema.Ptr[any](%s),`, literal))
+			case !enumConst:
+				sg.gen.P(fmt.Sprintf(`Enum: []any{%s, nil},`, literal))
+			}
 		}
 	}
 
@@ -626,7 +3496,21 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 			targetField = "AdditionalProperties"
 		}
 
-		if cfg.nested.messageRef != "" {
+		// Extension keywords targeting the elements or values (extension parameter), and
+		// the bounds of Timestamp elements or values (timestamp_bounds parameter).
+		elementExtra := sg.gr.elementExtensions[field.Desc.FullName()]
+		if bounds := sg.gr.timestampBounds[field.Desc.FullName()]; len(bounds) > 0 {
+			elementExtra = maps.Clone(elementExtra)
+			if elementExtra == nil {
+				elementExtra = make(map[string]any)
+			}
+			maps.Copy(elementExtra, bounds)
+		}
+
+		if cfg.nested.messageRef != "" && len(elementExtra) > 0 {
+			// Message reference with keywords: reference the message's schema next to them.
+			sg.gen.P(fmt.Sprintf(`%s: &jsonschema.Schema{Ref: %s.Ref, Extra: %s},`, targetField, cfg.nested.messageRef, goLiteral(elementExtra)))
+		} else if cfg.nested.messageRef != "" {
 			// Message reference: emit direct function call for the nested schema.
 			sg.gen.P(fmt.Sprintf(`%s: %s,`, targetField, cfg.nested.messageRef))
 		} else {
@@ -634,15 +3518,23 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 			sg.gen.P(fmt.Sprintf(`%s: &jsonschema.Schema{`, targetField))
 
 			// Emit type for the nested schema.
-			if cfg.nested.typeName != "" {
+			if len(cfg.nested.types) > 0 {
+				sg.emitTypes(cfg.nested.types)
+			} else if cfg.nested.typeName != "" {
 				sg.gen.P(fmt.Sprintf(`Type: "%s",`, cfg.nested.typeName))
-			} else if cfg.nested.nested == nil {
+			} else if cfg.nested.nested == nil && !cfg.nested.anyType {
 				// Fallback for external types without explicit type info (e.g., google.type.LatLng).
 				sg.gen.P(`Type: "object",`)
 			}
 
 			// Apply value constraints to the nested element schema.
 			emitValueConstraints(*cfg.nested)
+			if len(cfg.nested.anyTypes) > 0 {
+				sg.emitAnyTypes(cfg.nested.anyTypes, false)
+			}
+			if len(elementExtra) > 0 {
+				sg.gen.P(fmt.Sprintf(`Extra: %s,`, goLiteral(elementExtra)))
+			}
 
 			sg.gen.P(`},`)
 		}
@@ -661,6 +3553,38 @@ func (sg *MessageSchemaGenerator) emitSchemaField(cfg schemaFieldConfig, field *
 		sg.gen.P(`},`)
 	}
 
+	// --- Extension Keywords ---
+	// With presence_metadata, whether an absent property means unset or the default; with
+	// coerce, the JSON types a gateway may convert to the field's type; identifier fields
+	// and output-only fields holding resources, which the input profiles leave out; the
+	// bounds of singular Timestamp fields; and the keywords of the extension parameter.
+	extra := make(map[string]any)
+	if sg.gr.opts.PresenceMetadata {
+		presence := "implicit"
+		if field.Desc.HasPresence() {
+			presence = "explicit"
+		}
+		extra["x-proto-presence"] = presence
+	}
+	if coerce := sg.gr.coercions[field.Desc.FullName()]; len(coerce) > 0 {
+		extra["x-coerce"] = coerce
+	}
+	if identifier {
+		extra["x-identifier"] = true
+	}
+	if slices.Contains(behaviors, fieldBehaviorOutputOnly) && isResourceField(field) {
+		extra["x-output-only-resource"] = true
+	}
+	if cfg.nested == nil {
+		maps.Copy(extra, sg.gr.timestampBounds[field.Desc.FullName()])
+	}
+	for keyword, value := range sg.gr.extensions[field.Desc.FullName()] {
+		extra[keyword] = value
+	}
+	if len(extra) > 0 {
+		sg.gen.P(fmt.Sprintf(`Extra: %s,`, goLiteral(extra)))
+	}
+
 	sg.gen.P("}")
 }
 
@@ -686,31 +3610,39 @@ func (sg *MessageSchemaGenerator) getArraySchemaConfig(field *protogen.Field, ti
 	kindTypeName, _ := sg.getKindTypeName(field.Desc)
 
 	cfg := schemaFieldConfig{
-		fieldName:   getFieldName(field),
+		fieldName:   sg.gr.getFieldName(field),
 		title:       title,
 		description: description,
 		typeName:    jsArray,
+		rules:       sg.gr.validation[field.Desc.FullName()],
 	}
 
 	// Create the nested config based on the element type.
 	switch field.Desc.Kind() {
-	case protoreflect.MessageKind:
-		// Message elements: delegate to getMessageSchemaConfig for Google type handling or reference.
-		nestedCfg := sg.getMessageSchemaConfig(field.Message)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		// Message elements: delegate to getFieldMessageSchemaConfig for Google type handling or reference.
+		nestedCfg := sg.getFieldMessageSchemaConfig(field, field.Message)
 		cfg.nested = &nestedCfg
 
 	case protoreflect.EnumKind:
 		// Enum elements: integer type with allowed values.
-		cfg.nested = &schemaFieldConfig{typeName: kindTypeName, enumValues: sg.getEnumValues(field)}
+		cfg.nested = &schemaFieldConfig{typeName: kindTypeName, enumValues: sg.getEnumValues(field), enumNames: sg.getEnumNames(field.Enum.Desc), enumOneOf: sg.getEnumOneOf(field.Enum.Desc), enumRef: sg.enumReferenceName(field.Enum), enumAliases: sg.gr.enumAliases[field.Desc.FullName()]}
 
 	case protoreflect.BytesKind:
 		// Bytes elements: string type with base64 encoding.
 		cfg.nested = &schemaFieldConfig{typeName: kindTypeName, isBytes: true}
 
+	case protoreflect.StringKind:
+		// String elements: the referenced resource's name pattern for resource name fields.
+		cfg.nested = &schemaFieldConfig{typeName: kindTypeName, pattern: sg.gr.resourcePatterns[field.Desc.FullName()]}
+
 	default:
 		// All other scalar types (including 64-bit integers): use the direct JSON Schema type mapping.
 		cfg.nested = &schemaFieldConfig{typeName: kindTypeName}
 	}
+	if cfg.rules != nil {
+		cfg.nested.rules = cfg.rules.items
+	}
 
 	return cfg
 }
@@ -729,10 +3661,11 @@ func (sg *MessageSchemaGenerator) getArraySchemaConfig(field *protogen.Field, ti
 // Value handling mirrors getArraySchemaConfig for consistency.
 func (sg *MessageSchemaGenerator) getMapSchemaConfig(field *protogen.Field, title, description string) schemaFieldConfig {
 	cfg := schemaFieldConfig{
-		fieldName:   getFieldName(field),
+		fieldName:   sg.gr.getFieldName(field),
 		title:       title,
 		description: description,
 		typeName:    jsObject,
+		rules:       sg.gr.validation[field.Desc.FullName()],
 	}
 
 	mapValue := field.Desc.MapValue()
@@ -761,7 +3694,7 @@ func (sg *MessageSchemaGenerator) getMapSchemaConfig(field *protogen.Field, titl
 	kindTypeName, _ := sg.getKindTypeName(mapValue)
 
 	switch mapValue.Kind() {
-	case protoreflect.MessageKind:
+	case protoreflect.MessageKind, protoreflect.GroupKind:
 		// Message values: find the value message from the synthetic map entry.
 		// Map fields are represented as repeated synthetic messages with key (field 1)
 		// and value (field 2) fields.
@@ -773,13 +3706,13 @@ func (sg *MessageSchemaGenerator) getMapSchemaConfig(field *protogen.Field, titl
 			}
 		}
 		if valMsg != nil {
-			nestedCfg := sg.getMessageSchemaConfig(valMsg)
+			nestedCfg := sg.getFieldMessageSchemaConfig(field, valMsg)
 			cfg.nested = &nestedCfg
 		}
 
 	case protoreflect.EnumKind:
 		// Enum values: use descriptor-based enum extraction (no field context available).
-		cfg.nested = &schemaFieldConfig{typeName: kindTypeName, enumValues: sg.getEnumValuesFromDescriptor(mapValue.Enum())}
+		cfg.nested = &schemaFieldConfig{typeName: kindTypeName, enumValues: sg.getEnumValuesFromDescriptor(mapValue.Enum()), enumNames: sg.getEnumNames(mapValue.Enum()), enumOneOf: sg.getEnumOneOf(mapValue.Enum()), enumRef: sg.enumReferenceName(fieldEnum(field)), enumAliases: sg.gr.enumAliases[field.Desc.FullName()]}
 
 	case protoreflect.BytesKind:
 		// Bytes values: string type with base64 encoding.
@@ -789,6 +3722,9 @@ func (sg *MessageSchemaGenerator) getMapSchemaConfig(field *protogen.Field, titl
 		// All other scalar types (including 64-bit integers): direct JSON Schema type mapping.
 		cfg.nested = &schemaFieldConfig{typeName: kindTypeName}
 	}
+	if cfg.rules != nil && cfg.nested != nil {
+		cfg.nested.rules = cfg.rules.items
+	}
 
 	return cfg
 }
@@ -807,21 +3743,26 @@ func (sg *MessageSchemaGenerator) getScalarSchemaConfig(field *protogen.Field, t
 	kindTypeName, _ := sg.getKindTypeName(field.Desc)
 
 	cfg := schemaFieldConfig{
-		fieldName:   getFieldName(field),
+		fieldName:   sg.gr.getFieldName(field),
 		title:       title,
 		description: description,
 		typeName:    kindTypeName,
+		rules:       sg.gr.validation[field.Desc.FullName()],
 	}
 
 	switch field.Desc.Kind() {
-	case protoreflect.MessageKind:
+	case protoreflect.MessageKind, protoreflect.GroupKind:
 		// For message fields, get the config from getMessageSchemaConfig and merge it.
 		// This handles Google types (returning inline schemas) and user messages (returning refs).
-		nestedCfg := sg.getMessageSchemaConfig(field.Message)
+		nestedCfg := sg.getFieldMessageSchemaConfig(field, field.Message)
+		cfg.types = nestedCfg.types
+		cfg.anyType = nestedCfg.anyType
 		cfg.typeName = nestedCfg.typeName
+		cfg.isBytes = nestedCfg.isBytes
 		cfg.format = nestedCfg.format
 		cfg.pattern = nestedCfg.pattern
 		cfg.messageRef = nestedCfg.messageRef
+		cfg.anyTypes = nestedCfg.anyTypes
 		cfg.nested = nestedCfg.nested
 		// Inherit description from message schema if not set on field.
 		if cfg.description == "" && nestedCfg.description != "" {
@@ -829,12 +3770,26 @@ func (sg *MessageSchemaGenerator) getScalarSchemaConfig(field *protogen.Field, t
 		}
 
 	case protoreflect.EnumKind:
-		// Enum fields: add the allowed integer values.
+		// Enum fields: add the allowed integer values (or a reference to the shared definition).
 		cfg.enumValues = sg.getEnumValues(field)
+		cfg.enumNames = sg.getEnumNames(field.Enum.Desc)
+		cfg.enumOneOf = sg.getEnumOneOf(field.Enum.Desc)
+		cfg.enumRef = sg.enumReferenceName(field.Enum)
+		cfg.enumAliases = sg.gr.enumAliases[field.Desc.FullName()]
 
 	case protoreflect.BytesKind:
 		// Bytes fields: flag for base64 encoding.
 		cfg.isBytes = true
+
+	case protoreflect.StringKind:
+		// Resource name fields: the referenced resource's name pattern.
+		cfg.pattern = sg.gr.resourcePatterns[field.Desc.FullName()]
+	}
+
+	// Fields of google.type messages get their canonical constraints.
+	if c, ok := googleTypeConstraints[field.Desc.FullName()]; ok {
+		cfg.minimum, cfg.maximum = c.minimum, c.maximum
+		cfg.pattern = c.pattern
 	}
 
 	return cfg
@@ -842,22 +3797,104 @@ func (sg *MessageSchemaGenerator) getScalarSchemaConfig(field *protogen.Field, t
 
 // getMessageSchemaConfig creates a schema configuration for message-type fields.
 //
-// All messages (including Google types) are handled as references to schema generation functions.
+// Messages are handled as references to schema generation functions, except for
+// Google types that plugin options map to an inline schema (see isInlinedMessage).
 func (sg *MessageSchemaGenerator) getMessageSchemaConfig(msg *protogen.Message) schemaFieldConfig {
+	if sg.gr.isInlinedMessage(msg) {
+		return sg.getInlinedMessageSchemaConfig(msg)
+	}
+
 	// Return a reference to the message's schema generation function.
 	return schemaFieldConfig{messageRef: sg.referenceName(msg)}
 }
 
+// getFieldMessageSchemaConfig returns the schema configuration for the values of a
+// message-typed field, where msg is the field's message (or map value message). Duration
+// fields listed by the duration_seconds parameter are numbers, and Any fields restricted
+// by the any_types parameter are objects holding one of the allowed messages; other
+// messages are handled by getMessageSchemaConfig.
+func (sg *MessageSchemaGenerator) getFieldMessageSchemaConfig(field *protogen.Field, msg *protogen.Message) schemaFieldConfig {
+	if sg.gr.durationSeconds[field.Desc.FullName()] {
+		return durationSecondsConfig
+	}
+	allowed := sg.gr.anyTypes[field.Desc.FullName()]
+	if len(allowed) == 0 {
+		return sg.getMessageSchemaConfig(msg)
+	}
+	cfg := schemaFieldConfig{typeName: jsObject}
+	for _, m := range allowed {
+		cfg.anyTypes = append(cfg.anyTypes, anyType{typeURL: anyTypeURLPrefix + string(m.Desc.FullName()), ref: sg.referenceName(m)})
+	}
+	return cfg
+}
+
+// durationSecondsConfig is the inline schema configuration of Duration values under the
+// duration_seconds parameter: a number of seconds, e.g. 3.5.
+var durationSecondsConfig = schemaFieldConfig{typeName: jsNumber, description: "Duration in seconds."}
+
+// getInlinedMessageSchemaConfig returns the inline schema configuration for a message
+// that isInlinedMessage reports as inlined.
+func (sg *MessageSchemaGenerator) getInlinedMessageSchemaConfig(msg *protogen.Message) schemaFieldConfig {
+	switch msg.Desc.FullName() {
+	case "google.protobuf.Duration":
+		if sg.gr.opts.allDurationSeconds() {
+			return durationSecondsConfig
+		}
+		// protojson: seconds with up to 9 fractional digits and an "s" suffix, e.g. "3.5s".
+		return schemaFieldConfig{typeName: jsString, pattern: durationPattern, description: `Duration in seconds with an "s" suffix, e.g. "3.5s".`}
+	case "google.protobuf.Timestamp":
+		// protojson: an RFC 3339 date-time in UTC, e.g. "2017-01-15T01:30:15.01Z".
+		cfg := schemaFieldConfig{typeName: jsString, format: "date-time", description: `RFC 3339 timestamp, e.g. "2017-01-15T01:30:15.01Z".`}
+		if sg.gr.opts.TimestampUTC {
+			cfg.pattern = timestampUTCPattern
+		}
+		return cfg
+	case "google.protobuf.FieldMask":
+		// protojson: comma-separated lowerCamelCase paths, e.g. "user.displayName,photo".
+		return schemaFieldConfig{typeName: jsString, pattern: fieldMaskPattern, description: `Comma-separated field paths, e.g. "user.displayName,photo".`}
+	case "google.protobuf.Struct":
+		// Free-form JSON: any object, whatever its values.
+		return schemaFieldConfig{typeName: jsObject, description: "Arbitrary JSON object."}
+	case "google.protobuf.Value":
+		// Free-form JSON: any value, including null.
+		return schemaFieldConfig{anyType: true, description: "Arbitrary JSON value."}
+	case "google.protobuf.ListValue":
+		// Free-form JSON: an array of any values.
+		return schemaFieldConfig{typeName: jsArray, description: "Arbitrary JSON array."}
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		// protojson: 64-bit values are written as decimal strings and read from strings or
+		// numbers, e.g. "123"; null when unset.
+		pattern := "^-?[0-9]+$"
+		if msg.Desc.FullName() == "google.protobuf.UInt64Value" {
+			pattern = "^[0-9]+$"
+		}
+		return schemaFieldConfig{types: []string{jsInteger, jsString, jsNull}, typeName: jsInteger, pattern: pattern}
+	}
+	if valueType, ok := wrapperValueTypes[msg.Desc.FullName()]; ok {
+		// protojson: the wrapped value itself, or null when unset.
+		return schemaFieldConfig{
+			types:    []string{valueType, jsNull},
+			typeName: valueType,
+			isBytes:  msg.Desc.FullName() == "google.protobuf.BytesValue",
+		}
+	}
+	return schemaFieldConfig{typeName: jsObject}
+}
+
 // referenceName generates the Go function call expression to retrieve a message's schema.
 //
 // For same-package messages: "MessageName_JsonSchema_WithDefs(defs)"
 // For cross-package messages: "otherpkg.MessageName_JsonSchema_WithDefs(defs)"
-// For Google types: "admin_google_protobuf_Timestamp_JsonSchema_WithDefs(defs)" (standalone function with file prefix)
+// For Google types: "admin_google_protobuf_Timestamp_JsonSchema_WithDefs(defs)" (standalone function owned by the package)
 func (sg *MessageSchemaGenerator) referenceName(msg *protogen.Message) string {
+	if sg.references != nil {
+		sg.references[msg.Desc.FullName()] = definition{message: msg}
+	}
+
 	// Check if this is a Google type
-	if isGoogleType(msg) {
-		// For Google types, use the standalone function name format with file prefix
-		funcName := googleTypeFunctionName(msg, sg.filePrefix) + "_JsonSchema_WithDefs"
+	if sg.gr.isStandaloneType(msg) {
+		// For Google types, use the package's registered standalone function name
+		funcName := sg.googleFunctionName(msg) + "_JsonSchema_WithDefs"
 		return funcName + "(defs)"
 	}
 
@@ -866,16 +3903,67 @@ func (sg *MessageSchemaGenerator) referenceName(msg *protogen.Message) string {
 	ident := protogen.GoIdent{GoName: funcName, GoImportPath: msg.GoIdent.GoImportPath}
 
 	// QualifiedGoIdent handles import aliasing and returns the properly qualified name.
-	return sg.gen.QualifiedGoIdent(ident) + "(defs)"
+	return sg.qualifiedGoIdent(ident) + "(defs)"
+}
+
+// qualifiedGoIdent returns the qualified Go identifier for ident, importing its package
+// if needed. protogen assigns each imported package a unique alias within the file; if
+// that alias would be shadowed by a local declared in generated functions, an error is
+// recorded on sg since the generated reference would not compile.
+func (sg *MessageSchemaGenerator) qualifiedGoIdent(ident protogen.GoIdent) string {
+	qualified := sg.gen.QualifiedGoIdent(ident)
+	if alias, _, ok := strings.Cut(qualified, "."); ok && generatedLocalNames[alias] && sg.err == nil {
+		sg.err = fmt.Errorf("%s: cannot reference %s: its import alias %q is shadowed by a local variable in generated code; "+
+			"use a go_package import path whose last element is not %q",
+			sg.file.Desc.Path(), ident.GoImportPath, alias, alias)
+	}
+	return qualified
+}
+
+// googleFunctionName returns the base function name of a Google type's helper in the
+// Go package being generated, as registered in the run's googleHelpers registry.
+// Falls back to the current file's prefix for types the registry does not know.
+func (sg *MessageSchemaGenerator) googleFunctionName(msg *protogen.Message) string {
+	if name, ok := sg.gr.googleHelpers.names[sg.file.GoImportPath][msg.Desc.FullName()]; ok {
+		return name
+	}
+	return googleTypeFunctionName(msg, fileNamePrefix(sg.file))
+}
+
+// enumReferenceName generates the Go function call expression to retrieve a shared enum definition.
+//
+// For same-package enums: "EnumName_JsonSchema_WithDefs(defs)"
+// For cross-package enums: "otherpkg.EnumName_JsonSchema_WithDefs(defs)"
+//
+// Returns an empty string if the enum is not shared in this run, in which case
+// callers emit the enum values inline.
+func (sg *MessageSchemaGenerator) enumReferenceName(enum *protogen.Enum) string {
+	if enum == nil || !sg.gr.sharedEnums[string(enum.Desc.FullName())] {
+		return ""
+	}
+	if sg.references != nil {
+		sg.references[enum.Desc.FullName()] = definition{enum: enum}
+	}
+
+	funcName := enum.GoIdent.GoName + "_JsonSchema_WithDefs"
+	ident := protogen.GoIdent{GoName: funcName, GoImportPath: enum.GoIdent.GoImportPath}
+	return sg.qualifiedGoIdent(ident) + "(defs)"
 }
 
 // -----------------------------------------------------------------------------
 // Message Schema Generation
 // -----------------------------------------------------------------------------
 
+// generateMessage runs generateMessageJSONSchema, converting a panic into an error that
+// names the file and message being generated.
+func (sg *MessageSchemaGenerator) generateMessage(message *protogen.Message) (err error) {
+	defer recoverPanic(&err, fmt.Sprintf("%s: message %s", sg.file.Desc.Path(), message.Desc.FullName()))
+	return sg.generateMessageJSONSchema(message)
+}
+
 // generateMessageJSONSchema generates the complete JSON Schema code for a single message.
 //
-// This method produces two Go functions for each message:
+// This method produces the following Go functions for each message:
 //
 //  1. JsonSchema() method - Public entry point that returns a complete schema with
 //     all definitions bundled. This is the primary API for consumers.
@@ -884,6 +3972,9 @@ func (sg *MessageSchemaGenerator) referenceName(msg *protogen.Message) string {
 //     a shared definitions map. This enables cross-references between messages and
 //     prevents infinite recursion with circular references.
 //
+//  3. MCPInputSchema() method - Like JsonSchema(), but the root is a copy of the message's
+//     own definition so it is object-typed. Not generated for Google types.
+//
 // The generated schema includes:
 //   - Type "object" with Properties for each field
 //   - Required array for non-optional, non-oneof fields
@@ -902,31 +3993,48 @@ func (sg *MessageSchemaGenerator) generateMessageJSONSchema(message *protogen.Me
 
 	goName := message.GoIdent.GoName
 	title, description := sg.gr.getTitleAndDescription(message.Desc)
+	// With resource_titles, resources without a title are titled after their type.
+	var resource string
+	if sg.gr.opts.ResourceTitles {
+		var resourceTitle string
+		resource, resourceTitle = resourceType(message)
+		if title == "" {
+			title = resourceTitle
+		}
+	}
 
 	// --- Generate Public Entry Point ---
 	// For Google types, generate standalone functions instead of methods (since we can't add methods to imported types).
-	// The file prefix ensures unique function names when multiple files in the same package import Google types.
+	// The package's googleHelpers registry ensures each Google type function is defined once per package.
 	// Ref-as-root pattern: return a $ref wrapper with full defs. This avoids circular
 	// references when marshaling (root != defs[key]) and enables recursive types.
 	defKey := string(message.Desc.FullName())
-	if isGoogleType(message) {
-		googleFuncName := googleTypeFunctionName(message, sg.filePrefix)
+	if sg.gr.isStandaloneType(message) {
+		googleFuncName := sg.googleFunctionName(message)
 		sg.gen.P(fmt.Sprintf("// %s_JsonSchema returns the JSON schema for the %s message.", googleFuncName, message.Desc.Name()))
+		sg.gen.P(concurrencyDoc)
 		sg.gen.P(fmt.Sprintf("func %s_JsonSchema() *jsonschema.Schema {", googleFuncName))
-		sg.gen.P("defs := make(map[string]*jsonschema.Schema)")
-		sg.gen.P(fmt.Sprintf("_ = %s_JsonSchema_WithDefs(defs)", googleFuncName))
-		sg.gen.P(fmt.Sprintf("root := &jsonschema.Schema{Ref: \"#/$defs/%s\", Type: \"object\"}", defKey))
-		sg.gen.P("root.Defs = defs")
-		sg.gen.P("return root")
+		sg.emitRootSchema(googleFuncName+"_JsonSchema_WithDefs", message)
 		sg.gen.P("}")
 		sg.gen.P()
 	} else {
 		// Regular messages get methods
 		sg.gen.P(fmt.Sprintf("// JsonSchema returns the JSON schema for the %s message.", message.Desc.Name()))
+		sg.gen.P(concurrencyDoc)
 		sg.gen.P(fmt.Sprintf("func (x *%s) JsonSchema() *jsonschema.Schema {", goName))
+		sg.emitRootSchema(goName+"_JsonSchema_WithDefs", message)
+		sg.gen.P("}")
+		sg.gen.P()
+
+		// MCP tool input schemas must have an object-typed root without a sibling $ref.
+		// The root is a copy of the message's own def, so self-references via $defs still resolve.
+		sg.gen.P(fmt.Sprintf("// MCPInputSchema returns the JSON schema for the %s message with an object-typed root,", message.Desc.Name()))
+		sg.gen.P("// suitable for use as an MCP tool input schema.")
+		sg.gen.P(concurrencyDoc)
+		sg.gen.P(fmt.Sprintf("func (x *%s) MCPInputSchema() *jsonschema.Schema {", goName))
 		sg.gen.P("defs := make(map[string]*jsonschema.Schema)")
 		sg.gen.P(fmt.Sprintf("_ = %s_JsonSchema_WithDefs(defs)", goName))
-		sg.gen.P(fmt.Sprintf("root := &jsonschema.Schema{Ref: \"#/$defs/%s\", Type: \"object\"}", defKey))
+		sg.gen.P(fmt.Sprintf("root := defs[\"%s\"].CloneSchemas()", defKey))
 		sg.gen.P("root.Defs = defs")
 		sg.gen.P("return root")
 		sg.gen.P("}")
@@ -936,19 +4044,21 @@ func (sg *MessageSchemaGenerator) generateMessageJSONSchema(message *protogen.Me
 	// --- Generate Internal Helper ---
 	// This function populates the shared definitions map and returns a $ref.
 	// The early return on existing defs prevents infinite recursion.
+	// Use the registered Google type function name for Google types, regular Go name for others
+	var helperFuncName string
+	if sg.gr.isStandaloneType(message) {
+		helperFuncName = sg.googleFunctionName(message) + "_JsonSchema_WithDefs"
+	} else {
+		helperFuncName = goName + "_JsonSchema_WithDefs"
+	}
 	{
-		// Use Google type function name (with file prefix) for Google types, regular Go name for others
-		var helperFuncName string
-		if isGoogleType(message) {
-			helperFuncName = googleTypeFunctionName(message, sg.filePrefix) + "_JsonSchema_WithDefs"
-		} else {
-			helperFuncName = goName + "_JsonSchema_WithDefs"
-		}
+		sg.gen.P(fmt.Sprintf("// %s adds the %s schema and its dependencies to defs and returns a $ref to it.", helperFuncName, message.Desc.Name()))
+		sg.gen.P(withDefsConcurrencyDoc)
 		sg.gen.P(fmt.Sprintf("func %s(defs map[string]*jsonschema.Schema) *jsonschema.Schema {", helperFuncName))
 
 		// Return early if already defined (handles circular references).
 		sg.gen.P(fmt.Sprintf("if _, ok := defs[\"%s\"]; ok {", defKey))
-		sg.gen.P(fmt.Sprintf("return &jsonschema.Schema{Ref: \"#/$defs/%s\"}", defKey))
+		sg.gen.P(fmt.Sprintf("return &jsonschema.Schema{Ref: \"%s\"}", sg.gr.definitionRef(message.Desc)))
 		sg.gen.P("}")
 		sg.gen.P()
 	}
@@ -956,6 +4066,9 @@ func (sg *MessageSchemaGenerator) generateMessageJSONSchema(message *protogen.Me
 	// --- Generate Schema Object ---
 	{
 		sg.gen.P("schema := &jsonschema.Schema{")
+		if id := sg.gr.definitionID(message.Desc); id != "" {
+			sg.gen.P(fmt.Sprintf(`ID: "%s",`, id))
+		}
 		sg.gen.P(`Type: "object",`)
 		if title != "" {
 			sg.gen.P(fmt.Sprintf(`Title: "%s",`, sg.gr.escapeGoString(title)))
@@ -963,115 +4076,619 @@ func (sg *MessageSchemaGenerator) generateMessageJSONSchema(message *protogen.Me
 		if description != "" {
 			sg.gen.P(fmt.Sprintf(`Description: "%s",`, sg.gr.escapeGoString(description)))
 		}
+		if isDeprecated(message.Desc) {
+			sg.gen.P(`Deprecated: true,`)
+		}
 		sg.gen.P(`Properties: make(map[string]*jsonschema.Schema),`)
+		// google.protobuf.Empty admits only the empty object, giving parameterless RPCs a
+		// minimal closed schema; with closed_objects, other messages reject the properties
+		// they do not declare. additional_properties sets the keyword per message.
+		value, ok := sg.gr.additionalProperties[message.Desc.FullName()]
+		switch {
+		case ok && value == "true":
+			sg.gen.P(`AdditionalProperties: &jsonschema.Schema{},`)
+		case ok && value != "false":
+			sg.gen.P(fmt.Sprintf(`AdditionalProperties: &jsonschema.Schema{Type: "%s"},`, value))
+		case ok || message.Desc.FullName() == "google.protobuf.Empty" || sg.gr.closedObjects[message.Desc.FullName()]:
+			sg.gen.P(`AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},`)
+		}
+		// unevaluatedProperties also sees the properties declared by the oneOf, anyOf and
+		// allOf branches of the schema, which additionalProperties ignores.
+		if sg.gr.unevaluatedProperties[message.Desc.FullName()] {
+			sg.gen.P(`UnevaluatedProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},`)
+		}
+	}
+
+	// --- Collect Required Fields ---
+	// A field is required only if it's a singular scalar/message field that is not optional.
+	// Fields are NOT required if they are: in a oneof, marked optional, repeated (arrays), or maps.
+	// Note: In proto3, all singular fields are implicitly optional unless explicitly required.
+	// google.type messages document zero values as meaningful (e.g. a Date without a year),
+	// and protojson omits them, so their canonical schemas require no fields.
+	var requiredFields []string
+	for _, field := range message.Fields {
+		if message.Desc.ParentFile().Package() == "google.type" {
+			break
+		}
+		opts := getFieldJsonSchemaOptions(field)
+		if opts.GetIgnore() {
+			continue
+		}
+		// Fields in oneofs, marked optional, repeated (arrays), or maps are not required,
+		// unless buf.validate requires an optional field to be set.
+		required := field.Oneof == nil && !isOptionalField(field)
+		if sg.gr.validation[field.Desc.FullName()].isRequired() && (field.Oneof == nil || field.Oneof.Desc.IsSynthetic()) {
+			required = true
+		}
+		if required && !field.Desc.IsList() && !field.Desc.IsMap() {
+			requiredFields = append(requiredFields, sg.gr.getFieldName(field))
+		}
+	}
+
+	// Emit Required array if any fields are required.
+	if len(requiredFields) > 0 {
+		sg.gen.P(`Required: []string{`)
+		for _, f := range requiredFields {
+			sg.gen.P(fmt.Sprintf(`"%s",`, f))
+		}
+		sg.gen.P(`},`)
+	}
+
+	// Embed the effective options for auditing (options_snapshot), the generator and source
+	// of the schema (provenance), the proto field order (as x-property-order with
+	// property_order, and with target=gemini as propertyOrdering, which schemautil.ToGemini
+	// emits), the depth inlining converters
+	// expand the message to within itself (max_recursion_depth), and the resource type
+	// (resource_titles) and cross-field CEL rules (cel_constraint).
+	extra := make(map[string]any)
+	if sg.gr.opts.OptionsSnapshot {
+		extra["x-generation-options"] = sg.gr.getOptionsSnapshot(message)
+	}
+	if sg.gr.opts.Provenance {
+		extra["x-generator"] = sg.gr.getProvenance(message)
+	}
+	if depth, ok := sg.gr.recursionDepths[message.Desc.FullName()]; ok {
+		extra["x-max-recursion-depth"] = depth
+	}
+	if resource != "" {
+		extra["x-resource-type"] = resource
+	}
+	if constraints := sg.gr.celConstraints[message.Desc.FullName()]; len(constraints) > 0 {
+		extra[schemautil.ConstraintsKeyword] = constraints
+	}
+	if ordering := sg.gr.propertyOrder(message); len(ordering) > 0 {
+		if sg.gr.opts.PropertyOrder {
+			extra["x-property-order"] = ordering
+		}
+		if slices.Contains(sg.gr.targets, targetGemini) {
+			extra["propertyOrdering"] = ordering
+		}
+	}
+	if len(extra) > 0 {
+		sg.gen.P(fmt.Sprintf(`Extra: %s,`, goLiteral(extra)))
+	}
+	sg.gen.P("}")
+	sg.gen.P()
+
+	// Register schema in definitions before processing fields to handle self-references.
+	sg.gen.P(`// Register schema BEFORE processing fields to handle self-references.`)
+	sg.gen.P(`// This prevents infinite recursion when a message contains itself.`)
+	sg.gen.P(fmt.Sprintf("defs[\"%s\"] = schema", defKey))
+	sg.gen.P()
+
+	// --- Collect Fields and OneOf Groups ---
+	// Track oneof groups for generating mutual exclusivity constraints.
+	// Oneofs selected by the discriminated_oneof parameter become discriminated unions
+	// instead of constraints.
+	var fields []*protogen.Field
+	oneofGroups := make(map[string][]string)
+	groupOneofs := make(map[string]*protogen.Oneof)
+	var unions []*protogen.Oneof
+	for _, field := range message.Fields {
+		opts := getFieldJsonSchemaOptions(field)
+		if opts.GetIgnore() {
+			continue
+		}
+		fields = append(fields, field)
+
+		// Track fields that belong to oneof groups (excluding synthetic oneofs for optional).
+		if _, ok := sg.gr.discriminatedOneofs[oneofFullName(field)]; ok {
+			if !slices.Contains(unions, field.Oneof) {
+				unions = append(unions, field.Oneof)
+			}
+		} else if oneof := field.Oneof; oneof != nil && !oneof.Desc.IsSynthetic() {
+			groupName := string(oneof.Desc.Name())
+			oneofGroups[groupName] = append(oneofGroups[groupName], sg.gr.getFieldName(field))
+			groupOneofs[groupName] = oneof
+		}
+	}
+
+	// --- Generate Field Schemas ---
+	// Large messages populate their properties in chunked helper functions (emitted
+	// after this function) to keep generated functions within linter and compiler limits.
+	var chunks [][]*protogen.Field
+	if len(fields) > maxFieldsPerFunction {
+		for start := 0; start < len(fields); start += maxFieldsPerFunction {
+			end := min(start+maxFieldsPerFunction, len(fields))
+			chunks = append(chunks, fields[start:end])
+			sg.gen.P(fmt.Sprintf("%s(defs, schema)", propertiesFuncName(helperFuncName, len(chunks))))
+		}
+		sg.gen.P("")
+	} else if err := sg.emitFieldSchemas(fields); err != nil {
+		return err
+	}
+
+	// --- Generate Discriminated Unions ---
+	for _, oneof := range unions {
+		sg.emitDiscriminatedUnion(oneof)
+	}
+
+	// --- Generate OneOf Constraints ---
+	// Proto3 oneof fields are mutually exclusive and optional (zero or one field set).
+	// Each oneof group becomes a oneOf constraint where one branch matches each
+	// alternative, plus a "none" branch (using not/anyOf) that matches when no
+	// field in the group is present. This faithfully reflects proto3 semantics;
+	// oneof_presence=exactly_one drops the "none" branch to require one field.
+	// - Single oneof group: Use OneOf at the schema root
+	// - Multiple oneof groups: Use AllOf containing individual OneOf constraints
+	// A group titled or described by oneof_title or oneof_description always goes in an
+	// AllOf entry, so its title does not replace the message's.
+	if len(oneofGroups) > 0 {
+		// Sort group names for deterministic output.
+		var groupNames []string
+		for name := range oneofGroups {
+			groupNames = append(groupNames, name)
+		}
+		sort.Strings(groupNames)
+
+		_, titled := sg.gr.oneofTitles[groupOneofs[groupNames[0]].Desc.FullName()]
+		_, described := sg.gr.oneofDescriptions[groupOneofs[groupNames[0]].Desc.FullName()]
+		if len(groupNames) == 1 && !titled && !described {
+			fields := oneofGroups[groupNames[0]]
+			sg.gen.P(`schema.OneOf = []*jsonschema.Schema{`)
+			for _, f := range fields {
+				sg.gen.P(fmt.Sprintf(`{Required: []string{"%s"}},`, f))
+			}
+			sg.emitOneOfNoneBranch(fields)
+			sg.gen.P(`}`)
+		} else {
+			sg.gen.P(`schema.AllOf = []*jsonschema.Schema{`)
+			for _, name := range groupNames {
+				fields := oneofGroups[name]
+				sg.gen.P(`{`)
+				if title, ok := sg.gr.oneofTitles[groupOneofs[name].Desc.FullName()]; ok {
+					sg.gen.P(fmt.Sprintf(`Title: "%s",`, sg.gr.escapeGoString(title)))
+				}
+				if description, ok := sg.gr.oneofDescriptions[groupOneofs[name].Desc.FullName()]; ok {
+					sg.gen.P(fmt.Sprintf(`Description: "%s",`, sg.gr.escapeGoString(description)))
+				}
+				sg.gen.P(`OneOf: []*jsonschema.Schema{`)
+				for _, f := range fields {
+					sg.gen.P(fmt.Sprintf(`{Required: []string{"%s"}},`, f))
+				}
+				sg.emitOneOfNoneBranch(fields)
+				sg.gen.P(`},`)
+				sg.gen.P(`},`)
+			}
+			sg.gen.P(`}`)
+		}
+	}
+
+	// --- Generate Preset Constraints ---
+	// Cross-field constraints of the preset given to the message by the preset parameter.
+	if sg.gr.presets[message.Desc.FullName()] == presetMoney {
+		units, nanos := moneyPresetFields(message)
+		sg.emitMoneyPreset(sg.gr.getFieldName(units), sg.gr.getFieldName(nanos))
+	}
+
+	// Return a $ref to this message's schema definition.
+	sg.gen.P(fmt.Sprintf("    return &jsonschema.Schema{Ref: \"%s\"}", sg.gr.definitionRef(message.Desc)))
+	sg.gen.P("}")
+
+	// --- Generate Property Chunks ---
+	for i, chunk := range chunks {
+		funcName := propertiesFuncName(helperFuncName, i+1)
+		sg.gen.P()
+		sg.gen.P(fmt.Sprintf("// %s populates properties %s to %s of the %s schema.",
+			funcName, sg.gr.getFieldName(chunk[0]), sg.gr.getFieldName(chunk[len(chunk)-1]), message.Desc.Name()))
+		sg.gen.P(fmt.Sprintf("func %s(defs map[string]*jsonschema.Schema, schema *jsonschema.Schema) {", funcName))
+		if err := sg.emitFieldSchemas(chunk); err != nil {
+			return err
+		}
+		sg.gen.P("}")
+	}
+	return sg.err
+}
+
+// emitFieldSchemas emits the property schemas of the given fields onto schema.
+func (sg *MessageSchemaGenerator) emitFieldSchemas(fields []*protogen.Field) error {
+	for _, field := range fields {
+		if err := sg.generateFieldJSONSchema(field); err != nil {
+			return err
+		}
+		sg.gen.P("")
+	}
+	return nil
+}
+
+// propertiesFuncName returns the name of the n-th (1-based) property chunk function
+// of the message whose helper is helperFuncName.
+// Example: "User_JsonSchema_WithDefs", 2 -> "User_jsonSchemaProperties2"
+func propertiesFuncName(helperFuncName string, n int) string {
+	return fmt.Sprintf("%s_jsonSchemaProperties%d", strings.TrimSuffix(helperFuncName, "_JsonSchema_WithDefs"), n)
+}
+
+// emitRootSchema emits the body of a JsonSchema() entry point that builds the
+// definitions via helperFuncName and returns the root schema for message.
+//
+// By default the root is a ref-as-root wrapper ({$ref, $defs}). With the object_root
+// option the message's own object schema becomes the root and its $defs entry is
+// re-pointed to "#", so self-references still resolve without a pointer cycle.
+func (sg *MessageSchemaGenerator) emitRootSchema(helperFuncName string, message *protogen.Message) {
+	sg.gen.P("defs := make(map[string]*jsonschema.Schema)")
+	sg.gen.P(fmt.Sprintf("_ = %s(defs)", helperFuncName))
+	sg.gr.emitRoot(sg.gen, message, false)
+}
+
+// emitRoot emits the statements that return the root schema for message from the
+// definitions in defs. With shared, the definitions are shared with other schemas, so
+// the object_root root is a copy of the message's definition rather than the definition
+// itself.
+func (gr *Generator) emitRoot(g codeWriter, message *protogen.Message, shared bool) {
+	defKey := string(message.Desc.FullName())
+	if gr.opts.ObjectRoot {
+		if shared {
+			g.P(fmt.Sprintf("root := defs[\"%s\"].CloneSchemas()", defKey))
+		} else {
+			g.P(fmt.Sprintf("root := defs[\"%s\"]", defKey))
+		}
+		g.P(fmt.Sprintf("defs[\"%s\"] = &jsonschema.Schema{Ref: \"#\"}", defKey))
+	} else {
+		g.P(fmt.Sprintf("root := &jsonschema.Schema{Ref: \"%s\", Type: \"object\"}", gr.definitionRef(message.Desc)))
+	}
+	g.P("root.Defs = defs")
+	if len(gr.vocabularies) > 0 {
+		g.P("root.Vocabulary = " + gr.vocabularyLiteral())
+	}
+	g.P("return root")
+}
+
+// vocabularyLiteral returns the Go literal of the $vocabulary of JsonSchema() roots: the
+// draft 2020-12 vocabularies, required, and those of the vocabulary parameter, optional.
+func (gr *Generator) vocabularyLiteral() string {
+	uris := slices.Sorted(maps.Keys(gr.vocabularies))
+	var b strings.Builder
+	b.WriteString("map[string]bool{")
+	for _, vocab := range draft202012Vocabularies {
+		fmt.Fprintf(&b, "%q: true, ", vocab)
+	}
+	for i, uri := range uris {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q: false", uri)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// generateEnumJSONSchema generates the shared definition helper for an enum.
+//
+// The helper registers an integer schema with the enum's allowed values (or, with the
+// enum_names parameter, a string schema with the value names) under the enum's full
+// name in the shared definitions map and returns a $ref to it, so every field
+// referencing the enum (in any file of the run) shares a single definition. With the
+// enum_oneof parameter, the values are listed as described const branches of a oneOf.
+func (sg *MessageSchemaGenerator) generateEnumJSONSchema(enum *protogen.Enum) {
+	defKey := string(enum.Desc.FullName())
+	title, description := sg.gr.getTitleAndDescription(enum.Desc)
+
+	sg.gen.P(fmt.Sprintf("// %s_JsonSchema_WithDefs adds the %s enum schema to defs and returns a $ref to it.", enum.GoIdent.GoName, enum.Desc.Name()))
+	sg.gen.P(withDefsConcurrencyDoc)
+	sg.gen.P(fmt.Sprintf("func %s_JsonSchema_WithDefs(defs map[string]*jsonschema.Schema) *jsonschema.Schema {", enum.GoIdent.GoName))
+	sg.gen.P(fmt.Sprintf("if _, ok := defs[\"%s\"]; ok {", defKey))
+	sg.gen.P(fmt.Sprintf("return &jsonschema.Schema{Ref: \"%s\"}", sg.gr.definitionRef(enum.Desc)))
+	sg.gen.P("}")
+	sg.gen.P()
+
+	names := sg.getEnumNames(enum.Desc)
+	sg.gen.P(fmt.Sprintf("defs[\"%s\"] = &jsonschema.Schema{", defKey))
+	if id := sg.gr.definitionID(enum.Desc); id != "" {
+		sg.gen.P(fmt.Sprintf(`ID: "%s",`, id))
+	}
+	if names != nil {
+		sg.gen.P(fmt.Sprintf(`Type: "%s",`, jsString))
+	} else {
+		sg.gen.P(fmt.Sprintf(`Type: "%s",`, jsInteger))
+	}
+	if title != "" {
+		sg.gen.P(fmt.Sprintf(`Title: "%s",`, sg.gr.escapeGoString(title)))
+	}
+	if description = sg.gr.withEnumValueDescriptions(description, enum.Desc); description != "" {
+		sg.gen.P(fmt.Sprintf(`Description: "%s",`, sg.gr.escapeGoString(description)))
+	}
+	if isDeprecated(enum.Desc) {
+		sg.gen.P(`Deprecated: true,`)
+	}
+	if consts := sg.getEnumOneOf(enum.Desc); consts != nil {
+		sg.emitEnumOneOf(consts, false)
+	} else {
+		sg.gen.P(`Enum: []any{`)
+		if names != nil {
+			for _, name := range names {
+				sg.gen.P(fmt.Sprintf(`%q,`, name))
+			}
+		} else {
+			for _, value := range sg.getEnumValuesFromDescriptor(enum.Desc) {
+				sg.gen.P(fmt.Sprintf(`%d,`, value))
+			}
+		}
+		sg.gen.P(`},`)
+	}
+	sg.gen.P("}")
+	sg.gen.P()
+	sg.gen.P(fmt.Sprintf("return &jsonschema.Schema{Ref: \"%s\"}", sg.gr.definitionRef(enum.Desc)))
+	sg.gen.P("}")
+}
+
+// generateEnumValuesHelper generates <Enum>_JsonSchemaEnum(), which lists the enum's
+// values with their names (without the enum prefix with strip_enum_prefix) and descriptions
+// (from the value's leading comments).
+//
+// Schema-driven UIs can use it to render labeled choices without reflecting over the
+// proto enum. The element type is an unnamed struct so that helpers emitted by different
+// files of the same Go package never declare conflicting types.
+func (sg *MessageSchemaGenerator) generateEnumValuesHelper(enum *protogen.Enum) {
+	const elemType = "struct {\nValue int32\nName string\nDescription string\n}"
+	funcName := enum.GoIdent.GoName + "_JsonSchemaEnum"
+
+	sg.gen.P(fmt.Sprintf("// %s returns the values of the %s enum with their names and descriptions.", funcName, enum.Desc.Name()))
+	sg.gen.P("// It is safe for concurrent use; each call returns a new slice.")
+	sg.gen.P(fmt.Sprintf("func %s() []%s {", funcName, elemType))
+	sg.gen.P(fmt.Sprintf("return []%s{", elemType))
+	names := sg.gr.enumValueNames(enum.Desc)
+	for i, value := range enum.Values {
+		description := sg.gr.getEnumValueDescription(value.Desc)
+		sg.gen.P(fmt.Sprintf(`{Value: %d, Name: "%s", Description: "%s"},`, value.Desc.Number(), names[i], sg.gr.escapeGoString(description)))
+	}
+	sg.gen.P("}")
+	sg.gen.P("}")
+}
+
+// generateEnumValueMapping generates <Enum>_JsonSchemaValue(), which maps the value
+// names emitted with the strip_enum_prefix or enum_case parameters back to the values'
+// numbers, so callers can turn validated documents into proto enums.
+func (sg *MessageSchemaGenerator) generateEnumValueMapping(enum *protogen.Enum) {
+	funcName := enum.GoIdent.GoName + "_JsonSchemaValue"
+	names := sg.gr.enumValueNames(enum.Desc)
+
+	sg.gen.P(fmt.Sprintf("// %s returns the number of the %s value that name represents in the", funcName, enum.Desc.Name()))
+	sg.gen.P("// schema, whose value names differ from the proto ones, and false if it represents none.")
+	sg.gen.P(fmt.Sprintf("func %s(name string) (int32, bool) {", funcName))
+	sg.gen.P("switch name {")
+	for i, value := range enum.Values {
+		sg.gen.P(fmt.Sprintf("case %q:", names[i]))
+		sg.gen.P(fmt.Sprintf("return %d, true", value.Desc.Number()))
+	}
+	sg.gen.P("}")
+	sg.gen.P("return 0, false")
+	sg.gen.P("}")
+}
+
+// emitTypes emits a union of JSON Schema types, e.g. Types: []string{"string", "null"}.
+func (sg *MessageSchemaGenerator) emitTypes(types []string) {
+	quoted := make([]string, len(types))
+	for i, t := range types {
+		quoted[i] = strconv.Quote(t)
+	}
+	sg.gen.P(fmt.Sprintf(`Types: []string{%s},`, strings.Join(quoted, ", ")))
+}
+
+// emitAnyTypes emits the keywords restricting a google.protobuf.Any value to the protojson
+// form of the allowed messages: a required "@type" property listing their type URLs, and
+// a oneOf with one branch per message that pins "@type" and applies the message schema,
+// plus a null branch when the field is nullable.
+func (sg *MessageSchemaGenerator) emitAnyTypes(anyTypes []anyType, nullable bool) {
+	sg.gen.P(`Properties: map[string]*jsonschema.Schema{`)
+	sg.gen.P(`"@type": {Type: "string", Enum: []any{`)
+	for _, t := range anyTypes {
+		sg.gen.P(fmt.Sprintf(`%s,`, strconv.Quote(t.typeURL)))
+	}
+	sg.gen.P(`}},`)
+	sg.gen.P(`},`)
+	sg.gen.P(`Required: []string{"@type"},`)
+	sg.gen.P(`OneOf: []*jsonschema.Schema{`)
+	for _, t := range anyTypes {
+		sg.gen.P(`{`)
+		sg.gen.P(fmt.Sprintf(`Properties: map[string]*jsonschema.Schema{"@type": {Const: jsonschema.Ptr[any](%s)}},`, strconv.Quote(t.typeURL)))
+		sg.gen.P(fmt.Sprintf(`AllOf: []*jsonschema.Schema{%s},`, t.ref))
+		sg.gen.P(`},`)
+	}
+	if nullable {
+		sg.gen.P(`{Type: "null"},`)
 	}
+	sg.gen.P(`},`)
+}
 
-	// --- Collect Required Fields ---
-	// A field is required only if it's a singular scalar/message field that is not optional.
-	// Fields are NOT required if they are: in a oneof, marked optional, repeated (arrays), or maps.
-	// Note: In proto3, all singular fields are implicitly optional unless explicitly required.
-	var requiredFields []string
-	for _, field := range message.Fields {
-		opts := getFieldJsonSchemaOptions(field)
-		if opts.GetIgnore() {
-			continue
+// emitRef emits a reference to the schema returned by a WithDefs call, or with nullable
+// an anyOf of the reference and null, since the referenced schema rejects null.
+func (sg *MessageSchemaGenerator) emitRef(call string, nullable bool) {
+	if nullable {
+		sg.gen.P(fmt.Sprintf(`AnyOf: []*jsonschema.Schema{{Ref: %s.Ref}, {Type: "null"}},`, call))
+	} else {
+		sg.gen.P(fmt.Sprintf(`Ref: %s.Ref,`, call))
+	}
+}
+
+// emitEnumAliases emits the values of an enum field with aliases (the enum_alias
+// parameter) as an anyOf of the enum's own schema and one list of aliases per aliased
+// value, plus a null branch when the field is nullable.
+func (sg *MessageSchemaGenerator) emitEnumAliases(c schemaFieldConfig) {
+	sg.gen.P(`AnyOf: []*jsonschema.Schema{`)
+	sg.gen.P(`{`)
+	switch {
+	case c.enumRef != "":
+		sg.emitRef(c.enumRef, false)
+	case len(c.enumOneOf) > 0:
+		sg.emitEnumOneOf(c.enumOneOf, false)
+	default:
+		sg.gen.P(`Enum: []any{`)
+		for _, name := range c.enumNames {
+			sg.gen.P(fmt.Sprintf(`%q,`, name))
 		}
-		// Fields in oneofs, marked optional, repeated (arrays), or maps are not required.
-		if field.Oneof == nil && !field.Desc.HasOptionalKeyword() && !field.Desc.IsList() && !field.Desc.IsMap() {
-			requiredFields = append(requiredFields, getFieldName(field))
+		sg.gen.P(`},`)
+	}
+	sg.gen.P(`},`)
+	for _, a := range c.enumAliases {
+		quoted := make([]string, len(a.aliases))
+		for i, alias := range a.aliases {
+			quoted[i] = strconv.Quote(alias)
 		}
+		sg.gen.P(fmt.Sprintf(`{Enum: []any{%s}, Description: "%s"},`, strings.Join(quoted, ", "), sg.gr.escapeGoString("Aliases of "+a.name+".")))
+	}
+	if c.nullable {
+		sg.gen.P(`{Type: "null"},`)
 	}
+	sg.gen.P(`},`)
+}
 
-	// Emit Required array if any fields are required.
-	if len(requiredFields) > 0 {
-		sg.gen.P(`Required: []string{`)
-		for _, f := range requiredFields {
-			sg.gen.P(fmt.Sprintf(`"%s",`, f))
+// generateEnumAliasNormalizer generates <Message>_<Field>_JsonSchemaNormalize(), which
+// maps the aliases accepted for the values of an enum field (the enum_alias parameter) to
+// the schema's value names, so callers can unmarshal validated documents.
+func (sg *MessageSchemaGenerator) generateEnumAliasNormalizer(field *protogen.Field, aliases []enumAlias) {
+	funcName := field.Parent.GoIdent.GoName + "_" + field.GoName + "_JsonSchemaNormalize"
+
+	sg.gen.P(fmt.Sprintf("// %s returns the value name that name stands for in the %s field", funcName, field.Desc.Name()))
+	sg.gen.P(fmt.Sprintf("// of the %s message: the value's schema name for an alias, and name itself otherwise.", field.Parent.Desc.Name()))
+	sg.gen.P(fmt.Sprintf("func %s(name string) string {", funcName))
+	sg.gen.P("switch name {")
+	for _, a := range aliases {
+		quoted := make([]string, len(a.aliases))
+		for i, alias := range a.aliases {
+			quoted[i] = strconv.Quote(alias)
 		}
-		sg.gen.P(`},`)
+		sg.gen.P(fmt.Sprintf("case %s:", strings.Join(quoted, ", ")))
+		sg.gen.P(fmt.Sprintf("return %q", a.name))
 	}
 	sg.gen.P("}")
-	sg.gen.P()
+	sg.gen.P("return name")
+	sg.gen.P("}")
+}
 
-	// Register schema in definitions before processing fields to handle self-references.
-	sg.gen.P(`// Register schema BEFORE processing fields to handle self-references.`)
-	sg.gen.P(`// This prevents infinite recursion when a message contains itself.`)
-	sg.gen.P(fmt.Sprintf("defs[\"%s\"] = schema", defKey))
-	sg.gen.P()
+// emitEnumOneOf emits a oneOf with one const branch per enum value, carrying the value's
+// description when it has one, plus a null branch when the field is nullable.
+func (sg *MessageSchemaGenerator) emitEnumOneOf(consts []enumConst, nullable bool) {
+	sg.gen.P(`OneOf: []*jsonschema.Schema{`)
+	for _, c := range consts {
+		branch := fmt.Sprintf(`Const: jsonschema.Ptr[any](%s)`, c.literal)
+		if c.description != "" {
+			branch += fmt.Sprintf(`, Description: "%s"`, sg.gr.escapeGoString(c.description))
+		}
+		if c.deprecated {
+			branch += `, Deprecated: true`
+		}
+		sg.gen.P("{", branch, "},")
+	}
+	if nullable {
+		sg.gen.P(`{Type: "null"},`)
+	}
+	sg.gen.P(`},`)
+}
 
-	// --- Generate Field Schemas and Collect OneOf Groups ---
-	// Track oneof groups for generating mutual exclusivity constraints.
-	oneofGroups := make(map[string][]string)
+// moneyPresetFields returns the units and nanos fields of a Money-like message
+// (google.type.Money or any message with the same singular int64 units and int32
+// nanos fields), or nils if the money preset cannot apply to the message.
+func moneyPresetFields(message *protogen.Message) (units, nanos *protogen.Field) {
 	for _, field := range message.Fields {
-		opts := getFieldJsonSchemaOptions(field)
-		if opts.GetIgnore() {
+		if field.Desc.Cardinality() == protoreflect.Repeated || getFieldJsonSchemaOptions(field).GetIgnore() {
 			continue
 		}
-
-		// Track fields that belong to oneof groups (excluding synthetic oneofs for optional).
-		if oneof := field.Oneof; oneof != nil && !oneof.Desc.IsSynthetic() {
-			groupName := string(oneof.Desc.Name())
-			oneofGroups[groupName] = append(oneofGroups[groupName], getFieldName(field))
-		}
-
-		// Generate the field's schema.
-		if err := sg.generateFieldJSONSchema(field); err != nil {
-			return err
+		switch {
+		case field.Desc.Name() == "units" && field.Desc.Kind() == protoreflect.Int64Kind:
+			units = field
+		case field.Desc.Name() == "nanos" && field.Desc.Kind() == protoreflect.Int32Kind:
+			nanos = field
 		}
-		sg.gen.P("")
 	}
+	if units == nil || nanos == nil {
+		return nil, nil
+	}
+	return units, nanos
+}
 
-	// --- Generate OneOf Constraints ---
-	// Proto3 oneof fields are mutually exclusive and optional (zero or one field set).
-	// Each oneof group becomes a oneOf constraint where one branch matches each
-	// alternative, plus a "none" branch (using not/anyOf) that matches when no
-	// field in the group is present. This faithfully reflects proto3 semantics.
-	// - Single oneof group: Use OneOf at the schema root
-	// - Multiple oneof groups: Use AllOf containing individual OneOf constraints
-	if len(oneofGroups) > 0 {
-		// Sort group names for deterministic output.
-		var groupNames []string
-		for name := range oneofGroups {
-			groupNames = append(groupNames, name)
-		}
-		sort.Strings(groupNames)
+// emitMoneyPreset emits the money preset: nanos must lie within ±999,999,999 and
+// must not have the opposite sign of units (e.g. -1.75 is units=-1, nanos=-750000000).
+// The constraints are appended to AllOf so they combine with any oneof constraints.
+func (sg *MessageSchemaGenerator) emitMoneyPreset(units, nanos string) {
+	sg.gen.P(`// Money preset: nanos is bounded and has the same sign as units.`)
+	sg.gen.P(`schema.AllOf = append(schema.AllOf,`)
+	sg.gen.P(fmt.Sprintf(`&jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"%s": {Minimum: &[]float64{-999999999}[0], Maximum: &[]float64{999999999}[0]}}},`, nanos))
+	sg.gen.P(`&jsonschema.Schema{`)
+	sg.gen.P(fmt.Sprintf(`If:   &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"%s": {ExclusiveMinimum: &[]float64{0}[0]}}, Required: []string{"%s"}},`, units, units))
+	sg.gen.P(fmt.Sprintf(`Then: &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"%s": {Minimum: &[]float64{0}[0]}}},`, nanos))
+	sg.gen.P(`},`)
+	sg.gen.P(`&jsonschema.Schema{`)
+	sg.gen.P(fmt.Sprintf(`If:   &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"%s": {ExclusiveMaximum: &[]float64{0}[0]}}, Required: []string{"%s"}},`, units, units))
+	sg.gen.P(fmt.Sprintf(`Then: &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"%s": {Maximum: &[]float64{0}[0]}}},`, nanos))
+	sg.gen.P(`},`)
+	sg.gen.P(`)`)
+	sg.gen.P()
+}
 
-		if len(groupNames) == 1 {
-			fields := oneofGroups[groupNames[0]]
-			sg.gen.P(`schema.OneOf = []*jsonschema.Schema{`)
-			for _, f := range fields {
-				sg.gen.P(fmt.Sprintf(`{Required: []string{"%s"}},`, f))
-			}
-			sg.emitOneOfNoneBranch(fields)
-			sg.gen.P(`}`)
-		} else {
-			sg.gen.P(`schema.AllOf = []*jsonschema.Schema{`)
-			for _, name := range groupNames {
-				fields := oneofGroups[name]
-				sg.gen.P(`{`)
-				sg.gen.P(`OneOf: []*jsonschema.Schema{`)
-				for _, f := range fields {
-					sg.gen.P(fmt.Sprintf(`{Required: []string{"%s"}},`, f))
-				}
-				sg.emitOneOfNoneBranch(fields)
-				sg.gen.P(`},`)
-				sg.gen.P(`},`)
-			}
-			sg.gen.P(`}`)
+// emitDiscriminatedUnion emits the property representing a oneof as a discriminated union
+// (the discriminated_oneof parameter): a oneOf with one closed object per member, holding
+// the discriminator property, whose const is the member's property name, and the member's
+// property schema, which is moved there from the message's properties. The property is
+// not required, since a oneof may be unset.
+func (sg *MessageSchemaGenerator) emitDiscriminatedUnion(oneof *protogen.Oneof) {
+	discriminator := sg.gr.discriminatedOneofs[oneof.Desc.FullName()]
+	var members []string
+	for _, field := range oneof.Fields {
+		if !getFieldJsonSchemaOptions(field).GetIgnore() {
+			members = append(members, sg.gr.getFieldName(field))
 		}
 	}
 
-	// Return a $ref to this message's schema definition.
-	sg.gen.P(fmt.Sprintf("    return &jsonschema.Schema{Ref: \"#/$defs/%s\"}", defKey))
-	sg.gen.P("}")
-	return nil
+	sg.gen.P(fmt.Sprintf(`// Oneof %s is a discriminated union on "%s".`, oneof.Desc.Name(), discriminator))
+	sg.gen.P(fmt.Sprintf(`schema.Properties["%s"] = &jsonschema.Schema{`, sg.gr.getOneofName(oneof)))
+	title, description := sg.gr.getTitleAndDescription(oneof.Desc)
+	if t, ok := sg.gr.oneofTitles[oneof.Desc.FullName()]; ok {
+		title = t
+	}
+	if d, ok := sg.gr.oneofDescriptions[oneof.Desc.FullName()]; ok {
+		description = d
+	}
+	if title != "" {
+		sg.gen.P(fmt.Sprintf(`Title: "%s",`, sg.gr.escapeGoString(title)))
+	}
+	if description != "" {
+		sg.gen.P(fmt.Sprintf(`Description: "%s",`, sg.gr.escapeGoString(description)))
+	}
+	sg.gen.P(`OneOf: []*jsonschema.Schema{`)
+	for _, member := range members {
+		sg.gen.P(`{`)
+		sg.gen.P(`Type: "object",`)
+		sg.gen.P(fmt.Sprintf(`Properties: map[string]*jsonschema.Schema{"%s": {Const: jsonschema.Ptr[any]("%s")}, "%s": schema.Properties["%s"]},`, discriminator, member, member, member))
+		sg.gen.P(fmt.Sprintf(`Required: []string{"%s", "%s"},`, discriminator, member))
+		sg.gen.P(`AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},`)
+		sg.gen.P(`},`)
+	}
+	sg.gen.P(`},`)
+	sg.gen.P(`}`)
+	for _, member := range members {
+		sg.gen.P(fmt.Sprintf(`delete(schema.Properties, "%s")`, member))
+	}
+	sg.gen.P()
 }
 
 // emitOneOfNoneBranch emits a "none present" branch for a oneOf group, making the
 // entire group optional. This matches proto3 semantics where a oneof does not require
 // any alternative to be set. The branch uses not/anyOf to match only when none of the
-// fields in the group are present.
+// fields in the group are present. With oneof_presence=exactly_one nothing is emitted.
 func (sg *MessageSchemaGenerator) emitOneOfNoneBranch(fields []string) {
+	if sg.gr.opts.OneofPresence == oneofPresenceExactlyOne {
+		return
+	}
 	sg.gen.P(`{Not: &jsonschema.Schema{AnyOf: []*jsonschema.Schema{`)
 	for _, f := range fields {
 		sg.gen.P(fmt.Sprintf(`{Required: []string{"%s"}},`, f))
@@ -1087,7 +4704,8 @@ func (sg *MessageSchemaGenerator) emitOneOfNoneBranch(fields []string) {
 //   - Map fields → getMapSchemaConfig
 //   - All other fields (singular messages, scalars) → getScalarSchemaConfig
 //
-// The resulting config is then passed to emitSchemaField for code generation.
+// The resulting config is checked by validateFieldOptions and then passed to
+// emitSchemaField for code generation.
 func (sg *MessageSchemaGenerator) generateFieldJSONSchema(field *protogen.Field) error {
 	// Extract metadata from proto comments.
 	title, description := sg.gr.getTitleAndDescription(field.Desc)
@@ -1100,24 +4718,256 @@ func (sg *MessageSchemaGenerator) generateFieldJSONSchema(field *protogen.Field)
 		cfg = sg.getMapSchemaConfig(field, title, description)
 	} else {
 		cfg = sg.getScalarSchemaConfig(field, title, description)
+		// With nullable_optional, optional fields also accept null, unless their schema
+		// already does (wrapper types, google.protobuf.Value).
+		cfg.nullable = sg.gr.opts.NullableOptional && isOptionalField(field) && !cfg.anyType && !slices.Contains(cfg.types, jsNull)
+	}
+
+	// Reject options that do not apply to the field before emitting any keywords.
+	if err := sg.validateFieldOptions(field); err != nil {
+		return err
+	}
+
+	patches, ok := sg.gr.rawSchemas[field.Desc.FullName()]
+	if !ok {
+		// Generate the actual schema code.
+		sg.emitSchemaField(cfg, field)
+		return nil
 	}
 
-	// Generate the actual schema code.
+	// Merge the raw_schema patch over the generated keywords, emitting the merged schema.
+	out := sg.gen
+	captured := &capturedCode{codeWriter: out}
+	sg.gen = captured
 	sg.emitSchemaField(cfg, field)
+	sg.gen = out
+	assignment := fmt.Sprintf(`schema.Properties["%s"] = `, cfg.fieldName)
+	lit, err := parseSchemaLiteral("&jsonschema.Schema", strings.TrimPrefix(strings.TrimSpace(captured.buf.String()), assignment))
+	for _, patch := range patches {
+		if err != nil {
+			break
+		}
+		var keywords map[string]any
+		if err = json.Unmarshal([]byte(patch), &keywords); err == nil {
+			err = sg.mergeRawSchema(lit, keywords)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("raw_schema parameter of %s: %w", field.Desc.FullName(), err)
+	}
+	sg.gen.P(assignment + lit.String())
 	return nil
 }
 
+// fieldOptionViolation is a field option that does not apply to the field it is set on.
+type fieldOptionViolation struct {
+	// option is the option's name, e.g. "max_items".
+	option string
+
+	// target describes what the option applies to, e.g. "repeated fields".
+	target string
+
+	// got describes the field, e.g. "singular field" or "integer values".
+	got string
+}
+
+// fieldOptionViolations returns the options of a field that do not apply to it, such as
+// max_items on a singular field or pattern on an integer. Such options would emit
+// keywords that validators ignore or that are silently dropped. Value constraints are
+// checked against the JSON type of the field's values (array items and map values for
+// container fields, see fieldValueType).
+//
+// Applicability:
+//   - min_items, max_items, unique_items: repeated fields
+//   - min_properties, max_properties: map fields
+//   - pattern, min_length, max_length, content_encoding, content_media_type: string values
+//     (string, bytes and, with enum_names, enum fields)
+//   - minimum, maximum, exclusive_minimum, exclusive_maximum: integer and number values
+//   - format: string, integer and number values
+//
+// Value constraints never apply to message values other than inlined Google types,
+// since those are emitted as references to the message's schema.
+func (sg *MessageSchemaGenerator) fieldOptionViolations(field *protogen.Field) []fieldOptionViolation {
+	opts := getFieldJsonSchemaOptions(field)
+	if opts == nil {
+		return nil
+	}
+
+	// Describe the field's cardinality and the JSON type of its values.
+	cardinality := "singular"
+	switch {
+	case field.Desc.IsList():
+		cardinality = "repeated"
+	case field.Desc.IsMap():
+		cardinality = "map"
+	}
+	valueType := sg.fieldValueType(field)
+
+	isString := valueType == jsString
+	isNumeric := valueType == jsInteger || valueType == jsNumber
+	checks := []struct {
+		option  string
+		set     bool
+		applies bool
+		target  string
+	}{
+		{"min_items", opts.GetMinItems() != 0, field.Desc.IsList(), "repeated fields"},
+		{"max_items", opts.GetMaxItems() != 0, field.Desc.IsList(), "repeated fields"},
+		{"unique_items", opts.GetUniqueItems(), field.Desc.IsList(), "repeated fields"},
+		{"min_properties", opts.GetMinProperties() != 0, field.Desc.IsMap(), "map fields"},
+		{"max_properties", opts.GetMaxProperties() != 0, field.Desc.IsMap(), "map fields"},
+		{"pattern", opts.GetPattern() != "", isString, "string values"},
+		{"min_length", opts.GetMinLength() != 0, isString, "string values"},
+		{"max_length", opts.GetMaxLength() != 0, isString, "string values"},
+		{"content_encoding", opts.GetContentEncoding() != "", isString, "string values"},
+		{"content_media_type", opts.GetContentMediaType() != "", isString, "string values"},
+		{"format", opts.GetFormat() != "", isString || isNumeric, "string, integer and number values"},
+		{"minimum", opts.GetMinimum() != 0, isNumeric, "integer and number values"},
+		{"maximum", opts.GetMaximum() != 0, isNumeric, "integer and number values"},
+		{"exclusive_minimum", opts.GetExclusiveMinimum(), isNumeric, "integer and number values"},
+		{"exclusive_maximum", opts.GetExclusiveMaximum(), isNumeric, "integer and number values"},
+	}
+	var violations []fieldOptionViolation
+	for _, c := range checks {
+		if !c.set || c.applies {
+			continue
+		}
+		got := cardinality + " field"
+		if strings.HasSuffix(c.target, "values") {
+			got = valueType + " values"
+		}
+		violations = append(violations, fieldOptionViolation{option: c.option, target: c.target, got: got})
+	}
+	return violations
+}
+
+// fieldValueType returns the JSON type of a field's values (array items and map values
+// for container fields), "any" for free-form google.protobuf.Value values, or "message"
+// for message values emitted as references.
+func (sg *MessageSchemaGenerator) fieldValueType(field *protogen.Field) string {
+	desc, msg := field.Desc, field.Message
+	if field.Desc.IsMap() {
+		for _, f := range field.Message.Fields {
+			if f.Desc.Number() == 2 { // Field number 2 is always the value in map entries
+				desc, msg = f.Desc, f.Message
+			}
+		}
+	}
+	if msg != nil {
+		if sg.gr.durationSeconds[field.Desc.FullName()] {
+			return durationSecondsConfig.typeName
+		}
+		if sg.gr.isInlinedMessage(msg) {
+			if cfg := sg.getInlinedMessageSchemaConfig(msg); !cfg.anyType {
+				return cfg.typeName
+			}
+			return "any"
+		}
+		return "message"
+	}
+	typeName, _ := sg.getKindTypeName(desc)
+	return typeName
+}
+
+// validateFieldOptions rejects the first field option that does not apply to the field
+// (see fieldOptionViolations) instead of emitting keywords that are ignored by
+// validators or silently dropped.
+func (sg *MessageSchemaGenerator) validateFieldOptions(field *protogen.Field) error {
+	violations := sg.fieldOptionViolations(field)
+	if len(violations) == 0 {
+		return nil
+	}
+	v := violations[0]
+	return fmt.Errorf("%s: field %s: json_schema option %s applies only to %s, not %s",
+		sg.file.Desc.Path(), field.Desc.FullName(), v.option, v.target, v.got)
+}
+
 // -----------------------------------------------------------------------------
 // Type Mapping Utilities
 // -----------------------------------------------------------------------------
 
-// getFieldName returns the proto field name (snake_case) to use in the JSON schema.
-// This uses the proto field name directly, not the JSON name, since agents/MCP tools
-// use json.Marshal instead of protojson.Marshal.
-func getFieldName(field *protogen.Field) string {
+// getFieldName returns the property key used for a field in the JSON schema, as selected
+// by the field_names plugin parameter. The default is the proto field name (snake_case),
+// since agents/MCP tools use json.Marshal instead of protojson.Marshal; "json_name" uses
+// the name protojson emits, honoring custom json_name options.
+func (gr *Generator) getFieldName(field *protogen.Field) string {
+	switch gr.opts.FieldNames {
+	case fieldNamesCamel:
+		return lowerCamelCase(string(field.Desc.Name()))
+	case fieldNamesJSONName:
+		return field.Desc.JSONName()
+	}
 	return string(field.Desc.Name())
 }
 
+// getOneofName returns the property key of a oneof represented as a discriminated union:
+// its name, converted like field names by the field_names parameter.
+func (gr *Generator) getOneofName(oneof *protogen.Oneof) string {
+	if gr.opts.FieldNames == fieldNamesCamel || gr.opts.FieldNames == fieldNamesJSONName {
+		return lowerCamelCase(string(oneof.Desc.Name()))
+	}
+	return string(oneof.Desc.Name())
+}
+
+// propertyOrder returns the property names of a message's schema in proto declaration
+// order. Members of discriminated oneofs are listed at the position of their union
+// property.
+func (gr *Generator) propertyOrder(message *protogen.Message) []string {
+	var ordering []string
+	for _, field := range message.Fields {
+		if getFieldJsonSchemaOptions(field).GetIgnore() {
+			continue
+		}
+		name := gr.getFieldName(field)
+		if _, ok := gr.discriminatedOneofs[oneofFullName(field)]; ok {
+			name = gr.getOneofName(field.Oneof)
+		}
+		if !slices.Contains(ordering, name) {
+			ordering = append(ordering, name)
+		}
+	}
+	return ordering
+}
+
+// lowerCamelCase converts a snake_case proto field name to lowerCamelCase the way protoc
+// derives default JSON names: underscores are dropped and the following letter is
+// upper-cased.
+func lowerCamelCase(name string) string {
+	var b strings.Builder
+	upperNext := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upperNext = true
+		case upperNext && 'a' <= c && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upperNext = false
+		default:
+			b.WriteRune(c)
+			upperNext = false
+		}
+	}
+	return b.String()
+}
+
+// upperSnakeCase converts a CamelCase enum name to UPPER_SNAKE_CASE the way style guides
+// derive enum value prefixes, e.g. "UserStatus" to "USER_STATUS" and "HTTPMethod" to
+// "HTTP_METHOD".
+func upperSnakeCase(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if i > 0 && 'A' <= c && c <= 'Z' {
+			prev := rune(name[i-1])
+			nextLower := i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z'
+			if ('a' <= prev && prev <= 'z') || ('0' <= prev && prev <= '9') || (nextLower && 'A' <= prev && prev <= 'Z') {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(c)
+	}
+	return strings.ToUpper(b.String())
+}
+
 // getKindTypeName maps Protocol Buffer field kinds to JSON Schema type names.
 //
 // This follows the proto3 JSON mapping specification, with special handling:
@@ -1132,7 +4982,11 @@ func (sg *MessageSchemaGenerator) getKindTypeName(desc protoreflect.FieldDescrip
 		return jsBoolean, nil
 
 	case protoreflect.EnumKind:
-		// Enums use integer type for encoding/json compatibility (numeric values).
+		// Enums use integer type for encoding/json compatibility (numeric values),
+		// or string type for value names with the enum_names parameter.
+		if sg.gr.opts.EnumNames {
+			return jsString, nil
+		}
 		return jsInteger, nil
 
 	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
@@ -1159,7 +5013,7 @@ func (sg *MessageSchemaGenerator) getKindTypeName(desc protoreflect.FieldDescrip
 		return jsObject, nil
 
 	case protoreflect.GroupKind:
-		// Groups (deprecated proto2 feature) are treated like messages.
+		// Groups (proto2) and delimited-encoded message fields (editions) are messages.
 		return jsObject, nil
 
 	default:
@@ -1178,6 +5032,28 @@ func (gr *Generator) escapeGoString(s string) string {
 	return quoted[1 : len(quoted)-1]
 }
 
+// definitionID returns the $id of the definition of a message or enum with
+// schema_base_uri: the base URI followed by the proto package as a path and the name
+// within the package (e.g. ".../users/v1/Address.AddressDetails.json"). It returns "" if
+// the parameter is not set.
+func (gr *Generator) definitionID(desc protoreflect.Descriptor) string {
+	if gr.schemaBaseURI == "" {
+		return ""
+	}
+	pkg := desc.ParentFile().Package()
+	name := strings.TrimPrefix(string(desc.FullName()), string(pkg)+".")
+	return fmt.Sprintf("%s/%s/%s.json", gr.schemaBaseURI, strings.ReplaceAll(string(pkg), ".", "/"), name)
+}
+
+// definitionRef returns the reference to the definition of a message or enum: its $id
+// with schema_base_uri, and otherwise the "#/$defs/" pointer to it.
+func (gr *Generator) definitionRef(desc protoreflect.Descriptor) string {
+	if id := gr.definitionID(desc); id != "" {
+		return id
+	}
+	return "#/$defs/" + string(desc.FullName())
+}
+
 // getTitleAndDescription extracts title and description from proto comments.
 //
 // The parsing follows a convention where:
@@ -1250,6 +5126,289 @@ func (sg *MessageSchemaGenerator) getEnumValuesFromDescriptor(enumDesc protorefl
 	return enumValues
 }
 
+// getEnumNames returns the value names of an enum when the enum_names parameter is set
+// (the representation protojson emits), or nil otherwise.
+// Example: ["USER_STATUS_UNSPECIFIED", "USER_STATUS_ACTIVE", ...] for UserStatus enum
+func (sg *MessageSchemaGenerator) getEnumNames(enumDesc protoreflect.EnumDescriptor) []string {
+	if !sg.gr.opts.EnumNames {
+		return nil
+	}
+	return sg.gr.enumValueNames(enumDesc)
+}
+
+// enumValueNames returns the names representing the values of an enum with the
+// enum_names parameter, in declaration order. With the strip_enum_prefix parameter, the
+// enum's name in UPPER_SNAKE_CASE is removed from the value names (e.g. "ACTIVE" for
+// USER_STATUS_ACTIVE of UserStatus), unless a value lacks the prefix or would start with
+// a digit without it, so that the names stay distinct and valid identifiers. The
+// enum_case parameter then rewrites their casing, unless that makes two names equal.
+func (gr *Generator) enumValueNames(enumDesc protoreflect.EnumDescriptor) []string {
+	values := enumDesc.Values()
+	names := make([]string, values.Len())
+	for i := range names {
+		names[i] = string(values.Get(i).Name())
+	}
+	return gr.caseEnumValueNames(gr.stripEnumValueNames(enumDesc, names))
+}
+
+// stripEnumValueNames removes the enum name prefix from names with the
+// strip_enum_prefix parameter (see enumValueNames).
+func (gr *Generator) stripEnumValueNames(enumDesc protoreflect.EnumDescriptor, names []string) []string {
+	if !gr.opts.StripEnumPrefix {
+		return names
+	}
+	prefix := upperSnakeCase(string(enumDesc.Name())) + "_"
+	stripped := make([]string, len(names))
+	for i, name := range names {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok || rest == "" || ('0' <= rest[0] && rest[0] <= '9') {
+			return names
+		}
+		stripped[i] = rest
+	}
+	return stripped
+}
+
+// rewritesEnumCase reports whether the enum_case parameter changes value names.
+func (gr *Generator) rewritesEnumCase() bool {
+	return gr.opts.EnumCase != "" && gr.opts.EnumCase != enumCaseOriginal
+}
+
+// caseEnumValueNames rewrites the casing of names with the enum_case parameter (e.g.
+// "user-status-active" for USER_STATUS_ACTIVE with kebab). Names are returned unchanged
+// if two of them would collide, e.g. FOO and foo with lower.
+func (gr *Generator) caseEnumValueNames(names []string) []string {
+	if !gr.rewritesEnumCase() {
+		return names
+	}
+	cased := make([]string, len(names))
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		switch gr.opts.EnumCase {
+		case enumCaseLower:
+			cased[i] = strings.ToLower(name)
+		case enumCaseUpper:
+			cased[i] = strings.ToUpper(name)
+		case enumCaseKebab:
+			cased[i] = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+		}
+		if seen[cased[i]] {
+			return names
+		}
+		seen[cased[i]] = true
+	}
+	return cased
+}
+
+// anyType is one message allowed in a google.protobuf.Any field by the any_types parameter.
+type anyType struct {
+	// typeURL is the "@type" value of the message, e.g. "type.googleapis.com/users.v1.User".
+	typeURL string
+
+	// ref is the Go expression retrieving the message schema (see referenceName).
+	ref string
+}
+
+// enumAlias is the list of alternative strings accepted for one enum value by the
+// enum_alias parameter.
+type enumAlias struct {
+	// name is the value's name in the schema (see enumValueNames).
+	name string
+
+	// aliases are the alternative strings, in parameter order.
+	aliases []string
+}
+
+// enumConst is one allowed value of an enum in a oneOf-of-const representation.
+type enumConst struct {
+	// literal is the Go literal of the const: the value number, or the quoted value name
+	// with the enum_names parameter.
+	literal string
+
+	// description is the value's description from its leading comments.
+	description string
+
+	// deprecated reports whether the value is marked deprecated = true.
+	deprecated bool
+}
+
+// getEnumOneOf returns the const branches of an enum when the enum_oneof parameter is set,
+// or nil otherwise. Aliases of an earlier value (allow_alias) are skipped when consts are
+// numbers, since duplicate branches would make every instance fail oneOf.
+// Example: [{0, "Unspecified status."}, {1, "The user is active."}, ...] for UserStatus enum
+func (sg *MessageSchemaGenerator) getEnumOneOf(enumDesc protoreflect.EnumDescriptor) []enumConst {
+	if !sg.gr.opts.EnumOneOf {
+		return nil
+	}
+	var consts []enumConst
+	seen := make(map[protoreflect.EnumNumber]bool)
+	values := enumDesc.Values()
+	for i := 0; i < values.Len(); i++ {
+		value := values.Get(i)
+		literal := fmt.Sprintf("%q", sg.gr.enumValueNames(enumDesc)[i])
+		if !sg.gr.opts.EnumNames {
+			if seen[value.Number()] {
+				continue
+			}
+			seen[value.Number()] = true
+			literal = fmt.Sprintf("%d", value.Number())
+		}
+		consts = append(consts, enumConst{literal: literal, description: sg.gr.getEnumValueDescription(value), deprecated: isDeprecated(value)})
+	}
+	return consts
+}
+
+// getEnumValueDescription returns the description of an enum value from its leading
+// comments, keeping a title paragraph (see getTitleAndDescription) as part of it.
+func (gr *Generator) getEnumValueDescription(value protoreflect.EnumValueDescriptor) string {
+	title, description := gr.getTitleAndDescription(value)
+	if title != "" {
+		description = title + "\n\n" + description
+	}
+	return description
+}
+
+// withEnumValueDescriptions returns description followed by a list of the enum's values
+// and their descriptions when the enum_descriptions parameter is set, or description
+// otherwise. Values are listed by schema name, with their number when the schema holds
+// numbers; values without comments are left out.
+// Example: "Status of a user.\n\nValues:\n- USER_STATUS_ACTIVE (1): The user is active."
+func (gr *Generator) withEnumValueDescriptions(description string, enumDesc protoreflect.EnumDescriptor) string {
+	if !gr.opts.EnumDescriptions {
+		return description
+	}
+	var lines []string
+	values := enumDesc.Values()
+	for i := 0; i < values.Len(); i++ {
+		value := values.Get(i)
+		text := strings.Join(strings.Fields(gr.getEnumValueDescription(value)), " ")
+		if text == "" {
+			continue
+		}
+		name := gr.enumValueNames(enumDesc)[i]
+		if !gr.opts.EnumNames {
+			name = fmt.Sprintf("%s (%d)", name, value.Number())
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", name, text))
+	}
+	if len(lines) == 0 {
+		return description
+	}
+	list := "Values:\n" + strings.Join(lines, "\n")
+	if description == "" {
+		return list
+	}
+	return description + "\n\n" + list
+}
+
+// getOptionsSnapshot returns the effective options used to generate a message's schema:
+// non-default plugin parameters, the file and message options, and the options of
+// each field that sets any. Empty sections are omitted.
+func (gr *Generator) getOptionsSnapshot(message *protogen.Message) map[string]any {
+	snapshot := make(map[string]any)
+	if params := gr.opts.params(); len(params) > 0 {
+		snapshot["plugin"] = params
+	}
+	file := message.Desc.ParentFile()
+	if opts := proto.GetExtension(file.Options(), optionsPb.E_File).(*optionsPb.FileOptions).GetJsonSchema(); opts != nil {
+		snapshot["file"] = optionFieldValues(opts)
+	}
+	if opts := getMessageJsonSchemaOptions(message); opts != nil {
+		snapshot["message"] = optionFieldValues(opts)
+	}
+	fields := make(map[string]any)
+	for _, field := range message.Fields {
+		if opts := getFieldJsonSchemaOptions(field); opts != nil {
+			fields[gr.getFieldName(field)] = optionFieldValues(opts)
+		}
+	}
+	if len(fields) > 0 {
+		snapshot["fields"] = fields
+	}
+	return snapshot
+}
+
+// getProvenance returns the x-generator block of a message's schema (provenance): the
+// plugin version, the options hash, and the message's proto source file and package.
+func (gr *Generator) getProvenance(message *protogen.Message) map[string]any {
+	file := message.Desc.ParentFile()
+	return map[string]any{
+		"name":         "protoc-gen-go-jsonschema",
+		"version":      gr.Version,
+		"options_hash": gr.opts.hash(),
+		"source":       file.Path(),
+		"package":      string(file.Package()),
+	}
+}
+
+// optionFieldValues returns the populated fields of an options message keyed by
+// proto field name.
+func optionFieldValues(opts proto.Message) map[string]any {
+	values := make(map[string]any)
+	opts.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		values[string(fd.Name())] = v.Interface()
+		return true
+	})
+	return values
+}
+
+// inferFormat returns the format infer_formats gives a string field (or string map
+// values) from its name, or "" if its name suggests none.
+func inferFormat(field *protogen.Field) string {
+	kind := field.Desc.Kind()
+	if field.Desc.IsMap() {
+		kind = field.Desc.MapValue().Kind()
+	}
+	if kind != protoreflect.StringKind {
+		return ""
+	}
+	name := string(field.Desc.Name())
+	switch {
+	case name == "email" || strings.HasSuffix(name, "_email"):
+		return "email"
+	case name == "url" || name == "uri" || strings.HasSuffix(name, "_url") || strings.HasSuffix(name, "_uri"):
+		return "uri"
+	case strings.HasSuffix(name, "_at") || strings.HasSuffix(name, "_time"):
+		return "date-time"
+	}
+	return ""
+}
+
+// goLiteral renders a value built from maps, strings, booleans and numbers as a
+// Go expression of type any, with map keys sorted for deterministic output.
+func goLiteral(v any) string {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("map[string]any{")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%s: %s", strconv.Quote(k), goLiteral(v[k]))
+		}
+		b.WriteString("}")
+		return b.String()
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, e := range v {
+			quoted[i] = strconv.Quote(e)
+		}
+		return "[]string{" + strings.Join(quoted, ", ") + "}"
+	case float32, float64:
+		return fmt.Sprintf("%g", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
 // -----------------------------------------------------------------------------
 // Proto Options Extraction Helpers
 // -----------------------------------------------------------------------------
@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// requestIndex indexes the declarations of the files in a plugin run, so that the
+// parameters naming files, messages, fields, oneofs or methods are resolved by lookups
+// instead of each walking the files again. Built once per plugin run by newRequestIndex.
+type requestIndex struct {
+	// files maps proto file paths to their messages, nested ones included, in declaration
+	// order (each message before its nested messages).
+	files map[string][]*protogen.Message
+
+	// messages, fields, oneofs and methods map full names to their declarations. Synthetic
+	// oneofs of proto3 optional fields are left out.
+	messages map[protoreflect.FullName]*protogen.Message
+	fields   map[protoreflect.FullName]*protogen.Field
+	oneofs   map[protoreflect.FullName]*protogen.Oneof
+	methods  map[protoreflect.FullName]*protogen.Method
+}
+
+// newRequestIndex indexes the files of a plugin run.
+func newRequestIndex(gen *protogen.Plugin) *requestIndex {
+	index := &requestIndex{
+		files:    make(map[string][]*protogen.Message),
+		messages: make(map[protoreflect.FullName]*protogen.Message),
+		fields:   make(map[protoreflect.FullName]*protogen.Field),
+		oneofs:   make(map[protoreflect.FullName]*protogen.Oneof),
+		methods:  make(map[protoreflect.FullName]*protogen.Method),
+	}
+	var walk func(path string, msgs []*protogen.Message)
+	walk = func(path string, msgs []*protogen.Message) {
+		for _, msg := range msgs {
+			index.files[path] = append(index.files[path], msg)
+			index.messages[msg.Desc.FullName()] = msg
+			for _, field := range msg.Fields {
+				index.fields[field.Desc.FullName()] = field
+			}
+			for _, oneof := range msg.Oneofs {
+				if !oneof.Desc.IsSynthetic() {
+					index.oneofs[oneof.Desc.FullName()] = oneof
+				}
+			}
+			walk(path, msg.Messages)
+		}
+	}
+	for _, f := range gen.Files {
+		index.files[f.Desc.Path()] = nil
+		walk(f.Desc.Path(), f.Messages)
+		for _, service := range f.Services {
+			for _, method := range service.Methods {
+				index.methods[method.Desc.FullName()] = method
+			}
+		}
+	}
+	return index
+}
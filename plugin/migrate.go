@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Migration is a proto edit needed to upgrade option usage that the plugin no longer
+// accepts, as reported by Migrate.
+type Migration struct {
+	// Path is the proto file declaring the field.
+	Path string
+
+	// Line and Column locate the field declaration (1-based); both are 0 when the
+	// descriptor set has no source info.
+	Line, Column int
+
+	// Field is the full name of the field, e.g. "users.v1.User.age".
+	Field string
+
+	// Problem describes why the option is no longer accepted.
+	Problem string
+
+	// Edit is the change to make in the proto source.
+	Edit string
+}
+
+// String formats the migration as "path:line:column: field: problem" followed by the
+// edit on an indented line.
+func (m Migration) String() string {
+	pos := m.Path
+	if m.Line > 0 {
+		pos = fmt.Sprintf("%s:%d:%d", m.Path, m.Line, m.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s\n\t%s", pos, m.Field, m.Problem, m.Edit)
+}
+
+// Migrate scans the files to generate for option usage that earlier releases accepted
+// but generation now rejects, and returns the proto edits needed to upgrade, in file and
+// field order. Fields of every message are scanned, whether or not the message generates
+// a schema, so that enabling generation later does not fail.
+//
+// Current migrations remove field options that do not apply to their field (see
+// fieldOptionViolations). The plugin options that change how fields are typed
+// (enum_names, duration_seconds, well_known_types) should match those used to generate.
+func Migrate(plugin *protogen.Plugin, opts Options) ([]Migration, error) {
+	if err := opts.validateWellKnownTypes(); err != nil {
+		return nil, err
+	}
+	gr := &Generator{opts: opts, index: newRequestIndex(plugin)}
+	durationSeconds, err := gr.getDurationSeconds()
+	if err != nil {
+		return nil, err
+	}
+	gr.durationSeconds = durationSeconds
+
+	var migrations []Migration
+	for _, f := range plugin.Files {
+		if !f.Generate {
+			continue
+		}
+		sg := &MessageSchemaGenerator{gr: gr, file: f}
+		for _, message := range gr.index.files[f.Desc.Path()] {
+			for _, field := range message.Fields {
+				if getFieldJsonSchemaOptions(field).GetIgnore() {
+					continue
+				}
+				for _, v := range sg.fieldOptionViolations(field) {
+					migrations = append(migrations, newFieldOptionMigration(field, v))
+				}
+			}
+		}
+	}
+	return migrations, nil
+}
+
+// newFieldOptionMigration returns the migration removing the option reported by v
+// from field.
+func newFieldOptionMigration(field *protogen.Field, v fieldOptionViolation) Migration {
+	m := Migration{
+		Path:    field.Desc.ParentFile().Path(),
+		Field:   string(field.Desc.FullName()),
+		Problem: fmt.Sprintf("json_schema option %s applies only to %s, not %s", v.option, v.target, v.got),
+		Edit:    fmt.Sprintf("remove `%s` from the field's (alis.open.options.v1.field).json_schema options", fieldOptionText(field, v.option)),
+	}
+	if loc := field.Desc.ParentFile().SourceLocations().ByDescriptor(field.Desc); loc.Path != nil {
+		m.Line, m.Column = loc.StartLine+1, loc.StartColumn+1
+	}
+	return m
+}
+
+// fieldOptionText renders a field's json_schema option as it is written in a proto
+// file, e.g. `max_items: 2` or `pattern: "^[a-z]+$"`.
+func fieldOptionText(field *protogen.Field, option string) string {
+	msg := getFieldJsonSchemaOptions(field).ProtoReflect()
+	fd := msg.Descriptor().Fields().ByName(protoreflect.Name(option))
+	value := msg.Get(fd)
+	if fd.Kind() == protoreflect.StringKind {
+		return fmt.Sprintf("%s: %s", option, strconv.Quote(value.String()))
+	}
+	return fmt.Sprintf("%s: %v", option, value.Interface())
+}
@@ -0,0 +1,1264 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// draft202012Vocabularies are the URIs of the vocabularies of the draft 2020-12
+// meta-schema, which JsonSchema() roots declare with the vocabulary parameter.
+var draft202012Vocabularies = []string{
+	"https://json-schema.org/draft/2020-12/vocab/core",
+	"https://json-schema.org/draft/2020-12/vocab/applicator",
+	"https://json-schema.org/draft/2020-12/vocab/unevaluated",
+	"https://json-schema.org/draft/2020-12/vocab/validation",
+	"https://json-schema.org/draft/2020-12/vocab/meta-data",
+	"https://json-schema.org/draft/2020-12/vocab/format-annotation",
+	"https://json-schema.org/draft/2020-12/vocab/content",
+}
+
+// Supported values of the draft plugin parameter.
+const (
+	draft202012 = "2020-12"
+	draft07     = "draft-07"
+)
+
+// Supported values of the schema_lib plugin parameter.
+const (
+	schemaLibGoogle   = "google"
+	schemaLibSanthosh = "santhosh"
+	schemaLibInvopop  = "invopop"
+	schemaLibOpenAPI3 = "openapi3"
+)
+
+// Supported values of the target plugin parameter, in the order their accessor files are
+// generated. They match the profiles of schemautil.ToProfile.
+const (
+	targetMCP    = "mcp"
+	targetOpenAI = "openai"
+	targetGemini = "gemini"
+	targetClaude = "claude"
+	targetPlain  = "plain"
+)
+
+// supportedTargets lists the supported values of the target plugin parameter.
+var supportedTargets = []string{targetMCP, targetOpenAI, targetGemini, targetClaude, targetPlain}
+
+// Supported values of the stream_framing plugin parameter.
+const (
+	streamFramingNDJSON = "ndjson"
+	streamFramingArray  = "array"
+)
+
+// Supported values of the enum_case plugin parameter.
+const (
+	enumCaseOriginal = "original"
+	enumCaseLower    = "lower"
+	enumCaseUpper    = "upper"
+	enumCaseKebab    = "kebab"
+)
+
+// Supported values of the oneof_presence plugin parameter.
+const (
+	oneofPresenceAtMostOne  = "at_most_one"
+	oneofPresenceExactlyOne = "exactly_one"
+)
+
+// Supported values of the external_schemas plugin parameter.
+const (
+	externalSchemasReference = "reference"
+	externalSchemasAuto      = "auto"
+	externalSchemasInline    = "inline"
+)
+
+// Supported values of the field_names plugin parameter.
+const (
+	fieldNamesSnake    = "snake"
+	fieldNamesCamel    = "camel"
+	fieldNamesJSONName = "json_name"
+)
+
+// Supported values of the well_known_types plugin parameter.
+const (
+	wellKnownTypesEncodingJSON = "encoding_json"
+	wellKnownTypesProtojson    = "protojson"
+)
+
+// Options holds plugin-wide settings supplied as protoc plugin parameters
+// (e.g. --go-jsonschema_opt=object_root=true). The zero value reproduces the
+// default generation behaviour.
+//
+// Parameters that select files, messages, fields, oneofs or methods by path or full name,
+// such as closed_objects or identifier, have no counterpart in the options proto
+// (alis.open.options.v1), so they are only plugin parameters.
+//
+// The param tag holds the name of the plugin parameter for each field, and the usage,
+// example and (for parameters whose zero value selects a named default) default tags
+// document it; see Params. snapshot:"-" marks parameters that do not affect the schemas
+// and are left out of x-generation-options, and unordered:"true" marks list parameters
+// whose values form a set, so that their order does not change the options hash.
+type Options struct {
+	// ObjectRoot makes JsonSchema() return the message's own object schema as the
+	// root instead of a ref-as-root wrapper, so that schema.Type == "object" holds.
+	// The message's entry in $defs is re-pointed to "#" so self-references still resolve.
+	ObjectRoot bool `param:"object_root" usage:"Return the message's object schema as the JsonSchema() root instead of a $ref wrapper" example:"object_root=true"`
+
+	// DurationSeconds represents google.protobuf.Duration fields as a JSON number of
+	// seconds (e.g. 3.5) instead of the Duration message's object schema; the
+	// duration_seconds parameter may be repeated. Each value is "true" (every Duration
+	// field) or the full name of a Duration field (e.g. "users.v1.Job.timeout"), whose
+	// values, elements for repeated fields and map values for maps, become numbers.
+	DurationSeconds []string `param:"duration_seconds" unordered:"true" usage:"Represent google.protobuf.Duration fields as a number of seconds (true or a field full name); may be repeated" example:"duration_seconds=users.v1.Job.timeout"`
+
+	// OptionsSnapshot embeds the effective options used for each message schema
+	// (plugin parameters, file, message and field options) as x-generation-options.
+	OptionsSnapshot bool `param:"options_snapshot" usage:"Embed the effective options used for each message schema as x-generation-options" example:"options_snapshot=true"`
+
+	// PropertyOrder records the proto declaration order of each message's properties as
+	// x-property-order, since the properties of a schema form an unordered map. Form
+	// renderers can follow it, and schemautil.ToGemini emits it as propertyOrdering.
+	PropertyOrder bool `param:"property_order" usage:"Record the proto field order of each message's properties as x-property-order" example:"property_order=true"`
+
+	// PresenceMetadata annotates each property with x-proto-presence: "explicit" when the
+	// field tracks presence (optional scalars, messages, oneof members), so an absent
+	// property means unset, or "implicit" when an absent property means the default value
+	// (other scalars, repeated and map fields). Tooling that builds field masks from
+	// partial documents uses it to tell the two apart.
+	PresenceMetadata bool `param:"presence_metadata" usage:"Annotate each property with x-proto-presence (explicit, implicit)" example:"presence_metadata=true"`
+
+	// NullableOptional lets optional fields (the optional keyword, or explicit presence in
+	// editions files) hold null as well as a value, for clients that send explicit nulls
+	// for unset fields: scalars get a type union with null (e.g. ["string", "null"]), enum
+	// lists and oneOfs a null value, and message and shared enum references an anyOf with
+	// {"type": "null"}.
+	NullableOptional bool `param:"nullable_optional" usage:"Allow null for optional fields (type unions with null, or anyOf with null for references)" example:"nullable_optional=true"`
+
+	// ClosedObjects lists the message schemas that reject properties they do not declare
+	// (additionalProperties: false); the closed_objects parameter may be repeated. Each
+	// value is "all" (every message), a proto file path (the messages declared in the
+	// file, e.g. "users/v1/user.proto") or a message full name (e.g. "users.v1.User").
+	ClosedObjects []string `param:"closed_objects" unordered:"true" usage:"Message schemas that reject undeclared properties (all, a proto file path or a message full name); may be repeated" example:"closed_objects=users/v1/user.proto"`
+
+	// AdditionalProperties sets the additionalProperties keyword of message schemas; the
+	// additional_properties parameter may be repeated. Each value has the form
+	// <message>=<value>, with the message's full name (e.g. "users.v1.Metadata=string"),
+	// where the value is false (reject undeclared properties), true (accept any, for
+	// messages that act as open property bags) or a JSON type that undeclared properties
+	// must have. It takes precedence over closed_objects.
+	AdditionalProperties []string `param:"additional_properties" usage:"additionalProperties of a message schema (<message>=<false, true or a JSON type>); may be repeated" example:"additional_properties=users.v1.Metadata=string"`
+
+	// UnevaluatedProperties lists the message schemas that set unevaluatedProperties to
+	// false, which unlike additionalProperties also accepts the properties declared by
+	// oneOf, anyOf and allOf branches; the unevaluated_properties parameter may be
+	// repeated. Values are resolved like those of closed_objects.
+	UnevaluatedProperties []string `param:"unevaluated_properties" unordered:"true" usage:"Message schemas that set unevaluatedProperties to false (all, a proto file path or a message full name); may be repeated" example:"unevaluated_properties=all"`
+
+	// Presets applies named sets of cross-field constraints to message schemas; the preset
+	// parameter may be repeated. Each value has the form <message>=<preset>, with the
+	// message's full name (e.g. "users.v1.Price=money"). The only preset is money, for
+	// messages with singular int64 units and int32 nanos fields like google.type.Money:
+	// nanos is bounded to ±999,999,999 and must not have the opposite sign of units.
+	Presets []string `param:"preset" usage:"Named cross-field constraints of a message schema (<message>=money); may be repeated" example:"preset=users.v1.Price=money"`
+
+	// CELConstraints attach cross-field rules written in CEL to message schemas; the
+	// cel_constraint parameter may be repeated. Each value has the form
+	// <message>=<expression or file>, with the message's full name and a CEL expression
+	// over this, the decoded JSON object of the message (e.g.
+	// "users.v1.Booking=this.start_day <= this.end_day"), given inline when it has no
+	// commas (which separate plugin parameters) or as the path of a .cel file holding it.
+	// Expressions are checked to compile at generation time and emitted, in parameter
+	// order, as the schema's x-constraints list, which JSON Schema validators ignore and
+	// schemautil.EvaluateConstraints evaluates after validation.
+	CELConstraints []string `param:"cel_constraint" usage:"CEL expression over this a message's JSON object must satisfy, emitted as x-constraints (<message>=<expression or .cel file path>); may be repeated" example:"cel_constraint=users.v1.Booking=this.start_day <= this.end_day"`
+
+	// DiscriminatedOneofs represents oneofs as discriminated unions; the
+	// discriminated_oneof parameter may be repeated. Each value has the form
+	// <oneof>=<property>, with the oneof's full name (e.g.
+	// "users.v1.OneOfDemo.field1=kind"). Instead of the members' properties and the
+	// Required-based constraint, the message gets a property named after the oneof whose
+	// value is one of a set of objects, each holding the discriminator property, with the
+	// member's name as a const, and the member.
+	DiscriminatedOneofs []string `param:"discriminated_oneof" usage:"Oneofs to represent as discriminated unions under a property named after the oneof (<oneof>=<discriminator property>); may be repeated" example:"discriminated_oneof=users.v1.OneOfDemo.field1=kind"`
+
+	// ExternalSchemas selects how schemas reference messages declared in files outside the
+	// run: "reference" calls the helpers their declaring packages are assumed to define
+	// (and fails if the declaring file's options do not target the message), "auto" does
+	// so only for messages the declaring file's options target and inlines the others with
+	// a warning, and "inline" emits every such message into the referencing package as a
+	// standalone function, like Google types, for imported packages that were not
+	// generated with this plugin.
+	ExternalSchemas string `param:"external_schemas" default:"reference" usage:"Handling of messages declared outside the run (reference, auto, inline)" example:"external_schemas=auto"`
+
+	// OneofPresence selects how many members of a oneof a message may set: "at_most_one"
+	// (the default, proto semantics) adds a branch matching documents that set none of
+	// them to the oneOf constraint, "exactly_one" omits it so that one member is required,
+	// for APIs that reject requests leaving a oneof unset.
+	OneofPresence string `param:"oneof_presence" default:"at_most_one" usage:"Number of oneof members a message may set (at_most_one, exactly_one)" example:"oneof_presence=exactly_one"`
+
+	// Drafts lists the JSON Schema drafts to emit ("2020-12", "draft-07"); the draft
+	// parameter may be repeated. Draft 2020-12 code is always generated; "draft-07"
+	// additionally emits a <file>_jsonschema_draft07.pb.go file with JsonSchemaDraft07()
+	// accessors.
+	Drafts []string `param:"draft" unordered:"true" usage:"JSON Schema draft to emit in addition to 2020-12 (draft-07); may be repeated" example:"draft=draft-07"`
+
+	// SchemaLib selects the JSON Schema library targeted by generated code ("google",
+	// "santhosh", "invopop" or "openapi3"). Schemas are always constructed with
+	// github.com/google/jsonschema-go; "santhosh" additionally emits a
+	// <file>_jsonschema_santhosh.pb.go file with JsonSchemaSanthosh() accessors that compile
+	// them with github.com/santhosh-tekuri/jsonschema/v6, "invopop" a
+	// <file>_jsonschema_invopop.pb.go file with JsonSchemaInvopop() accessors that convert
+	// them to github.com/invopop/jsonschema structs, and "openapi3" a
+	// <file>_jsonschema_openapi3.pb.go file with JsonSchemaOpenAPI3() accessors that build
+	// github.com/getkin/kin-openapi/openapi3 schemas.
+	SchemaLib string `param:"schema_lib" default:"google" usage:"JSON Schema library targeted by generated code (google, santhosh, invopop, openapi3)" example:"schema_lib=santhosh"`
+
+	// FieldNames selects the property keys used for message fields: "snake" (the proto
+	// field name, the default), "camel" (lowerCamelCase of the proto field name) or
+	// "json_name" (the protojson name, honoring custom json_name options).
+	FieldNames string `param:"field_names" default:"snake" usage:"Property keys used for message fields (snake, camel, json_name)" example:"field_names=json_name"`
+
+	// EnumNames represents enum fields as strings restricted to the enum's value names
+	// (e.g. "USER_STATUS_ACTIVE", as protojson emits) instead of integers.
+	EnumNames bool `param:"enum_names" usage:"Represent enum fields as strings restricted to the enum's value names" example:"enum_names=true"`
+
+	// StripEnumPrefix removes the enum's name, in UPPER_SNAKE_CASE, from the value names
+	// emitted with EnumNames (e.g. "ACTIVE" for USER_STATUS_ACTIVE of UserStatus), following
+	// API style guides that prefix enum values. Enums with values lacking the prefix keep
+	// their names. Shared enum definitions get a <Enum>_JsonSchemaValue() function mapping
+	// the stripped names back to the values' numbers.
+	StripEnumPrefix bool `param:"strip_enum_prefix" usage:"Remove the enum name prefix from value names emitted with enum_names (requires enum_names)" example:"strip_enum_prefix=true"`
+
+	// EnumCase rewrites the casing of the value names emitted with EnumNames (after
+	// StripEnumPrefix), for gateways that rewrite enum values: "original" (the default)
+	// keeps the proto names, "lower" and "upper" change their case and "kebab" also
+	// replaces underscores with hyphens (e.g. "user-status-active"). Enums whose names
+	// would collide keep theirs. Shared enum definitions get a <Enum>_JsonSchemaValue()
+	// function mapping the rewritten names back to the values' numbers.
+	EnumCase string `param:"enum_case" default:"original" usage:"Casing of value names emitted with enum_names (original, lower, upper, kebab; requires enum_names)" example:"enum_case=kebab"`
+
+	// EnumAliases accepts alternative strings for the values of enum fields; the
+	// enum_alias parameter may be repeated. Each value has the form
+	// <field>=<value>=<alias>|<alias>..., with the field's full name and the proto name of
+	// the enum value (e.g. "users.v1.User.status=USER_STATUS_ACTIVE=active|enabled"). The
+	// field's values become an anyOf of the enum's schema and one list of aliases per
+	// aliased value, and the file gets a <Message>_<Field>_JsonSchemaNormalize() function
+	// mapping aliases to the schema's value names. Requires EnumNames.
+	EnumAliases []string `param:"enum_alias" usage:"Alternative strings accepted for a value of an enum field (<field>=<value>=<alias>|<alias>...; requires enum_names); may be repeated" example:"enum_alias=users.v1.User.status=USER_STATUS_ACTIVE=active|enabled"`
+
+	// EnumOneOf represents the allowed values of enums as a oneOf of const schemas, each
+	// described by the value's leading comments, instead of a bare enum list. Consts are
+	// numbers, or value names with EnumNames.
+	EnumOneOf bool `param:"enum_oneof" usage:"Represent enum values as a oneOf of consts described by the values' comments" example:"enum_oneof=true"`
+
+	// EnumDescriptions appends the enum's values with their leading comments to the
+	// description of enum schemas: that of the shared definition, or the field's for enums
+	// emitted inline. Unlike EnumOneOf it keeps the plain enum list, for consumers that only
+	// read descriptions.
+	EnumDescriptions bool `param:"enum_descriptions" usage:"Append the enum values and their comments to the description of enum schemas" example:"enum_descriptions=true"`
+
+	// WellKnownTypes selects the JSON serialization targeted by well-known type schemas:
+	// "encoding_json" (the default) keeps the message object schemas that encoding/json
+	// produces, "protojson" maps google.protobuf.Timestamp, Duration and FieldMask to their
+	// canonical protojson strings.
+	WellKnownTypes string `param:"well_known_types" default:"encoding_json" usage:"Serialization targeted by well-known type schemas (encoding_json, protojson)" example:"well_known_types=protojson"`
+
+	// TimestampUTC restricts the google.protobuf.Timestamp strings of WellKnownTypes
+	// "protojson" to UTC, with a "Z" suffix rather than an offset: protojson writes them
+	// so, but also reads offsets.
+	TimestampUTC bool `param:"timestamp_utc" usage:"Require google.protobuf.Timestamp strings to be in UTC, with a Z suffix (requires well_known_types=protojson)" example:"timestamp_utc=true"`
+
+	// TimestampBounds bounds the google.protobuf.Timestamp strings of fields under
+	// WellKnownTypes "protojson"; the timestamp_bounds parameter may be repeated. Each
+	// value has the form <field>=<min>..<max>, with the field's full name and inclusive
+	// bounds that are RFC 3339 date-times or dates (midnight UTC), either of which may be
+	// omitted (e.g. "users.v1.User.birth_time=1900-01-01.."). Bounds are emitted as the
+	// x-formatMinimum and x-formatMaximum keywords of the strings, which JSON Schema
+	// validators ignore and schemautil.EvaluateConstraints (and schemautil.Validator)
+	// check.
+	TimestampBounds []string `param:"timestamp_bounds" unordered:"true" usage:"Inclusive bounds of the google.protobuf.Timestamp strings of a field, as RFC 3339 date-times or dates (<field>=<min>..<max>, either may be omitted; requires well_known_types=protojson); may be repeated" example:"timestamp_bounds=users.v1.User.birth_time=1900-01-01..2100-01-01"`
+
+	// Targets lists the consumer profiles to emit schemas for; the target parameter may
+	// be repeated. Each profile emits a <file>_jsonschema_<profile>.pb.go file with
+	// JsonSchema<Profile>() accessors that strip or rewrite the keywords the consumer does
+	// not support (see schemautil.ToProfile), so one proto feeds several tool frameworks:
+	// "mcp" (MCP tool input schemas), "openai" (OpenAI structured outputs in strict mode),
+	// "gemini" (inlined Gemini function declaration parameters; message schemas also
+	// record their field order as propertyOrdering), "claude" (Claude tool input schemas)
+	// and "plain" (the JsonSchema() schema with JSON Schema keywords only).
+	Targets []string `param:"target" unordered:"true" usage:"Schema profile to emit accessors for (mcp, openai, gemini, claude, plain); may be repeated" example:"target=openai"`
+
+	// StreamFraming emits a <file>_jsonschema_stream.pb.go file describing the response
+	// stream of each server-streaming method as framed by a gateway: "ndjson" (each line
+	// is one response message) or "array" (the stream is a JSON array of response
+	// messages). Unset, no stream schemas are generated.
+	StreamFraming string `param:"stream_framing" snapshot:"-" usage:"Framing of server-streaming responses to emit stream schemas for (ndjson, array)" example:"stream_framing=ndjson"`
+
+	// MethodDescriptions replaces the comments of methods in the schemas generated for
+	// services (see StreamFraming); the method_description parameter may be repeated. Each
+	// value has the form <method>=<description>, with the method's full name (e.g.
+	// "users.v1.UserService.StreamUsers=Streams users."). The service comments still
+	// follow the description. As protoc splits parameters on commas, descriptions cannot
+	// contain any.
+	MethodDescriptions []string `param:"method_description" snapshot:"-" usage:"Description replacing a method's comments in service schemas (<method>=<description>); may be repeated" example:"method_description=users.v1.UserService.StreamUsers=Streams the requested users."`
+
+	// OneofTitles sets the title of the oneOf constraint generated for a oneof, or of its
+	// property with discriminated_oneof, so that tools and validation reports can name it;
+	// the oneof_title parameter may be repeated. Each value has the form <oneof>=<title>,
+	// with the oneof's full name (e.g. "users.v1.OneOfDemo.field1=Scalar value"). With
+	// discriminated_oneof it replaces the title taken from the oneof's comments.
+	OneofTitles []string `param:"oneof_title" snapshot:"-" usage:"Title of a oneof's oneOf constraint or discriminated union property (<oneof>=<title>); may be repeated" example:"oneof_title=users.v1.OneOfDemo.field1=Scalar value"`
+
+	// OneofDescriptions sets the description of the oneOf constraint generated for a
+	// oneof, or of its property with discriminated_oneof, like OneofTitles; the
+	// oneof_description parameter may be repeated. As protoc splits parameters on commas,
+	// descriptions cannot contain any.
+	OneofDescriptions []string `param:"oneof_description" snapshot:"-" usage:"Description of a oneof's oneOf constraint or discriminated union property (<oneof>=<description>); may be repeated" example:"oneof_description=users.v1.OneOfDemo.field1=Set at most one scalar value."`
+
+	// MaxRecursionDepths lets inlining converters (schemautil.ToGemini) expand
+	// self-referencing messages; the max_recursion_depth parameter may be repeated. Each
+	// value has the form <message>=<depth>, with the message's full name (e.g.
+	// "comments.v1.Comment=3"). The message schema records the depth as
+	// x-max-recursion-depth, and references to the message within itself are inlined that
+	// many levels deep before being stubbed with a free-form object. Without it, recursive
+	// messages cannot be inlined.
+	MaxRecursionDepths []string `param:"max_recursion_depth" usage:"Levels a self-referencing message is expanded by inlining profiles before stubbing (<message>=<depth>); may be repeated" example:"max_recursion_depth=users.v1.AddressDetails=3"`
+
+	// DriftDir is the directory holding the previously generated files, usually the
+	// protoc output directory. When set, each regenerated <file>_jsonschema.pb.go whose
+	// previous version is found there lists the message schemas and properties added or
+	// removed since that version in its header comment, to make reviewing regenerated
+	// code tractable.
+	DriftDir string `param:"drift_dir" snapshot:"-" usage:"Directory of previously generated files; summarizes schema changes in regenerated file headers" example:"drift_dir=gen/go"`
+
+	// Verify re-parses each generated file and checks its message schemas against the
+	// source descriptors: every message has a definition, every non-ignored field has a
+	// property and no property lacks a field, and properties with a literal JSON type
+	// agree with their field's type. Mismatches fail generation, guarding against emitter
+	// regressions that golden files would only catch for the messages they cover.
+	Verify bool `param:"verify" snapshot:"-" usage:"Check each generated file's message schemas against the descriptors (field coverage, JSON types) and fail on mismatches" example:"verify=true"`
+
+	// AnyTypes restricts google.protobuf.Any fields to listed message types; the any_types
+	// parameter may be repeated. Each value has the form <field>=<type>|<type>..., with
+	// full names (e.g. "users.v1.Event.payload=users.v1.User|users.v1.Admin"). The field's
+	// Any values are then validated as the protojson form of one of the types: an object
+	// with a matching "@type" URL and the type's properties. The types are the branches of
+	// a oneOf rather than an anyOf: each requires its own "@type", so no value matches two
+	// and both accept the same values, but oneOf tells validators and code generators that
+	// the branches are exclusive.
+	AnyTypes []string `param:"any_types" usage:"Message types allowed in a google.protobuf.Any field (<field>=<type>|<type>...); may be repeated" example:"any_types=users.v1.Event.payload=users.v1.User|users.v1.Admin"`
+
+	// Coercions annotate scalar fields with the JSON types a gateway may coerce to the
+	// field's type before validation; the coerce parameter may be repeated. Each value has
+	// the form <field>=<type>|<type>..., with the field's full name and JSON types among
+	// boolean, integer, number and string (e.g. "users.v1.User.age=string"). The hint is
+	// emitted as x-coerce next to the field's type, which is unchanged, so validators
+	// ignore it and the published schema stays strict. For repeated and map fields, it
+	// applies to the elements and values.
+	Coercions []string `param:"coerce" usage:"JSON types a gateway may coerce to a scalar field's type before validation, emitted as x-coerce (<field>=<type>|<type>...); may be repeated" example:"coerce=users.v1.User.age=string"`
+
+	// Provenance embeds an x-generator block in each message schema recording the plugin
+	// version, a hash of the schema-affecting plugin parameters, and the proto source
+	// file and package of the message, so registries ingesting the serialized schemas can
+	// trace each one back to its source.
+	Provenance bool `param:"provenance" usage:"Embed x-generator in each message schema with the plugin version, options hash, proto source file and package" example:"provenance=true"`
+
+	// Vocabularies declare in-house keyword vocabularies; the vocabulary parameter may be
+	// repeated. Each value has the form <uri>=<keyword>|<keyword>..., with an absolute URI
+	// and the extension keywords the vocabulary defines (e.g.
+	// "https://schemas.example.com/vocab/ui=x-ui-widget|x-sensitive"). JsonSchema() roots
+	// then carry a $vocabulary listing the draft 2020-12 vocabularies as required and the
+	// declared ones as optional, so validators that do not implement them still evaluate
+	// the schemas. Keywords must not be JSON Schema keywords or belong to two vocabularies.
+	Vocabularies []string `param:"vocabulary" usage:"Custom vocabulary declared in the $vocabulary of JsonSchema() roots (<uri>=<keyword>|<keyword>...); may be repeated" example:"vocabulary=https://schemas.example.com/vocab/ui=x-ui-widget|x-sensitive"`
+
+	// AllowedValues restrict enum fields to some of their values; the allowed_values
+	// parameter may be repeated. Each value has the form <field>=<value>|<value>..., with
+	// the field's full name and the values by proto name, schema name (as enum_names,
+	// strip_enum_prefix and enum_case make it) or number (e.g.
+	// "users.v1.User.status=ACTIVE|SUSPENDED"). Only these values are listed in the
+	// field's enum, in declaration order. For repeated and map fields, it applies to the
+	// elements and values. Replaces the protovalidate enum in and not_in rules of the
+	// field.
+	AllowedValues []string `param:"allowed_values" usage:"Values an enum field may take, narrowing its enum (<field>=<value>|<value>..., by name or number); may be repeated" example:"allowed_values=users.v1.ComprehensiveUser.status=USER_STATUS_ACTIVE|USER_STATUS_SUSPENDED"`
+
+	// MultipleOfs constrain numeric fields to multiples of a step; the multiple_of
+	// parameter may be repeated. Each value has the form <field>=<number>, with the
+	// field's full name and a positive step (e.g. "users.v1.Order.amount_cents=5"),
+	// emitted as multipleOf. For repeated and map fields, it applies to the elements and
+	// values.
+	MultipleOfs []string `param:"multiple_of" usage:"Step a numeric field's values must be a multiple of, emitted as multipleOf (<field>=<number>); may be repeated" example:"multiple_of=users.v1.ComprehensiveUser.balance=0.01"`
+
+	// Extensions attach custom extension keywords to fields; the extension parameter may
+	// be repeated. Each value has the form <field>:<keyword>=<value>, with the field's full
+	// name, an x- keyword and a JSON string, number or boolean value, where strings may be
+	// unquoted (e.g. "users.v1.User.bio:x-ui-widget=textarea" or
+	// "users.v1.User.ssn:x-sensitive=true"). Keywords are emitted next to the field's
+	// keywords and take precedence over extension keywords the plugin emits itself. A
+	// field name ending in [items] or [values] targets the schema of the elements of a
+	// repeated field or the values of a map field instead (e.g.
+	// "users.v1.User.tags[items]:x-ui-widget=chip").
+	Extensions []string `param:"extension" usage:"Extension keyword emitted on a field's schema (<field>:<x-keyword>=<JSON scalar>); may be repeated" example:"extension=users.v1.User.bio:x-ui-widget=textarea"`
+
+	// Identifiers lists the identifier fields of resource messages, by full name (e.g.
+	// "users.v1.User.name"); the identifier parameter may be repeated. Like fields with
+	// the google.api.field_behavior IDENTIFIER, they are readOnly, constrained to the name
+	// pattern of their message's google.api.resource annotation, and left out of the input
+	// profile schemas (see schemautil.ToMCP).
+	Identifiers []string `param:"identifier" unordered:"true" usage:"Identifier fields (full name) to mark readOnly, constrain to their resource's name pattern and leave out of input profiles; may be repeated" example:"identifier=users.v1.User.name"`
+
+	// OwnedGooglePackages lists google.* proto packages (e.g. "google.cloud.tasks.v2",
+	// matching its sub-packages too) whose Go code is generated alongside the schemas, so
+	// their messages get JsonSchema methods and are referenced like ordinary messages of
+	// another package instead of through the standalone functions emitted for other
+	// google.* messages. The owned_google_packages parameter may be repeated.
+	// google.protobuf cannot be owned.
+	OwnedGooglePackages []string `param:"owned_google_packages" unordered:"true" usage:"google.* packages (and their sub-packages) whose messages get ordinary methods and cross-package references; may be repeated" example:"owned_google_packages=google.cloud"`
+
+	// SchemaBaseURI gives every definition an $id under the base URI, built from its
+	// proto package and name (e.g. "https://schemas.alis.build/users/v1/User.json" for
+	// users.v1.User with "https://schemas.alis.build"), and makes references to
+	// definitions use it instead of a "#/$defs/" pointer, so that definitions stay
+	// valid when published and referenced across files.
+	SchemaBaseURI string `param:"schema_base_uri" usage:"Absolute base URI of the $id given to every definition (<base>/<package path>/<name>.json) and used by references" example:"schema_base_uri=https://schemas.alis.build"`
+
+	// ResourceTitles makes the schemas of messages annotated with google.api.resource
+	// self-describing: those without a title are titled after the resource's singular
+	// name, or else the last segment of its type (e.g. "Book" for
+	// "library.googleapis.com/Book"), and all of them record the type as
+	// x-resource-type.
+	ResourceTitles bool `param:"resource_titles" usage:"Title resource message schemas after their google.api.resource type and record it as x-resource-type" example:"resource_titles=true"`
+
+	// InferFormats infers the format of string fields without one from their names:
+	// "email" and names ending in "_email" get "email", "url", "uri" and names ending in
+	// "_url" or "_uri" get "uri", and names ending in "_at" or "_time" get "date-time".
+	// Each generated file lists the formats it inferred in a closing comment, so that
+	// authors can confirm them with the format option or override them.
+	InferFormats bool `param:"infer_formats" usage:"Infer email, uri and date-time formats of string fields from their names (*_email, *_url, *_uri, *_at, *_time)" example:"infer_formats=true"`
+
+	// Defaults set the default keyword of fields; the default parameter may be repeated.
+	// Each value has the form <field>=<JSON value>, with the field's full name and its
+	// default in the field's proto JSON form, given inline when it has no commas or as the
+	// path of a file holding it (e.g. "users.v1.User.locale=\"en\"" or
+	// "users.v1.User.tags=defaults/tags.json"). The value is checked against the field's
+	// type when generating and replaces the field's explicit proto default, if any.
+	Defaults []string `param:"default" usage:"Default value of a field, emitted as default (<field>=<JSON value or file path>); may be repeated" example:"default=users.v1.User.age=18"`
+
+	// Consts fix fields to a single value, emitted as const; the const parameter may be
+	// repeated. Values are given like those of the default parameter (e.g.
+	// "users.v1.Event.kind=\"user\"" for a discriminator) and checked against the field's
+	// type when generating. Enum values may be given by name or number, and are emitted
+	// as the field's schema lists them. A const replaces a protovalidate const rule of the
+	// field.
+	Consts []string `param:"const" usage:"Value a field must always have, emitted as const (<field>=<JSON value or file path>); may be repeated" example:"const=users.v1.Event.kind=\"user\""`
+
+	// RawSchemas merge JSON over the generated schemas of fields; the raw_schema parameter
+	// may be repeated. Each value has the form <field>=<JSON object or file>, with the
+	// field's full name and a JSON merge patch (RFC 7386), given inline when it has no
+	// commas (which separate plugin parameters) or as the path of a file holding it (e.g.
+	// "users.v1.User.bio={\"maxLength\":500}" or "users.v1.User.bio=schemas/bio.json").
+	// The patch is checked and merged into the field's schema at generation time, so the
+	// generated code holds the merged keywords. A field name ending in [items] or [values]
+	// targets the schema of the elements of a repeated field or the values of a map field
+	// (e.g. "users.v1.User.tags[items]={\"maxLength\":20}"); string and numeric keywords
+	// such as maxLength are rejected on the field's own schema when it is an array or map.
+	RawSchemas []string `param:"raw_schema" usage:"JSON merge patch applied to a field's generated schema (<field>=<JSON object or file path>); may be repeated" example:"raw_schema=users.v1.User.bio=schemas/bio.json"`
+
+	// Prune emits a <file>_jsonschema_prune.pb.go file giving each message a PruneToSchema
+	// method, which drops the properties of decoded JSON data that the message schema does
+	// not declare (see schemautil.Prune).
+	Prune bool `param:"prune" usage:"Emit PruneToSchema() methods dropping properties the message schema does not declare" example:"prune=true"`
+
+	// CachedAccessors emits a <file>_jsonschema_cached.pb.go file giving each message
+	// JsonSchemaCached and JsonSchemaResolved methods, which build the message schema and
+	// resolve it for validation once, with sync.OnceValue, and return the same values to
+	// every caller, for request handlers that would otherwise rebuild the schema per call.
+	CachedAccessors bool `param:"cached_accessors" usage:"Emit JsonSchemaCached() and JsonSchemaResolved() methods building the schema once" example:"cached_accessors=true"`
+
+	// CoerceToolArgs emits a <file>_jsonschema_toolargs.pb.go file giving each message a
+	// CoerceToolArgs method, which converts the values of tool call arguments that have the
+	// wrong JSON type, such as numeric strings for integers, to the types of the message's
+	// MCP input schema before validation (see schemautil.CoerceToolArgs).
+	CoerceToolArgs bool `param:"coerce_tool_args" usage:"Emit CoerceToolArgs() methods fixing mistyped tool call arguments against MCPInputSchema()" example:"coerce_tool_args=true"`
+}
+
+// drafts returns the JSON Schema drafts requested by the draft parameter, in order and
+// without duplicates, always including 2020-12.
+func (o Options) drafts() ([]string, error) {
+	drafts := []string{draft202012}
+	for _, d := range o.Drafts {
+		switch d = strings.TrimSpace(d); d {
+		case draft202012:
+		case draft07:
+			if len(drafts) == 1 {
+				drafts = append(drafts, d)
+			}
+		default:
+			return nil, fmt.Errorf("invalid draft parameter: unsupported draft %q (supported: %s, %s)", d, draft202012, draft07)
+		}
+	}
+	return drafts, nil
+}
+
+// schemaLib returns the library selected by the schema_lib parameter, defaulting to
+// "google".
+func (o Options) schemaLib() (string, error) {
+	switch o.SchemaLib {
+	case "", schemaLibGoogle:
+		return schemaLibGoogle, nil
+	case schemaLibSanthosh, schemaLibInvopop, schemaLibOpenAPI3:
+		return o.SchemaLib, nil
+	}
+	return "", fmt.Errorf("invalid schema_lib parameter %q (supported: %s, %s, %s, %s)", o.SchemaLib, schemaLibGoogle, schemaLibSanthosh, schemaLibInvopop, schemaLibOpenAPI3)
+}
+
+// validateFieldNames reports an error if the field_names parameter has an unsupported value.
+func (o Options) validateFieldNames() error {
+	switch o.FieldNames {
+	case "", fieldNamesSnake, fieldNamesCamel, fieldNamesJSONName:
+		return nil
+	}
+	return fmt.Errorf("invalid field_names parameter %q (supported: %s, %s, %s)", o.FieldNames, fieldNamesSnake, fieldNamesCamel, fieldNamesJSONName)
+}
+
+// targets returns the profiles requested by the target parameter, in the order of
+// supportedTargets and without duplicates.
+func (o Options) targets() ([]string, error) {
+	var targets []string
+	for _, t := range o.Targets {
+		t = strings.TrimSpace(t)
+		if !slices.Contains(supportedTargets, t) {
+			return nil, fmt.Errorf("invalid target parameter %q (supported: %s)", t, strings.Join(supportedTargets, ", "))
+		}
+	}
+	for _, t := range supportedTargets {
+		if slices.ContainsFunc(o.Targets, func(v string) bool { return strings.TrimSpace(v) == t }) {
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+// validateStreamFraming reports an error if the stream_framing parameter has an
+// unsupported value.
+func (o Options) validateStreamFraming() error {
+	switch o.StreamFraming {
+	case "", streamFramingNDJSON, streamFramingArray:
+		return nil
+	}
+	return fmt.Errorf("invalid stream_framing parameter %q (supported: %s, %s)", o.StreamFraming, streamFramingNDJSON, streamFramingArray)
+}
+
+// schemaBaseURI returns the schema_base_uri parameter without trailing slashes, or an
+// error if it is not an absolute URI without a query or fragment.
+func (o Options) schemaBaseURI() (string, error) {
+	if o.SchemaBaseURI == "" {
+		return "", nil
+	}
+	u, err := url.Parse(o.SchemaBaseURI)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid schema_base_uri parameter %q (expected an absolute URI without a query or fragment, e.g. https://schemas.example.com)", o.SchemaBaseURI)
+	}
+	return strings.TrimRight(o.SchemaBaseURI, "/"), nil
+}
+
+// ownedGooglePackages returns the packages given by the owned_google_packages
+// parameter, or an error if one is not a google.* package or is google.protobuf.
+func (o Options) ownedGooglePackages() ([]protoreflect.FullName, error) {
+	var packages []protoreflect.FullName
+	for _, value := range o.OwnedGooglePackages {
+		pkg := protoreflect.FullName(strings.TrimPrefix(strings.TrimSpace(value), "."))
+		if !strings.HasPrefix(string(pkg), "google.") || !pkg.IsValid() {
+			return nil, fmt.Errorf("invalid owned_google_packages parameter %q (expected a google.* package, e.g. google.cloud)", value)
+		}
+		if pkg == "google.protobuf" || strings.HasPrefix(string(pkg), "google.protobuf.") {
+			return nil, fmt.Errorf("invalid owned_google_packages parameter %q: the well-known types cannot be owned", value)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// validateExternalSchemas reports an error if the external_schemas parameter has an
+// unsupported value.
+func (o Options) validateExternalSchemas() error {
+	switch o.ExternalSchemas {
+	case "", externalSchemasReference, externalSchemasAuto, externalSchemasInline:
+		return nil
+	}
+	return fmt.Errorf("invalid external_schemas parameter %q (supported: %s, %s, %s)", o.ExternalSchemas, externalSchemasReference, externalSchemasAuto, externalSchemasInline)
+}
+
+// validateOneofPresence reports an error if the oneof_presence parameter has an
+// unsupported value.
+func (o Options) validateOneofPresence() error {
+	switch o.OneofPresence {
+	case "", oneofPresenceAtMostOne, oneofPresenceExactlyOne:
+		return nil
+	}
+	return fmt.Errorf("invalid oneof_presence parameter %q (supported: %s, %s)", o.OneofPresence, oneofPresenceAtMostOne, oneofPresenceExactlyOne)
+}
+
+// validateStripEnumPrefix reports an error if the strip_enum_prefix parameter is set
+// without enum_names, since enum values are otherwise numbers.
+func (o Options) validateStripEnumPrefix() error {
+	if o.StripEnumPrefix && !o.EnumNames {
+		return fmt.Errorf("invalid strip_enum_prefix parameter: it requires enum_names")
+	}
+	return nil
+}
+
+// validateEnumCase reports an error if the enum_case parameter has an unsupported value,
+// or rewrites names without enum_names, since enum values are otherwise numbers.
+func (o Options) validateEnumCase() error {
+	switch o.EnumCase {
+	case "", enumCaseOriginal:
+		return nil
+	case enumCaseLower, enumCaseUpper, enumCaseKebab:
+		if !o.EnumNames {
+			return fmt.Errorf("invalid enum_case parameter: %s requires enum_names", o.EnumCase)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid enum_case parameter %q (supported: %s, %s, %s, %s)", o.EnumCase, enumCaseOriginal, enumCaseLower, enumCaseUpper, enumCaseKebab)
+}
+
+// validateWellKnownTypes reports an error if the well_known_types parameter has an
+// unsupported value or is combined with duration_seconds, which maps Duration differently,
+// or if timestamp_utc or timestamp_bounds is set without protojson, the only mode with
+// Timestamp strings.
+func (o Options) validateWellKnownTypes() error {
+	switch o.WellKnownTypes {
+	case "", wellKnownTypesEncodingJSON:
+		if o.TimestampUTC {
+			return fmt.Errorf("invalid timestamp_utc parameter: it requires well_known_types=%s", wellKnownTypesProtojson)
+		}
+		if len(o.TimestampBounds) > 0 {
+			return fmt.Errorf("invalid timestamp_bounds parameter: it requires well_known_types=%s", wellKnownTypesProtojson)
+		}
+		return nil
+	case wellKnownTypesProtojson:
+		if len(o.durationSecondsFields()) > 0 || o.allDurationSeconds() {
+			return fmt.Errorf("invalid well_known_types parameter: %s cannot be combined with duration_seconds", wellKnownTypesProtojson)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid well_known_types parameter %q (supported: %s, %s)", o.WellKnownTypes, wellKnownTypesEncodingJSON, wellKnownTypesProtojson)
+}
+
+// allDurationSeconds reports whether the duration_seconds parameter applies to every
+// Duration field.
+func (o Options) allDurationSeconds() bool {
+	return slices.ContainsFunc(o.DurationSeconds, func(v string) bool { return strings.TrimSpace(v) == "true" })
+}
+
+// durationSecondsFields returns the field full names given by the duration_seconds
+// parameter; "true" and "false" select every field and none.
+func (o Options) durationSecondsFields() map[protoreflect.FullName]bool {
+	fields := make(map[protoreflect.FullName]bool)
+	for _, value := range o.DurationSeconds {
+		value = strings.TrimPrefix(strings.TrimSpace(value), ".")
+		if value != "true" && value != "false" && value != "" {
+			fields[protoreflect.FullName(value)] = true
+		}
+	}
+	return fields
+}
+
+// maxRecursionDepths returns the depths given by the max_recursion_depth parameter, keyed
+// by message full name.
+func (o Options) maxRecursionDepths() (map[protoreflect.FullName]int, error) {
+	depths := make(map[protoreflect.FullName]int)
+	for _, value := range o.MaxRecursionDepths {
+		message, depth, ok := strings.Cut(value, "=")
+		message = strings.TrimPrefix(strings.TrimSpace(message), ".")
+		n, err := strconv.Atoi(strings.TrimSpace(depth))
+		if !ok || message == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid max_recursion_depth parameter %q (expected <message>=<depth>, with a depth of 0 or more)", value)
+		}
+		depths[protoreflect.FullName(message)] = n
+	}
+	return depths, nil
+}
+
+// additionalProperties returns the values given by the additional_properties parameter,
+// keyed by message full name: "false", "true" or a JSON type other than null.
+func (o Options) additionalProperties() (map[protoreflect.FullName]string, error) {
+	values := make(map[protoreflect.FullName]string)
+	for _, value := range o.AdditionalProperties {
+		message, v, ok := strings.Cut(value, "=")
+		message = strings.TrimPrefix(strings.TrimSpace(message), ".")
+		v = strings.TrimSpace(v)
+		if !ok || message == "" {
+			return nil, fmt.Errorf("invalid additional_properties parameter %q (expected <message>=<value>)", value)
+		}
+		switch v {
+		case "false", "true", "array", "boolean", "integer", "number", "object", "string":
+		default:
+			return nil, fmt.Errorf("invalid additional_properties parameter %q: unsupported value %q (expected false, true, array, boolean, integer, number, object or string)", value, v)
+		}
+		values[protoreflect.FullName(message)] = v
+	}
+	return values, nil
+}
+
+// presetMoney is the preset parameter value of the money preset.
+const presetMoney = "money"
+
+// presets returns the presets given by the preset parameter, keyed by message full name.
+func (o Options) presets() (map[protoreflect.FullName]string, error) {
+	presets := make(map[protoreflect.FullName]string)
+	for _, value := range o.Presets {
+		message, preset, ok := strings.Cut(value, "=")
+		message = strings.TrimPrefix(strings.TrimSpace(message), ".")
+		preset = strings.TrimSpace(preset)
+		if !ok || message == "" {
+			return nil, fmt.Errorf("invalid preset parameter %q (expected <message>=<preset>)", value)
+		}
+		if preset != presetMoney {
+			return nil, fmt.Errorf("invalid preset parameter %q: unsupported preset %q (supported: %s)", value, preset, presetMoney)
+		}
+		presets[protoreflect.FullName(message)] = preset
+	}
+	return presets, nil
+}
+
+// celConstraints returns the expressions given by the cel_constraint parameter, keyed by
+// message full name, in parameter order. Values ending in .cel are read from the file
+// they name.
+func (o Options) celConstraints() (map[protoreflect.FullName][]string, error) {
+	constraints := make(map[protoreflect.FullName][]string)
+	for _, value := range o.CELConstraints {
+		message, expression, ok := strings.Cut(value, "=")
+		message = strings.TrimPrefix(strings.TrimSpace(message), ".")
+		expression = strings.TrimSpace(expression)
+		if !ok || message == "" || expression == "" {
+			return nil, fmt.Errorf("invalid cel_constraint parameter %q (expected <message>=<expression or file>)", value)
+		}
+		if strings.HasSuffix(expression, ".cel") {
+			data, err := os.ReadFile(expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cel_constraint parameter %q: %w", value, err)
+			}
+			if expression = strings.TrimSpace(string(data)); expression == "" {
+				return nil, fmt.Errorf("invalid cel_constraint parameter %q: the file holds no expression", value)
+			}
+		}
+		constraints[protoreflect.FullName(message)] = append(constraints[protoreflect.FullName(message)], expression)
+	}
+	return constraints, nil
+}
+
+// timestampBounds returns the minimum and maximum given by the timestamp_bounds
+// parameter, keyed by field full name, as RFC 3339 date-times in UTC; an omitted bound
+// is "".
+func (o Options) timestampBounds() (map[protoreflect.FullName][2]string, error) {
+	bounds := make(map[protoreflect.FullName][2]string)
+	for _, value := range o.TimestampBounds {
+		field, interval, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		minimum, maximum, found := strings.Cut(interval, "..")
+		if !ok || !found || field == "" || strings.TrimSpace(minimum) == "" && strings.TrimSpace(maximum) == "" {
+			return nil, fmt.Errorf("invalid timestamp_bounds parameter %q (expected <field>=<min>..<max>, with at least one bound)", value)
+		}
+		var times [2]time.Time
+		var b [2]string
+		for i, bound := range []string{minimum, maximum} {
+			if bound = strings.TrimSpace(bound); bound == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, bound)
+			if err != nil {
+				if t, err = time.Parse(time.DateOnly, bound); err != nil {
+					return nil, fmt.Errorf("invalid timestamp_bounds parameter %q: %q is not an RFC 3339 date-time or a YYYY-MM-DD date", value, bound)
+				}
+			}
+			times[i], b[i] = t, t.UTC().Format(time.RFC3339Nano)
+		}
+		if b[0] != "" && b[1] != "" && times[1].Before(times[0]) {
+			return nil, fmt.Errorf("invalid timestamp_bounds parameter %q: the minimum is after the maximum", value)
+		}
+		bounds[protoreflect.FullName(field)] = b
+	}
+	return bounds, nil
+}
+
+// discriminatedOneofs returns the discriminator properties given by the
+// discriminated_oneof parameter, keyed by oneof full name.
+func (o Options) discriminatedOneofs() (map[protoreflect.FullName]string, error) {
+	discriminators := make(map[protoreflect.FullName]string)
+	for _, value := range o.DiscriminatedOneofs {
+		oneof, property, ok := strings.Cut(value, "=")
+		oneof = strings.TrimPrefix(strings.TrimSpace(oneof), ".")
+		property = strings.TrimSpace(property)
+		if !ok || oneof == "" || property == "" {
+			return nil, fmt.Errorf("invalid discriminated_oneof parameter %q (expected <oneof>=<discriminator property>)", value)
+		}
+		discriminators[protoreflect.FullName(oneof)] = property
+	}
+	return discriminators, nil
+}
+
+// oneofTitles returns the titles given by the oneof_title parameter, keyed by oneof full
+// name.
+func (o Options) oneofTitles() (map[protoreflect.FullName]string, error) {
+	return oneofTexts("oneof_title", "title", o.OneofTitles)
+}
+
+// oneofDescriptions returns the descriptions given by the oneof_description parameter,
+// keyed by oneof full name.
+func (o Options) oneofDescriptions() (map[protoreflect.FullName]string, error) {
+	return oneofTexts("oneof_description", "description", o.OneofDescriptions)
+}
+
+// oneofTexts parses the <oneof>=<text> values of the parameter param, where text names
+// the kind of text in errors.
+func oneofTexts(param, text string, values []string) (map[protoreflect.FullName]string, error) {
+	texts := make(map[protoreflect.FullName]string)
+	for _, value := range values {
+		oneof, t, ok := strings.Cut(value, "=")
+		oneof = strings.TrimPrefix(strings.TrimSpace(oneof), ".")
+		t = strings.TrimSpace(t)
+		if !ok || oneof == "" || t == "" {
+			return nil, fmt.Errorf("invalid %s parameter %q (expected <oneof>=<%s>)", param, value, text)
+		}
+		texts[protoreflect.FullName(oneof)] = t
+	}
+	return texts, nil
+}
+
+// methodDescriptions returns the descriptions given by the method_description parameter,
+// keyed by method full name.
+func (o Options) methodDescriptions() (map[protoreflect.FullName]string, error) {
+	descriptions := make(map[protoreflect.FullName]string)
+	for _, value := range o.MethodDescriptions {
+		method, description, ok := strings.Cut(value, "=")
+		method = strings.TrimPrefix(strings.TrimSpace(method), ".")
+		description = strings.TrimSpace(description)
+		if !ok || method == "" || description == "" {
+			return nil, fmt.Errorf("invalid method_description parameter %q (expected <method>=<description>)", value)
+		}
+		descriptions[protoreflect.FullName(method)] = description
+	}
+	return descriptions, nil
+}
+
+// enumAliases returns the aliases given by the enum_alias parameter, keyed by field full
+// name and enum value name, in order.
+func (o Options) enumAliases() (map[protoreflect.FullName]map[protoreflect.Name][]string, error) {
+	aliases := make(map[protoreflect.FullName]map[protoreflect.Name][]string)
+	for _, value := range o.EnumAliases {
+		parts := strings.SplitN(value, "=", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid enum_alias parameter %q (expected <field>=<value>=<alias>|<alias>...)", value)
+		}
+		field := protoreflect.FullName(strings.TrimPrefix(strings.TrimSpace(parts[0]), "."))
+		enumValue := protoreflect.Name(strings.TrimSpace(parts[1]))
+		if field == "" || enumValue == "" || strings.TrimSpace(parts[2]) == "" {
+			return nil, fmt.Errorf("invalid enum_alias parameter %q (expected <field>=<value>=<alias>|<alias>...)", value)
+		}
+		if aliases[field] == nil {
+			aliases[field] = make(map[protoreflect.Name][]string)
+		}
+		for _, alias := range strings.Split(parts[2], "|") {
+			if alias = strings.TrimSpace(alias); alias == "" {
+				return nil, fmt.Errorf("invalid enum_alias parameter %q: empty alias", value)
+			}
+			if !slices.Contains(aliases[field][enumValue], alias) {
+				aliases[field][enumValue] = append(aliases[field][enumValue], alias)
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// anyTypes returns the allow-lists given by the any_types parameter, keyed by field full
+// name, with the type names in order.
+func (o Options) anyTypes() (map[protoreflect.FullName][]protoreflect.FullName, error) {
+	allowed := make(map[protoreflect.FullName][]protoreflect.FullName)
+	for _, value := range o.AnyTypes {
+		field, types, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		if !ok || field == "" || strings.TrimSpace(types) == "" {
+			return nil, fmt.Errorf("invalid any_types parameter %q (expected <field>=<type>|<type>...)", value)
+		}
+		for _, t := range strings.Split(types, "|") {
+			if t = strings.TrimPrefix(strings.TrimSpace(t), "."); t == "" {
+				return nil, fmt.Errorf("invalid any_types parameter %q: empty type name", value)
+			}
+			name := protoreflect.FullName(t)
+			if !slices.Contains(allowed[protoreflect.FullName(field)], name) {
+				allowed[protoreflect.FullName(field)] = append(allowed[protoreflect.FullName(field)], name)
+			}
+		}
+	}
+	return allowed, nil
+}
+
+// coercions returns the JSON types given by the coerce parameter, keyed by field full
+// name, in order.
+func (o Options) coercions() (map[protoreflect.FullName][]string, error) {
+	coercions := make(map[protoreflect.FullName][]string)
+	for _, value := range o.Coercions {
+		field, types, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		if !ok || field == "" || strings.TrimSpace(types) == "" {
+			return nil, fmt.Errorf("invalid coerce parameter %q (expected <field>=<type>|<type>...)", value)
+		}
+		for _, t := range strings.Split(types, "|") {
+			switch t = strings.TrimSpace(t); t {
+			case "boolean", "integer", "number", "string":
+			default:
+				return nil, fmt.Errorf("invalid coerce parameter %q: unsupported type %q (expected boolean, integer, number or string)", value, t)
+			}
+			if !slices.Contains(coercions[protoreflect.FullName(field)], t) {
+				coercions[protoreflect.FullName(field)] = append(coercions[protoreflect.FullName(field)], t)
+			}
+		}
+	}
+	return coercions, nil
+}
+
+// allowedValues returns the values given by the allowed_values parameter, keyed by field
+// full name.
+func (o Options) allowedValues() (map[protoreflect.FullName][]string, error) {
+	allowedValues := make(map[protoreflect.FullName][]string)
+	for _, value := range o.AllowedValues {
+		field, list, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		if !ok || field == "" {
+			return nil, fmt.Errorf("invalid allowed_values parameter %q (expected <field>=<value>|<value>...)", value)
+		}
+		var values []string
+		for _, v := range strings.Split(list, "|") {
+			if v = strings.TrimSpace(v); v == "" {
+				return nil, fmt.Errorf("invalid allowed_values parameter %q: empty value", value)
+			}
+			values = append(values, v)
+		}
+		allowedValues[protoreflect.FullName(field)] = append(allowedValues[protoreflect.FullName(field)], values...)
+	}
+	return allowedValues, nil
+}
+
+// multipleOfs returns the steps given by the multiple_of parameter, keyed by field full
+// name.
+func (o Options) multipleOfs() (map[protoreflect.FullName]float64, error) {
+	multipleOfs := make(map[protoreflect.FullName]float64)
+	for _, value := range o.MultipleOfs {
+		field, number, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		if !ok || field == "" {
+			return nil, fmt.Errorf("invalid multiple_of parameter %q (expected <field>=<number>)", value)
+		}
+		step, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || step <= 0 || math.IsInf(step, 0) {
+			return nil, fmt.Errorf("invalid multiple_of parameter %q: %q is not a positive number", value, strings.TrimSpace(number))
+		}
+		multipleOfs[protoreflect.FullName(field)] = step
+	}
+	return multipleOfs, nil
+}
+
+// extensions returns the keywords given by the extension parameter, keyed by field full
+// name, with the target suffix if any (see splitFieldTarget), and then keyword.
+func (o Options) extensions() (map[protoreflect.FullName]map[string]any, error) {
+	extensions := make(map[protoreflect.FullName]map[string]any)
+	for _, value := range o.Extensions {
+		target, raw, ok := strings.Cut(value, "=")
+		field, keyword, hasKeyword := strings.Cut(target, ":")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		keyword = strings.TrimSpace(keyword)
+		if !ok || !hasKeyword || field == "" {
+			return nil, fmt.Errorf("invalid extension parameter %q (expected <field>:<keyword>=<value>)", value)
+		}
+		if !strings.HasPrefix(keyword, "x-") || len(keyword) == len("x-") {
+			return nil, fmt.Errorf("invalid extension parameter %q: keyword %q does not start with x-", value, keyword)
+		}
+		raw = strings.TrimSpace(raw)
+		var v any
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			v = raw
+		}
+		switch v.(type) {
+		case string, float64, bool:
+		default:
+			return nil, fmt.Errorf("invalid extension parameter %q: value %s is not a string, number or boolean", value, raw)
+		}
+		if extensions[protoreflect.FullName(field)] == nil {
+			extensions[protoreflect.FullName(field)] = make(map[string]any)
+		}
+		extensions[protoreflect.FullName(field)][keyword] = v
+	}
+	return extensions, nil
+}
+
+// Targets of the extension and raw_schema parameters, given as a suffix of the field
+// name in brackets (see splitFieldTarget).
+const (
+	// fieldTargetItems targets the items schema of a repeated field.
+	fieldTargetItems = "items"
+	// fieldTargetValues targets the additionalProperties schema of a map field.
+	fieldTargetValues = "values"
+)
+
+// splitFieldTarget splits an [items] or [values] suffix off a field name of the extension
+// and raw_schema parameters, returning the field's full name and the target ("" for the
+// field's own schema).
+func splitFieldTarget(name protoreflect.FullName) (protoreflect.FullName, string) {
+	for _, target := range []string{fieldTargetItems, fieldTargetValues} {
+		if field, ok := strings.CutSuffix(string(name), "["+target+"]"); ok {
+			return protoreflect.FullName(field), target
+		}
+	}
+	return name, ""
+}
+
+// rawSchemas returns the JSON merge patches given by the raw_schema parameter, compacted
+// and keyed by field full name, with the target suffix if any (see splitFieldTarget). Patches that are not JSON objects, or that set keywords
+// to values of the wrong type, are rejected.
+func (o Options) rawSchemas() (map[protoreflect.FullName]string, error) {
+	rawSchemas := make(map[protoreflect.FullName]string)
+	for _, value := range o.RawSchemas {
+		field, raw, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		raw = strings.TrimSpace(raw)
+		if !ok || field == "" || raw == "" {
+			return nil, fmt.Errorf("invalid raw_schema parameter %q (expected <field>=<JSON object or file>)", value)
+		}
+		patch := []byte(raw)
+		if !strings.HasPrefix(raw, "{") {
+			var err error
+			if patch, err = os.ReadFile(raw); err != nil {
+				return nil, fmt.Errorf("invalid raw_schema parameter %q: %w", value, err)
+			}
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, patch); err != nil {
+			return nil, fmt.Errorf("invalid raw_schema parameter %q: %w", value, err)
+		}
+		var keywords map[string]json.RawMessage
+		if err := json.Unmarshal(compact.Bytes(), &keywords); err != nil || keywords == nil {
+			return nil, fmt.Errorf("invalid raw_schema parameter %q: the patch is not a JSON object", value)
+		}
+		if err := json.Unmarshal(compact.Bytes(), &jsonschema.Schema{}); err != nil {
+			return nil, fmt.Errorf("invalid raw_schema parameter %q: %w", value, err)
+		}
+		rawSchemas[protoreflect.FullName(field)] = compact.String()
+	}
+	return rawSchemas, nil
+}
+
+// defaults returns the compacted JSON values given by the default parameter, keyed by
+// field full name (see fieldJSONValues).
+func (o Options) defaults() (map[protoreflect.FullName]string, error) {
+	return fieldJSONValues("default", o.Defaults)
+}
+
+// consts returns the compacted JSON values given by the const parameter, keyed by field
+// full name (see fieldJSONValues).
+func (o Options) consts() (map[protoreflect.FullName]string, error) {
+	return fieldJSONValues("const", o.Consts)
+}
+
+// fieldJSONValues parses the <field>=<JSON value> values of a parameter into compacted
+// JSON values keyed by field full name. Values starting like a JSON value (an object,
+// array, string, number, true, false or null) are inline; others are read from the file
+// they name.
+func fieldJSONValues(param string, values []string) (map[protoreflect.FullName]string, error) {
+	parsed := make(map[protoreflect.FullName]string)
+	for _, value := range values {
+		field, raw, ok := strings.Cut(value, "=")
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		raw = strings.TrimSpace(raw)
+		if !ok || field == "" || raw == "" {
+			return nil, fmt.Errorf("invalid %s parameter %q (expected <field>=<JSON value or file>)", param, value)
+		}
+		data := []byte(raw)
+		if !strings.ContainsAny(raw[:1], "{[\"-0123456789") && raw != "true" && raw != "false" && raw != "null" {
+			var err error
+			if data, err = os.ReadFile(raw); err != nil {
+				return nil, fmt.Errorf("invalid %s parameter %q: %w", param, value, err)
+			}
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %q: %w", param, value, err)
+		}
+		parsed[protoreflect.FullName(field)] = compact.String()
+	}
+	return parsed, nil
+}
+
+// vocabularies returns the keywords of the vocabularies given by the vocabulary
+// parameter, keyed by URI, in order.
+func (o Options) vocabularies() (map[string][]string, error) {
+	vocabularies := make(map[string][]string)
+	owners := make(map[string]string)
+	for _, value := range o.Vocabularies {
+		i := strings.LastIndex(value, "=")
+		if i < 0 || strings.TrimSpace(value[i+1:]) == "" {
+			return nil, fmt.Errorf("invalid vocabulary parameter %q (expected <uri>=<keyword>|<keyword>...)", value)
+		}
+		uri := strings.TrimSpace(value[:i])
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("invalid vocabulary parameter %q: %q is not an absolute URI", value, uri)
+		}
+		for _, keyword := range strings.Split(value[i+1:], "|") {
+			keyword = strings.TrimSpace(keyword)
+			switch owner, ok := owners[keyword]; {
+			case keyword == "":
+				return nil, fmt.Errorf("invalid vocabulary parameter %q: empty keyword", value)
+			case isSchemaKeyword(keyword):
+				return nil, fmt.Errorf("invalid vocabulary parameter %q: %q is a JSON Schema keyword", value, keyword)
+			case ok && owner != uri:
+				return nil, fmt.Errorf("invalid vocabulary parameter %q: keyword %q is also declared by %s", value, keyword, owner)
+			case !ok:
+				owners[keyword] = uri
+				vocabularies[uri] = append(vocabularies[uri], keyword)
+			}
+		}
+	}
+	return vocabularies, nil
+}
+
+// isSchemaKeyword reports whether keyword is a JSON Schema keyword, as named by the
+// JSON fields of jsonschema.Schema.
+func isSchemaKeyword(keyword string) bool {
+	t := reflect.TypeFor[jsonschema.Schema]()
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// params returns the plugin parameters that affect the schemas and differ from their
+// defaults, keyed by parameter name.
+func (o Options) params() map[string]any {
+	params := make(map[string]any)
+	v := reflect.ValueOf(o)
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag
+		if tag.Get("snapshot") == "-" {
+			continue
+		}
+		if name := tag.Get("param"); name != "" && !v.Field(i).IsZero() {
+			params[name] = v.Field(i).Interface()
+		}
+	}
+	return params
+}
+
+// hash returns a short hex digest of the schema-affecting parameters (see params). List
+// values are trimmed, and those of unordered parameters sorted and deduplicated, so that
+// the hash is the same for equivalent orders and spacings of these values.
+func (o Options) hash() string {
+	params := o.params()
+	t := reflect.TypeFor[Options]()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("param")
+		values, ok := params[name].([]string)
+		if !ok {
+			continue
+		}
+		normalized := make([]string, len(values))
+		for j, value := range values {
+			normalized[j] = strings.TrimSpace(value)
+		}
+		if t.Field(i).Tag.Get("unordered") == "true" {
+			slices.Sort(normalized)
+			normalized = slices.Compact(normalized)
+		}
+		params[name] = normalized
+	}
+	encoded, _ := json.Marshal(params)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Param documents a plugin parameter, as declared by the tags of its Options field.
+type Param struct {
+	// Name is the parameter name, e.g. "schema_lib".
+	Name string
+
+	// Type is "bool", "string", or "list" for parameters that may be repeated.
+	Type string
+
+	// Default is the value used when the parameter is not set, or "" if unset means off.
+	Default string
+
+	// Usage is a one-line description.
+	Usage string
+
+	// Example is a parameter setting as passed in --go-jsonschema_opt, e.g.
+	// "schema_lib=santhosh".
+	Example string
+}
+
+// Params returns the plugin parameters in declaration order. It is derived from the
+// Options struct tags, so the parameter documentation and RegisterFlags cannot drift
+// from the options the plugin accepts.
+func Params() []Param {
+	var params []Param
+	t := reflect.TypeOf(Options{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("param")
+		if name == "" {
+			continue
+		}
+		p := Param{Name: name, Default: f.Tag.Get("default"), Usage: f.Tag.Get("usage"), Example: f.Tag.Get("example")}
+		switch f.Type.Kind() {
+		case reflect.Bool:
+			p.Type = "bool"
+		case reflect.String:
+			p.Type = "string"
+		case reflect.Slice:
+			p.Type = "list"
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// RegisterFlags defines a flag for each plugin parameter in flags, setting the
+// corresponding field of o. List parameters append each value they are set to.
+func (o *Options) RegisterFlags(flags *flag.FlagSet) {
+	v := reflect.ValueOf(o).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag
+		name := tag.Get("param")
+		if name == "" {
+			continue
+		}
+		switch field := v.Field(i).Addr().Interface().(type) {
+		case *bool:
+			flags.BoolVar(field, name, false, tag.Get("usage"))
+		case *string:
+			flags.StringVar(field, name, "", tag.Get("usage"))
+		case *[]string:
+			flags.Func(name, tag.Get("usage"), func(value string) error {
+				*field = append(*field, value)
+				return nil
+			})
+		default:
+			panic(fmt.Sprintf("plugin parameter %s has unsupported type %T", name, field))
+		}
+	}
+}
@@ -1,22 +1,319 @@
 package plugin
 
-import "google.golang.org/protobuf/compiler/protogen"
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// The protoc features and editions supported by the plugin, declared in the
+// CodeGeneratorResponse so that protoc rejects inputs the plugin cannot handle.
+const (
+	SupportedFeatures        = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL | pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+	SupportedEditionsMinimum = descriptorpb.Edition_EDITION_PROTO2
+	SupportedEditionsMaximum = descriptorpb.Edition_EDITION_2023
+)
 
 // Generate generates JSON Schema code for all files in the plugin request.
 // The version parameter is included in generated file headers for traceability.
 func Generate(plugin *protogen.Plugin, version string) error {
+	return GenerateWithOptions(plugin, version, Options{})
+}
+
+// GenerateWithOptions is like Generate but applies the given plugin options.
+//
+// Problems with the request that silently reduce the output (see Warnings) are printed to
+// stderr. Errors are reported through plugin.Error, and so in the CodeGeneratorResponse, as well
+// as returned. Errors concerning a proto file start with its path; a panic in generation
+// code is reported as an internal error naming the file and message being generated
+// instead of crashing protoc.
+func GenerateWithOptions(plugin *protogen.Plugin, version string, opts Options) (err error) {
+	plugin.SupportedFeatures = SupportedFeatures
+	plugin.SupportedEditionsMinimum = SupportedEditionsMinimum
+	plugin.SupportedEditionsMaximum = SupportedEditionsMaximum
+
+	defer func() {
+		if err != nil {
+			plugin.Error(err)
+		}
+	}()
+	defer recoverPanic(&err, "protoc-gen-go-jsonschema")
+
+	generator := Generator{Version: version, opts: opts, index: newRequestIndex(plugin)}
+
+	drafts, err := opts.drafts()
+	if err != nil {
+		return err
+	}
+	schemaLib, err := opts.schemaLib()
+	if err != nil {
+		return err
+	}
+	if err := opts.validateFieldNames(); err != nil {
+		return err
+	}
+	if err := opts.validateWellKnownTypes(); err != nil {
+		return err
+	}
+	if err := opts.validateStripEnumPrefix(); err != nil {
+		return err
+	}
+	if err := opts.validateEnumCase(); err != nil {
+		return err
+	}
+	if err := opts.validateStreamFraming(); err != nil {
+		return err
+	}
+	if err := opts.validateOneofPresence(); err != nil {
+		return err
+	}
+	if err := opts.validateExternalSchemas(); err != nil {
+		return err
+	}
+	targets, err := opts.targets()
+	if err != nil {
+		return err
+	}
+	generator.targets = targets
+	schemaBaseURI, err := opts.schemaBaseURI()
+	if err != nil {
+		return err
+	}
+	generator.schemaBaseURI = schemaBaseURI
+	ownedGooglePackages, err := opts.ownedGooglePackages()
+	if err != nil {
+		return err
+	}
+	generator.ownedGooglePackages = ownedGooglePackages
+
+	// Misconfigured requests generate less than their files ask for without failing, so
+	// the causes are reported on stderr, which protoc passes through.
+	printWarnings(os.Stderr, Warnings(plugin))
+
+	closedObjects, err := generator.getClosedObjects()
+	if err != nil {
+		return err
+	}
+	generator.closedObjects = closedObjects
+
+	additionalProperties, err := generator.getAdditionalProperties()
+	if err != nil {
+		return err
+	}
+	generator.additionalProperties = additionalProperties
+
+	presets, err := generator.getPresets()
+	if err != nil {
+		return err
+	}
+	generator.presets = presets
+
+	celConstraints, err := generator.getCELConstraints()
+	if err != nil {
+		return err
+	}
+	generator.celConstraints = celConstraints
+
+	timestampBounds, err := generator.getTimestampBounds()
+	if err != nil {
+		return err
+	}
+	generator.timestampBounds = timestampBounds
+
+	unevaluatedProperties, err := generator.getUnevaluatedProperties()
+	if err != nil {
+		return err
+	}
+	generator.unevaluatedProperties = unevaluatedProperties
+
+	// Any fields restricted by the any_types parameter are resolved before dependency
+	// collection, which follows them to the allowed messages, and after the parameters
+	// closing message schemas, which the allowed messages must not be.
+	anyTypes, err := generator.getAnyTypes()
+	if err != nil {
+		return err
+	}
+	generator.anyTypes = anyTypes
+
+	recursionDepths, err := generator.getRecursionDepths()
+	if err != nil {
+		return err
+	}
+	generator.recursionDepths = recursionDepths
+
+	discriminatedOneofs, err := generator.getDiscriminatedOneofs()
+	if err != nil {
+		return err
+	}
+	generator.discriminatedOneofs = discriminatedOneofs
+
+	enumAliases, err := generator.getEnumAliases()
+	if err != nil {
+		return err
+	}
+	generator.enumAliases = enumAliases
+
+	identifiers, err := generator.getIdentifiers()
+	if err != nil {
+		return err
+	}
+	generator.identifiers = identifiers
+
+	generator.resourcePatterns = generator.getResourcePatterns(plugin)
+	generator.validation = generator.getValidateRules()
+
+	coercions, err := generator.getCoercions()
+	if err != nil {
+		return err
+	}
+	generator.coercions = coercions
+
+	durationSeconds, err := generator.getDurationSeconds()
+	if err != nil {
+		return err
+	}
+	generator.durationSeconds = durationSeconds
+
+	multipleOfs, err := generator.getMultipleOfs()
+	if err != nil {
+		return err
+	}
+	generator.multipleOfs = multipleOfs
+
+	extensions, elementExtensions, err := generator.getExtensions()
+	if err != nil {
+		return err
+	}
+	generator.extensions, generator.elementExtensions = extensions, elementExtensions
+
+	defaults, err := generator.getDefaults()
+	if err != nil {
+		return err
+	}
+	generator.defaults = defaults
+
+	allowedValues, err := generator.getAllowedValues()
+	if err != nil {
+		return err
+	}
+	generator.allowedValues = allowedValues
+
+	consts, err := generator.getConsts()
+	if err != nil {
+		return err
+	}
+	generator.consts = consts
+
+	rawSchemas, err := generator.getRawSchemas()
+	if err != nil {
+		return err
+	}
+	generator.rawSchemas = rawSchemas
+
+	oneofTitles, oneofDescriptions, err := generator.getOneofTexts()
+	if err != nil {
+		return err
+	}
+	generator.oneofTitles, generator.oneofDescriptions = oneofTitles, oneofDescriptions
+
+	methodDescriptions, err := generator.getMethodDescriptions()
+	if err != nil {
+		return err
+	}
+	generator.methodDescriptions = methodDescriptions
+
+	vocabularies, err := opts.vocabularies()
+	if err != nil {
+		return err
+	}
+	generator.vocabularies = vocabularies
+
+	// Shared enum definitions are resolved across all files in the run so that
+	// each enum is emitted once by its declaring file and referenced everywhere else.
+	generator.sharedEnums = generator.getSharedEnums(plugin)
+
+	// Messages declared outside the run that external_schemas inlines are emitted like
+	// Google types, so they are resolved before the required messages and helpers.
+	externalMessages, warnings := generator.getExternalMessages(plugin)
+	printWarnings(os.Stderr, warnings)
+	generator.externalMessages = externalMessages
+	generator.externalMarkers = generator.getExternalMarkers(plugin)
+
+	// Messages referenced across files are emitted by their declaring file; references
+	// to files outside the run that cannot resolve are reported up front.
+	requiredMessages, err := generator.getRequiredMessages(plugin)
+	if err != nil {
+		return err
+	}
+	generator.requiredMessages = requiredMessages
+
+	// Google type helpers are registered per Go package so that files sharing a
+	// go_package define each helper exactly once. They cover the Google types reached by
+	// required messages, which their declaring files emit.
+	generator.googleHelpers = generator.getGoogleHelpers(plugin)
+
 	for _, f := range plugin.Files {
 		if !f.Generate {
 			continue
 		}
+		if err := generator.generateFiles(plugin, f, drafts, schemaLib); err != nil {
+			return err
+		}
+	}
 
-		generator := Generator{Version: version}
+	return nil
+}
 
-		if _, err := generator.generateFile(plugin, f); err != nil {
-			plugin.Error(err)
+// generateFiles generates the schema file for f and the companion files selected by
+// the draft, target, prune, cached_accessors, coerce_tool_args, stream_framing and
+// schema_lib parameters.
+func (gr *Generator) generateFiles(plugin *protogen.Plugin, f *protogen.File, drafts []string, schemaLib string) (err error) {
+	defer recoverPanic(&err, f.Desc.Path())
+
+	if _, err := gr.generateFile(plugin, f); err != nil {
+		return err
+	}
+	if slices.Contains(drafts, draft07) {
+		gr.generateDraft07File(plugin, f)
+	}
+	for _, target := range gr.targets {
+		gr.generateTargetFile(plugin, f, target)
+	}
+	if gr.opts.Prune {
+		gr.generatePruneFile(plugin, f)
+	}
+	if gr.opts.CachedAccessors {
+		gr.generateCachedFile(plugin, f)
+	}
+	if gr.opts.CoerceToolArgs {
+		gr.generateToolArgsFile(plugin, f)
+	}
+	if gr.opts.StreamFraming != "" {
+		if _, err := gr.generateStreamFile(plugin, f); err != nil {
 			return err
 		}
 	}
-
+	switch schemaLib {
+	case schemaLibSanthosh:
+		gr.generateSanthoshFile(plugin, f)
+	case schemaLibInvopop:
+		gr.generateInvopopFile(plugin, f)
+	case schemaLibOpenAPI3:
+		gr.generateOpenAPI3File(plugin, f)
+	}
 	return nil
 }
+
+// recoverPanic, when deferred, converts a panic into an internal error prefixed with
+// where (e.g. "users/v1/user.proto: message users.v1.User") and carrying the stack trace,
+// so that it is reported in the CodeGeneratorResponse.
+func recoverPanic(err *error, where string) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%s: internal error: %v\n%s", where, r, debug.Stack())
+	}
+}
@@ -0,0 +1,434 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// codeWriter is the part of protogen.GeneratedFile that MessageSchemaGenerator writes
+// code with, so that the code of a field schema can be captured and rewritten.
+type codeWriter interface {
+	P(v ...any)
+	QualifiedGoIdent(ident protogen.GoIdent) string
+}
+
+// capturedCode buffers the lines written to it, qualifying identifiers in the file being
+// generated.
+type capturedCode struct {
+	codeWriter
+	buf strings.Builder
+}
+
+// P buffers a line of code, like protogen.GeneratedFile.P.
+func (c *capturedCode) P(v ...any) {
+	for _, x := range v {
+		fmt.Fprint(&c.buf, x)
+	}
+	c.buf.WriteByte('\n')
+}
+
+// schemaLiteral is a composite literal of generated schema code that a raw_schema patch
+// is merged into: a &jsonschema.Schema literal keyed by Go field names, or a map literal
+// keyed by quoted strings. Element values are Go source, or literals the patch reaches into.
+type schemaLiteral struct {
+	// typ is the literal's type, e.g. "&jsonschema.Schema" or "map[string]any".
+	typ string
+
+	// keys lists the element keys in order.
+	keys []string
+
+	// values maps keys to their values: a string of Go source or a *schemaLiteral.
+	values map[string]any
+}
+
+// set sets the value of a key, appending new keys.
+func (l *schemaLiteral) set(key string, value any) {
+	if _, ok := l.values[key]; !ok {
+		l.keys = append(l.keys, key)
+	}
+	l.values[key] = value
+}
+
+// remove removes a key.
+func (l *schemaLiteral) remove(key string) {
+	if _, ok := l.values[key]; !ok {
+		return
+	}
+	delete(l.values, key)
+	for i, k := range l.keys {
+		if k == key {
+			l.keys = append(l.keys[:i], l.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// String renders the literal as Go source.
+func (l *schemaLiteral) String() string {
+	var b strings.Builder
+	b.WriteString(l.typ + "{\n")
+	for _, key := range l.keys {
+		value := l.values[key]
+		if v, ok := value.(*schemaLiteral); ok {
+			value = v.String()
+		}
+		if l.typ == "map[string]*jsonschema.Schema" {
+			value = strings.TrimPrefix(value.(string), "&jsonschema.Schema")
+		}
+		fmt.Fprintf(&b, "%s: %s,\n", key, value)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// parseSchemaLiteral parses the Go source of a value of type typ ("&jsonschema.Schema" or
+// a map type) into a literal. A schema given by a call, such as a message reference
+// X_JsonSchema_WithDefs(defs), becomes a literal holding its $ref, and composite literals
+// with an elided type get typ.
+func parseSchemaLiteral(typ, src string) (*schemaLiteral, error) {
+	if strings.HasPrefix(src, "{") {
+		src = typ + src
+	}
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("parsing generated schema code %q: %w", src, err)
+	}
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
+	}
+	lit := &schemaLiteral{typ: typ, values: make(map[string]any)}
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		if typ != "&jsonschema.Schema" {
+			return nil, fmt.Errorf("generated schema code %q is not a literal", src)
+		}
+		lit.set("Ref", src+".Ref")
+	case *ast.CompositeLit:
+		for _, elt := range e.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, fmt.Errorf("generated schema code %q has an element without a key", src)
+			}
+			lit.set(src[kv.Key.Pos()-1:kv.Key.End()-1], src[kv.Value.Pos()-1:kv.Value.End()-1])
+		}
+	default:
+		return nil, fmt.Errorf("generated schema code %q is not a literal", src)
+	}
+	return lit, nil
+}
+
+// schemaFields maps keywords to the jsonschema.Schema fields holding them. Keywords
+// missing from it are kept in Extra.
+var schemaFields = func() map[string][]reflect.StructField {
+	fields := map[string][]reflect.StructField{}
+	t := reflect.TypeOf(jsonschema.Schema{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		keyword, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch f.Name {
+		case "Type", "Types":
+			keyword = "type"
+		case "Items", "ItemsArray":
+			keyword = "items"
+		case "DependencySchemas", "DependencyStrings":
+			keyword = "dependencies"
+		}
+		if keyword != "" && keyword != "-" {
+			fields[keyword] = append(fields[keyword], f)
+		}
+	}
+	return fields
+}()
+
+var (
+	schemaPointerType = reflect.TypeOf(&jsonschema.Schema{})
+	schemaMapType     = reflect.TypeOf(map[string]*jsonschema.Schema{})
+)
+
+// mergeRawSchema merges a JSON merge patch (RFC 7386) into a schema literal, as
+// schemautil.MergePatch does at run time: keywords of the patch replace those of the
+// schema, null removes a keyword, and objects merge into the subschemas and maps of
+// subschemas they patch.
+func (sg *MessageSchemaGenerator) mergeRawSchema(lit *schemaLiteral, patch map[string]any) error {
+	keywords := make([]string, 0, len(patch))
+	for keyword := range patch {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		value := patch[keyword]
+		fields := schemaFields[keyword]
+		if fields == nil {
+			if err := sg.mergeRawValue(lit, "Extra", "map[string]any", strconv.Quote(keyword), value); err != nil {
+				return err
+			}
+			if extra, ok := lit.values["Extra"].(*schemaLiteral); ok && len(extra.keys) == 0 {
+				lit.remove("Extra")
+			}
+			continue
+		}
+		object, isObject := value.(map[string]any)
+		for _, f := range fields {
+			current, ok := lit.values[f.Name]
+			if !ok || !isObject {
+				continue
+			}
+			switch f.Type {
+			case schemaPointerType:
+				sub, err := asSchemaLiteral("&jsonschema.Schema", current)
+				if err != nil {
+					return err
+				}
+				if err := sg.mergeRawSchema(sub, object); err != nil {
+					return err
+				}
+				lit.set(f.Name, sub)
+				object = nil
+			case schemaMapType:
+				sub, err := asSchemaLiteral("map[string]*jsonschema.Schema", current)
+				if err != nil {
+					return err
+				}
+				if err := sg.mergeRawSchemaMap(sub, object); err != nil {
+					return err
+				}
+				lit.set(f.Name, sub)
+				object = nil
+			}
+		}
+		if isObject && object == nil {
+			// Merged into the subschema or map of subschemas.
+			continue
+		}
+
+		// Replace the keyword.
+		for _, f := range fields {
+			lit.remove(f.Name)
+		}
+		if value == nil {
+			continue
+		}
+		data, err := json.Marshal(map[string]any{keyword: withoutNulls(value)})
+		if err != nil {
+			return err
+		}
+		var decoded jsonschema.Schema
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return fmt.Errorf("keyword %s: %w", keyword, err)
+		}
+		for _, f := range fields {
+			if v := reflect.ValueOf(decoded).FieldByIndex(f.Index); !v.IsZero() {
+				lit.set(f.Name, sg.goValue(v))
+			}
+		}
+	}
+	return nil
+}
+
+// mergeRawSchemaMap merges a patch into a map literal of subschemas, such as properties.
+func (sg *MessageSchemaGenerator) mergeRawSchemaMap(lit *schemaLiteral, patch map[string]any) error {
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		quoted := strconv.Quote(key)
+		object, isObject := patch[key].(map[string]any)
+		switch {
+		case patch[key] == nil:
+			lit.remove(quoted)
+		case isObject:
+			sub := &schemaLiteral{typ: "&jsonschema.Schema", values: make(map[string]any)}
+			if current, ok := lit.values[quoted]; ok {
+				var err error
+				if sub, err = asSchemaLiteral("&jsonschema.Schema", current); err != nil {
+					return err
+				}
+			}
+			if err := sg.mergeRawSchema(sub, object); err != nil {
+				return err
+			}
+			lit.set(quoted, sub)
+		default:
+			data, err := json.Marshal(patch[key])
+			if err != nil {
+				return err
+			}
+			var decoded *jsonschema.Schema
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				return fmt.Errorf("subschema %s: %w", key, err)
+			}
+			lit.set(quoted, sg.goValue(reflect.ValueOf(decoded)))
+		}
+	}
+	return nil
+}
+
+// mergeRawValue merges a patch value into the element key of the map literal held by the
+// element field of lit (creating it with type typ), for keywords kept in Extra: null
+// removes the element, objects merge into map literals, and other values replace it.
+func (sg *MessageSchemaGenerator) mergeRawValue(lit *schemaLiteral, field, typ, key string, value any) error {
+	container, ok := lit.values[field]
+	if !ok && value == nil {
+		return nil
+	}
+	var sub *schemaLiteral
+	if ok {
+		var err error
+		if sub, err = asSchemaLiteral(typ, container); err != nil {
+			return err
+		}
+	} else {
+		sub = &schemaLiteral{typ: typ, values: make(map[string]any)}
+	}
+	lit.set(field, sub)
+
+	object, isObject := value.(map[string]any)
+	switch {
+	case value == nil:
+		sub.remove(key)
+	case isObject:
+		current, ok := sub.values[key]
+		if ok {
+			if src, isSrc := current.(string); isSrc && !strings.HasPrefix(src, "map[string]any{") {
+				ok = false
+			}
+		}
+		if !ok {
+			sub.set(key, goAnyLiteral(withoutNulls(value)))
+			return nil
+		}
+		keys := make([]string, 0, len(object))
+		for k := range object {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := sg.mergeRawValue(sub, key, "map[string]any", strconv.Quote(k), object[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		sub.set(key, goAnyLiteral(value))
+	}
+	return nil
+}
+
+// asSchemaLiteral returns a literal element value as a literal of type typ, parsing it
+// if it is Go source.
+func asSchemaLiteral(typ string, value any) (*schemaLiteral, error) {
+	if lit, ok := value.(*schemaLiteral); ok {
+		return lit, nil
+	}
+	return parseSchemaLiteral(typ, value.(string))
+}
+
+// withoutNulls returns a decoded JSON value without the null members of its objects,
+// which a merge patch removes from the value it patches.
+func withoutNulls(value any) any {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	out := make(map[string]any, len(object))
+	for key, v := range object {
+		if v != nil {
+			out[key] = withoutNulls(v)
+		}
+	}
+	return out
+}
+
+// goValue renders a jsonschema.Schema field value decoded from a patch as Go source.
+func (sg *MessageSchemaGenerator) goValue(v reflect.Value) string {
+	switch v.Type() {
+	case schemaPointerType:
+		if v.IsNil() {
+			return "nil"
+		}
+		var b strings.Builder
+		b.WriteString("&jsonschema.Schema{")
+		s := v.Elem()
+		for i := 0; i < s.NumField(); i++ {
+			if f := s.Field(i); !f.IsZero() {
+				fmt.Fprintf(&b, "%s: %s, ", s.Type().Field(i).Name, sg.goValue(f))
+			}
+		}
+		return strings.TrimSuffix(b.String(), ", ") + "}"
+	case reflect.TypeOf(json.RawMessage(nil)):
+		literal := strconv.Quote(string(v.Bytes()))
+		if strconv.CanBackquote(string(v.Bytes())) {
+			literal = "`" + string(v.Bytes()) + "`"
+		}
+		return fmt.Sprintf("%s(%s)", sg.gen.QualifiedGoIdent(protogen.GoImportPath("encoding/json").Ident("RawMessage")), literal)
+	case reflect.TypeOf((*any)(nil)):
+		return fmt.Sprintf("jsonschema.Ptr[any](%s)", goAnyLiteral(v.Elem().Interface()))
+	case reflect.TypeOf((*float64)(nil)):
+		return fmt.Sprintf("&[]float64{%s}[0]", strconv.FormatFloat(v.Elem().Float(), 'g', -1, 64))
+	case reflect.TypeOf((*int)(nil)):
+		return fmt.Sprintf("&[]int{%d}[0]", v.Elem().Int())
+	}
+
+	var elems []string
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, strings.TrimPrefix(sg.goValue(v.Index(i)), "&jsonschema.Schema"))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			elems = append(elems, strconv.Quote(key.String())+": "+strings.TrimPrefix(sg.goValue(v.MapIndex(key)), "&jsonschema.Schema"))
+		}
+	case reflect.Interface:
+		return goAnyLiteral(v.Interface())
+	}
+	typ := strings.ReplaceAll(v.Type().String(), "interface {}", "any")
+	return typ + "{" + strings.Join(elems, ", ") + "}"
+}
+
+// goAnyLiteral renders a decoded JSON value as a Go expression of type any.
+func goAnyLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []any:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = goAnyLiteral(e)
+		}
+		return "[]any{" + strings.Join(elems, ", ") + "}"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		elems := make([]string, len(keys))
+		for i, k := range keys {
+			elems[i] = strconv.Quote(k) + ": " + goAnyLiteral(v[k])
+		}
+		return "map[string]any{" + strings.Join(elems, ", ") + "}"
+	default:
+		return goLiteral(v)
+	}
+}
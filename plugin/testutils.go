@@ -18,6 +18,7 @@ type SchemaFieldConfigResult struct {
 	Pattern              string
 	PropertyNamesPattern string
 	EnumValues           []int32
+	EnumRef              string
 	MessageRef           string
 	Nested               *SchemaFieldConfigResult
 }
@@ -27,6 +28,7 @@ type SchemaFieldConfigResult struct {
 type TestingHelper interface {
 	EscapeGoString(s string) string
 	GetTitleAndDescription(desc protoreflect.Descriptor) (title, description string)
+	ComposeComments(override string, descs ...protoreflect.Descriptor) (title, description string)
 	GetMessages(messages []*protogen.Message, defaultGenerate bool, visited map[string]bool) []*protogen.Message
 	GetMessagesWithForce(messages []*protogen.Message, defaultGenerate bool, force bool, visited map[string]bool) []*protogen.Message
 	GenerateFile(plugin *protogen.Plugin, file *protogen.File) (*protogen.GeneratedFile, error)
@@ -40,6 +42,9 @@ type TestingHelper interface {
 	ReferenceName(msg *protogen.Message) string
 	// MessageHasExplicitGenerateFalse returns true if the message has generate=false option.
 	MessageHasExplicitGenerateFalse(message *protogen.Message) bool
+	// VerifySchemas checks the message schemas of the file's generated code in content
+	// against the descriptors, as the verify parameter does.
+	VerifySchemas(file *protogen.File, filename string, content []byte) error
 }
 
 // testingHelper implements TestingHelper by delegating to Generator and MessageSchemaGenerator.
@@ -58,6 +63,10 @@ func (t *testingHelper) GetTitleAndDescription(desc protoreflect.Descriptor) (st
 	return t.gr.getTitleAndDescription(desc)
 }
 
+func (t *testingHelper) ComposeComments(override string, descs ...protoreflect.Descriptor) (string, string) {
+	return t.gr.composeComments(override, descs...)
+}
+
 func (t *testingHelper) GetMessages(messages []*protogen.Message, defaultGenerate bool, visited map[string]bool) []*protogen.Message {
 	return t.gr.getMessages(messages, defaultGenerate, visited)
 }
@@ -107,6 +116,10 @@ func (t *testingHelper) MessageHasExplicitGenerateFalse(message *protogen.Messag
 	return opts != nil && !opts.GetGenerate()
 }
 
+func (t *testingHelper) VerifySchemas(file *protogen.File, filename string, content []byte) error {
+	return t.gr.verifySchemas(file, filename, content, t.gr.getLocalMessages(file))
+}
+
 func schemaFieldConfigToResult(cfg schemaFieldConfig) SchemaFieldConfigResult {
 	res := SchemaFieldConfigResult{
 		FieldName:            cfg.fieldName,
@@ -117,6 +130,7 @@ func schemaFieldConfigToResult(cfg schemaFieldConfig) SchemaFieldConfigResult {
 		Pattern:              cfg.pattern,
 		PropertyNamesPattern: cfg.propertyNamesPattern,
 		EnumValues:           cfg.enumValues,
+		EnumRef:              cfg.enumRef,
 		MessageRef:           cfg.messageRef,
 	}
 	if cfg.nested != nil {
@@ -130,15 +144,22 @@ func schemaFieldConfigToResult(cfg schemaFieldConfig) SchemaFieldConfigResult {
 // Requires the plugintest build tag. Returns an error if generateFile fails.
 func NewTestingHelper(plugin *protogen.Plugin, file *protogen.File) (TestingHelper, error) {
 	gr := &Generator{}
+	gr.sharedEnums = gr.getSharedEnums(plugin)
+	gr.googleHelpers = gr.getGoogleHelpers(plugin)
+	requiredMessages, err := gr.getRequiredMessages(plugin)
+	if err != nil {
+		return nil, err
+	}
+	gr.requiredMessages = requiredMessages
 	genFile, err := gr.generateFile(plugin, file)
 	if err != nil {
 		return nil, err
 	}
 	sg := &MessageSchemaGenerator{
-		gr:         gr,
-		gen:        genFile,
-		visited:    make(map[string]bool),
-		filePrefix: fileNamePrefix(file),
+		gr:      gr,
+		gen:     genFile,
+		visited: make(map[string]bool),
+		file:    file,
 	}
 	return &testingHelper{gr: gr, sg: sg}, nil
 }
@@ -0,0 +1,466 @@
+package plugin
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Extensions on field options holding validation rules, which are read from the raw
+// options like google.api.resource_reference, so the generator depends on neither
+// validation module.
+const (
+	// validateFieldNumber is buf.validate.field (protovalidate's FieldRules).
+	validateFieldNumber protowire.Number = 1159
+
+	// pgvRulesNumber is validate.rules (the FieldRules of protoc-gen-validate, or PGV),
+	// protovalidate's predecessor. Its rule messages share protovalidate's field numbers,
+	// so both are translated by parseValidateRules.
+	pgvRulesNumber protowire.Number = 1071
+)
+
+// Field numbers of buf.validate.FieldRules and of the rule messages it holds.
+const (
+	// FieldRules: the type-specific rules (float = 1 through sfixed64 = 12 are numeric
+	// rules sharing const = 1, lt = 2, lte = 3, gt = 4, gte = 5; bool and enum rules also
+	// have const = 1), required and ignore.
+	validateRulesFloat    protowire.Number = 1
+	validateRulesDouble   protowire.Number = 2
+	validateRulesInt32    protowire.Number = 3
+	validateRulesInt64    protowire.Number = 4
+	validateRulesUint32   protowire.Number = 5
+	validateRulesUint64   protowire.Number = 6
+	validateRulesSint32   protowire.Number = 7
+	validateRulesSint64   protowire.Number = 8
+	validateRulesFixed32  protowire.Number = 9
+	validateRulesFixed64  protowire.Number = 10
+	validateRulesSfixed32 protowire.Number = 11
+	validateRulesSfixed64 protowire.Number = 12
+	validateRulesBool     protowire.Number = 13
+	validateRulesString   protowire.Number = 14
+	validateRulesEnum     protowire.Number = 16
+	validateRulesRepeated protowire.Number = 18
+	validateRulesMap      protowire.Number = 19
+	validateRequired      protowire.Number = 25
+	validateIgnore        protowire.Number = 27
+
+	// NumericRules const and bounds, and the const of BoolRules and EnumRules.
+	validateConst      protowire.Number = 1
+	validateNumericLt  protowire.Number = 2
+	validateNumericLte protowire.Number = 3
+	validateNumericGt  protowire.Number = 4
+	validateNumericGte protowire.Number = 5
+
+	// EnumRules.
+	validateEnumIn    protowire.Number = 3
+	validateEnumNotIn protowire.Number = 4
+
+	// StringRules.
+	validateStringConst   protowire.Number = 1
+	validateStringMinLen  protowire.Number = 2
+	validateStringMaxLen  protowire.Number = 3
+	validateStringPattern protowire.Number = 6
+	validateStringIn      protowire.Number = 10
+	validateStringLen     protowire.Number = 19
+
+	// RepeatedRules and MapRules.
+	validateRepeatedMinItems protowire.Number = 1
+	validateRepeatedMaxItems protowire.Number = 2
+	validateRepeatedUnique   protowire.Number = 3
+	validateRepeatedItems    protowire.Number = 4
+	validateMapMinPairs      protowire.Number = 1
+	validateMapMaxPairs      protowire.Number = 2
+	validateMapValues        protowire.Number = 5
+
+	// validateIgnoreAlways is the buf.validate.Ignore value disabling a field's rules.
+	validateIgnoreAlways = 3
+
+	// PGV's FieldRules.message (MessageRules) and MessageRules.required, which PGV has
+	// instead of FieldRules.required.
+	pgvMessageRules    protowire.Number = 17
+	pgvMessageRequired protowire.Number = 2
+)
+
+// validateStringFormats maps the StringRules well-known string rules, by field number, to
+// the JSON Schema format asserting them. Rules without an equivalent format (ip, address,
+// host_and_port, ...) are not translated.
+var validateStringFormats = map[protowire.Number]string{
+	12: "email",         // email
+	13: "hostname",      // hostname
+	15: "ipv4",          // ipv4
+	16: "ipv6",          // ipv6
+	17: "uri",           // uri
+	18: "uri-reference", // uri_ref
+	22: "uuid",          // uuid
+}
+
+// validateRules holds the JSON Schema keywords translated from a field's
+// buf.validate.field rules. Field options set in json_schema take precedence over them.
+type validateRules struct {
+	// required is FieldRules.required: the field must be set.
+	required bool
+
+	// format and pattern are string rules (well-known string rules, pattern).
+	format, pattern string
+
+	// enum lists the strings a string field may hold (in).
+	enum []string
+
+	// constant is the Go literal of the value a string, numeric or bool field must hold
+	// (const), e.g. `"user"` or `42`; empty if there is none.
+	constant string
+
+	// enumConst is the number an enum field must hold (enum.const), or nil.
+	enumConst *int32
+
+	// enumIn and enumNotIn are the numbers an enum field may and may not hold (enum.in,
+	// enum.not_in); a nil enumIn allows every value.
+	enumIn, enumNotIn []int32
+
+	// minLength and maxLength are string length rules (len, min_len, max_len).
+	minLength, maxLength *uint64
+
+	// minimum, maximum, exclusiveMinimum and exclusiveMaximum are numeric bounds (gte,
+	// lte, gt, lt).
+	minimum, maximum, exclusiveMinimum, exclusiveMaximum *float64
+
+	// minItems, maxItems and uniqueItems are repeated rules; required sets minItems to 1.
+	minItems, maxItems *uint64
+	uniqueItems        bool
+
+	// minProperties and maxProperties are map rules (min_pairs, max_pairs); required sets
+	// minProperties to 1.
+	minProperties, maxProperties *uint64
+
+	// items holds the rules of repeated items or map values.
+	items *validateRules
+}
+
+// getValidateRules translates the buf.validate.field rules of every field in the plugin
+// run, by full name, or else its legacy validate.rules (PGV) rules. Fields without rules,
+// or whose rules are ignored (IGNORE_ALWAYS), are not included.
+func (gr *Generator) getValidateRules() map[protoreflect.FullName]*validateRules {
+	rules := make(map[protoreflect.FullName]*validateRules)
+	for _, field := range gr.index.fields {
+		// Repeated occurrences of an encoded message merge when concatenated.
+		list, isMap := field.Desc.IsList(), field.Desc.IsMap()
+		if payloads := optionPayloads(field.Desc.Options(), validateFieldNumber); len(payloads) > 0 {
+			if r := parseValidateRules(bytes.Join(payloads, nil), list, isMap); r != nil {
+				rules[field.Desc.FullName()] = r
+			}
+			continue
+		}
+		if payloads := optionPayloads(field.Desc.Options(), pgvRulesNumber); len(payloads) > 0 {
+			payload := bytes.Join(payloads, nil)
+			r := parseValidateRules(payload, list, isMap)
+			for _, message := range encodedBytes(payload, pgvMessageRules) {
+				r.required = r.required || lastEncodedVarint(message, pgvMessageRequired) != 0
+			}
+			rules[field.Desc.FullName()] = r
+		}
+	}
+	return rules
+}
+
+// parseValidateRules translates an encoded buf.validate.FieldRules. list and isMap tell
+// whether the rules apply to a repeated or map field, where required means non-empty.
+func parseValidateRules(b []byte, list, isMap bool) *validateRules {
+	if lastEncodedVarint(b, validateIgnore) == validateIgnoreAlways {
+		return nil
+	}
+
+	r := &validateRules{}
+	required := lastEncodedVarint(b, validateRequired) != 0
+	switch {
+	case list:
+		if required {
+			r.minItems = &[]uint64{1}[0]
+		}
+	case isMap:
+		if required {
+			r.minProperties = &[]uint64{1}[0]
+		}
+	default:
+		r.required = required
+	}
+
+	for number := validateRulesFloat; number <= validateRulesSfixed64; number++ {
+		for _, numeric := range encodedBytes(b, number) {
+			r.parseNumericRules(numeric, number)
+		}
+	}
+	for _, str := range encodedBytes(b, validateRulesString) {
+		r.parseStringRules(str)
+	}
+	for _, boolean := range encodedBytes(b, validateRulesBool) {
+		if v, ok := lastEncodedScalar(boolean, validateConst); ok {
+			r.constant = strconv.FormatBool(v != 0)
+		}
+	}
+	for _, enum := range encodedBytes(b, validateRulesEnum) {
+		if v, ok := lastEncodedScalar(enum, validateConst); ok {
+			r.enumConst = &[]int32{int32(v)}[0]
+		}
+		for _, v := range encodedVarints(enum, validateEnumIn) {
+			r.enumIn = append(r.enumIn, int32(v))
+		}
+		for _, v := range encodedVarints(enum, validateEnumNotIn) {
+			r.enumNotIn = append(r.enumNotIn, int32(v))
+		}
+	}
+	for _, repeated := range encodedBytes(b, validateRulesRepeated) {
+		r.minItems = orUint64(lastEncodedUint64(repeated, validateRepeatedMinItems), r.minItems)
+		r.maxItems = orUint64(lastEncodedUint64(repeated, validateRepeatedMaxItems), r.maxItems)
+		r.uniqueItems = r.uniqueItems || lastEncodedVarint(repeated, validateRepeatedUnique) != 0
+		for _, items := range encodedBytes(repeated, validateRepeatedItems) {
+			r.items = parseValidateRules(items, false, false)
+		}
+	}
+	for _, m := range encodedBytes(b, validateRulesMap) {
+		r.minProperties = orUint64(lastEncodedUint64(m, validateMapMinPairs), r.minProperties)
+		r.maxProperties = orUint64(lastEncodedUint64(m, validateMapMaxPairs), r.maxProperties)
+		for _, values := range encodedBytes(m, validateMapValues) {
+			r.items = parseValidateRules(values, false, false)
+		}
+	}
+	return r
+}
+
+// parseNumericRules translates the bounds of an encoded numeric rules message, where
+// rulesNumber is its FieldRules field number, which determines the bounds' encoding.
+func (r *validateRules) parseNumericRules(b []byte, rulesNumber protowire.Number) {
+	bound := func(number protowire.Number) *float64 {
+		v, ok := lastEncodedScalar(b, number)
+		if !ok {
+			return nil
+		}
+		var f float64
+		switch rulesNumber {
+		case validateRulesFloat:
+			f = float64(math.Float32frombits(uint32(v)))
+		case validateRulesDouble:
+			f = math.Float64frombits(v)
+		case validateRulesInt32, validateRulesSfixed32:
+			f = float64(int32(v))
+		case validateRulesInt64, validateRulesSfixed64:
+			f = float64(int64(v))
+		case validateRulesSint32, validateRulesSint64:
+			f = float64(protowire.DecodeZigZag(v))
+		case validateRulesFixed32:
+			f = float64(uint32(v))
+		default:
+			f = float64(v)
+		}
+		return &f
+	}
+	if v, ok := lastEncodedScalar(b, validateConst); ok {
+		switch rulesNumber {
+		case validateRulesFloat:
+			r.constant = strconv.FormatFloat(float64(math.Float32frombits(uint32(v))), 'g', -1, 32)
+		case validateRulesDouble:
+			r.constant = strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64)
+		case validateRulesInt32, validateRulesSfixed32:
+			r.constant = strconv.FormatInt(int64(int32(v)), 10)
+		case validateRulesInt64, validateRulesSfixed64:
+			r.constant = strconv.FormatInt(int64(v), 10)
+		case validateRulesSint32, validateRulesSint64:
+			r.constant = strconv.FormatInt(protowire.DecodeZigZag(v), 10)
+		case validateRulesFixed32:
+			r.constant = strconv.FormatUint(uint64(uint32(v)), 10)
+		default:
+			r.constant = strconv.FormatUint(v, 10)
+		}
+	}
+	r.exclusiveMaximum = orFloat64(bound(validateNumericLt), r.exclusiveMaximum)
+	r.maximum = orFloat64(bound(validateNumericLte), r.maximum)
+	r.exclusiveMinimum = orFloat64(bound(validateNumericGt), r.exclusiveMinimum)
+	r.minimum = orFloat64(bound(validateNumericGte), r.minimum)
+}
+
+// parseStringRules translates an encoded buf.validate.StringRules.
+func (r *validateRules) parseStringRules(b []byte) {
+	if n := lastEncodedUint64(b, validateStringLen); n != nil {
+		r.minLength, r.maxLength = n, n
+	}
+	r.minLength = orUint64(lastEncodedUint64(b, validateStringMinLen), r.minLength)
+	r.maxLength = orUint64(lastEncodedUint64(b, validateStringMaxLen), r.maxLength)
+	if p := lastEncodedString(b, validateStringPattern); p != "" {
+		r.pattern = p
+	}
+	for number, format := range validateStringFormats {
+		if lastEncodedVarint(b, number) != 0 {
+			r.format = format
+		}
+	}
+	if values := encodedBytes(b, validateStringConst); len(values) > 0 {
+		r.constant = strconv.Quote(string(values[len(values)-1]))
+	} else {
+		for _, v := range encodedBytes(b, validateStringIn) {
+			r.enum = append(r.enum, string(v))
+		}
+	}
+}
+
+// constLiteral returns the Go literal of the value the rules fix a field to (const), or
+// "" if there is none. enum is the enum of the field's values, if any: its const is a
+// value name with the enum_names parameter, and otherwise a number.
+func (gr *Generator) constLiteral(r *validateRules, enum *protogen.Enum) string {
+	if r.enumConst == nil || enum == nil {
+		return r.constant
+	}
+	if !gr.opts.EnumNames {
+		return strconv.Itoa(int(*r.enumConst))
+	}
+	value := enum.Desc.Values().ByNumber(protoreflect.EnumNumber(*r.enumConst))
+	if value == nil {
+		return ""
+	}
+	return strconv.Quote(gr.enumValueNames(enum.Desc)[value.Index()])
+}
+
+// enumSubset returns the Go literals of the values of enum the rules allow (enum.in,
+// enum.not_in) in declaration order (see enumLiterals), or nil if the rules allow every
+// value or enum is nil.
+func (gr *Generator) enumSubset(r *validateRules, enum *protogen.Enum) []string {
+	if enum == nil || (r.enumIn == nil && r.enumNotIn == nil) {
+		return nil
+	}
+	return gr.enumLiterals(enum, func(number int32) bool {
+		return (r.enumIn == nil || slices.Contains(r.enumIn, number)) && !slices.Contains(r.enumNotIn, number)
+	})
+}
+
+// enumLiterals returns the Go literals of the values of enum whose numbers keep accepts,
+// in declaration order, as value names with the enum_names parameter and numbers
+// otherwise.
+func (gr *Generator) enumLiterals(enum *protogen.Enum, keep func(number int32) bool) []string {
+	names := gr.enumValueNames(enum.Desc)
+	literals := []string{}
+	for i, value := range enum.Values {
+		number := int32(value.Desc.Number())
+		if !keep(number) {
+			continue
+		}
+		if gr.opts.EnumNames {
+			literals = append(literals, strconv.Quote(names[i]))
+		} else {
+			literals = append(literals, strconv.Itoa(int(number)))
+		}
+	}
+	return literals
+}
+
+// isRequired reports whether the rules require a singular field to be set. It is safe
+// to call on nil rules.
+func (r *validateRules) isRequired() bool {
+	return r != nil && r.required
+}
+
+// lastEncodedScalar returns the value of a singular varint, fixed32 or fixed64 field
+// number in an encoded message, as its raw bits. The last value wins, as in proto parsing.
+func lastEncodedScalar(b []byte, number protowire.Number) (value uint64, ok bool) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+		if num == number {
+			switch typ {
+			case protowire.VarintType:
+				if v, m := protowire.ConsumeVarint(b); m >= 0 {
+					value, ok = v, true
+				}
+			case protowire.Fixed32Type:
+				if v, m := protowire.ConsumeFixed32(b); m >= 0 {
+					value, ok = uint64(v), true
+				}
+			case protowire.Fixed64Type:
+				if v, m := protowire.ConsumeFixed64(b); m >= 0 {
+					value, ok = v, true
+				}
+			}
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+	}
+	return value, ok
+}
+
+// lastEncodedVarint returns the value of a singular varint field number in an encoded
+// message, or 0 if it is not set.
+func lastEncodedVarint(b []byte, number protowire.Number) uint64 {
+	v, _ := lastEncodedScalar(b, number)
+	return v
+}
+
+// lastEncodedUint64 returns the value of a singular varint field number in an encoded
+// message, or nil if it is not set.
+func lastEncodedUint64(b []byte, number protowire.Number) *uint64 {
+	if v, ok := lastEncodedScalar(b, number); ok {
+		return &v
+	}
+	return nil
+}
+
+// encodedVarints returns the values of a repeated varint field number in an encoded
+// message, packed or not, in order.
+func encodedVarints(b []byte, number protowire.Number) []uint64 {
+	var values []uint64
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+		if num == number && typ == protowire.VarintType {
+			var v uint64
+			if v, n = protowire.ConsumeVarint(b); n >= 0 {
+				values = append(values, v)
+			}
+		} else if num == number && typ == protowire.BytesType {
+			var packed []byte
+			if packed, n = protowire.ConsumeBytes(b); n >= 0 {
+				for len(packed) > 0 {
+					v, m := protowire.ConsumeVarint(packed)
+					if m < 0 {
+						break
+					}
+					values = append(values, v)
+					packed = packed[m:]
+				}
+			}
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+	}
+	return values
+}
+
+// orUint64 returns v, or fallback if v is nil.
+func orUint64(v, fallback *uint64) *uint64 {
+	if v != nil {
+		return v
+	}
+	return fallback
+}
+
+// orFloat64 returns v, or fallback if v is nil.
+func orFloat64(v, fallback *float64) *float64 {
+	if v != nil {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// verifyFile re-parses the generated file and checks the schemas of messages against
+// their descriptors (the verify parameter). It returns an error listing every mismatch,
+// or nil if there is none.
+func (gr *Generator) verifyFile(g *protogen.GeneratedFile, file *protogen.File, filename string, messages []*protogen.Message) error {
+	content, err := g.Content()
+	if err != nil {
+		return fmt.Errorf("%s: verify: %w", file.Desc.Path(), err)
+	}
+	return gr.verifySchemas(file, filename, content, messages)
+}
+
+// verifySchemas checks the schemas of messages defined by the generated code in content
+// against their descriptors (see verifyFile).
+func (gr *Generator) verifySchemas(file *protogen.File, filename string, content []byte, messages []*protogen.Message) error {
+	defs, err := parseSchemaDefinitions(filename, content)
+	if err != nil {
+		return fmt.Errorf("%s: verify: parsing generated %s: %w", file.Desc.Path(), filename, err)
+	}
+
+	var problems []string
+	for _, msg := range messages {
+		i := slices.IndexFunc(defs, func(d schemaDefinition) bool { return d.name == string(msg.Desc.FullName()) })
+		if i < 0 {
+			problems = append(problems, fmt.Sprintf("%s: no schema definition", msg.Desc.FullName()))
+			continue
+		}
+		problems = append(problems, gr.verifyMessage(msg, defs[i])...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: verify: generated %s does not match the descriptors:\n  %s", file.Desc.Path(), filename, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// verifyMessage returns the mismatches between a message and its parsed schema
+// definition: fields without a property, properties without a field, and properties
+// whose literal JSON type disagrees with the field's type. Members of discriminated
+// oneofs are expected under the union's property.
+func (gr *Generator) verifyMessage(msg *protogen.Message, def schemaDefinition) []string {
+	var problems []string
+	var expected []string
+	for _, field := range msg.Fields {
+		if getFieldJsonSchemaOptions(field).GetIgnore() {
+			continue
+		}
+		name := gr.getFieldName(field)
+		if _, ok := gr.discriminatedOneofs[oneofFullName(field)]; ok {
+			name = gr.getOneofName(field.Oneof)
+		}
+		if slices.Contains(expected, name) {
+			continue
+		}
+		expected = append(expected, name)
+		if !slices.Contains(def.properties, name) {
+			problems = append(problems, fmt.Sprintf("%s: no property %q", field.Desc.FullName(), name))
+			continue
+		}
+		if name != gr.getFieldName(field) {
+			continue
+		}
+		want, ok := gr.fieldJSONType(field)
+		if types := def.types[name]; ok && len(types) > 0 && !slices.Contains(types, want) {
+			problems = append(problems, fmt.Sprintf("%s: property %q has type %s, want %s", field.Desc.FullName(), name, strings.Join(types, "|"), want))
+		}
+	}
+	for _, property := range def.properties {
+		if !slices.Contains(expected, property) {
+			problems = append(problems, fmt.Sprintf("%s: property %q has no field", msg.Desc.FullName(), property))
+		}
+	}
+	return problems
+}
+
+// fieldJSONType returns the JSON type of a field's values in the schema: array for
+// repeated fields, object for maps, and the type of the field's kind for scalars and
+// enums. It reports false for message fields, whose schemas depend on the message
+// (well-known types map to strings, numbers or any value).
+func (gr *Generator) fieldJSONType(field *protogen.Field) (string, bool) {
+	switch {
+	case field.Desc.IsMap():
+		return jsObject, true
+	case field.Desc.IsList():
+		return jsArray, true
+	case field.Desc.Kind() == protoreflect.MessageKind || field.Desc.Kind() == protoreflect.GroupKind:
+		return "", false
+	}
+	sg := &MessageSchemaGenerator{gr: gr}
+	typeName, err := sg.getKindTypeName(field.Desc)
+	return typeName, err == nil
+}
@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	optionsPb "open.alis.services/protobuf/alis/open/options/v1"
+)
+
+// optionExtensions are the custom option extensions that enable and configure generation.
+var optionExtensions = []protoreflect.ExtensionType{optionsPb.E_File, optionsPb.E_Message, optionsPb.E_Field}
+
+// Warnings returns the problems with the request that make the plugin silently generate
+// less than the files ask for, most often a misconfigured protoc invocation:
+//
+//   - no file to generate imports alis/open/options/v1/options.proto, so no schemas are
+//     enabled;
+//   - an option extension is present in a descriptor but could not be resolved, which
+//     happens when the descriptors were decoded without the options file (e.g. a
+//     descriptor set built without --include_imports), so the option is ignored;
+//   - an option sets fields this version of the plugin does not know, because the
+//     options file used to compile the protos is newer than the plugin.
+//
+// GenerateWithOptions prints them to stderr, which protoc shows to the user.
+func Warnings(plugin *protogen.Plugin) []string {
+	optionsPath := optionsPb.File_alis_open_options_v1_options_proto.Path()
+
+	index := newRequestIndex(plugin)
+	var warnings []string
+	importsOptions := false
+	for _, f := range plugin.Files {
+		if !f.Generate {
+			continue
+		}
+		for i := range f.Desc.Imports().Len() {
+			if f.Desc.Imports().Get(i).Path() == optionsPath {
+				importsOptions = true
+			}
+		}
+
+		path := f.Desc.Path()
+		warnings = append(warnings, optionWarnings(path, f.Desc.Options())...)
+		for _, msg := range index.files[path] {
+			warnings = append(warnings, optionWarnings(fmt.Sprintf("%s: %s", path, msg.Desc.FullName()), msg.Desc.Options())...)
+			for _, field := range msg.Fields {
+				warnings = append(warnings, optionWarnings(fmt.Sprintf("%s: %s", path, field.Desc.FullName()), field.Desc.Options())...)
+			}
+		}
+	}
+
+	if !importsOptions && slices.ContainsFunc(plugin.Files, func(f *protogen.File) bool { return f.Generate }) {
+		warnings = append([]string{fmt.Sprintf(
+			"no file to generate imports %s, so no schemas are generated; import it and set its json_schema options, with the alis protos on the protoc --proto_path",
+			optionsPath)}, warnings...)
+	}
+	return warnings
+}
+
+// optionWarnings returns the warnings for the options of one descriptor, found at where:
+// the option extensions it carries unresolved, and the fields of resolved ones that the
+// plugin does not know.
+func optionWarnings(where string, opts proto.Message) []string {
+	if opts == nil {
+		return nil
+	}
+	var warnings []string
+	for _, ext := range optionExtensions {
+		desc := ext.TypeDescriptor()
+		if desc.ContainingMessage().FullName() != opts.ProtoReflect().Descriptor().FullName() {
+			continue
+		}
+		if slices.Contains(unknownFieldNumbers(opts.ProtoReflect()), desc.Number()) {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s: option (%s) could not be resolved and is ignored; include %s in the request (protoc --include_imports when using descriptor sets)",
+				where, desc.FullName(), desc.ParentFile().Path()))
+		}
+		if proto.HasExtension(opts, ext) {
+			value := proto.GetExtension(opts, ext).(proto.Message)
+			for _, unknown := range unknownFields(value.ProtoReflect()) {
+				warnings = append(warnings, fmt.Sprintf(
+					"%s: option (%s) sets %s, which this version of the plugin does not know and ignores; upgrade protoc-gen-go-jsonschema",
+					where, desc.FullName(), unknown))
+			}
+		}
+	}
+	return warnings
+}
+
+// unknownFields describes the unknown fields of m and of the messages it contains, e.g.
+// "field 99 of alis.open.options.v1.FieldOptions.JsonSchema".
+func unknownFields(m protoreflect.Message) []string {
+	var unknown []string
+	for _, number := range unknownFieldNumbers(m) {
+		unknown = append(unknown, fmt.Sprintf("field %d of %s", number, m.Descriptor().FullName()))
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if isMessageKind(fd.MapValue().Kind()) {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					unknown = append(unknown, unknownFields(mv.Message())...)
+					return true
+				})
+			}
+		case !isMessageKind(fd.Kind()):
+		case fd.IsList():
+			for i := range v.List().Len() {
+				unknown = append(unknown, unknownFields(v.List().Get(i).Message())...)
+			}
+		default:
+			unknown = append(unknown, unknownFields(v.Message())...)
+		}
+		return true
+	})
+	return unknown
+}
+
+// unknownFieldNumbers returns the numbers of the unknown fields of m, in order and
+// without duplicates.
+func unknownFieldNumbers(m protoreflect.Message) []protoreflect.FieldNumber {
+	var numbers []protoreflect.FieldNumber
+	b := m.GetUnknown()
+	for len(b) > 0 {
+		number, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+		if n = protowire.ConsumeFieldValue(number, typ, b); n < 0 {
+			break
+		}
+		b = b[n:]
+		if !slices.Contains(numbers, number) {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// printWarnings writes warnings to w, one per line, prefixed with the plugin name.
+func printWarnings(w io.Writer, warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "protoc-gen-go-jsonschema: warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,141 @@
+//go:build plugintest
+
+package plugintest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// fixtureAreas lists the feature areas with fixture protos in
+// testdata/protos/fixtures/<area>/v1. New features should add a targeted fixture to an
+// area (or a new area) instead of growing users/v1/user.proto.
+var fixtureAreas = []string{"enums", "maps", "oneofs", "wkt", "recursion", "options", "validate"}
+
+// Patterns reading the package and the receivers of generated JsonSchema methods from
+// generated code.
+var (
+	packageClause    = regexp.MustCompile(`(?m)^package (\w+)`)
+	accessorReceiver = regexp.MustCompile(`(?m)^func \(x \*(\w+)\) JsonSchema\(\)`)
+)
+
+// LoadFixture compiles the fixture protos of an area into the fixtures_<area>.pb
+// descriptor set (see compileProtos) and returns a fresh protogen.Plugin generating all
+// of them.
+func (s *PluginTestSuite) LoadFixture(area string) *protogen.Plugin {
+	protoPath := filepath.Join(s.workspaceRoot, "testdata", "protos")
+	matches, err := filepath.Glob(filepath.Join(protoPath, "fixtures", area, "v1", "*.proto"))
+	s.Require().NoError(err)
+	s.Require().NotEmpty(matches, "No fixture protos for area %q", area)
+
+	var files []string
+	for _, match := range matches {
+		rel, err := filepath.Rel(protoPath, match)
+		s.Require().NoError(err)
+		files = append(files, filepath.ToSlash(rel))
+	}
+
+	fds := s.compileProtos("fixtures_"+area+".pb", files...)
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: files, ProtoFile: fds.File})
+	s.Require().NoError(err, "Failed to create protogen.Plugin")
+	return p
+}
+
+// GenerateFixture runs GenerateWithOptions on the fixture protos of an area and returns
+// the generated content keyed by file name.
+func (s *PluginTestSuite) GenerateFixture(area string, opts plugin.Options) map[string]string {
+	p := s.LoadFixture(area)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", opts), "Generate failed")
+
+	resp := p.Response()
+	s.Require().Empty(resp.GetError(), "Generate response error: %s", resp.GetError())
+
+	result := make(map[string]string)
+	for _, file := range resp.File {
+		if file.Content != nil {
+			result[file.GetName()] = file.GetContent()
+		}
+	}
+	return result
+}
+
+// RunFixtureModule compiles generated fixture code in a temporary module and runs its
+// tests. The module is rendered from testdata/templates: go.mod.tmpl (which requires the
+// plugin module from the workspace when the generated code or a test imports schemautil),
+// stub_types.go.tmpl (a stand-in type for every message with a JsonSchema method) and
+// resolve_test.go.tmpl (which marshals and resolves every message schema). tests adds
+// test files, keyed by file name, for checks specific to a feature.
+func (s *PluginTestSuite) RunFixtureModule(area string, contents map[string]string, tests map[string]string) {
+	tmpDir := s.TempDir()
+
+	var pkg, workspace string
+	var receivers []string
+	for name, content := range contents {
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644))
+		if m := packageClause.FindStringSubmatch(content); m != nil {
+			pkg = m[1]
+		}
+		if strings.Contains(content, `"github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"`) {
+			workspace = s.workspaceRoot
+		}
+		for _, m := range accessorReceiver.FindAllStringSubmatch(content, -1) {
+			receivers = append(receivers, m[1])
+		}
+	}
+	s.Require().NotEmpty(pkg, "No generated files for area %q", area)
+	sort.Strings(receivers)
+	for name, content := range tests {
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644))
+		if strings.Contains(content, `"github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"`) {
+			workspace = s.workspaceRoot
+		}
+	}
+
+	data := struct {
+		Module    string
+		Package   string
+		Messages  []string
+		Workspace string
+	}{
+		Module:    "fixtures/" + area,
+		Package:   pkg,
+		Messages:  receivers,
+		Workspace: workspace,
+	}
+	for name, tmpl := range map[string]string{
+		"go.mod":          "go.mod.tmpl",
+		"stub_types.go":   "stub_types.go.tmpl",
+		"resolve_test.go": "resolve_test.go.tmpl",
+	} {
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, name), []byte(s.renderTemplate(tmpl, data)), 0o644))
+	}
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "./...")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "Fixture tests for area %q failed: %s", area, string(output))
+}
+
+// renderTemplate executes a template of testdata/templates with data.
+func (s *PluginTestSuite) renderTemplate(name string, data any) string {
+	tmpl, err := template.ParseFiles(filepath.Join(s.workspaceRoot, "testdata", "templates", name))
+	s.Require().NoError(err, "Failed to parse template %s", name)
+
+	var b strings.Builder
+	s.Require().NoError(tmpl.Execute(&b, data), "Failed to render template %s", name)
+	return b.String()
+}
@@ -160,6 +160,35 @@ func (s *FunctionsTestSuite) TestGetTitleAndDescription() {
 	}
 }
 
+// TestComposeComments tests the precedence of method, service and override comments.
+func (s *FunctionsTestSuite) TestComposeComments() {
+	helper := s.TestingHelper()
+
+	service := s.File().Services[0].Desc
+	method := service.Methods().ByName("StreamUsers")
+	s.Require().NotNil(method)
+	const (
+		methodDesc  = "StreamUsers streams the requested users as they are retrieved."
+		serviceDesc = "UserService provides operations for managing users."
+	)
+
+	s.Run("method then service", func() {
+		title, desc := helper.ComposeComments("", method, service)
+		s.Empty(title)
+		s.Equal(methodDesc+"\n\n"+serviceDesc, desc)
+	})
+
+	s.Run("override replaces the method comment only", func() {
+		_, desc := helper.ComposeComments("Streams users.", method, service)
+		s.Equal("Streams users.\n\n"+serviceDesc, desc)
+	})
+
+	s.Run("repeated paragraphs are skipped", func() {
+		_, desc := helper.ComposeComments(serviceDesc, method, service)
+		s.Equal(serviceDesc, desc)
+	})
+}
+
 // TestGetEnumValues tests enum value extraction.
 func (s *FunctionsTestSuite) TestGetEnumValues() {
 	helper := s.TestingHelper()
@@ -201,11 +230,15 @@ func (s *FunctionsTestSuite) TestGetMessageSchemaConfigGoogleTypes() {
 		fieldName        string
 		expectedRef      string
 		expectMessageRef bool
+		expectedType     string
 	}{
-		{"created_at", "google_protobuf_Timestamp_JsonSchema_WithDefs(defs)", true},
-		{"time_duration", "google_protobuf_Duration_JsonSchema_WithDefs(defs)", true},
-		{"struct_field", "google_protobuf_Struct_JsonSchema_WithDefs(defs)", true},
-		{"any_field", "google_protobuf_Any_JsonSchema_WithDefs(defs)", true},
+		{"created_at", "google_protobuf_Timestamp_JsonSchema_WithDefs(defs)", true, ""},
+		{"time_duration", "google_protobuf_Duration_JsonSchema_WithDefs(defs)", true, ""},
+		{"any_field", "google_protobuf_Any_JsonSchema_WithDefs(defs)", true, ""},
+		// Free-form JSON types are inlined.
+		{"struct_field", "", false, jsObject},
+		{"value_field", "", false, ""},
+		{"list_field", "", false, jsArray},
 	}
 
 	for _, tt := range tests {
@@ -217,6 +250,9 @@ func (s *FunctionsTestSuite) TestGetMessageSchemaConfigGoogleTypes() {
 				s.NotEmpty(cfg.MessageRef, "MessageRef for %s should be set", tt.fieldName)
 				s.Contains(cfg.MessageRef, tt.expectedRef, "MessageRef for %s should contain expected function name", tt.fieldName)
 				s.Empty(cfg.TypeName, "TypeName for %s should be empty (using $ref)", tt.fieldName)
+			} else {
+				s.Empty(cfg.MessageRef, "MessageRef for %s should be empty (inlined)", tt.fieldName)
+				s.Equal(tt.expectedType, cfg.TypeName, "TypeName for %s", tt.fieldName)
 			}
 		})
 	}
@@ -295,6 +331,7 @@ func (s *FunctionsTestSuite) TestGetArraySchemaConfig() {
 			}
 			if tt.hasEnumValues {
 				s.NotEmpty(cfg.Nested.EnumValues, "Expected enum values for %s", tt.fieldName)
+				s.Equal("UserStatus_JsonSchema_WithDefs(defs)", cfg.Nested.EnumRef, "Expected shared enum ref for %s", tt.fieldName)
 			}
 			if tt.hasMessageRef {
 				s.NotEmpty(cfg.Nested.MessageRef, "Expected message ref for %s", tt.fieldName)
@@ -338,6 +375,7 @@ func (s *FunctionsTestSuite) TestGetMapSchemaConfig() {
 			}
 			if tt.hasEnumValues {
 				s.NotEmpty(cfg.Nested.EnumValues, "Expected enum values for %s", tt.fieldName)
+				s.Equal("UserStatus_JsonSchema_WithDefs(defs)", cfg.Nested.EnumRef, "Expected shared enum ref for %s", tt.fieldName)
 			}
 			if tt.hasMessageRef {
 				s.NotEmpty(cfg.Nested.MessageRef, "Expected message ref for %s", tt.fieldName)
@@ -3,18 +3,30 @@
 package plugintest
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/jsonschematest"
 	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
+	optionsPb "open.alis.services/protobuf/alis/open/options/v1"
 )
 
 // IntegrationTestSuite contains integration tests that test the full plugin pipeline.
@@ -54,8 +66,9 @@ func (s *IntegrationTestSuite) buildPlugin() {
 	tmpDir := os.TempDir()
 	s.pluginBinary = filepath.Join(tmpDir, "protoc-gen-go-jsonschema-test")
 
-	// Build the plugin
-	buildCmd := exec.Command("go", "build", "-o", s.pluginBinary, "./cmd/protoc-gen-go-jsonschema")
+	// Build the plugin. Without VCS stamping it is a development build whatever the state
+	// of the checkout, rather than one carrying an unpublished pseudo-version.
+	buildCmd := exec.Command("go", "build", "-buildvcs=false", "-o", s.pluginBinary, "./cmd/protoc-gen-go-jsonschema")
 	buildCmd.Dir = s.workspaceRoot
 	output, err := buildCmd.CombinedOutput()
 	s.Require().NoError(err, "Failed to build plugin: %s", string(output))
@@ -76,7 +89,7 @@ func (s *IntegrationTestSuite) TestGoldenFile() {
 		baseName := filepath.Base(name)
 		goldenPath := filepath.Join(goldenBase, baseName+".golden")
 
-		assertGoldenFile(s.T(), content, goldenPath, *updateGolden)
+		jsonschematest.AssertGolden(s.T(), content, goldenPath, *updateGolden)
 	}
 }
 
@@ -143,6 +156,7 @@ type OneOfDemo struct{}
 type WellKnownTypesDemo struct{}
 type Address_AddressDetails struct{}
 type Common struct{}
+type Price struct{}
 type Admin struct{}
 `
 	stubPath := filepath.Join(pkgDir, "stub_types.go")
@@ -170,9 +184,7 @@ func (s *IntegrationTestSuite) TestEndToEndWithProtoc() {
 	}
 
 	// Check if protoc is available
-	if _, err := exec.LookPath("protoc"); err != nil {
-		s.T().Skip("protoc not found in PATH, skipping end-to-end test")
-	}
+	s.requireProtoc()
 
 	// Create a temporary directory for output
 	tmpDir := s.TempDir()
@@ -251,9 +263,7 @@ func (s *IntegrationTestSuite) TestDescriptorSetGeneration() {
 	}
 
 	// Check if protoc is available
-	if _, err := exec.LookPath("protoc"); err != nil {
-		s.T().Skip("protoc not found in PATH")
-	}
+	s.requireProtoc()
 
 	tmpDir := s.TempDir()
 
@@ -396,6 +406,7 @@ type OneOfDemo struct{}
 type WellKnownTypesDemo struct{}
 type Address_AddressDetails struct{}
 type Common struct{}
+type Price struct{}
 type Admin struct{}
 `
 	stubPath := filepath.Join(pkgDir, "stub_types.go")
@@ -837,9 +848,120 @@ func (s *IntegrationTestSuite) TestForceLogicForFieldDependencies() {
 		"Expected parent message to reference field dependency")
 }
 
+// TestObjectRootRuntime verifies that with the object_root option JsonSchema() returns an
+// object-typed root whose self-references resolve via the re-pointed $defs entry.
+func (s *IntegrationTestSuite) TestObjectRootRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{ObjectRoot: true})
+
+	content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+	s.Contains(content, `defs["users.v1.AddressDetails"] = &jsonschema.Schema{Ref: "#"}`)
+	s.NotContains(content, `root := &jsonschema.Schema{Ref: "#/$defs/users.v1.AddressDetails", Type: "object"}`)
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestObjectRoot(t *testing.T) {
+	testCases := []struct {
+		name   string
+		key    string
+		schema func() *jsonschema.Schema
+	}{
+		{"User", "users.v1.User", func() *jsonschema.Schema { return (&User{}).JsonSchema() }},
+		{"AddressDetails", "users.v1.AddressDetails", func() *jsonschema.Schema { return (&AddressDetails{}).JsonSchema() }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := tc.schema()
+			if schema.Type != "object" || schema.Ref != "" {
+				t.Fatalf("expected object-typed root without $ref, got type %q ref %q", schema.Type, schema.Ref)
+			}
+			if schema.Defs[tc.key].Ref != "#" {
+				t.Fatalf("expected self definition to point to the root, got %q", schema.Defs[tc.key].Ref)
+			}
+			// Resolve fails if any $ref (including self-references via "#") cannot be resolved
+			if _, err := schema.Resolve(&jsonschema.ResolveOptions{ValidateDefaults: true}); err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if _, err := json.Marshal(schema); err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+		})
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "object_root_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testobjectroot/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-v", "-timeout", "30s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.T().Logf("Object root test output:\n%s", string(output))
+	s.Require().NoError(err, "Object root runtime tests failed: %s", string(output))
+}
+
 // TestForceLogicRuntime verifies that forced messages are present in $defs at runtime.
+// Price opts into the money preset, which TestMoneyPresetConstraints checks.
 func (s *IntegrationTestSuite) TestForceLogicRuntime() {
-	contents := s.RunGenerate()
+	contents := s.RunGenerateWithOptions(plugin.Options{Presets: []string{"users.v1.Price=money"}})
 
 	// Create temp directory for runtime test
 	tmpDir, err := os.MkdirTemp("", "force-logic-test-*")
@@ -884,6 +1006,7 @@ type ConstraintDemo struct{}
 type OneOfDemo struct{}
 type WellKnownTypesDemo struct{}
 type Common struct{}
+type Price struct{}
 type Admin struct{}
 `
 	err = os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
@@ -1074,6 +1197,75 @@ func TestNestedMessageInDefs(t *testing.T) {
 	}
 }
 
+func TestNestedMessageAccessorIsSelfContained(t *testing.T) {
+	// Nested message Go types get their own public accessor, not just a _WithDefs helper
+	schema := (&Address_AddressDetails{}).JsonSchema()
+	if schema == nil {
+		t.Fatal("Address_AddressDetails.JsonSchema() returned nil")
+	}
+
+	nestedKey := "users.v1.Address.AddressDetails"
+	if schema.Ref != "#/$defs/"+nestedKey {
+		t.Errorf("Expected root $ref to %q, got %q", nestedKey, schema.Ref)
+	}
+	if _, ok := schema.Defs[nestedKey]; !ok {
+		t.Fatalf("Expected %q in Defs, got keys: %v", nestedKey, getDefKeys(schema.Defs))
+	}
+	if _, ok := schema.Defs["users.v1.Address"]; ok {
+		t.Error("Nested message schema should not pull in its parent's definition")
+	}
+
+	if _, err := ValidateSchemaWithName("Address_AddressDetails", schema); err != nil {
+		t.Fatalf("Address_AddressDetails schema validation failed: %v", err)
+	}
+	if _, err := (&Address_AddressDetails{}).MCPInputSchema().Resolve(nil); err != nil {
+		t.Fatalf("Address_AddressDetails MCP input schema failed to resolve: %v", err)
+	}
+}
+
+func TestEnumValuesHelper(t *testing.T) {
+	values := UserStatus_JsonSchemaEnum()
+	if len(values) != 5 {
+		t.Fatalf("Expected 5 UserStatus values, got %d", len(values))
+	}
+	if values[1].Value != 1 || values[1].Name != "USER_STATUS_ACTIVE" || values[1].Description == "" {
+		t.Errorf("Unexpected UserStatus value: %+v", values[1])
+	}
+}
+
+func TestMoneyPresetConstraints(t *testing.T) {
+	resolved, err := (&Price{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Price schema failed to resolve: %v", err)
+	}
+
+	testCases := []struct {
+		name  string
+		units int64
+		nanos int32
+		valid bool
+	}{
+		{"positive", 1, 750000000, true},
+		{"negative", -1, -750000000, true},
+		{"zero units", 0, -500000000, true},
+		{"positive units with negative nanos", 1, -1, false},
+		{"negative units with positive nanos", -1, 1, false},
+		{"nanos out of range", 0, 1000000000, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			instance := map[string]any{"currency_code": "USD", "units": tc.units, "nanos": tc.nanos}
+			err := resolved.Validate(instance)
+			if tc.valid && err != nil {
+				t.Errorf("Expected valid, got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
 func TestFieldDependencyInDefs(t *testing.T) {
 	// Test that ComprehensiveUser schema has Address in its definitions
 	// This verifies that field dependencies are forced to generate
@@ -1139,47 +1331,8 @@ require github.com/google/jsonschema-go v0.3.0
 // JSON marshalling/unmarshalling validation to ensure all schema types work correctly.
 func (s *IntegrationTestSuite) TestWeatherForecastSchemaValidation() {
 	// Generate descriptor set for weather proto
-	workspaceRoot := s.findWorkspaceRoot()
-	protoPath := filepath.Join(workspaceRoot, "testdata", "protos")
 	weatherProto := "weather/v1/weather.proto"
-	outputPath := filepath.Join(workspaceRoot, "testdata", "descriptors", "weather.pb")
-
-	// Create output directory if it doesn't exist
-	err := os.MkdirAll(filepath.Dir(outputPath), 0o755)
-	s.Require().NoError(err, "Failed to create descriptor output directory")
-
-	// Build protoc command arguments
-	args := []string{
-		"--descriptor_set_out=" + outputPath,
-		"--include_imports",
-		"--include_source_info",
-		"--proto_path=" + protoPath,
-	}
-
-	// Find alis proto path if available (for custom options)
-	// Use home directory to make path portable across systems
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		alisPath := filepath.Join(homeDir, "alis.build", "alis", "define")
-		if _, err := os.Stat(alisPath); err == nil {
-			args = append(args, "--proto_path="+alisPath)
-		}
-	}
-
-	// Add the proto file
-	args = append(args, weatherProto)
-
-	// Run protoc
-	cmd := exec.Command("protoc", args...)
-	output, err := cmd.CombinedOutput()
-	s.Require().NoError(err, "Failed to run protoc for weather proto: %s\nArgs: %v", string(output), args)
-
-	// Load the descriptor set
-	data, err := os.ReadFile(outputPath)
-	s.Require().NoError(err, "Failed to read weather descriptor set")
-
-	var fds descriptorpb.FileDescriptorSet
-	err = proto.Unmarshal(data, &fds)
-	s.Require().NoError(err, "Failed to unmarshal weather descriptor set")
+	fds := s.compileProtos("weather.pb", weatherProto)
 
 	// Create plugin request
 	req := &pluginpb.CodeGeneratorRequest{
@@ -1669,13 +1822,14 @@ func TestNestedMessageSchemasAreValid(t *testing.T) {
 
 	for _, defKey := range nestedSchemas {
 		t.Run(defKey, func(t *testing.T) {
-			nestedSchema, ok := requestSchema.Defs[defKey]
-			if !ok {
+			if _, ok := requestSchema.Defs[defKey]; !ok {
 				t.Fatalf("Nested schema %q not found in Definitions", defKey)
 			}
 
-			// Validate the nested schema
-			resolved, err := ValidateSchemaWithName(defKey, nestedSchema)
+			// Validate the nested schema as a ref-as-root bundle, since nested
+			// schemas may $ref other definitions (e.g., shared enums).
+			bundled := &jsonschema.Schema{Ref: "#/$defs/" + defKey, Defs: requestSchema.Defs}
+			resolved, err := ValidateSchemaWithName(defKey, bundled)
 			if err != nil {
 				t.Fatalf("Nested schema validation failed: %v", err)
 			}
@@ -1710,13 +1864,14 @@ func TestResponseNestedMessageSchemasAreValid(t *testing.T) {
 
 	for _, defKey := range nestedSchemas {
 		t.Run(defKey, func(t *testing.T) {
-			nestedSchema, ok := responseSchema.Defs[defKey]
-			if !ok {
+			if _, ok := responseSchema.Defs[defKey]; !ok {
 				t.Fatalf("Nested schema %q not found in Definitions", defKey)
 			}
 
-			// Validate the nested schema
-			resolved, err := ValidateSchemaWithName(defKey, nestedSchema)
+			// Validate the nested schema as a ref-as-root bundle, since nested
+			// schemas may $ref other definitions (e.g., shared enums).
+			bundled := &jsonschema.Schema{Ref: "#/$defs/" + defKey, Defs: responseSchema.Defs}
+			resolved, err := ValidateSchemaWithName(defKey, bundled)
 			if err != nil {
 				t.Fatalf("Nested schema validation failed: %v", err)
 			}
@@ -1730,6 +1885,37 @@ func TestResponseNestedMessageSchemasAreValid(t *testing.T) {
 	}
 }
 
+func TestMCPInputSchemaHasObjectRoot(t *testing.T) {
+	// MCP tool input schemas must be object-typed at the root, without a sibling $ref
+	schema := (&GetWeatherForecastRequest{}).MCPInputSchema()
+	if schema == nil {
+		t.Fatal("GetWeatherForecastRequest.MCPInputSchema() returned nil")
+	}
+	if schema.Type != "object" {
+		t.Errorf("Expected root type 'object', got %q", schema.Type)
+	}
+	if schema.Ref != "" {
+		t.Errorf("Expected no root $ref, got %q", schema.Ref)
+	}
+	if len(schema.Properties) == 0 {
+		t.Error("Expected root to carry the message properties")
+	}
+	if schema.Defs["weather.v1.GetWeatherForecastRequest"] == schema {
+		t.Error("Root must not be the same pointer as its own definition")
+	}
+
+	resolved, err := ValidateSchemaWithName("GetWeatherForecastRequest", schema)
+	if err != nil {
+		t.Fatalf("Schema validation failed: %v", err)
+	}
+	if resolved == nil {
+		t.Fatal("Resolved schema is nil")
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("Failed to marshal MCP input schema: %v", err)
+	}
+}
+
 func getDefKeys(defs map[string]*jsonschema.Schema) []string {
 	keys := make([]string, 0, len(defs))
 	for k := range defs {
@@ -1752,9 +1938,9 @@ require github.com/google/jsonschema-go v0.3.0
 	s.Require().NoError(err)
 
 	// Run go mod tidy
-	cmd = exec.Command("go", "mod", "tidy")
+	cmd := exec.Command("go", "mod", "tidy")
 	cmd.Dir = tmpDir
-	output, err = cmd.CombinedOutput()
+	output, err := cmd.CombinedOutput()
 	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
 
 	// Run the tests
@@ -1767,70 +1953,12 @@ require github.com/google/jsonschema-go v0.3.0
 	s.Require().NoError(err, "Weather forecast schema tests failed: %s\n\nThis indicates issues with schema generation or JSON marshalling.", string(output))
 }
 
-// Helper function to find workspace root (duplicated from suite_test.go)
-func (s *IntegrationTestSuite) findWorkspaceRoot() string {
-	cwd, err := os.Getwd()
-	s.Require().NoError(err, "Failed to get current working directory")
-
-	dir := cwd
-	for {
-		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
-			return dir
-		}
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			s.T().Fatalf("Could not find workspace root (go.mod) starting from %s", cwd)
-			return ""
-		}
-		dir = parent
-	}
-}
-
 // TestNoJsonSchemaOptionsProto tests that no jsonschema file is generated when
 // a proto file has no json_schema options at any level (file, message, or field).
 func (s *IntegrationTestSuite) TestNoJsonSchemaOptionsProto() {
 	// Generate descriptor set for no_options proto
-	workspaceRoot := s.findWorkspaceRoot()
-	protoPath := filepath.Join(workspaceRoot, "testdata", "protos")
 	noOptionsProto := "no_options/v1/no_options.proto"
-	outputPath := filepath.Join(workspaceRoot, "testdata", "descriptors", "no_options.pb")
-
-	// Create output directory if it doesn't exist
-	err := os.MkdirAll(filepath.Dir(outputPath), 0o755)
-	s.Require().NoError(err, "Failed to create descriptor output directory")
-
-	// Build protoc command arguments
-	args := []string{
-		"--descriptor_set_out=" + outputPath,
-		"--include_imports",
-		"--include_source_info",
-		"--proto_path=" + protoPath,
-	}
-
-	// Find alis proto path if available (for custom options - not needed for this proto but keep for consistency)
-	// Use home directory to make path portable across systems
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		alisPath := filepath.Join(homeDir, "alis.build", "alis", "define")
-		if _, err := os.Stat(alisPath); err == nil {
-			args = append(args, "--proto_path="+alisPath)
-		}
-	}
-
-	// Add the proto file
-	args = append(args, noOptionsProto)
-
-	// Run protoc
-	cmd := exec.Command("protoc", args...)
-	output, err := cmd.CombinedOutput()
-	s.Require().NoError(err, "Failed to run protoc for no_options proto: %s\nArgs: %v", string(output), args)
-
-	// Load the descriptor set
-	data, err := os.ReadFile(outputPath)
-	s.Require().NoError(err, "Failed to read no_options descriptor set")
-
-	var fds descriptorpb.FileDescriptorSet
-	err = proto.Unmarshal(data, &fds)
-	s.Require().NoError(err, "Failed to unmarshal no_options descriptor set")
+	fds := s.compileProtos("no_options.pb", noOptionsProto)
 
 	// Create plugin request
 	req := &pluginpb.CodeGeneratorRequest{
@@ -1872,9 +2000,7 @@ func (s *IntegrationTestSuite) TestNoJsonSchemaOptionsWithProtoc() {
 	}
 
 	// Check if protoc is available
-	if _, err := exec.LookPath("protoc"); err != nil {
-		s.T().Skip("protoc not found in PATH, skipping end-to-end test")
-	}
+	s.requireProtoc()
 
 	// Create a temporary directory for output
 	tmpDir := s.TempDir()
@@ -1930,3 +2056,3962 @@ func (s *IntegrationTestSuite) TestNoJsonSchemaOptionsWithProtoc() {
 
 	s.T().Log("End-to-end test passed: no jsonschema files generated for proto without options")
 }
+
+// TestCrossFileDependenciesInPartialRuns tests that a message forced as a dependency
+// from another file is emitted by its declaring file when that file is part of the run,
+// and that Generate fails with an actionable error when it is not.
+func (s *IntegrationTestSuite) TestCrossFileDependenciesInPartialRuns() {
+	rootProto := "partial/v1/root.proto"
+	sharedProto := "partial/v1/shared.proto"
+	fds := s.compileProtos("partial.pb", rootProto, sharedProto)
+
+	generate := func(files ...string) (*pluginpb.CodeGeneratorResponse, error) {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: files, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		err = plugin.Generate(p, "test")
+		return p.Response(), err
+	}
+
+	s.Run("declaring file in run emits the dependency", func() {
+		resp, err := generate(rootProto, sharedProto)
+		s.Require().NoError(err)
+		s.Require().Empty(resp.GetError())
+
+		contents := make(map[string]string)
+		for _, f := range resp.File {
+			contents[filepath.Base(f.GetName())] = f.GetContent()
+		}
+		s.Contains(contents["root_jsonschema.pb.go"], "Shared_JsonSchema_WithDefs(defs)")
+		s.NotContains(contents["root_jsonschema.pb.go"], "func Shared_JsonSchema_WithDefs(")
+		s.Contains(contents["shared_jsonschema.pb.go"], "func Shared_JsonSchema_WithDefs(defs map[string]*jsonschema.Schema) *jsonschema.Schema {")
+	})
+
+	s.Run("declaring file outside run is reported", func() {
+		resp, err := generate(rootProto)
+		s.Require().Error(err)
+		s.Contains(resp.GetError(), "partial.v1.Shared")
+		s.Contains(resp.GetError(), sharedProto)
+		s.Empty(resp.File, "No files should be generated when a dependency cannot resolve")
+	})
+}
+
+// TestExternalSchemas tests that external_schemas inlines messages declared in files
+// outside the run as standalone functions of the referencing package, that the code
+// compiles and resolves, and that it leaves messages of files in the run alone.
+func (s *IntegrationTestSuite) TestExternalSchemas() {
+	rootProto := "partial/v1/root.proto"
+	sharedProto := "partial/v1/shared.proto"
+	fds := s.compileProtos("partial.pb", rootProto, sharedProto)
+
+	generate := func(mode string, files ...string) (map[string]string, error) {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: files, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		err = plugin.GenerateWithOptions(p, "test", plugin.Options{ExternalSchemas: mode})
+		contents := make(map[string]string)
+		for _, f := range p.Response().File {
+			contents[filepath.Base(f.GetName())] = f.GetContent()
+		}
+		return contents, err
+	}
+
+	for _, mode := range []string{"auto", "inline"} {
+		s.Run(mode+" inlines the dependency", func() {
+			contents, err := generate(mode, rootProto)
+			s.Require().NoError(err)
+			root := contents["root_jsonschema.pb.go"]
+			s.Contains(root, "func root_partial_v1_Shared_JsonSchema_WithDefs(defs map[string]*jsonschema.Schema) *jsonschema.Schema {")
+			s.Contains(root, `schema.Properties["shared"] = root_partial_v1_Shared_JsonSchema_WithDefs(defs)`)
+			s.NotContains(root, "func (x *Shared)")
+		})
+
+		s.Run(mode+" keeps references within the run", func() {
+			contents, err := generate(mode, rootProto, sharedProto)
+			s.Require().NoError(err)
+			s.Contains(contents["root_jsonschema.pb.go"], `schema.Properties["shared"] = Shared_JsonSchema_WithDefs(defs)`)
+			s.NotContains(contents["root_jsonschema.pb.go"], "partial_v1_Shared")
+		})
+	}
+
+	s.Run("inlined schema compiles and resolves", func() {
+		contents, err := generate("auto", rootProto)
+		s.Require().NoError(err)
+
+		tmpDir := s.TempDir()
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "root_jsonschema.pb.go"), []byte(contents["root_jsonschema.pb.go"]), 0o644))
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package partialv1\n\ntype Root struct{}\n"), 0o644))
+		testContent := `package partialv1
+
+import "testing"
+
+func TestExternalSchemas(t *testing.T) {
+	schema, err := (&Root{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"name": "a", "shared": map[string]any{"value": "b"}}); err != nil {
+		t.Errorf("valid root rejected: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"shared": map[string]any{"value": 1}}); err == nil {
+		t.Error("invalid shared value accepted")
+	}
+}
+`
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "external_test.go"), []byte(testContent), 0o644))
+		goModContent := `module example.com/partial/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+		cmd := exec.Command("go", "mod", "tidy")
+		cmd.Dir = tmpDir
+		output, err := cmd.CombinedOutput()
+		s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+		cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+		cmd.Dir = tmpDir
+		output, err = cmd.CombinedOutput()
+		s.Require().NoError(err, "external schemas runtime tests failed: %s", string(output))
+	})
+
+	s.Run("reference reports the dependency", func() {
+		_, err := generate("reference", rootProto)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "external_schemas=auto")
+	})
+
+	s.Run("unknown mode is rejected", func() {
+		_, err := generate("stub", rootProto)
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid external_schemas parameter "stub"`)
+	})
+}
+
+// TestFileMarkers tests that every generated file declares its version and descriptor
+// hash constants, and that with external_schemas=auto a file referencing the schemas of a
+// file outside the run asserts that file's version marker.
+func (s *IntegrationTestSuite) TestFileMarkers() {
+	userProto := "users/v1/user.proto"
+	commonProto := "users/v1/common.proto"
+	fds := s.compileProtos("markers.pb", userProto, commonProto)
+
+	generate := func(opts plugin.Options, files ...string) map[string]string {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: files, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		s.Require().NoError(plugin.GenerateWithOptions(p, "v9.9.9", opts))
+		contents := make(map[string]string)
+		for _, f := range p.Response().File {
+			contents[filepath.Base(f.GetName())] = f.GetContent()
+		}
+		return contents
+	}
+
+	s.Run("version and descriptor hash", func() {
+		contents := generate(plugin.Options{}, userProto, commonProto)
+		user := contents["user_jsonschema.pb.go"]
+		s.Contains(user, `const JsonSchemaGenVersion_users_v1_user = "v9.9.9"`)
+		s.Regexp(`const JsonSchemaDescriptorHash_users_v1_user = "[0-9a-f]{64}"`, user)
+		s.Contains(contents["common_jsonschema.pb.go"], `const JsonSchemaGenVersion_users_v1_common = "v9.9.9"`)
+		s.NotContains(user, "const _ =", "no marker assertions without external_schemas=auto")
+	})
+
+	s.Run("hash follows the descriptor", func() {
+		first := generate(plugin.Options{}, userProto)
+		second := generate(plugin.Options{}, userProto, commonProto)
+		hash := regexp.MustCompile(`JsonSchemaDescriptorHash_users_v1_\w+ = "([0-9a-f]+)"`)
+		user := hash.FindStringSubmatch(first["user_jsonschema.pb.go"])
+		s.Require().Len(user, 2)
+		s.Equal(user, hash.FindStringSubmatch(second["user_jsonschema.pb.go"]))
+		s.NotEqual(user[1], hash.FindStringSubmatch(second["common_jsonschema.pb.go"])[1])
+	})
+
+	s.Run("auto asserts referenced files outside the run", func() {
+		user := generate(plugin.Options{ExternalSchemas: "auto"}, userProto)["user_jsonschema.pb.go"]
+		s.Equal(1, strings.Count(user, "const _ = JsonSchemaGenVersion_users_v1_common"))
+
+		user = generate(plugin.Options{ExternalSchemas: "auto"}, userProto, commonProto)["user_jsonschema.pb.go"]
+		s.NotContains(user, "const _ =", "files in the run need no assertion")
+	})
+}
+
+// TestMapValuesAcrossPackages tests that a message used only as a map value by another
+// package is emitted by its declaring file together with its nested messages, its own
+// dependencies and the Google type helpers they need, that the code compiles and resolves
+// across both packages, and that Generate fails when the declaring file is not in the run.
+func (s *IntegrationTestSuite) TestMapValuesAcrossPackages() {
+	inventoryProto := "mapvalues/inventory/v1/inventory.proto"
+	storeProto := "mapvalues/store/v1/store.proto"
+	fds := s.compileProtos("mapvalues.pb", inventoryProto, storeProto)
+
+	generate := func(files ...string) (*pluginpb.CodeGeneratorResponse, error) {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: files, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		err = plugin.Generate(p, "test")
+		return p.Response(), err
+	}
+
+	s.Run("declaring package emits map values and their dependencies", func() {
+		resp, err := generate(inventoryProto, storeProto)
+		s.Require().NoError(err)
+		s.Require().Empty(resp.GetError())
+
+		contents := make(map[string]string)
+		for _, f := range resp.File {
+			contents[f.GetName()] = f.GetContent()
+		}
+		store := contents["example.com/mapvalues/store/v1/store_jsonschema.pb.go"]
+		s.Require().NotEmpty(store)
+		s.Regexp(`AdditionalProperties:\s+v1\.Item_JsonSchema_WithDefs\(defs\),`, store)
+		s.Regexp(`AdditionalProperties:\s+v1\.Item_Variant_JsonSchema_WithDefs\(defs\),`, store)
+
+		inventory := contents["example.com/mapvalues/inventory/v1/inventory_jsonschema.pb.go"]
+		s.Require().NotEmpty(inventory)
+		for _, fn := range []string{"Item", "Item_Variant", "Item_Variant_Price", "Location", "Availability", "inventory_google_protobuf_Timestamp"} {
+			s.Contains(inventory, "func "+fn+"_JsonSchema_WithDefs(defs map[string]*jsonschema.Schema) *jsonschema.Schema {")
+		}
+
+		tmpDir := s.TempDir()
+		stubs := map[string]string{
+			"inventory/v1": "package inventoryv1\n\ntype Item struct{}\n\ntype Item_Variant struct{}\n\ntype Item_Variant_Price struct{}\n\ntype Location struct{}\n",
+			"store/v1":     "package storev1\n\ntype Store struct{}\n",
+		}
+		for dir, stub := range stubs {
+			s.Require().NoError(os.MkdirAll(filepath.Join(tmpDir, dir), 0o755))
+			s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, dir, "stub_types.go"), []byte(stub), 0o644))
+		}
+		for name, content := range contents {
+			path := filepath.Join(tmpDir, strings.TrimPrefix(name, "example.com/mapvalues/"))
+			s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+		}
+
+		testContent := `package storev1
+
+import "testing"
+
+func TestStoreSchemaResolves(t *testing.T) {
+	schema := (&Store{}).JsonSchema()
+	for _, key := range []string{
+		"mapvalues.store.v1.Store",
+		"mapvalues.inventory.v1.Item",
+		"mapvalues.inventory.v1.Item.Variant",
+		"mapvalues.inventory.v1.Item.Variant.Price",
+		"mapvalues.inventory.v1.Location",
+		"mapvalues.inventory.v1.Availability",
+		"google.protobuf.Timestamp",
+	} {
+		if schema.Defs[key] == nil {
+			t.Errorf("expected %s in $defs", key)
+		}
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	variant := map[string]any{"name": "red", "price": map[string]any{"cents": 250}, "restocked_at": map[string]any{"seconds": 1, "nanos": 0}, "availability": 1}
+	item := map[string]any{"sku": "a1", "default_variant": variant, "variants": map[string]any{"red": variant}, "location": map[string]any{"aisle": 4}}
+	if err := resolved.Validate(map[string]any{"name": "main", "items": map[string]any{"a1": item}, "featured": map[string]any{"1": variant}}); err != nil {
+		t.Errorf("Validate(valid store) failed: %v", err)
+	}
+	item["location"] = map[string]any{"aisle": "four"}
+	if err := resolved.Validate(map[string]any{"name": "main", "items": map[string]any{"a1": item}, "featured": map[string]any{}}); err == nil {
+		t.Error("expected an error for an invalid nested map value")
+	}
+}
+`
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "store/v1", "store_test.go"), []byte(testContent), 0o644))
+
+		goModContent := `module example.com/mapvalues
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+		cmd := exec.Command("go", "mod", "tidy")
+		cmd.Dir = tmpDir
+		output, err := cmd.CombinedOutput()
+		s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+		cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s", "./...")
+		cmd.Dir = tmpDir
+		output, err = cmd.CombinedOutput()
+		s.Require().NoError(err, "map value runtime tests failed: %s", string(output))
+	})
+
+	s.Run("declaring file outside run is reported", func() {
+		resp, err := generate(storeProto)
+		s.Require().Error(err)
+		s.Contains(resp.GetError(), "mapvalues.inventory.v1.Item")
+		s.Contains(resp.GetError(), inventoryProto)
+	})
+}
+
+// TestImportAliasesAcrossPackages tests schemas spanning three interdependent packages:
+// app/v1 references common/v1 (base name "v1", like app/v1 itself) and a package whose
+// import path ends in "jsonschema", the name of the JSON Schema library import. The
+// clashing package must get a distinct alias and the generated code must compile and
+// resolve. A package whose alias would be shadowed by a generated local is reported.
+func (s *IntegrationTestSuite) TestImportAliasesAcrossPackages() {
+	tokenProto := "aliases/jsonschema/token.proto"
+	commonProto := "aliases/common/v1/common.proto"
+	appProto := "aliases/app/v1/app.proto"
+	recordProto := "aliases/schema/record.proto"
+	auditProto := "aliases/app/v1/audit.proto"
+	fds := s.compileProtos("aliases.pb", tokenProto, commonProto, appProto, recordProto, auditProto)
+
+	generate := func(files ...string) (*pluginpb.CodeGeneratorResponse, error) {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: files, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		err = plugin.Generate(p, "test")
+		return p.Response(), err
+	}
+
+	s.Run("clashing package names get distinct aliases", func() {
+		resp, err := generate(tokenProto, commonProto, appProto)
+		s.Require().NoError(err)
+		s.Require().Empty(resp.GetError())
+
+		contents := make(map[string]string)
+		for _, f := range resp.File {
+			contents[f.GetName()] = f.GetContent()
+		}
+		app := contents["example.com/aliases/app/v1/app_jsonschema.pb.go"]
+		s.Require().NotEmpty(app)
+		s.Contains(app, `jsonschema "github.com/google/jsonschema-go/jsonschema"`)
+		s.Contains(app, `jsonschema1 "example.com/aliases/jsonschema"`)
+		s.Contains(app, `v1 "example.com/aliases/common/v1"`)
+		s.Contains(app, "jsonschema1.Token_JsonSchema_WithDefs(defs)")
+		s.Contains(app, "v1.Meta_JsonSchema_WithDefs(defs)")
+		s.Contains(app, "v1.Level_JsonSchema_WithDefs(defs).Ref")
+		s.Contains(contents["example.com/aliases/common/v1/common_jsonschema.pb.go"], "jsonschema1.Token_JsonSchema_WithDefs(defs)")
+
+		tmpDir := s.TempDir()
+		stubs := map[string]string{
+			"jsonschema": "package jsonschema\n\ntype Token struct{}\n",
+			"common/v1":  "package commonv1\n\ntype Meta struct{}\n",
+			"app/v1":     "package appv1\n\ntype Request struct{}\n",
+		}
+		for dir, stub := range stubs {
+			s.Require().NoError(os.MkdirAll(filepath.Join(tmpDir, dir), 0o755))
+			s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, dir, "stub_types.go"), []byte(stub), 0o644))
+		}
+		for name, content := range contents {
+			path := filepath.Join(tmpDir, strings.TrimPrefix(name, "example.com/aliases/"))
+			s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+		}
+
+		testContent := `package appv1
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestRequestSchemaResolves(t *testing.T) {
+	schema := (&Request{}).JsonSchema()
+	for _, key := range []string{"aliases.app.v1.Request", "aliases.common.v1.Meta", "aliases.common.v1.Level", "aliases.jsonschema.Token"} {
+		if schema.Defs[key] == nil {
+			t.Errorf("expected %s in $defs", key)
+		}
+	}
+	if _, err := schema.Resolve(&jsonschema.ResolveOptions{ValidateDefaults: true}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+}
+`
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "app/v1", "aliases_test.go"), []byte(testContent), 0o644))
+
+		goModContent := `module example.com/aliases
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+		cmd := exec.Command("go", "mod", "tidy")
+		cmd.Dir = tmpDir
+		output, err := cmd.CombinedOutput()
+		s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+		cmd = exec.Command("go", "test", "-timeout", "30s", "./...")
+		cmd.Dir = tmpDir
+		output, err = cmd.CombinedOutput()
+		s.Require().NoError(err, "Import alias runtime tests failed: %s", string(output))
+	})
+
+	s.Run("alias shadowed by generated local is reported", func() {
+		resp, err := generate(recordProto, auditProto)
+		s.Require().Error(err)
+		s.Contains(resp.GetError(), auditProto)
+		s.Contains(resp.GetError(), `"schema"`)
+		s.Contains(resp.GetError(), "example.com/aliases/schema")
+	})
+}
+
+// TestLargeMessagePropertyChunks tests that a message with 200+ fields populates its
+// properties in chunked helper functions, and that the chunked code compiles and
+// produces every property.
+func (s *IntegrationTestSuite) TestLargeMessagePropertyChunks() {
+	largeProto := "large/v1/large.proto"
+	fds := s.compileProtos("large.pb", largeProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{largeProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.Generate(p, "test"))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	// 220 fields -> chunks of 50, 50, 50, 50, 20
+	for i := 1; i <= 5; i++ {
+		s.Contains(content, fmt.Sprintf("\tWide_jsonSchemaProperties%d(defs, schema)\n", i))
+		s.Contains(content, fmt.Sprintf("func Wide_jsonSchemaProperties%d(defs map[string]*jsonschema.Schema, schema *jsonschema.Schema) {", i))
+	}
+	s.NotContains(content, "Wide_jsonSchemaProperties6")
+	s.Contains(content, "// Wide_jsonSchemaProperties5 populates properties field_201 to field_220 of the Wide schema.")
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "large_jsonschema.pb.go"), []byte(content), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package largev1\n\ntype Wide struct{}\n"), 0o644))
+
+	testContent := `package largev1
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestWideSchema(t *testing.T) {
+	schema := (&Wide{}).JsonSchema()
+	def := schema.Defs["large.v1.Wide"]
+	if len(def.Properties) != 220 {
+		t.Fatalf("expected 220 properties, got %d", len(def.Properties))
+	}
+	if len(def.Required) != 220 {
+		t.Fatalf("expected 220 required fields, got %d", len(def.Required))
+	}
+	if _, err := schema.Resolve(&jsonschema.ResolveOptions{ValidateDefaults: true}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "large_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/large/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-timeout", "30s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "Large message runtime tests failed: %s", string(output))
+}
+
+// TestConcurrentAccessorsRace verifies the documented concurrency guarantees of generated
+// accessors by calling them from many goroutines under the race detector, modifying the
+// returned schemas to check that calls never share state, and checking that the
+// cached_accessors methods return one shared schema, equal to the one JsonSchema() builds.
+func (s *IntegrationTestSuite) TestConcurrentAccessorsRace() {
+	if testing.Short() {
+		s.T().Skip("Skipping race test in short mode")
+	}
+	if out, err := exec.Command("go", "env", "CGO_ENABLED").Output(); err != nil || strings.TrimSpace(string(out)) != "1" {
+		s.T().Skip("Skipping race test: the race detector requires cgo")
+	}
+
+	contents := s.RunGenerateWithOptions(plugin.Options{CachedAccessors: true})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestConcurrentAccessors(t *testing.T) {
+	accessors := map[string]func() *jsonschema.Schema{
+		"User.JsonSchema":                 func() *jsonschema.Schema { return (&User{}).JsonSchema() },
+		"ComprehensiveUser.JsonSchema":    func() *jsonschema.Schema { return (&ComprehensiveUser{}).JsonSchema() },
+		"AddressDetails.MCPInputSchema":   func() *jsonschema.Schema { return (&AddressDetails{}).MCPInputSchema() },
+		"WellKnownTypesDemo.JsonSchema":   func() *jsonschema.Schema { return (&WellKnownTypesDemo{}).JsonSchema() },
+		"common_google_protobuf_Timestamp": common_google_protobuf_Timestamp_JsonSchema,
+	}
+
+	var wg sync.WaitGroup
+	for name, accessor := range accessors {
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(name string, accessor func() *jsonschema.Schema) {
+				defer wg.Done()
+				schema := accessor()
+				// Callers may modify the returned schema without affecting other calls.
+				schema.Title = name
+				for _, def := range schema.Defs {
+					def.Description = name
+				}
+				if _, err := json.Marshal(schema); err != nil {
+					t.Errorf("%s: Marshal failed: %v", name, err)
+				}
+				if _, err := schema.Resolve(nil); err != nil {
+					t.Errorf("%s: Resolve failed: %v", name, err)
+				}
+				values := UserStatus_JsonSchemaEnum()
+				values[0].Name = name
+			}(name, accessor)
+		}
+	}
+	wg.Wait()
+
+	if got := UserStatus_JsonSchemaEnum()[0].Name; got != "USER_STATUS_UNSPECIFIED" {
+		t.Fatalf("enum values helper returned shared state: got %q", got)
+	}
+	if got := (&User{}).JsonSchema().Title; got == "User.JsonSchema" {
+		t.Fatalf("JsonSchema returned shared state: got title %q", got)
+	}
+}
+
+func TestConcurrentCachedAccessors(t *testing.T) {
+	schemas := make([]*jsonschema.Schema, 16)
+	resolved := make([]*jsonschema.Resolved, 16)
+	var wg sync.WaitGroup
+	for i := range schemas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			schemas[i] = (&ComprehensiveUser{}).JsonSchemaCached()
+			r, err := (&ComprehensiveUser{}).JsonSchemaResolved()
+			if err != nil {
+				t.Errorf("JsonSchemaResolved failed: %v", err)
+				return
+			}
+			if err := r.Validate(map[string]any{}); err == nil {
+				t.Errorf("Validate accepted an object without the required properties")
+			}
+			resolved[i] = r
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range schemas {
+		if schemas[i] != schemas[0] || resolved[i] != resolved[0] {
+			t.Fatalf("cached accessors built more than one value")
+		}
+	}
+	if schemas[0] == (&ComprehensiveUser{}).JsonSchema() {
+		t.Fatalf("JsonSchema returned the cached schema")
+	}
+
+	// The cached schemas share definitions built one at a time, across files and with the
+	// Google type helpers, yet marshal like the schemas JsonSchema() builds.
+	for name, pair := range map[string][2]func() *jsonschema.Schema{
+		"ComprehensiveUser": {(&ComprehensiveUser{}).JsonSchema, (&ComprehensiveUser{}).JsonSchemaCached},
+		"UserProfile":       {(&UserProfile{}).JsonSchema, (&UserProfile{}).JsonSchemaCached},
+		"Common":            {(&Common{}).JsonSchema, (&Common{}).JsonSchemaCached},
+	} {
+		want, _ := json.Marshal(pair[0]())
+		got, _ := json.Marshal(pair[1]())
+		if string(got) != string(want) {
+			t.Errorf("%s: cached schema differs:\ngot  %s\nwant %s", name, got, want)
+		}
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "concurrency_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testconcurrency/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-race", "-count=1", "-timeout", "120s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "Concurrent accessor tests failed: %s", string(output))
+}
+
+// TestGeneratedCodeAcrossLibraryVersions tests that the generated code compiles and
+// resolves against every supported minor version of github.com/google/jsonschema-go.
+// Generated code must only use Schema fields that exist, with the same types, in all
+// of them (see the Dependencies section of the README).
+func (s *IntegrationTestSuite) TestGeneratedCodeAcrossLibraryVersions() {
+	contents := s.RunGenerate()
+
+	for _, version := range []string{"v0.3.0", "v0.4.2"} {
+		s.Run(version, func() {
+			tmpDir := s.TempDir()
+			for name, content := range contents {
+				err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+				s.Require().NoError(err)
+			}
+
+			stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+			err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+			s.Require().NoError(err)
+
+			testContent := `package usersv1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestAccessorsResolve(t *testing.T) {
+	schemas := map[string]*jsonschema.Schema{
+		"ComprehensiveUser":  (&ComprehensiveUser{}).JsonSchema(),
+		"RepeatedFieldsDemo": (&RepeatedFieldsDemo{}).JsonSchema(),
+		"MapFieldsDemo":      (&MapFieldsDemo{}).JsonSchema(),
+		"ConstraintDemo":     (&ConstraintDemo{}).JsonSchema(),
+		"OneOfDemo":          (&OneOfDemo{}).JsonSchema(),
+		"WellKnownTypesDemo": (&WellKnownTypesDemo{}).JsonSchema(),
+		"AddressDetails":     (&AddressDetails{}).MCPInputSchema(),
+	}
+	for name, schema := range schemas {
+		if _, err := json.Marshal(schema); err != nil {
+			t.Errorf("%s: Marshal failed: %v", name, err)
+		}
+		if _, err := schema.Resolve(nil); err != nil {
+			t.Errorf("%s: Resolve failed: %v", name, err)
+		}
+	}
+}
+`
+			err = os.WriteFile(filepath.Join(tmpDir, "versions_test.go"), []byte(testContent), 0o644)
+			s.Require().NoError(err)
+
+			goModContent := fmt.Sprintf(`module testversions/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go %s
+`, version)
+			err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+			s.Require().NoError(err)
+
+			cmd := exec.Command("go", "mod", "tidy")
+			cmd.Dir = tmpDir
+			output, err := cmd.CombinedOutput()
+			s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+			cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+			cmd.Dir = tmpDir
+			output, err = cmd.CombinedOutput()
+			s.Require().NoError(err, "Generated code failed against jsonschema-go %s: %s", version, string(output))
+		})
+	}
+}
+
+// TestSanthoshSchemaLibRuntime tests that the schema_lib=santhosh accessors compile with
+// github.com/santhosh-tekuri/jsonschema/v6 and validate instances like jsonschema-go does.
+func (s *IntegrationTestSuite) TestSanthoshSchemaLibRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{SchemaLib: "santhosh"})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import "testing"
+
+func TestSanthoshAccessors(t *testing.T) {
+	compiled, err := (&Price{}).JsonSchemaSanthosh()
+	if err != nil {
+		t.Fatalf("Price.JsonSchemaSanthosh failed: %v", err)
+	}
+	resolved, err := (&Price{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Price.JsonSchema Resolve failed: %v", err)
+	}
+	for _, tc := range []struct {
+		instance map[string]any
+		valid    bool
+	}{
+		{map[string]any{"currency_code": "USD", "units": 10.0, "nanos": 0.0}, true},
+		{map[string]any{"currency_code": "USD", "units": "ten", "nanos": 0.0}, false},
+		{map[string]any{"currency_code": "USD"}, false},
+	} {
+		if err := compiled.Validate(tc.instance); (err == nil) != tc.valid {
+			t.Errorf("santhosh Validate(%v) = %v, want valid=%v", tc.instance, err, tc.valid)
+		}
+		if err := resolved.Validate(tc.instance); (err == nil) != tc.valid {
+			t.Errorf("jsonschema-go Validate(%v) = %v, want valid=%v", tc.instance, err, tc.valid)
+		}
+	}
+
+	for name, accessor := range map[string]func() error{
+		"ComprehensiveUser":  func() error { _, err := (&ComprehensiveUser{}).JsonSchemaSanthosh(); return err },
+		"ConstraintDemo":     func() error { _, err := (&ConstraintDemo{}).JsonSchemaSanthosh(); return err },
+		"WellKnownTypesDemo": func() error { _, err := (&WellKnownTypesDemo{}).JsonSchemaSanthosh(); return err },
+		"Price":              func() error { _, err := (&Price{}).JsonSchemaSanthosh(); return err },
+	} {
+		if err := accessor(); err != nil {
+			t.Errorf("%s.JsonSchemaSanthosh failed: %v", name, err)
+		}
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "santhosh_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testsanthosh/usersv1
+
+go 1.21
+
+require (
+	github.com/google/jsonschema-go v0.3.0
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.3
+)
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "santhosh-tekuri runtime tests failed: %s", string(output))
+}
+
+// TestInvopopSchemaLibRuntime tests that the schema_lib=invopop accessors produce
+// github.com/invopop/jsonschema structs that render the same JSON as the jsonschema-go
+// schemas they are converted from.
+func (s *IntegrationTestSuite) TestInvopopSchemaLibRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{SchemaLib: "invopop"})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	invopop "github.com/invopop/jsonschema"
+)
+
+func TestInvopopAccessors(t *testing.T) {
+	accessors := map[string]struct {
+		google  func() *jsonschema.Schema
+		invopop func() (*invopop.Schema, error)
+	}{
+		"ComprehensiveUser":  {(&ComprehensiveUser{}).JsonSchema, (&ComprehensiveUser{}).JsonSchemaInvopop},
+		"RepeatedFieldsDemo": {(&RepeatedFieldsDemo{}).JsonSchema, (&RepeatedFieldsDemo{}).JsonSchemaInvopop},
+		"MapFieldsDemo":      {(&MapFieldsDemo{}).JsonSchema, (&MapFieldsDemo{}).JsonSchemaInvopop},
+		"ConstraintDemo":     {(&ConstraintDemo{}).JsonSchema, (&ConstraintDemo{}).JsonSchemaInvopop},
+		"OneOfDemo":          {(&OneOfDemo{}).JsonSchema, (&OneOfDemo{}).JsonSchemaInvopop},
+		"WellKnownTypesDemo": {(&WellKnownTypesDemo{}).JsonSchema, (&WellKnownTypesDemo{}).JsonSchemaInvopop},
+	}
+	for name, a := range accessors {
+		converted, err := a.invopop()
+		if err != nil {
+			t.Errorf("%s.JsonSchemaInvopop failed: %v", name, err)
+			continue
+		}
+		if converted.Definitions[converted.Ref[len("#/$defs/"):]] == nil {
+			t.Errorf("%s: $ref %q does not resolve in Definitions", name, converted.Ref)
+		}
+		if got, want := decode(t, converted), decode(t, a.google()); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: invopop schema renders differently:\n got: %v\nwant: %v", name, got, want)
+		}
+	}
+}
+
+func decode(t *testing.T, schema any) any {
+	t.Helper()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return v
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "invopop_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testinvopop/usersv1
+
+go 1.24
+
+require (
+	github.com/google/jsonschema-go v0.3.0
+	github.com/invopop/jsonschema v0.14.0
+)
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "invopop runtime tests failed: %s", string(output))
+}
+
+// TestFieldNamesParameter tests that field_names selects the property keys used for
+// properties, required lists and oneof constraints, and that unknown values are rejected.
+func (s *IntegrationTestSuite) TestFieldNamesParameter() {
+	namingProto := "naming/v1/naming.proto"
+	fds := s.compileProtos("naming.pb", namingProto)
+
+	generate := func(fieldNames string) (string, error) {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{namingProto}, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		if err := plugin.GenerateWithOptions(p, "test", plugin.Options{FieldNames: fieldNames}); err != nil {
+			return "", err
+		}
+		resp := p.Response()
+		s.Require().Empty(resp.GetError())
+		s.Require().Len(resp.File, 1)
+		return resp.File[0].GetContent(), nil
+	}
+
+	for _, tc := range []struct {
+		fieldNames string
+		keys       []string
+	}{
+		{"", []string{"display_name", "user_id", "address_line_2", "email_address", "phone_number"}},
+		{"snake", []string{"display_name", "user_id", "address_line_2", "email_address", "phone_number"}},
+		{"camel", []string{"displayName", "userId", "addressLine2", "emailAddress", "phoneNumber"}},
+		{"json_name", []string{"displayName", "uid", "addressLine2", "emailAddress", "phoneNumber"}},
+	} {
+		s.Run("field_names="+tc.fieldNames, func() {
+			content, err := generate(tc.fieldNames)
+			s.Require().NoError(err)
+			for _, key := range tc.keys {
+				s.Contains(content, fmt.Sprintf(`schema.Properties[%q] = `, key))
+			}
+			s.Contains(content, fmt.Sprintf("Required: []string{\n\t\t\t%q,\n\t\t\t%q,\n\t\t\t%q,\n\t\t},", tc.keys[0], tc.keys[1], tc.keys[2]))
+			s.Contains(content, fmt.Sprintf("Required: []string{%q}", tc.keys[3]))
+			s.Contains(content, fmt.Sprintf("Required: []string{%q}", tc.keys[4]))
+		})
+	}
+
+	s.Run("unknown value is rejected", func() {
+		_, err := generate("kebab")
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid field_names parameter "kebab"`)
+	})
+}
+
+// TestOpenAPI3SchemaLibRuntime tests that the schema_lib=openapi3 accessors build
+// kin-openapi component schemas that render the same JSON as the jsonschema-go $defs
+// (with references into #/components/schemas/), with and without object_root.
+func (s *IntegrationTestSuite) TestOpenAPI3SchemaLibRuntime() {
+	for _, objectRoot := range []bool{false, true} {
+		s.Run(fmt.Sprintf("object_root=%t", objectRoot), func() {
+			s.SetupTest()
+			contents := s.RunGenerateWithOptions(plugin.Options{SchemaLib: "openapi3", ObjectRoot: objectRoot})
+
+			tmpDir := s.TempDir()
+			for name, content := range contents {
+				err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+				s.Require().NoError(err)
+			}
+
+			stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+			err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+			s.Require().NoError(err)
+
+			testContent := `package usersv1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestOpenAPI3Accessors(t *testing.T) {
+	accessors := map[string]struct {
+		google  func() *jsonschema.Schema
+		openapi func() (*openapi3.SchemaRef, openapi3.Schemas)
+	}{
+		"users.v1.ComprehensiveUser":  {(&ComprehensiveUser{}).JsonSchema, (&ComprehensiveUser{}).JsonSchemaOpenAPI3},
+		"users.v1.RepeatedFieldsDemo": {(&RepeatedFieldsDemo{}).JsonSchema, (&RepeatedFieldsDemo{}).JsonSchemaOpenAPI3},
+		"users.v1.MapFieldsDemo":      {(&MapFieldsDemo{}).JsonSchema, (&MapFieldsDemo{}).JsonSchemaOpenAPI3},
+		"users.v1.ConstraintDemo":     {(&ConstraintDemo{}).JsonSchema, (&ConstraintDemo{}).JsonSchemaOpenAPI3},
+		"users.v1.OneOfDemo":          {(&OneOfDemo{}).JsonSchema, (&OneOfDemo{}).JsonSchemaOpenAPI3},
+		"users.v1.WellKnownTypesDemo": {(&WellKnownTypesDemo{}).JsonSchema, (&WellKnownTypesDemo{}).JsonSchemaOpenAPI3},
+	}
+	for name, a := range accessors {
+		ref, components := a.openapi()
+		if want := "#/components/schemas/" + name; ref.Ref != want || ref.Value != components[name].Value {
+			t.Errorf("%s: root reference = %q, want %q to the component schema", name, ref.Ref, want)
+		}
+
+		schema := a.google()
+		defs := schema.Defs
+		if defs[name].Ref == "#" {
+			root := *schema
+			root.Defs = nil
+			defs[name] = &root
+		}
+		if len(components) != len(defs) {
+			t.Errorf("%s: got %d component schemas, want %d", name, len(components), len(defs))
+		}
+		for key, def := range defs {
+			got := decode(t, components[key])
+			want := decode(t, def)
+			want = rewriteRefs(want, name)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: component %s renders differently:\n got: %v\nwant: %v", name, key, got, want)
+			}
+		}
+
+		doc := &openapi3.T{
+			OpenAPI:    "3.1.0",
+			Info:       &openapi3.Info{Title: name, Version: "v1"},
+			Paths:      openapi3.NewPaths(),
+			Components: &openapi3.Components{Schemas: components},
+		}
+		if err := doc.Validate(context.Background()); err != nil {
+			t.Errorf("%s: OpenAPI document is invalid: %v", name, err)
+		}
+	}
+}
+
+// rewriteRefs points jsonschema-go references at the OpenAPI component schemas and moves
+// references with sibling keywords into allOf.
+func rewriteRefs(v any, name string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"]; ok && len(v) > 1 {
+			delete(v, "$ref")
+			allOf, _ := v["allOf"].([]any)
+			v["allOf"] = append([]any{map[string]any{"$ref": ref}}, allOf...)
+		}
+		for key, value := range v {
+			if ref, ok := value.(string); key == "$ref" && ok {
+				if ref == "#" {
+					ref = "#/$defs/" + name
+				}
+				v[key] = strings.Replace(ref, "#/$defs/", "#/components/schemas/", 1)
+				continue
+			}
+			v[key] = rewriteRefs(value, name)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = rewriteRefs(value, name)
+		}
+	}
+	return v
+}
+
+func decode(t *testing.T, schema any) any {
+	t.Helper()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return v
+}
+`
+			err = os.WriteFile(filepath.Join(tmpDir, "openapi3_test.go"), []byte(testContent), 0o644)
+			s.Require().NoError(err)
+
+			goModContent := `module testopenapi3/usersv1
+
+go 1.25
+
+require (
+	github.com/getkin/kin-openapi v0.149.0
+	github.com/google/jsonschema-go v0.3.0
+)
+`
+			err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+			s.Require().NoError(err)
+
+			cmd := exec.Command("go", "mod", "tidy")
+			cmd.Dir = tmpDir
+			output, err := cmd.CombinedOutput()
+			s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+			cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+			cmd.Dir = tmpDir
+			output, err = cmd.CombinedOutput()
+			s.Require().NoError(err, "kin-openapi runtime tests failed: %s", string(output))
+		})
+	}
+}
+
+// TestEnumNamesParameter tests that enum_names represents enum fields, repeated enums
+// and enum map values as strings restricted to the value names, and that the schemas
+// accept protojson-style enum names at runtime.
+func (s *IntegrationTestSuite) TestEnumNamesParameter() {
+	contents := s.RunGenerateWithOptions(plugin.Options{EnumNames: true})
+	content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+	s.Require().NotEmpty(content)
+	s.Contains(content, "defs[\"users.v1.UserStatus\"] = &jsonschema.Schema{\n\t\tType:        \"string\",")
+	s.Contains(content, "\t\t\t\"USER_STATUS_UNSPECIFIED\",\n\t\t\t\"USER_STATUS_ACTIVE\",")
+	s.NotContains(content, "Type:        \"integer\",\n\t\tTitle:       \"\",\n\t\tDescription: \"Current status of the user account.\",")
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import "testing"
+
+func TestEnumNames(t *testing.T) {
+	status, err := (&User{}).JsonSchema().Defs["users.v1.UserStatus"].Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	for _, tc := range []struct {
+		instance any
+		valid    bool
+	}{
+		{"USER_STATUS_ACTIVE", true},
+		{"USER_STATUS_UNKNOWN", false},
+		{1.0, false},
+	} {
+		if err := status.Validate(tc.instance); (err == nil) != tc.valid {
+			t.Errorf("Validate(%v) = %v, want valid=%v", tc.instance, err, tc.valid)
+		}
+	}
+
+	repeated, err := (&RepeatedFieldsDemo{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := repeated.Validate(map[string]any{"enum_list": []any{"USER_STATUS_ACTIVE", 2.0}}); err == nil {
+		t.Error("expected an error for a numeric value in enum_list")
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "enum_names_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testenumnames/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "enum_names runtime tests failed: %s", string(output))
+}
+
+// TestFieldOptionValidation tests that field options are rejected with a field-precise
+// error when they do not apply to the field's cardinality or value type, and accepted
+// where they do.
+func (s *IntegrationTestSuite) TestFieldOptionValidation() {
+	constraintsProto := "constraints/v1/constraints.proto"
+	fds := s.compileProtos("constraints.pb", constraintsProto)
+
+	// generate attaches jsonSchema to the named Target field and runs the plugin.
+	generate := func(fieldName string, jsonSchema *optionsPb.FieldOptions_JsonSchema, opts plugin.Options) error {
+		files := make([]*descriptorpb.FileDescriptorProto, len(fds.File))
+		for i, f := range fds.File {
+			files[i] = proto.Clone(f).(*descriptorpb.FileDescriptorProto)
+			if f.GetName() != constraintsProto {
+				continue
+			}
+			for _, field := range files[i].MessageType[0].Field {
+				if field.GetName() == fieldName {
+					field.Options = &descriptorpb.FieldOptions{}
+					proto.SetExtension(field.Options, optionsPb.E_Field, &optionsPb.FieldOptions{JsonSchema: jsonSchema})
+				}
+			}
+		}
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{constraintsProto}, ProtoFile: files})
+		s.Require().NoError(err)
+		return plugin.GenerateWithOptions(p, "test", opts)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		field      string
+		jsonSchema *optionsPb.FieldOptions_JsonSchema
+		opts       plugin.Options
+		wantErr    string
+	}{
+		{"max_items on repeated", "tags", &optionsPb.FieldOptions_JsonSchema{MaxItems: proto.Int64(2), UniqueItems: proto.Bool(true)}, plugin.Options{}, ""},
+		{"pattern on repeated string", "tags", &optionsPb.FieldOptions_JsonSchema{Pattern: proto.String("^[a-z]+$")}, plugin.Options{}, ""},
+		{"max_properties on map", "scores", &optionsPb.FieldOptions_JsonSchema{MaxProperties: proto.Int64(3)}, plugin.Options{}, ""},
+		{"minimum on map of integers", "scores", &optionsPb.FieldOptions_JsonSchema{Minimum: proto.Float64(1)}, plugin.Options{}, ""},
+		{"pattern on bytes", "data", &optionsPb.FieldOptions_JsonSchema{Pattern: proto.String("^[A-Za-z0-9+/]*={0,2}$")}, plugin.Options{}, ""},
+		{"format on integer", "count", &optionsPb.FieldOptions_JsonSchema{Format: proto.String("int32")}, plugin.Options{}, ""},
+		{"exclusive bound on number", "ratio", &optionsPb.FieldOptions_JsonSchema{Maximum: proto.Float64(1), ExclusiveMaximum: proto.Bool(true)}, plugin.Options{}, ""},
+		{"maximum on duration seconds", "timeout", &optionsPb.FieldOptions_JsonSchema{Maximum: proto.Float64(60)}, plugin.Options{DurationSeconds: []string{"true"}}, ""},
+		{"pattern on enum names", "status", &optionsPb.FieldOptions_JsonSchema{Pattern: proto.String("^STATUS_")}, plugin.Options{EnumNames: true}, ""},
+
+		{"max_items on singular", "count", &optionsPb.FieldOptions_JsonSchema{MaxItems: proto.Int64(2)},
+			plugin.Options{}, "field constraints.v1.Target.count: json_schema option max_items applies only to repeated fields, not singular field"},
+		{"unique_items on map", "scores", &optionsPb.FieldOptions_JsonSchema{UniqueItems: proto.Bool(true)},
+			plugin.Options{}, "option unique_items applies only to repeated fields, not map field"},
+		{"min_properties on repeated", "tags", &optionsPb.FieldOptions_JsonSchema{MinProperties: proto.Int64(1)},
+			plugin.Options{}, "option min_properties applies only to map fields, not repeated field"},
+		{"max_properties on message", "parent", &optionsPb.FieldOptions_JsonSchema{MaxProperties: proto.Int64(1)},
+			plugin.Options{}, "option max_properties applies only to map fields, not singular field"},
+		{"pattern on integer", "count", &optionsPb.FieldOptions_JsonSchema{Pattern: proto.String("^[0-9]+$")},
+			plugin.Options{}, "field constraints.v1.Target.count: json_schema option pattern applies only to string values, not integer values"},
+		{"max_length on enum", "status", &optionsPb.FieldOptions_JsonSchema{MaxLength: proto.Int64(10)},
+			plugin.Options{}, "option max_length applies only to string values, not integer values"},
+		{"minimum on string", "name", &optionsPb.FieldOptions_JsonSchema{Minimum: proto.Float64(1)},
+			plugin.Options{}, "option minimum applies only to integer and number values, not string values"},
+		{"minimum on repeated string", "tags", &optionsPb.FieldOptions_JsonSchema{Minimum: proto.Float64(1)},
+			plugin.Options{}, "option minimum applies only to integer and number values, not string values"},
+		{"format on boolean", "enabled", &optionsPb.FieldOptions_JsonSchema{Format: proto.String("bool")},
+			plugin.Options{}, "option format applies only to string, integer and number values, not boolean values"},
+		{"min_length on message", "parent", &optionsPb.FieldOptions_JsonSchema{MinLength: proto.Int64(1)},
+			plugin.Options{}, "option min_length applies only to string values, not message values"},
+		{"pattern on repeated message", "children", &optionsPb.FieldOptions_JsonSchema{Pattern: proto.String("^x$")},
+			plugin.Options{}, "option pattern applies only to string values, not message values"},
+		{"maximum on duration message", "timeout", &optionsPb.FieldOptions_JsonSchema{Maximum: proto.Float64(60)},
+			plugin.Options{}, "option maximum applies only to integer and number values, not message values"},
+		{"content_encoding on number", "ratio", &optionsPb.FieldOptions_JsonSchema{ContentEncoding: proto.String("base64")},
+			plugin.Options{}, "option content_encoding applies only to string values, not number values"},
+	} {
+		s.Run(tc.name, func() {
+			err := generate(tc.field, tc.jsonSchema, tc.opts)
+			if tc.wantErr == "" {
+				s.Require().NoError(err)
+				return
+			}
+			s.Require().Error(err)
+			s.Contains(err.Error(), constraintsProto+": ")
+			s.Contains(err.Error(), tc.wantErr)
+		})
+	}
+}
+
+// TestEnumDescriptionsParameter tests that enum_descriptions appends the enum values and
+// their comments to the description of shared enum definitions, and to the field's
+// description for enums emitted inline.
+func (s *IntegrationTestSuite) TestEnumDescriptionsParameter() {
+	content := s.RunGenerateWithOptions(plugin.Options{EnumDescriptions: true})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+	s.Require().NotEmpty(content)
+	s.Contains(content, `\n\nValues:\n- USER_STATUS_UNSPECIFIED (0): Unspecified status - should not be used in practice.\n- USER_STATUS_ACTIVE (1): User account is active and can be used normally.\n`)
+	s.Contains(content, "\t\t\t1,\n", "the enum list is kept")
+
+	s.Run("with enum_names", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{EnumDescriptions: true, EnumNames: true})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Contains(content, `\n- USER_STATUS_ACTIVE: User account is active and can be used normally.\n`)
+	})
+
+	s.Run("inline enum", func() {
+		adminProto := "users/v1/admin.proto"
+		fds := s.compileProtos("admin.pb", adminProto, "users/v1/common.proto")
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{adminProto}, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{EnumDescriptions: true}))
+		admin := p.Response().File[0].GetContent()
+		s.Regexp(`schema\.Properties\["home_region"\] = &jsonschema\.Schema\{\s+Type:\s+"integer",\s+Title:\s+"[^"]*",\s+Description:\s+"[^"]*Values:\\n- REGION_UNSPECIFIED \(0\): Unspecified region\.\\n- REGION_EU \(1\): European Union\.\\n- REGION_US \(2\): United States\."`, admin)
+	})
+
+	s.Run("off by default", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.NotContains(content, "Values:")
+	})
+}
+
+// TestEnumOneOfParameter tests that enum_oneof lists enum values as a oneOf of consts
+// described by the values' leading comments, as numbers or (with enum_names) value names,
+// and that the schemas validate enum values at runtime.
+func (s *IntegrationTestSuite) TestEnumOneOfParameter() {
+	contents := s.RunGenerateWithOptions(plugin.Options{EnumOneOf: true})
+	content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+	s.Require().NotEmpty(content)
+	s.Contains(content, "\t\tOneOf: []*jsonschema.Schema{\n"+
+		"\t\t\t{Const: jsonschema.Ptr[any](0), Description: \"Unspecified status - should not be used in practice.\"},\n"+
+		"\t\t\t{Const: jsonschema.Ptr[any](1), Description: \"User account is active and can be used normally.\"},")
+	s.NotContains(content, "\t\t\t\"USER_STATUS_UNSPECIFIED\",")
+
+	s.Run("with enum_names", func() {
+		contents := s.RunGenerateWithOptions(plugin.Options{EnumOneOf: true, EnumNames: true})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Contains(content, "{Const: jsonschema.Ptr[any](\"USER_STATUS_ACTIVE\"), Description: \"User account is active and can be used normally.\"},")
+	})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEnumOneOf(t *testing.T) {
+	schema := (&User{}).JsonSchema()
+	data, err := json.Marshal(schema.Defs["users.v1.UserStatus"])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := ` + "`" + `"oneOf":[{"description":"Unspecified status - should not be used in practice.","const":0},` + "`" + `; !strings.Contains(string(data), want) {
+		t.Errorf("UserStatus schema = %s, want it to contain %s", data, want)
+	}
+
+	status, err := schema.Defs["users.v1.UserStatus"].Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	for _, tc := range []struct {
+		instance any
+		valid    bool
+	}{
+		{0.0, true},
+		{4.0, true},
+		{7.0, false},
+		{"USER_STATUS_ACTIVE", false},
+	} {
+		if err := status.Validate(tc.instance); (err == nil) != tc.valid {
+			t.Errorf("Validate(%v) = %v, want valid=%v", tc.instance, err, tc.valid)
+		}
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "enum_oneof_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testenumoneof/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "enum_oneof runtime tests failed: %s", string(output))
+}
+
+// TestWellKnownTypesProtojsonRuntime tests that with well_known_types=protojson the
+// schemas accept Timestamp, Duration, FieldMask and wrapper values as protojson marshals
+// them, and that the invopop accessors represent nullable wrappers as anyOf.
+func (s *IntegrationTestSuite) TestWellKnownTypesProtojsonRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{WellKnownTypes: "protojson", SchemaLib: "invopop"})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// protojsonValue returns the protojson form of m decoded into a JSON value.
+func protojsonValue(t *testing.T, m proto.Message) any {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		t.Fatalf("protojson.Marshal failed: %v", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	return v
+}
+
+func TestWellKnownTypesProtojson(t *testing.T) {
+	schema, err := (&WellKnownTypesDemo{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	ts := protojsonValue(t, timestamppb.New(time.Date(2017, 1, 15, 1, 30, 15, 10_000_000, time.UTC)))
+	payload, err := structpb.NewStruct(map[string]any{"name": "x", "tags": []any{"a", 1.0}, "nested": map[string]any{"ok": true}})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct failed: %v", err)
+	}
+	list, err := structpb.NewList([]any{"a", 1.0, nil, map[string]any{}})
+	if err != nil {
+		t.Fatalf("structpb.NewList failed: %v", err)
+	}
+	valid := map[string]any{
+		"created_at":    ts,
+		"updated_at":    ts,
+		"time_duration": protojsonValue(t, durationpb.New(3500*time.Millisecond)),
+		"any_field":     map[string]any{"type_url": "", "value": ""},
+		"struct_field":  protojsonValue(t, payload),
+		"value_field":   protojsonValue(t, structpb.NewNullValue()),
+		"timestamps":    []any{ts},
+		"timestamp_map": map[string]any{"a": ts},
+		"update_mask":   protojsonValue(t, &fieldmaskpb.FieldMask{Paths: []string{"user.display_name", "photo"}}),
+		"list_field":    protojsonValue(t, list),
+		"value_map":     map[string]any{"a": protojsonValue(t, structpb.NewStructValue(payload)), "b": 1.0},
+		"empty_field":   protojsonValue(t, &emptypb.Empty{}),
+	}
+	if err := schema.Validate(valid); err != nil {
+		t.Fatalf("Validate(protojson values) failed: %v", err)
+	}
+
+	for field, value := range map[string]any{
+		"created_at":    map[string]any{"seconds": 1.0},
+		"time_duration": "3.5",
+		"update_mask":   "user.display_name",
+		"struct_field":  []any{},
+		"list_field":    map[string]any{},
+		"empty_field":   map[string]any{"unexpected": true},
+	} {
+		invalid := make(map[string]any, len(valid))
+		for k, v := range valid {
+			invalid[k] = v
+		}
+		invalid[field] = value
+		if err := schema.Validate(invalid); err == nil {
+			t.Errorf("expected an error for %s = %v", field, value)
+		}
+	}
+}
+
+func TestWrapperTypesProtojson(t *testing.T) {
+	properties := (&Admin{}).JsonSchema().Defs["users.v1.Admin"].Properties
+	for _, tc := range []struct {
+		field   string
+		valid   []any
+		invalid []any
+	}{
+		{"optional_string_value", []any{protojsonValue(t, wrapperspb.String("x")), nil}, []any{map[string]any{"value": "x"}, 1.0}},
+		{"optional_int32_value", []any{protojsonValue(t, wrapperspb.Int32(7)), nil}, []any{map[string]any{"value": 7.0}, "7"}},
+		{"optional_int64_value", []any{protojsonValue(t, wrapperspb.Int64(-123)), 123.0, nil}, []any{map[string]any{"value": "1"}, "1.5"}},
+		{"optional_bool_value", []any{protojsonValue(t, wrapperspb.Bool(true)), nil}, []any{map[string]any{"value": true}}},
+		{"optional_double_value", []any{protojsonValue(t, wrapperspb.Double(1.5)), nil}, []any{map[string]any{"value": 1.5}}},
+		{"optional_bytes_value", []any{protojsonValue(t, wrapperspb.Bytes([]byte("hi"))), nil}, []any{map[string]any{"value": "aGk="}}},
+	} {
+		resolved, err := properties[tc.field].Resolve(nil)
+		if err != nil {
+			t.Fatalf("%s: Resolve failed: %v", tc.field, err)
+		}
+		for _, v := range tc.valid {
+			if err := resolved.Validate(v); err != nil {
+				t.Errorf("%s: Validate(%v) failed: %v", tc.field, v, err)
+			}
+		}
+		for _, v := range tc.invalid {
+			if err := resolved.Validate(v); err == nil {
+				t.Errorf("%s: expected an error for %v", tc.field, v)
+			}
+		}
+	}
+
+	// invopop/jsonschema schemas have a single type, so unions become anyOf branches.
+	converted, err := (&Admin{}).JsonSchemaInvopop()
+	if err != nil {
+		t.Fatalf("JsonSchemaInvopop failed: %v", err)
+	}
+	property, _ := converted.Definitions["users.v1.Admin"].Properties.Get("optional_string_value")
+	if property == nil || property.Type != "" || len(property.AnyOf) != 2 || property.AnyOf[0].Type != "string" || property.AnyOf[1].Type != "null" {
+		t.Errorf("optional_string_value = %+v, want anyOf string, null", property)
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "well_known_types_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testwellknowntypes/usersv1
+
+go 1.24
+
+require (
+	github.com/google/jsonschema-go v0.3.0
+	github.com/invopop/jsonschema v0.14.0
+	google.golang.org/protobuf v1.36.11
+)
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "well_known_types runtime tests failed: %s", string(output))
+}
+
+// TestMigrateMode tests that the migrate subcommand reports mis-targeted field options in
+// a descriptor set with their location and the proto edit, honoring plugin parameters
+// given with --opt,
+// and exits with 1 when edits are needed and 0 otherwise.
+func (s *IntegrationTestSuite) TestMigrateMode() {
+	constraintsProto := "constraints/v1/constraints.proto"
+	fds := s.compileProtos("constraints.pb", constraintsProto)
+
+	// writeSet attaches jsonSchema options to Target fields and writes the descriptor set.
+	writeSet := func(options map[string]*optionsPb.FieldOptions_JsonSchema) string {
+		set := proto.Clone(fds).(*descriptorpb.FileDescriptorSet)
+		for _, f := range set.File {
+			if f.GetName() != constraintsProto {
+				continue
+			}
+			for _, field := range f.MessageType[0].Field {
+				if jsonSchema, ok := options[field.GetName()]; ok {
+					field.Options = &descriptorpb.FieldOptions{}
+					proto.SetExtension(field.Options, optionsPb.E_Field, &optionsPb.FieldOptions{JsonSchema: jsonSchema})
+				}
+			}
+		}
+		data, err := proto.Marshal(set)
+		s.Require().NoError(err)
+		path := filepath.Join(s.TempDir(), "set.pb")
+		s.Require().NoError(os.WriteFile(path, data, 0o644))
+		return path
+	}
+
+	// migrate runs the migrate subcommand and returns its output and exit code.
+	migrate := func(args ...string) (string, int) {
+		cmd := exec.Command(s.pluginBinary, append([]string{"migrate"}, args...)...)
+		output, err := cmd.Output()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(output), exitErr.ExitCode()
+		}
+		s.Require().NoError(err)
+		return string(output), 0
+	}
+
+	set := writeSet(map[string]*optionsPb.FieldOptions_JsonSchema{
+		"count":  {MaxItems: proto.Int64(2), Pattern: proto.String("^[0-9]+$")},
+		"tags":   {MaxItems: proto.Int64(5)},
+		"status": {Pattern: proto.String("^STATUS_")},
+	})
+
+	output, code := migrate("--descriptor_set", set)
+	s.Equal(1, code)
+	s.Equal(constraintsProto+":25:3: constraints.v1.Target.count: json_schema option max_items applies only to repeated fields, not singular field\n"+
+		"\tremove `max_items: 2` from the field's (alis.open.options.v1.field).json_schema options\n"+
+		constraintsProto+":25:3: constraints.v1.Target.count: json_schema option pattern applies only to string values, not integer values\n"+
+		"\tremove `pattern: \"^[0-9]+$\"` from the field's (alis.open.options.v1.field).json_schema options\n"+
+		constraintsProto+":43:3: constraints.v1.Target.status: json_schema option pattern applies only to string values, not integer values\n"+
+		"\tremove `pattern: \"^STATUS_\"` from the field's (alis.open.options.v1.field).json_schema options\n", output)
+
+	s.Run("plugin parameters", func() {
+		output, code := migrate("--descriptor_set", set, "--opt", "enum_names")
+		s.Equal(1, code)
+		s.NotContains(output, "constraints.v1.Target.status")
+	})
+
+	s.Run("no edits needed", func() {
+		output, code := migrate("--descriptor_set", writeSet(map[string]*optionsPb.FieldOptions_JsonSchema{"tags": {MaxItems: proto.Int64(5)}}))
+		s.Equal(0, code)
+		s.Empty(output)
+	})
+
+	s.Run("unreadable descriptor set", func() {
+		_, code := migrate("--descriptor_set", filepath.Join(s.TempDir(), "missing.pb"))
+		s.Equal(2, code)
+	})
+}
+
+// TestHelpParamsAndVersion tests that -help-params documents every plugin parameter, that
+// -version prints the version, and that subcommands reject global flags, missing flags and
+// unexpected arguments.
+func (s *IntegrationTestSuite) TestHelpParamsAndVersion() {
+	output, err := exec.Command(s.pluginBinary, "-help-params").Output()
+	s.Require().NoError(err)
+	for _, p := range plugin.Params() {
+		s.Contains(string(output), "\n  "+p.Name+" ("+p.Type)
+		s.Contains(string(output), "Example: --go-jsonschema_opt="+p.Example+"\n")
+	}
+	s.Contains(string(output), "  field_names (string, default snake)\n")
+
+	output, err = exec.Command(s.pluginBinary, "-version").Output()
+	s.Require().NoError(err)
+	s.NotEmpty(strings.TrimSpace(string(output)))
+	s.Run("subcommands", func() {
+		for _, args := range [][]string{
+			{"-version", "selftest"},
+			{"-help-params", "migrate", "--descriptor_set", filepath.Join(descriptorsDir(), "user.pb")},
+			{"migrate"},
+			{"selftest", "extra"},
+			{"unknown"},
+		} {
+			err := exec.Command(s.pluginBinary, args...).Run()
+			var exitErr *exec.ExitError
+			s.Require().ErrorAs(err, &exitErr, "%v", args)
+			s.Equal(2, exitErr.ExitCode(), "%v", args)
+		}
+	})
+}
+
+// TestSelfTest tests that the selftest subcommand generates and resolves the built-in schema and
+// reports the versions it ran with.
+func (s *IntegrationTestSuite) TestSelfTest() {
+	if testing.Short() {
+		s.T().Skip("Skipping self-test in short mode")
+	}
+
+	output, err := exec.Command(s.pluginBinary, "selftest").CombinedOutput()
+	s.Require().NoError(err, "selftest failed: %s", string(output))
+	s.Contains(string(output), "  google.golang.org/protobuf v")
+	s.Contains(string(output), "ok   generate: selftest/v1/selftest.proto")
+	s.Contains(string(output), "ok   go toolchain: go version go")
+	s.Contains(string(output), "ok   resolve: selftest.v1.Item with github.com/google/jsonschema-go v")
+}
+
+// TestServe tests that the serve subcommand builds a playground for a descriptor set that lists the
+// generated messages and validates payloads against them, and that it stops when
+// interrupted.
+func (s *IntegrationTestSuite) TestServe() {
+	if testing.Short() {
+		s.T().Skip("Skipping playground server in short mode")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	addr := listener.Addr().String()
+	s.Require().NoError(listener.Close())
+
+	logFile, err := os.Create(filepath.Join(s.TempDir(), "serve.log"))
+	s.Require().NoError(err)
+	defer logFile.Close()
+	output := func() string {
+		b, _ := os.ReadFile(logFile.Name())
+		return string(b)
+	}
+	cmd := exec.Command(s.pluginBinary, "serve", "--descriptor_set", filepath.Join(descriptorsDir(), "user.pb"), "--addr", addr)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	s.Require().NoError(cmd.Start())
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	get := func(path string) (string, error) {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	var messages string
+	var exitErr error
+	stopped := false
+	s.Require().Eventually(func() bool {
+		select {
+		case exitErr = <-exited:
+			stopped = true
+			return true
+		default:
+		}
+		messages, err = get("/messages")
+		return err == nil
+	}, 3*time.Minute, 500*time.Millisecond, "playground did not start: %s", output())
+	s.Require().False(stopped, "serve exited: %v: %s", exitErr, output())
+	s.Contains(output(), "message schemas from ")
+
+	var names []string
+	s.Require().NoError(json.Unmarshal([]byte(messages), &names))
+	s.Contains(names, "users.v1.User")
+
+	page, err := get("/")
+	s.Require().NoError(err)
+	s.Contains(page, "<select id=\"message\">")
+
+	schema, err := get("/schema?message=users.v1.User")
+	s.Require().NoError(err)
+	s.Contains(schema, `"$ref": "#/$defs/users.v1.User"`)
+
+	validate := func(payload string) map[string]any {
+		resp, err := http.Post("http://"+addr+"/validate?message=users.v1.User", "application/json", strings.NewReader(payload))
+		s.Require().NoError(err)
+		defer resp.Body.Close()
+		var result map[string]any
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+	s.Equal(false, validate(`{"id": 5}`)["valid"])
+	s.Contains(validate(`{"id": 5}`)["error"], "properties/id")
+	s.Contains(validate(`{`)["error"], "invalid JSON")
+
+	s.Require().NoError(cmd.Process.Signal(os.Interrupt))
+	select {
+	case err := <-exited:
+		s.NoError(err, "serve did not exit cleanly: %s", output())
+	case <-time.After(30 * time.Second):
+		s.Fail("serve did not stop when interrupted")
+		cmd.Process.Kill()
+	}
+}
+
+// TestValidateCommand tests that the validate subcommand validates JSON and NDJSON data
+// files against a generated message schema and reports failures by file, line and JSON
+// Pointer.
+func (s *IntegrationTestSuite) TestValidateCommand() {
+	if testing.Short() {
+		s.T().Skip("Skipping validate command in short mode")
+	}
+
+	valid := `{"id": "1", "name": "users/1", "email": "a@example.com", "password": "p", "status": 1, ` +
+		`"address": {"city": "c", "zip_code": "z", "country": "x", "latitude": 1, "longitude": 2, "address_details": {"street": "s", "city": "c", "state": "st", "zip_code": "z", "country": "x"}}}`
+	invalid := strings.Replace(valid, `"latitude": 1`, `"latitude": "north"`, 1)
+	dir := s.TempDir()
+	single := filepath.Join(dir, "user.json")
+	s.Require().NoError(os.WriteFile(single, []byte(valid+"\n"), 0o644))
+	records := filepath.Join(dir, "users.ndjson")
+	s.Require().NoError(os.WriteFile(records, []byte(valid+"\n\n"+invalid+"\n"+`{"id": 5}`+"\n"), 0o644))
+
+	run := func(files ...string) (string, int) {
+		args := append([]string{"validate", "--descriptor_set", filepath.Join(descriptorsDir(), "user.pb"), "--message", "users.v1.User"}, files...)
+		output, err := exec.Command(s.pluginBinary, args...).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(output), exitErr.ExitCode()
+		}
+		s.Require().NoError(err, string(output))
+		return string(output), 0
+	}
+
+	output, code := run(single)
+	s.Equal(0, code, output)
+	s.Contains(output, "0 of 1 records failed validation against users.v1.User")
+
+	output, code = run(single, records)
+	s.Equal(1, code, output)
+	s.Contains(output, records+`:3: at "/address/latitude": got string, want number`)
+	s.Contains(output, records+`:4: at "/id": got number, want string`)
+	s.Contains(output, records+`:4: at "": missing properties`)
+	s.NotContains(output, single+":")
+	s.NotContains(output, records+":1:")
+	s.Contains(output, "2 of 4 records failed validation against users.v1.User")
+
+	s.Require().NoError(os.WriteFile(records, []byte(valid+"\n{"), 0o644))
+	output, code = run(records)
+	s.Equal(1, code, output)
+	s.Contains(output, records+":2: invalid JSON")
+
+	output, code = run()
+	s.Equal(2, code, output)
+	s.Contains(output, "Usage: protoc-gen-go-jsonschema validate")
+
+	// The test binary is a development build, so schema modules cannot require its
+	// schemautil package at its version.
+	output, code = run("--opt", "prune=true", single)
+	s.Equal(2, code, output)
+	s.Contains(output, "imports github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil, which requires a protoc-gen-go-jsonschema binary installed from a published version")
+}
+
+// TestExportCommand tests that the export subcommand writes bundled schemas, or with
+// -split one file per definition with relative references, indexed by manifest.json.
+func (s *IntegrationTestSuite) TestExportCommand() {
+	if testing.Short() {
+		s.T().Skip("Skipping export command in short mode")
+	}
+
+	run := func(args ...string) (string, int) {
+		args = append([]string{"export", "--descriptor_set", filepath.Join(descriptorsDir(), "user.pb")}, args...)
+		output, err := exec.Command(s.pluginBinary, args...).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(output), exitErr.ExitCode()
+		}
+		s.Require().NoError(err, string(output))
+		return string(output), 0
+	}
+	readJSON := func(path string, v any) {
+		data, err := os.ReadFile(path)
+		s.Require().NoError(err)
+		s.Require().NoError(json.Unmarshal(data, v))
+	}
+
+	bundled := s.TempDir()
+	output, code := run("--out", bundled, "users.v1.User", "users.v1.Address")
+	s.Require().Equal(0, code, output)
+	s.Contains(output, "exported 2 message schemas in 3 files")
+	var user jsonschema.Schema
+	readJSON(filepath.Join(bundled, "users.v1.User.json"), &user)
+	s.Equal("#/$defs/users.v1.User", user.Ref)
+	s.Contains(user.Defs, "users.v1.Address")
+
+	split := s.TempDir()
+	output, code = run("--out", split, "--split", "users.v1.User", "users.v1.Address")
+	s.Require().Equal(0, code, output)
+	var manifest struct {
+		Messages    map[string]string `json:"messages"`
+		Definitions map[string]string `json:"definitions"`
+	}
+	readJSON(filepath.Join(split, "manifest.json"), &manifest)
+	s.Equal(map[string]string{"users.v1.User": "users.v1.User.json", "users.v1.Address": "users.v1.Address.json"}, manifest.Messages)
+	s.Equal("defs/users.v1.Address.AddressDetails.json", manifest.Definitions["users.v1.Address.AddressDetails"])
+	var root, def jsonschema.Schema
+	readJSON(filepath.Join(split, "users.v1.User.json"), &root)
+	s.Equal("defs/users.v1.User.json", root.Ref)
+	s.Nil(root.Defs)
+	readJSON(filepath.Join(split, "defs", "users.v1.User.json"), &def)
+	s.Equal("users.v1.Address.json", def.Properties["address"].Ref)
+
+	// The split documents resolve from their files like the bundled schema.
+	resolved, err := root.Resolve(&jsonschema.ResolveOptions{
+		BaseURI: "file://" + filepath.ToSlash(filepath.Join(split, "users.v1.User.json")),
+		Loader: func(uri *url.URL) (*jsonschema.Schema, error) {
+			var doc jsonschema.Schema
+			data, err := os.ReadFile(filepath.FromSlash(uri.Path))
+			if err != nil {
+				return nil, err
+			}
+			return &doc, json.Unmarshal(data, &doc)
+		},
+	})
+	s.Require().NoError(err)
+	s.Error(resolved.Validate(map[string]any{"id": "1", "address": map[string]any{"latitude": "north"}}))
+
+	legacy := s.TempDir()
+	output, code = run("--out", legacy, "--definitions", "users.v1.User")
+	s.Require().Equal(0, code, output)
+	var keywords map[string]json.RawMessage
+	readJSON(filepath.Join(legacy, "users.v1.User.json"), &keywords)
+	s.Require().Contains(keywords, "definitions")
+	s.JSONEq(string(keywords["$defs"]), string(keywords["definitions"]))
+
+	output, code = run("--out", s.TempDir(), "users.v1.Nope")
+	s.Equal(2, code, output)
+	s.Contains(output, `no schema for message "users.v1.Nope"`)
+
+	output, code = run()
+	s.Equal(2, code, output)
+	s.Contains(output, "Usage: protoc-gen-go-jsonschema export")
+}
+
+// TestEditionsSupport tests that the plugin declares editions support in its response,
+// that editions fields are required unless they have explicit presence, and that
+// delimited-encoded message fields are handled as message fields.
+func (s *IntegrationTestSuite) TestEditionsSupport() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{}))
+
+	resp := p.Response()
+	s.Empty(resp.GetError())
+	s.NotZero(resp.GetSupportedFeatures() & uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS))
+	s.NotZero(resp.GetSupportedFeatures() & uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
+	s.Equal(int32(descriptorpb.Edition_EDITION_PROTO2), resp.GetMinimumEdition())
+	s.Equal(int32(descriptorpb.Edition_EDITION_2023), resp.GetMaximumEdition())
+
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+	s.Regexp(`defs\["editions\.v1\.Account"\] = schema`, content)
+	s.Regexp(`Required: \[\]string\{\s+"id",\s+"version",\s+"role",\s+\},\s+\}\s+// Register schema BEFORE processing fields[^\n]*\n[^\n]*\n\s+defs\["editions\.v1\.Account"\]`, content,
+		"only implicit-presence and legacy-required fields should be required")
+	s.Regexp(`Required: \[\]string\{\s+"email",\s+\},\s+\}\s+// Register schema BEFORE processing fields[^\n]*\n[^\n]*\n\s+defs\["editions\.v1\.Owner"\]`, content)
+	s.Contains(content, `schema.Properties["delegate"] = Owner_JsonSchema_WithDefs(defs)`,
+		"delimited-encoded message fields should reference the message schema")
+	s.Regexp(`schema\.Properties\["previous_owners"\] = &jsonschema\.Schema\{[^}]+Items:\s+Owner_JsonSchema_WithDefs\(defs\),`, content)
+}
+
+// TestEditionsWithProtoc tests that protoc runs the plugin binary on an editions file.
+func (s *IntegrationTestSuite) TestEditionsWithProtoc() {
+	if testing.Short() {
+		s.T().Skip("Skipping end-to-end test in short mode")
+	}
+	s.requireProtoc()
+
+	outputDir := s.TempDir()
+	args := []string{
+		"--plugin=protoc-gen-go-jsonschema=" + s.pluginBinary,
+		"--go-jsonschema_out=" + outputDir,
+		"--go-jsonschema_opt=paths=source_relative",
+		"--proto_path=" + filepath.Join(s.workspaceRoot, "testdata", "protos"),
+		"editions/v1/editions.proto",
+	}
+	output, err := exec.Command("protoc", args...).CombinedOutput()
+	s.Require().NoError(err, "protoc failed: %s\nArgs: %v", string(output), args)
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "editions", "v1", "editions_jsonschema.pb.go"))
+	s.Require().NoError(err)
+	s.Contains(string(content), `defs["editions.v1.Account"] = schema`)
+}
+
+// TestGenerationPanicIsReported tests that a panic while generating a message is reported
+// as an error in the response naming the file and message, instead of crashing.
+func (s *IntegrationTestSuite) TestGenerationPanicIsReported() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+
+	// An enum field without its enum breaks generation of the Account schema.
+	for _, field := range p.FilesByPath[editionsProto].Messages[0].Fields {
+		if field.Desc.Name() == "role" {
+			field.Enum = nil
+		}
+	}
+
+	var genErr error
+	s.NotPanics(func() { genErr = plugin.GenerateWithOptions(p, "test", plugin.Options{}) })
+	s.Require().Error(genErr)
+	s.Contains(genErr.Error(), "editions/v1/editions.proto: message editions.v1.Account: internal error: ")
+	s.Contains(p.Response().GetError(), "editions/v1/editions.proto: message editions.v1.Account: internal error: ")
+}
+
+// TestAnyTypesRuntime tests that an Any field restricted by any_types accepts the protojson
+// form of the allowed messages only, including an allowed message closed to undeclared
+// properties other than strings.
+func (s *IntegrationTestSuite) TestAnyTypesRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{
+		AnyTypes: []string{
+			"users.v1.WellKnownTypesDemo.any_field=users.v1.GetUserRequest|users.v1.DeleteUserResponse",
+		},
+		AdditionalProperties: []string{"users.v1.GetUserRequest=string"},
+	})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestAnyTypes(t *testing.T) {
+	root := &jsonschema.Schema{
+		Defs:       (&WellKnownTypesDemo{}).JsonSchema().Defs,
+		Properties: map[string]*jsonschema.Schema{"any_field": {Ref: "#/$defs/users.v1.WellKnownTypesDemo/properties/any_field"}},
+	}
+	schema, err := root.Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	const getUser = "type.googleapis.com/users.v1.GetUserRequest"
+	const deleteUser = "type.googleapis.com/users.v1.DeleteUserResponse"
+	for _, tc := range []struct {
+		value map[string]any
+		valid bool
+	}{
+		{map[string]any{"@type": getUser, "id": "x"}, true},
+		{map[string]any{"@type": deleteUser, "success": true}, true},
+		{map[string]any{"@type": "type.googleapis.com/users.v1.User", "id": "x"}, false},
+		{map[string]any{"id": "x"}, false},
+		{map[string]any{"@type": getUser, "id": 1.0}, false},
+		{map[string]any{"@type": getUser, "success": true}, false},
+		{map[string]any{"@type": getUser, "id": "x", "note": "y"}, true},
+	} {
+		if err := schema.Validate(map[string]any{"any_field": tc.value}); (err == nil) != tc.valid {
+			t.Errorf("Validate(%v) = %v, want valid=%v", tc.value, err, tc.valid)
+		}
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "any_types_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testanytypes/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "any_types runtime tests failed: %s", string(output))
+}
+
+// TestGoogleTypeCanonicalSchemas tests that the google.type schemas carry the value ranges
+// and formats documented on their fields and require no fields.
+func (s *IntegrationTestSuite) TestGoogleTypeCanonicalSchemas() {
+	placesProto := "places/v1/places.proto"
+	fds := s.compileProtos("places.pb", placesProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{placesProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.Generate(p, "test"))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	s.Regexp(`schema\.Properties\["latitude"\] = &jsonschema\.Schema\{[^}]+Minimum:\s+&\[\]float64\{-90\}\[0\],\s+Maximum:\s+&\[\]float64\{90\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["currency_code"\] = &jsonschema\.Schema\{[^}]+Pattern:\s+"\^\[A-Z\]\{3\}\$",`, content)
+	s.Regexp(`defs\["google\.type\.PostalAddress"\]`, content)
+	s.NotRegexp(`Required: \[\]string\{\s+"region_code",`, content, "google.type schemas should not require fields")
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "places_jsonschema.pb.go"), []byte(content), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package placesv1\n\ntype Place struct{}\n"), 0o644))
+
+	testContent := `package placesv1
+
+import "testing"
+
+func TestGoogleTypes(t *testing.T) {
+	schema, err := (&Place{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	valid := map[string]any{
+		"location":        map[string]any{"latitude": -33.9, "longitude": 18.4},
+		"opened_on":       map[string]any{"year": 2020.0, "month": 5.0},
+		"opens_at":        map[string]any{"hours": 24.0},
+		"entry_fee":       map[string]any{"currency_code": "ZAR", "units": 5.0, "nanos": 500000000.0},
+		"address":         map[string]any{"region_code": "ZA", "address_lines": []any{"1 Main Road"}},
+		"color":           map[string]any{"red": 1.0, "alpha": map[string]any{"value": 0.5}},
+		"last_inspection": map[string]any{"year": 2024.0, "month": 2.0, "day": 29.0, "seconds": 60.0},
+		"phone":           map[string]any{"e164_number": "+27215550123"},
+		"rating":          map[string]any{"value": "4.5"},
+		"share":           map[string]any{"numerator": 1.0, "denominator": 3.0},
+	}
+	if err := schema.Validate(valid); err != nil {
+		t.Fatalf("Validate(valid place) failed: %v", err)
+	}
+
+	for field, value := range map[string]any{
+		"location":        map[string]any{"latitude": 91.0},
+		"opened_on":       map[string]any{"month": 13.0},
+		"opens_at":        map[string]any{"minutes": 60.0},
+		"entry_fee":       map[string]any{"currency_code": "zar"},
+		"address":         map[string]any{"region_code": "ZAF"},
+		"color":           map[string]any{"green": 1.5},
+		"last_inspection": map[string]any{"nanos": -1.0},
+		"phone":           map[string]any{"e164_number": "0215550123"},
+		"rating":          map[string]any{"value": "4,5"},
+		"share":           map[string]any{"denominator": 0.0},
+	} {
+		invalid := make(map[string]any, len(valid))
+		for k, v := range valid {
+			invalid[k] = v
+		}
+		invalid[field] = value
+		if err := schema.Validate(invalid); err == nil {
+			t.Errorf("expected an error for %s = %v", field, value)
+		}
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "places_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/places/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "google.type runtime tests failed: %s", string(output))
+}
+
+// TestOwnedGooglePackages tests that owned_google_packages makes google.* messages of
+// the listed packages ordinary cross-package messages: they are referenced through their
+// declaring package, get JsonSchema methods when their files are generated, and must be
+// generated like any other dependency.
+func (s *IntegrationTestSuite) TestOwnedGooglePackages() {
+	placesProto := "places/v1/places.proto"
+	fds := s.compileProtos("places.pb", placesProto)
+	opts := plugin.Options{OwnedGooglePackages: []string{"google.type"}}
+
+	s.Run("declaring files must be generated", func() {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{placesProto}, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		err = plugin.GenerateWithOptions(p, "test", opts)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "does not generate its schema")
+	})
+
+	s.Run("referenced through their package", func() {
+		var toGenerate []string
+		for _, f := range fds.File {
+			if f.GetPackage() == "google.type" || f.GetName() == placesProto {
+				toGenerate = append(toGenerate, f.GetName())
+			}
+		}
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: toGenerate, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		s.Require().NoError(plugin.GenerateWithOptions(p, "test", opts))
+		resp := p.Response()
+		s.Require().Empty(resp.GetError())
+
+		contents := make(map[string]string)
+		for _, f := range resp.File {
+			contents[f.GetName()] = f.GetContent()
+		}
+		places := contents["github.com/newtonnthiga/places/v1/places_jsonschema.pb.go"]
+		s.Require().NotEmpty(places)
+		s.Contains(places, "latlng.LatLng_JsonSchema_WithDefs(defs)")
+		s.NotContains(places, "google_type_LatLng", "owned messages get no standalone functions")
+		latlng := contents["google.golang.org/genproto/googleapis/type/latlng/latlng_jsonschema.pb.go"]
+		s.Require().NotEmpty(latlng)
+		s.Contains(latlng, "func (x *LatLng) JsonSchema() *jsonschema.Schema {")
+		s.Regexp(`Minimum:\s+&\[\]float64\{-90\}\[0\],`, latlng, "canonical google.type constraints are kept")
+	})
+
+	s.Run("well-known types cannot be owned", func() {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{placesProto}, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		err = plugin.GenerateWithOptions(p, "test", plugin.Options{OwnedGooglePackages: []string{"google.protobuf"}})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "invalid owned_google_packages parameter")
+	})
+}
+
+// TestTargetOpenAIRuntime tests that the target=openai accessors compile and return
+// schemas that require every property, accept null for optional ones and reject
+// additional properties.
+func (s *IntegrationTestSuite) TestTargetOpenAIRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{Targets: []string{"openai"}}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 2)
+
+	tmpDir := s.TempDir()
+	for _, f := range resp.File {
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, filepath.Base(f.GetName())), []byte(f.GetContent()), 0o644))
+	}
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import "testing"
+
+func TestOpenAI(t *testing.T) {
+	converted, err := (&Account{}).JsonSchemaOpenAI()
+	if err != nil {
+		t.Fatalf("JsonSchemaOpenAI failed: %v", err)
+	}
+	if len(converted.Required) != len(converted.Properties) {
+		t.Errorf("Required = %v, want every property", converted.Required)
+	}
+	schema, err := converted.Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	valid := map[string]any{
+		"name":            nil,
+		"id":              "a1",
+		"version":         1,
+		"owner":           map[string]any{"email": "owner@example.com"},
+		"tags":            []any{},
+		"role":            1,
+		"delegate":        nil,
+		"previous_owners": []any{},
+	}
+	if err := schema.Validate(valid); err != nil {
+		t.Fatalf("Validate(valid account) failed: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"id": "a1", "version": 1, "role": 1}); err == nil {
+		t.Error("expected an error for missing properties")
+	}
+	valid["extra"] = true
+	if err := schema.Validate(valid); err == nil {
+		t.Error("expected an error for an additional property")
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require (
+	github.com/alis-exchange/protoc-gen-go-jsonschema v0.0.0
+	github.com/google/jsonschema-go v0.3.0
+)
+
+replace github.com/alis-exchange/protoc-gen-go-jsonschema => ` + s.workspaceRoot + `
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "openai runtime tests failed: %s", string(output))
+}
+
+// TestPruneRuntime tests that the prune=true methods compile and drop undeclared
+// properties of the message and of nested messages.
+func (s *IntegrationTestSuite) TestPruneRuntime() {
+	contents := s.GenerateFixture("oneofs", plugin.Options{Prune: true})
+	s.Require().Contains(contents, "example.com/fixtures/oneofs/v1/oneofs_jsonschema_prune.pb.go")
+	s.RunFixtureModule("oneofs", contents, map[string]string{"prune_test.go": pruneTest})
+}
+
+// pruneTest checks PruneToSchema() on fixtures.oneofs.v1.Payment.
+const pruneTest = `package oneofsv1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	data := map[string]any{
+		"id":        "p1",
+		"reasoning": "model commentary",
+		"card":      map[string]any{"number": "4111", "confidence": 0.9},
+		"note":      "gift",
+	}
+	want := map[string]any{
+		"id":   "p1",
+		"card": map[string]any{"number": "4111"},
+		"note": "gift",
+	}
+	if got := (&Payment{}).PruneToSchema(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("PruneToSchema() = %v, want %v", got, want)
+	}
+	if _, ok := data["reasoning"]; !ok {
+		t.Error("PruneToSchema modified its input")
+	}
+}
+`
+
+// TestCELConstraintRuntime tests that cel_constraint expressions compile into the message
+// schemas and that schemautil.EvaluateConstraints applies them to the message and to
+// messages nested in it.
+func (s *IntegrationTestSuite) TestCELConstraintRuntime() {
+	contents := s.GenerateFixture("oneofs", plugin.Options{CELConstraints: []string{
+		"fixtures.oneofs.v1.Payment=!has(this.cash) || !has(this.note)",
+		"fixtures.oneofs.v1.Card=size(this.number) >= 4",
+	}})
+	s.RunFixtureModule("oneofs", contents, map[string]string{"constraints_test.go": celConstraintTest})
+}
+
+// celConstraintTest checks the x-constraints of fixtures.oneofs.v1.Payment and Card.
+const celConstraintTest = `package oneofsv1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"
+)
+
+func TestConstraints(t *testing.T) {
+	schema := (&Payment{}).JsonSchema()
+	for _, valid := range []map[string]any{
+		{"id": "p1", "cash": true},
+		{"id": "p1", "note": "gift", "card": map[string]any{"number": "4111"}},
+	} {
+		if err := schemautil.EvaluateConstraints(schema, valid); err != nil {
+			t.Errorf("EvaluateConstraints(%v) = %v", valid, err)
+		}
+	}
+	err := schemautil.EvaluateConstraints(schema, map[string]any{"id": "p1", "cash": true, "note": "gift"})
+	if err == nil || !strings.Contains(err.Error(), "at the instance") {
+		t.Errorf("cash payment with a note: got %v", err)
+	}
+	err = schemautil.EvaluateConstraints(schema, map[string]any{"id": "p1", "card": map[string]any{"number": "41"}})
+	if err == nil || !strings.Contains(err.Error(), "at /card") {
+		t.Errorf("short card number: got %v", err)
+	}
+}
+`
+
+// TestCoerceToolArgsRuntime tests that the coerce_tool_args=true methods compile and fix
+// mistyped arguments so that they validate against the MCP input schema.
+func (s *IntegrationTestSuite) TestCoerceToolArgsRuntime() {
+	contents := s.GenerateFixture("oneofs", plugin.Options{CoerceToolArgs: true})
+	s.Require().Contains(contents, "example.com/fixtures/oneofs/v1/oneofs_jsonschema_toolargs.pb.go")
+	s.RunFixtureModule("oneofs", contents, map[string]string{"coerce_test.go": coerceToolArgsTest})
+}
+
+// coerceToolArgsTest checks CoerceToolArgs() on fixtures.oneofs.v1.Payment.
+const coerceToolArgsTest = `package oneofsv1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoerceToolArgs(t *testing.T) {
+	args := map[string]any{
+		"id":          7,
+		"card":        ` + "`" + `{"number": "4111"}` + "`" + `,
+		"merchant_id": "9007199254740993",
+		"note":        []any{"gift"},
+	}
+	coerced, err := (&Payment{}).CoerceToolArgs(args)
+	if err != nil {
+		t.Fatalf("CoerceToolArgs failed: %v", err)
+	}
+	want := map[string]any{
+		"id":          "7",
+		"card":        map[string]any{"number": "4111"},
+		"merchant_id": int64(9007199254740993),
+		"note":        "gift",
+	}
+	if !reflect.DeepEqual(coerced, want) {
+		t.Errorf("CoerceToolArgs() = %v, want %v", coerced, want)
+	}
+	schema, err := (&Payment{}).MCPInputSchema().Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(coerced); err != nil {
+		t.Errorf("coerced arguments rejected: %v", err)
+	}
+	if args["merchant_id"] != "9007199254740993" {
+		t.Error("CoerceToolArgs modified its input")
+	}
+
+	coerced, err = (&Payment{}).CoerceToolArgs(map[string]any{"cash": "TRUE"})
+	if err != nil || coerced["cash"] != true {
+		t.Errorf("CoerceToolArgs(cash: TRUE) = %v, %v", coerced, err)
+	}
+	_, err = (&Payment{}).CoerceToolArgs(map[string]any{"merchant_id": "many"})
+	if err == nil || !strings.Contains(err.Error(), ` + "`" + `at "/merchant_id": cannot convert string "many" to integer` + "`" + `) {
+		t.Errorf("CoerceToolArgs(merchant_id: many) error = %v", err)
+	}
+}
+`
+
+// TestSchemaBaseURIRuntime tests that with schema_base_uri every definition has an $id
+// that references use, and that the schemas still resolve, validate and convert.
+func (s *IntegrationTestSuite) TestSchemaBaseURIRuntime() {
+	for _, value := range []string{"schemas/v1", "https://schemas.example.com/?v=1", "https://schemas.example.com/#defs"} {
+		err := plugin.GenerateWithOptions(s.LoadFixture("oneofs"), "test", plugin.Options{SchemaBaseURI: value})
+		s.Require().Error(err, value)
+		s.Contains(err.Error(), fmt.Sprintf("invalid schema_base_uri parameter %q", value))
+	}
+
+	contents := s.GenerateFixture("oneofs", plugin.Options{SchemaBaseURI: "https://schemas.example.com/", Targets: []string{"gemini"}, Prune: true})
+	s.Require().Len(contents, 3)
+	s.Regexp(`ID:\s+"https://schemas\.example\.com/fixtures/oneofs/v1/Payment\.json",`, contents["example.com/fixtures/oneofs/v1/oneofs_jsonschema.pb.go"])
+	s.RunFixtureModule("oneofs", contents, map[string]string{"base_uri_test.go": schemaBaseURITest})
+}
+
+// schemaBaseURITest checks the $id references of fixtures.oneofs.v1.Payment and that its
+// schemas validate, convert to Gemini and prune through them.
+const schemaBaseURITest = `package oneofsv1
+
+import (
+	"reflect"
+	"testing"
+)
+
+const base = "https://schemas.example.com/fixtures/oneofs/v1/"
+
+func TestSchemaBaseURI(t *testing.T) {
+	root := (&Payment{}).JsonSchema()
+	if root.Ref != base+"Payment.json" {
+		t.Errorf("root $ref = %q", root.Ref)
+	}
+	for name, id := range map[string]string{"fixtures.oneofs.v1.Payment": "Payment.json", "fixtures.oneofs.v1.Card": "Card.json"} {
+		if got := root.Defs[name].ID; got != base+id {
+			t.Errorf("%s $id = %q, want %q", name, got, base+id)
+		}
+	}
+
+	payment := map[string]any{"id": "p1", "card": map[string]any{"number": "4111"}}
+	for _, schema := range []string{"JsonSchema", "MCPInputSchema"} {
+		s := root
+		if schema == "MCPInputSchema" {
+			s = (&Payment{}).MCPInputSchema()
+		}
+		resolved, err := s.Resolve(nil)
+		if err != nil {
+			t.Fatalf("%s: Resolve failed: %v", schema, err)
+		}
+		if err := resolved.Validate(payment); err != nil {
+			t.Errorf("%s: valid payment rejected: %v", schema, err)
+		}
+		invalid := map[string]any{"id": "p1", "card": map[string]any{"number": 4111}}
+		if err := resolved.Validate(invalid); err == nil {
+			t.Errorf("%s: invalid card accepted through its $id reference", schema)
+		}
+	}
+
+	gemini, err := (&Payment{}).JsonSchemaGemini()
+	if err != nil {
+		t.Fatalf("JsonSchemaGemini failed: %v", err)
+	}
+	if card := gemini.Properties["card"]; card == nil || card.Type != "object" || card.Properties["number"] == nil {
+		t.Errorf("Gemini card = %+v, want the inlined Card schema", card)
+	}
+
+	pruned := (&Payment{}).PruneToSchema(map[string]any{"id": "p1", "card": map[string]any{"number": "4111", "extra": true}, "extra": true})
+	if want := map[string]any{"id": "p1", "card": map[string]any{"number": "4111"}}; !reflect.DeepEqual(pruned, want) {
+		t.Errorf("PruneToSchema() = %v, want %v", pruned, want)
+	}
+}
+`
+
+// TestTargetGeminiRuntime tests that the target=gemini accessors compile and return
+// schemas without references that list properties in proto field order.
+func (s *IntegrationTestSuite) TestTargetGeminiRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{Targets: []string{"gemini"}}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 2)
+
+	tmpDir := s.TempDir()
+	for _, f := range resp.File {
+		s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, filepath.Base(f.GetName())), []byte(f.GetContent()), 0o644))
+	}
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGemini(t *testing.T) {
+	converted, err := (&Account{}).JsonSchemaGemini()
+	if err != nil {
+		t.Fatalf("JsonSchemaGemini failed: %v", err)
+	}
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "$ref") || strings.Contains(string(data), "$defs") {
+		t.Errorf("schema still has references: %s", data)
+	}
+
+	var schema struct {
+		PropertyOrdering []string ` + "`json:\"propertyOrdering\"`" + `
+		Properties       map[string]struct {
+			Type     string         ` + "`json:\"type\"`" + `
+			Nullable bool           ` + "`json:\"nullable\"`" + `
+			Items    map[string]any ` + "`json:\"items\"`" + `
+		} ` + "`json:\"properties\"`" + `
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := []string{"name", "id", "version", "owner", "tags", "role", "delegate", "previous_owners"}
+	if !reflect.DeepEqual(schema.PropertyOrdering, want) {
+		t.Errorf("propertyOrdering = %v, want %v", schema.PropertyOrdering, want)
+	}
+	if owner := schema.Properties["owner"]; owner.Type != "object" {
+		t.Errorf("owner type = %q, want the inlined object", owner.Type)
+	}
+	if items := schema.Properties["previous_owners"].Items; items["type"] != "object" {
+		t.Errorf("previous_owners items = %v, want the inlined object", items)
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require (
+	github.com/alis-exchange/protoc-gen-go-jsonschema v0.0.0
+	github.com/google/jsonschema-go v0.3.0
+)
+
+replace github.com/alis-exchange/protoc-gen-go-jsonschema => ` + s.workspaceRoot + `
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "gemini runtime tests failed: %s", string(output))
+}
+
+// TestPresenceMetadataRuntime tests that the presence_metadata annotations compile, follow
+// the editions field_presence feature and leave the schema resolvable.
+func (s *IntegrationTestSuite) TestPresenceMetadataRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{PresenceMetadata: true}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_jsonschema.pb.go"), []byte(resp.File[0].GetContent()), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPresence(t *testing.T) {
+	schema := (&Account{}).MCPInputSchema()
+	if _, err := schema.Resolve(nil); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded struct {
+		Properties map[string]struct {
+			Presence string ` + "`json:\"x-proto-presence\"`" + `
+			Ref      string ` + "`json:\"$ref\"`" + `
+		} ` + "`json:\"properties\"`" + `
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	got := make(map[string]string)
+	for name, prop := range decoded.Properties {
+		got[name] = prop.Presence
+	}
+	want := map[string]string{
+		"name":            "explicit",
+		"id":              "implicit",
+		"version":         "explicit",
+		"owner":           "explicit",
+		"tags":            "implicit",
+		"role":            "implicit",
+		"delegate":        "explicit",
+		"previous_owners": "implicit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("x-proto-presence = %v, want %v", got, want)
+	}
+	if ref := decoded.Properties["owner"].Ref; ref != "#/$defs/editions.v1.Owner" {
+		t.Errorf("owner $ref = %q, want the Owner definition", ref)
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "presence_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "presence runtime tests failed: %s", string(output))
+}
+
+// TestClosedObjectsRuntime tests that closed_objects schemas reject undeclared properties
+// at every level while accepting instances that only use declared ones.
+func (s *IntegrationTestSuite) TestClosedObjectsRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{ClosedObjects: []string{"all"}}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_jsonschema.pb.go"), []byte(resp.File[0].GetContent()), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import "testing"
+
+func TestClosedObjects(t *testing.T) {
+	schema, err := (&Account{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	account := func() map[string]any {
+		return map[string]any{
+			"name": "a", "id": "1", "version": 1, "owner": map[string]any{"email": "o@x.y"},
+			"tags": []any{}, "role": 1, "delegate": map[string]any{"email": "d@x.y"}, "previous_owners": []any{},
+		}
+	}
+	if err := schema.Validate(account()); err != nil {
+		t.Errorf("valid account rejected: %v", err)
+	}
+	unknown := account()
+	unknown["nickname"] = "x"
+	if err := schema.Validate(unknown); err == nil {
+		t.Error("unknown top-level property accepted")
+	}
+	nested := account()
+	nested["owner"] = map[string]any{"email": "o@x.y", "phone": "1"}
+	if err := schema.Validate(nested); err == nil {
+		t.Error("unknown nested property accepted")
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "closed_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "closed objects runtime tests failed: %s", string(output))
+}
+
+// TestNullableOptionalRuntime tests that nullable_optional schemas accept explicit nulls
+// for optional scalar, enum and message fields and still reject them for other fields.
+func (s *IntegrationTestSuite) TestNullableOptionalRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{NullableOptional: true, EnumNames: true}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_jsonschema.pb.go"), []byte(resp.File[0].GetContent()), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import "testing"
+
+func TestNullableOptional(t *testing.T) {
+	schema, err := (&Account{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	account := func() map[string]any {
+		return map[string]any{
+			"name": "a", "id": "1", "version": 1, "owner": map[string]any{"email": "o@x.y"},
+			"tags": []any{}, "role": "ROLE_ADMIN", "delegate": map[string]any{"email": "d@x.y"}, "previous_owners": []any{},
+		}
+	}
+	if err := schema.Validate(account()); err != nil {
+		t.Errorf("valid account rejected: %v", err)
+	}
+	nulls := account()
+	nulls["name"], nulls["owner"], nulls["delegate"] = nil, nil, nil
+	if err := schema.Validate(nulls); err != nil {
+		t.Errorf("explicit nulls for optional fields rejected: %v", err)
+	}
+	for _, field := range []string{"id", "version", "role", "tags"} {
+		invalid := account()
+		invalid[field] = nil
+		if err := schema.Validate(invalid); err == nil {
+			t.Errorf("null accepted for non-optional field %s", field)
+		}
+	}
+	wrong := account()
+	wrong["owner"] = "o@x.y"
+	if err := schema.Validate(wrong); err == nil {
+		t.Error("non-object owner accepted")
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "nullable_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "nullable optional runtime tests failed: %s", string(output))
+}
+
+// TestConstRuntime verifies that const values, on nullable, enum, message and repeated
+// fields, compile and accept only the given value (and null for nullable fields).
+func (s *IntegrationTestSuite) TestConstRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{
+		NullableOptional: true,
+		EnumNames:        true,
+		Consts: []string{
+			`editions.v1.Account.name="a"`,
+			"editions.v1.Account.role=1",
+			`editions.v1.Account.owner={"email":"o@x.y"}`,
+			`editions.v1.Account.tags=["t"]`,
+		},
+	}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_jsonschema.pb.go"), []byte(resp.File[0].GetContent()), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import "testing"
+
+func TestConst(t *testing.T) {
+	schema, err := (&Account{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	account := func() map[string]any {
+		return map[string]any{
+			"name": "a", "id": "1", "version": 1, "owner": map[string]any{"email": "o@x.y"},
+			"tags": []any{"t"}, "role": "ROLE_ADMIN",
+		}
+	}
+	if err := schema.Validate(account()); err != nil {
+		t.Errorf("const values rejected: %v", err)
+	}
+	nulls := account()
+	nulls["name"], nulls["owner"] = nil, nil
+	if err := schema.Validate(nulls); err != nil {
+		t.Errorf("null rejected for nullable const fields: %v", err)
+	}
+	for field, value := range map[string]any{
+		"name":  "b",
+		"role":  "ROLE_UNSPECIFIED",
+		"owner": map[string]any{"email": "p@x.y"},
+		"tags":  []any{"t", "u"},
+	} {
+		invalid := account()
+		invalid[field] = value
+		if err := schema.Validate(invalid); err == nil {
+			t.Errorf("%s accepted a value other than its const", field)
+		}
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "const_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "const runtime tests failed: %s", string(output))
+}
+
+// TestStreamFramingRuntime verifies that an array-framed stream schema compiles, resolves
+// and validates its items as the response message.
+func (s *IntegrationTestSuite) TestStreamFramingRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{StreamFraming: "array"})
+	s.Require().Contains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema_stream.pb.go")
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import "testing"
+
+func TestStreamFraming(t *testing.T) {
+	schema, err := UserService_StreamUsers_StreamJsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := schema.Validate([]any{}); err != nil {
+		t.Errorf("empty stream rejected: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"id": "1"}); err == nil {
+		t.Error("a single message accepted as an array-framed stream")
+	}
+	if err := schema.Validate([]any{map[string]any{"id": 1}}); err == nil {
+		t.Error("a stream item with an invalid User accepted")
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "stream_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module teststream/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "stream framing runtime tests failed: %s", string(output))
+}
+
+// TestUnevaluatedPropertiesRuntime verifies that a message schema with
+// unevaluatedProperties false accepts its oneof members and rejects undeclared
+// properties.
+func (s *IntegrationTestSuite) TestUnevaluatedPropertiesRuntime() {
+	contents := s.GenerateFixture("oneofs", plugin.Options{UnevaluatedProperties: []string{"fixtures.oneofs.v1.Payment"}})
+	s.RunFixtureModule("oneofs", contents, map[string]string{"unevaluated_test.go": unevaluatedPropertiesTest})
+}
+
+// unevaluatedPropertiesTest validates instances of fixtures.oneofs.v1.Payment, closed
+// with unevaluatedProperties.
+const unevaluatedPropertiesTest = `package oneofsv1
+
+import "testing"
+
+func TestUnevaluatedProperties(t *testing.T) {
+	schema, err := (&Payment{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"id": "p1", "account": "a", "customer_id": "c", "note": "n"}); err != nil {
+		t.Errorf("oneof members rejected: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"id": "p1"}); err != nil {
+		t.Errorf("payment without oneof members rejected: %v", err)
+	}
+	if err := schema.Validate(map[string]any{"id": "p1", "account": "a", "nickname": "x"}); err == nil {
+		t.Error("undeclared property accepted")
+	}
+}
+`
+
+// TestDiscriminatedOneofRuntime verifies that a discriminated union accepts exactly one
+// member tagged with its name and rejects mismatched or untagged members.
+func (s *IntegrationTestSuite) TestDiscriminatedOneofRuntime() {
+	contents := s.RunGenerateWithOptions(plugin.Options{DiscriminatedOneofs: []string{"users.v1.OneOfDemo.field1=kind"}})
+
+	tmpDir := s.TempDir()
+	for name, content := range contents {
+		err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(name)), []byte(content), 0o644)
+		s.Require().NoError(err)
+	}
+
+	stubContent := `package usersv1
+
+type Address struct{}
+type Address_AddressDetails struct{}
+type AddressDetails struct{}
+type ContactInfo struct{}
+type Metadata struct{}
+type ComprehensiveUser struct{}
+type User struct{}
+type CreateUserRequest struct{}
+type GetUserRequest struct{}
+type UpdateUserRequest struct{}
+type DeleteUserRequest struct{}
+type DeleteUserResponse struct{}
+type CreateComprehensiveUserRequest struct{}
+type BatchGetUsersRequest struct{}
+type BatchGetUsersResponse struct{}
+type UserProfile struct{}
+type PersonalProfile struct{}
+type BusinessProfile struct{}
+type RepeatedFieldsDemo struct{}
+type MapFieldsDemo struct{}
+type ConstraintDemo struct{}
+type OneOfDemo struct{}
+type WellKnownTypesDemo struct{}
+type Common struct{}
+type Price struct{}
+type Admin struct{}
+`
+	err := os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte(stubContent), 0o644)
+	s.Require().NoError(err)
+
+	testContent := `package usersv1
+
+import "testing"
+
+func TestDiscriminatedOneof(t *testing.T) {
+	schema, err := (&OneOfDemo{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	for name, field1 := range map[string]any{
+		"string member": map[string]any{"kind": "string_value", "string_value": "a"},
+		"int member":    map[string]any{"kind": "int_value", "int_value": 3},
+	} {
+		if err := schema.Validate(map[string]any{"field1": field1}); err != nil {
+			t.Errorf("%s rejected: %v", name, err)
+		}
+	}
+	if err := schema.Validate(map[string]any{}); err != nil {
+		t.Errorf("unset oneof rejected: %v", err)
+	}
+	for name, field1 := range map[string]any{
+		"mismatched discriminator": map[string]any{"kind": "int_value", "string_value": "a"},
+		"missing discriminator":    map[string]any{"string_value": "a"},
+		"two members":              map[string]any{"kind": "int_value", "int_value": 3, "bool_value": true},
+		"invalid member":           map[string]any{"kind": "int_value", "int_value": "3"},
+	} {
+		if err := schema.Validate(map[string]any{"field1": field1}); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "discriminated_test.go"), []byte(testContent), 0o644)
+	s.Require().NoError(err)
+
+	goModContent := `module testdiscriminated/usersv1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644)
+	s.Require().NoError(err)
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "discriminated oneof runtime tests failed: %s", string(output))
+}
+
+// TestStripEnumPrefixRuntime tests that schemas generated with strip_enum_prefix accept
+// the stripped value names only and that the mapping function returns their numbers.
+func (s *IntegrationTestSuite) TestStripEnumPrefixRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{EnumNames: true, StripEnumPrefix: true}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_jsonschema.pb.go"), []byte(resp.File[0].GetContent()), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import "testing"
+
+func TestStripEnumPrefix(t *testing.T) {
+	schema, err := (&Account{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	account := func(role string) map[string]any {
+		return map[string]any{"id": "1", "version": 1, "tags": []any{}, "role": role, "previous_owners": []any{}}
+	}
+	if err := schema.Validate(account("ADMIN")); err != nil {
+		t.Errorf("stripped name rejected: %v", err)
+	}
+	if err := schema.Validate(account("ROLE_ADMIN")); err == nil {
+		t.Error("prefixed name accepted")
+	}
+	if n, ok := Role_JsonSchemaValue("ADMIN"); !ok || n != 1 {
+		t.Errorf("Role_JsonSchemaValue(ADMIN) = %d, %v; want 1, true", n, ok)
+	}
+	if _, ok := Role_JsonSchemaValue("ROLE_ADMIN"); ok {
+		t.Error("Role_JsonSchemaValue(ROLE_ADMIN) reported a value")
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "strip_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "strip enum prefix runtime tests failed: %s", string(output))
+}
+
+// TestEnumAliasRuntime tests that schemas generated with enum_alias accept the aliases
+// next to the value names and that the normalization function maps them back.
+func (s *IntegrationTestSuite) TestEnumAliasRuntime() {
+	editionsProto := "editions/v1/editions.proto"
+	fds := s.compileProtos("editions.pb", editionsProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{editionsProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{EnumNames: true, EnumAliases: []string{"editions.v1.Account.role=ROLE_ADMIN=admin|root"}}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+
+	tmpDir := s.TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "editions_jsonschema.pb.go"), []byte(resp.File[0].GetContent()), 0o644))
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "stub_types.go"), []byte("package editionsv1\n\ntype Account struct{}\n\ntype Owner struct{}\n"), 0o644))
+
+	testContent := `package editionsv1
+
+import "testing"
+
+func TestEnumAlias(t *testing.T) {
+	schema, err := (&Account{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	account := func(role string) map[string]any {
+		return map[string]any{"id": "1", "version": 1, "tags": []any{}, "role": role, "previous_owners": []any{}}
+	}
+	for _, role := range []string{"ROLE_ADMIN", "admin", "root", "ROLE_UNSPECIFIED"} {
+		if err := schema.Validate(account(role)); err != nil {
+			t.Errorf("%s rejected: %v", role, err)
+		}
+	}
+	if err := schema.Validate(account("superuser")); err == nil {
+		t.Error("unknown alias accepted")
+	}
+	if got := Account_Role_JsonSchemaNormalize("root"); got != "ROLE_ADMIN" {
+		t.Errorf("Account_Role_JsonSchemaNormalize(root) = %q; want ROLE_ADMIN", got)
+	}
+	if got := Account_Role_JsonSchemaNormalize("ROLE_UNSPECIFIED"); got != "ROLE_UNSPECIFIED" {
+		t.Errorf("Account_Role_JsonSchemaNormalize(ROLE_UNSPECIFIED) = %q; want it unchanged", got)
+	}
+}
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "alias_test.go"), []byte(testContent), 0o644))
+
+	goModContent := `module example.com/editions/v1
+
+go 1.21
+
+require github.com/google/jsonschema-go v0.3.0
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goModContent), 0o644))
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = tmpDir
+	output, err := cmd.CombinedOutput()
+	s.Require().NoError(err, "go mod tidy failed: %s", string(output))
+
+	cmd = exec.Command("go", "test", "-count=1", "-timeout", "60s")
+	cmd.Dir = tmpDir
+	output, err = cmd.CombinedOutput()
+	s.Require().NoError(err, "enum alias runtime tests failed: %s", string(output))
+}
+
+// TestFieldBehaviorReadWriteOnly tests that google.api.field_behavior OUTPUT_ONLY and
+// INPUT_ONLY fields are annotated readOnly and writeOnly, and that other behaviors leave
+// the schema unchanged.
+func (s *IntegrationTestSuite) TestFieldBehaviorReadWriteOnly() {
+	libraryProto := "library/v1/library.proto"
+	fds := s.compileProtos("library.pb", libraryProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{libraryProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.Generate(p, "test"))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	s.Regexp(`schema\.Properties\["create_time"\] = &jsonschema\.Schema\{\s+Ref:\s+library_google_protobuf_Timestamp_JsonSchema_WithDefs\(defs\)\.Ref,\s+Title:\s+"",\s+Description:\s+"Time the book was created\.",\s+ReadOnly:\s+true,\s+\}`, content)
+	s.Regexp(`schema\.Properties\["read_count"\] = &jsonschema\.Schema\{\s+Type:\s+"integer",\s+Title:\s+"",\s+Description:\s+"Number of times the book was read\.",\s+ReadOnly:\s+true,`, content)
+	s.Regexp(`schema\.Properties\["request_token"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"Token validating the request, never returned\.",\s+WriteOnly:\s+true,`, content)
+	s.Regexp(`schema\.Properties\["labels"\] = &jsonschema\.Schema\{\s+Type:\s+"array",\s+Title:\s+"",\s+Description:\s+"Labels of the book, kept once set\.",\s+Items:`, content)
+	// The IDENTIFIER name and the resources of Review are readOnly too (see
+	// TestIdentifierFields and TestOutputOnlyResourceFields).
+	s.Equal(5, strings.Count(content, "ReadOnly:"))
+	s.Equal(1, strings.Count(content, "WriteOnly:"))
+}
+
+// TestDeprecatedKeyword tests that fields, messages and enum values marked
+// deprecated = true in proto carry deprecated in their schemas.
+func (s *IntegrationTestSuite) TestDeprecatedKeyword() {
+	libraryProto := "library/v1/library.proto"
+	fds := s.compileProtos("library.pb", libraryProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{libraryProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{EnumNames: true, EnumOneOf: true}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	s.Regexp(`schema\.Properties\["isbn10"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"ISBN-10 of the book, superseded by the ISBN-13\.",\s+Deprecated:\s+true,`, content)
+	s.Regexp(`schema := &jsonschema\.Schema\{\s+Type:\s+"object",\s+Description:\s+"Shelf groups books, replaced by collections\.",\s+Deprecated:\s+true,`, content)
+	s.Contains(content, `{Const: jsonschema.Ptr[any]("CASSETTE"), Description: "Audio cassette edition, no longer published.", Deprecated: true},`)
+	s.Contains(content, `{Const: jsonschema.Ptr[any]("PAPERBACK"), Description: "Paperback edition."},`)
+	s.Equal(3, strings.Count(content, "Deprecated:"))
+}
+
+// TestResourceReferencePatterns tests that string fields annotated with
+// google.api.resource_reference get the name pattern of the referenced resource.
+func (s *IntegrationTestSuite) TestResourceReferencePatterns() {
+	libraryProto := "library/v1/library.proto"
+	fds := s.compileProtos("library.pb", libraryProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{libraryProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.Generate(p, "test"))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	// Message resources with several patterns match any of them.
+	s.Regexp(`schema\.Properties\["name"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"Name of the book to retrieve\.",\s+Pattern:\s+"\^\(publishers/\[\^/\]\+/books/\[\^/\]\+\|books/\[\^/\]\+\)\$",`, content)
+	// File resource definitions apply to the elements of repeated fields.
+	s.Regexp(`schema\.Properties\["publishers"\] = &jsonschema\.Schema\{\s+Type:\s+"array",\s+Title:\s+"",\s+Description:\s+"Publishers of the book\.",\s+Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Pattern:\s+"\^publishers/\[\^/\]\+\$",`, content)
+	// References to any type stay unconstrained.
+	s.Regexp(`schema\.Properties\["related"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"Related resource of any type\.",\s+\}`, content)
+	// The IDENTIFIER name of Book gets its pattern too (see TestIdentifierFields).
+	s.Equal(3, strings.Count(content, "Pattern:"))
+}
+
+// TestIdentifierFields tests that identifier fields, from the google.api.field_behavior
+// IDENTIFIER or the identifier parameter, are readOnly, get the name pattern of their
+// resource and are marked for the input profiles to leave out, and that invalid
+// identifier parameters are rejected.
+func (s *IntegrationTestSuite) TestIdentifierFields() {
+	libraryProto := "library/v1/library.proto"
+	fds := s.compileProtos("library.pb", libraryProto)
+	generate := func(opts plugin.Options) (string, error) {
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{libraryProto}, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		if err := plugin.GenerateWithOptions(p, "test", opts); err != nil {
+			return "", err
+		}
+		resp := p.Response()
+		s.Require().Empty(resp.GetError())
+		s.Require().Len(resp.File, 1)
+		return resp.File[0].GetContent(), nil
+	}
+
+	content, err := generate(plugin.Options{Identifiers: []string{"library.v1.Shelf.name"}})
+	s.Require().NoError(err)
+	s.Regexp(`schema\.Properties\["name"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"Resource name of the book\.",\s+ReadOnly:\s+true,\s+Pattern:\s+"\^\(publishers/\[\^/\]\+/books/\[\^/\]\+\|books/\[\^/\]\+\)\$",\s+Extra:\s+map\[string\]any\{"x-identifier": true\},`, content)
+	// Shelf has no google.api.resource annotation, so its name has no pattern.
+	s.Regexp(`schema\.Properties\["name"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"Resource name of the shelf\.",\s+ReadOnly:\s+true,\s+Extra:\s+map\[string\]any\{"x-identifier": true\},`, content)
+	s.Equal(2, strings.Count(content, `"x-identifier": true`))
+
+	for value, want := range map[string]string{
+		"library.v1.Shelf.missing": "invalid identifier parameter: field library.v1.Shelf.missing not found",
+		"library.v1.Book.format":   "invalid identifier parameter: field library.v1.Book.format is not a singular string field",
+		"library.v1.Book.labels":   "invalid identifier parameter: field library.v1.Book.labels is not a singular string field",
+	} {
+		_, err := generate(plugin.Options{Identifiers: []string{value}})
+		s.Require().Error(err, value)
+		s.Contains(err.Error(), want)
+	}
+}
+
+// TestOutputOnlyResourceFields tests that OUTPUT_ONLY fields holding resources are marked
+// for the input profiles to leave out, and that other fields are not.
+func (s *IntegrationTestSuite) TestOutputOnlyResourceFields() {
+	libraryProto := "library/v1/library.proto"
+	fds := s.compileProtos("library.pb", libraryProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{libraryProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.Generate(p, "test"))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	s.Regexp(`schema\.Properties\["book"\] = &jsonschema\.Schema\{\s+Ref:\s+Book_JsonSchema_WithDefs\(defs\)\.Ref,\s+Title:\s+"",\s+Description:\s+"Book reviewed, resolved by the server\.",\s+ReadOnly:\s+true,\s+Extra:\s+map\[string\]any\{"x-output-only-resource": true\},`, content)
+	s.Regexp(`schema\.Properties\["editions"\] = &jsonschema\.Schema\{\s+Type:\s+"array",[^}]+ReadOnly:\s+true,\s+Items:\s+Book_JsonSchema_WithDefs\(defs\),\s+Extra:\s+map\[string\]any\{"x-output-only-resource": true\},`, content)
+	// OUTPUT_ONLY fields holding other messages, such as create_time, stay in the inputs.
+	s.Equal(2, strings.Count(content, "x-output-only-resource"))
+}
+
+// TestResourceTitles tests that resource_titles titles resource message schemas after
+// their google.api.resource type and records it, leaving other messages unchanged.
+func (s *IntegrationTestSuite) TestResourceTitles() {
+	libraryProto := "library/v1/library.proto"
+	fds := s.compileProtos("library.pb", libraryProto)
+
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{libraryProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{ResourceTitles: true}))
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	content := resp.File[0].GetContent()
+
+	s.Regexp(`schema := &jsonschema\.Schema\{\s+Type:\s+"object",\s+Title:\s+"Book",\s+Description:\s+"Book is a resource annotated with google\.api field behaviors\.",`, content)
+	s.Contains(content, `Extra: map[string]any{"x-resource-type": "library.googleapis.com/Book"},`)
+	s.Contains(content, `Extra: map[string]any{"x-resource-type": "library.googleapis.com/Review"},`)
+	s.Equal(2, strings.Count(content, "x-resource-type"))
+	s.Regexp(`schema := &jsonschema\.Schema\{\s+Type:\s+"object",\s+Description:\s+"Shelf groups books, replaced by collections\.",`, content)
+}
+
+// TestFixtureAreas tests that the generated code of every fixture area compiles and that
+// its message schemas resolve, with the default options and with options changing the
+// representation of enums, optional fields and well-known types.
+func (s *IntegrationTestSuite) TestFixtureAreas() {
+	for _, area := range fixtureAreas {
+		s.Run(area, func() {
+			s.RunFixtureModule(area, s.GenerateFixture(area, plugin.Options{}), nil)
+		})
+		s.Run(area+" with options", func() {
+			opts := plugin.Options{EnumNames: true, EnumOneOf: true, NullableOptional: true, WellKnownTypes: "protojson"}
+			s.RunFixtureModule(area, s.GenerateFixture(area, opts), nil)
+		})
+	}
+}
+
+// TestCachedAccessorsRuntime tests that the cached schemas of every fixture area, whose
+// definitions are built one at a time and shared, marshal like the schemas JsonSchema()
+// builds, with a ref-as-root and an object root, and that building them leaves the
+// shared definitions unchanged.
+func (s *IntegrationTestSuite) TestCachedAccessorsRuntime() {
+	for _, area := range fixtureAreas {
+		for name, opts := range map[string]plugin.Options{
+			area:                 {CachedAccessors: true},
+			area + " objectroot": {CachedAccessors: true, ObjectRoot: true, EnumNames: true, WellKnownTypes: "protojson"},
+		} {
+			s.Run(name, func() {
+				contents := s.GenerateFixture(area, opts)
+				var pkg string
+				var receivers []string
+				for _, content := range contents {
+					if m := packageClause.FindStringSubmatch(content); m != nil {
+						pkg = m[1]
+					}
+					for _, m := range accessorReceiver.FindAllStringSubmatch(content, -1) {
+						receivers = append(receivers, m[1])
+					}
+				}
+				sort.Strings(receivers)
+				var b strings.Builder
+				for _, receiver := range receivers {
+					fmt.Fprintf(&b, "\t\t%q: {(&%s{}).JsonSchema, (&%s{}).JsonSchemaCached},\n", receiver, receiver, receiver)
+				}
+				test := strings.NewReplacer("PACKAGE", pkg, "ACCESSORS", b.String()).Replace(cachedAccessorsTest)
+				s.RunFixtureModule(area, contents, map[string]string{"cached_test.go": test})
+			})
+		}
+	}
+}
+
+// cachedAccessorsTest compares the cached schemas of a fixture package with those of
+// JsonSchema(); PACKAGE and ACCESSORS are replaced with the package name and the pairs of
+// accessors of each message.
+const cachedAccessorsTest = `package PACKAGE
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestCachedSchemas(t *testing.T) {
+	accessors := map[string][2]func() *jsonschema.Schema{
+ACCESSORS	}
+	for pass := 0; pass < 2; pass++ {
+		for name, pair := range accessors {
+			want, err := json.Marshal(pair[0]())
+			if err != nil {
+				t.Fatalf("%s: Marshal failed: %v", name, err)
+			}
+			got, err := json.Marshal(pair[1]())
+			if err != nil {
+				t.Fatalf("%s: Marshal of the cached schema failed: %v", name, err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s: cached schema differs (pass %d):\ngot  %s\nwant %s", name, pass, got, want)
+			}
+		}
+	}
+}
+`
+
+// TestProtovalidateRules tests that buf.validate.field rules are translated into JSON
+// Schema keywords, that json_schema options take precedence, and that the resulting
+// schema accepts and rejects instances as protovalidate would.
+func (s *IntegrationTestSuite) TestProtovalidateRules() {
+	contents := s.GenerateFixture("validate", plugin.Options{})
+	content := contents["example.com/fixtures/validate/v1/validate_jsonschema.pb.go"]
+	s.Require().NotEmpty(content)
+
+	// String, numeric, repeated and map rules become the matching keywords; the nested
+	// items and values rules apply to the element schemas.
+	s.Regexp(`schema\.Properties\["handle"\] = &jsonschema\.Schema\{[^}]+Pattern:\s+"\^\[a-z0-9_\]\+\$",\s+MinLength:\s+&\[\]int\{3\}\[0\],\s+MaxLength:\s+&\[\]int\{32\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["email"\] = &jsonschema\.Schema\{[^}]+Format:\s+"email",`, content)
+	s.Regexp(`schema\.Properties\["home_page"\] = &jsonschema\.Schema\{[^}]+Format:\s+"uri",`, content)
+	s.Regexp(`schema\.Properties\["country"\] = &jsonschema\.Schema\{[^}]+MinLength:\s+&\[\]int\{2\}\[0\],\s+MaxLength:\s+&\[\]int\{2\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["plan"\] = &jsonschema\.Schema\{[^}]+Enum: \[\]any\{\s+"free",\s+"pro",`, content)
+	s.Regexp(`schema\.Properties\["age"\] = &jsonschema\.Schema\{[^}]+Minimum:\s+&\[\]float64\{0\}\[0\],\s+ExclusiveMaximum: &\[\]float64\{150\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["balance"\] = &jsonschema\.Schema\{[^}]+ExclusiveMinimum: &\[\]float64\{-1000\.5\}\[0\],\s+Maximum:\s+&\[\]float64\{1e\+06\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["offset"\] = &jsonschema\.Schema\{[^}]+Minimum:\s+&\[\]float64\{-10\}\[0\],\s+Maximum:\s+&\[\]float64\{10\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["tags"\] = &jsonschema\.Schema\{[^}]+MinItems:\s+&\[\]int\{1\}\[0\],\s+MaxItems:\s+&\[\]int\{5\}\[0\],\s+UniqueItems: true,\s+Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+MinLength: &\[\]int\{1\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["labels"\] = &jsonschema\.Schema\{[^}]+MinProperties: &\[\]int\{1\}\[0\],\s+MaxProperties: &\[\]int\{8\}\[0\],\s+AdditionalProperties: &jsonschema\.Schema\{\s+Type:\s+"string",\s+MaxLength: &\[\]int\{64\}\[0\],`, content)
+
+	// required makes optional fields required and repeated fields non-empty.
+	s.Regexp(`Required: \[\]string\{[^}]+"nickname",`, content)
+	s.Regexp(`schema\.Properties\["aliases"\] = &jsonschema\.Schema\{[^}]+MinItems:\s+&\[\]int\{1\}\[0\],`, content)
+
+	// Ignored rules are not translated, and json_schema options take precedence.
+	s.Regexp(`schema\.Properties\["title"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description: "The account's title; rules are ignored\.",\s+\}`, content)
+	s.Regexp(`schema\.Properties\["display_name"\] = &jsonschema\.Schema\{[^}]+MaxLength:\s+&\[\]int\{50\}\[0\],\s+\}`, content)
+
+	s.RunFixtureModule("validate", contents, map[string]string{"validate_test.go": protovalidateRulesTest})
+}
+
+// TestPGVRules tests that legacy validate.rules (protoc-gen-validate) rules are translated
+// like protovalidate rules, and that protovalidate rules take precedence when a field has
+// both.
+func (s *IntegrationTestSuite) TestPGVRules() {
+	contents := s.GenerateFixture("validate", plugin.Options{})
+	content := contents["example.com/fixtures/validate/v1/pgv_jsonschema.pb.go"]
+	s.Require().NotEmpty(content)
+
+	s.Regexp(`schema\.Properties\["slug"\] = &jsonschema\.Schema\{[^}]+Pattern:\s+"\^\[a-z-\]\+\$",\s+MinLength:\s+&\[\]int\{2\}\[0\],\s+MaxLength:\s+&\[\]int\{40\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["website"\] = &jsonschema\.Schema\{[^}]+Format:\s+"uri",`, content)
+	s.Regexp(`schema\.Properties\["rating"\] = &jsonschema\.Schema\{[^}]+Minimum:\s+&\[\]float64\{0\}\[0\],\s+Maximum:\s+&\[\]float64\{5\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["visits"\] = &jsonschema\.Schema\{[^}]+ExclusiveMinimum: &\[\]float64\{0\}\[0\],`, content)
+	s.Regexp(`schema\.Properties\["topics"\] = &jsonschema\.Schema\{[^}]+MaxItems:\s+&\[\]int\{3\}\[0\],\s+Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Enum: \[\]any\{\s+"go",\s+"proto",`, content)
+	// message.required makes the optional message field required.
+	s.Regexp(`Required: \[\]string\{[^}]+"owner",`, content)
+	s.Regexp(`schema\.Properties\["motto"\] = &jsonschema\.Schema\{[^}]+MaxLength:\s+&\[\]int\{20\}\[0\],\s+\}`, content)
+}
+
+// TestProtovalidateConst tests that protovalidate const rules fix fields to their value
+// with the const keyword, as enum value names with enum_names, and that nullable
+// fields also accept null.
+func (s *IntegrationTestSuite) TestProtovalidateConst() {
+	const file = "example.com/fixtures/validate/v1/validate_jsonschema.pb.go"
+	content := s.GenerateFixture("validate", plugin.Options{})[file]
+	s.Require().NotEmpty(content)
+	hasConst := func(content, field, literal string) {
+		s.Regexp(`schema\.Properties\["`+field+`"\] = &jsonschema\.Schema\{[^}]+Const:\s+jsonschema\.Ptr\[any\]\(`+regexp.QuoteMeta(literal)+`\),`, content)
+	}
+	hasConst(content, "type", `"envelope"`)
+	hasConst(content, "version", "-2")
+	// Float consts keep their float32 value.
+	hasConst(content, "ratio", "0.1")
+	hasConst(content, "signed", "true")
+	hasConst(content, "kind", "1")
+	hasConst(content, "channel", `"default"`)
+	s.Regexp(`schema\.Properties\["markers"\] = &jsonschema\.Schema\{[^}]+Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Const:\s+jsonschema\.Ptr\[any\]\("x"\),`, content)
+
+	named := s.GenerateFixture("validate", plugin.Options{EnumNames: true, NullableOptional: true})[file]
+	hasConst(named, "kind", `"KIND_PERSONAL"`)
+	s.Regexp(`"The envelope's channel, if any\.",\s+Enum:\s+\[\]any\{"default", nil\},`, named)
+	s.NotRegexp(`"The envelope's channel, if any\.",\s+Const:`, named)
+
+	if testing.Short() {
+		return
+	}
+	s.RunFixtureModule("validate", s.GenerateFixture("validate", plugin.Options{}), map[string]string{"const_test.go": protovalidateConstTest})
+}
+
+// TestProtovalidateEnumSubset tests that protovalidate enum in and not_in rules restrict
+// enum fields to a subset of their values next to the shared enum definition, as numbers
+// or as value names, and that nullable fields also accept null.
+func (s *IntegrationTestSuite) TestProtovalidateEnumSubset() {
+	const file = "example.com/fixtures/validate/v1/validate_jsonschema.pb.go"
+	content := s.GenerateFixture("validate", plugin.Options{})[file]
+	s.Require().NotEmpty(content)
+	s.Regexp(`"The state to move the account to\.",\s+Ref:\s+State_JsonSchema_WithDefs\(defs\)\.Ref,\s+Enum:\s+\[\]any\{1, 2\},`, content)
+	s.Regexp(`Items: &jsonschema\.Schema\{\s+Type:\s+"integer",\s+Ref:\s+State_JsonSchema_WithDefs\(defs\)\.Ref,\s+Enum:\s+\[\]any\{1, 2\},`, content)
+
+	named := s.GenerateFixture("validate", plugin.Options{EnumNames: true, NullableOptional: true})[file]
+	s.Contains(named, `Enum:        []any{"STATE_ACTIVE", "STATE_SUSPENDED"},`)
+	s.Regexp(`"The state to restore on expiry, if any\.",\s+AnyOf:\s+\[\]\*jsonschema\.Schema\{\{Ref: State_JsonSchema_WithDefs\(defs\)\.Ref\}, \{Type: "null"\}\},\s+Enum:\s+\[\]any\{"STATE_ACTIVE", nil\},`, named)
+
+	if testing.Short() {
+		return
+	}
+	s.RunFixtureModule("validate", s.GenerateFixture("validate", plugin.Options{}), map[string]string{"enum_subset_test.go": protovalidateEnumSubsetTest})
+}
+
+// protovalidateEnumSubsetTest validates instances against the validate fixture's
+// StateChange schema.
+const protovalidateEnumSubsetTest = `package validatev1
+
+import "testing"
+
+func TestStateChangeEnumSubset(t *testing.T) {
+	resolved, err := (&StateChange{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instance := range []map[string]any{
+		{"target": 1, "from": []any{1, 2}, "restore": 1},
+		{"target": 2},
+	} {
+		if err := resolved.Validate(instance); err != nil {
+			t.Errorf("%v rejected: %v", instance, err)
+		}
+	}
+	for _, instance := range []map[string]any{
+		{"target": 3},
+		{"target": 0},
+		{"target": 1, "from": []any{1, 3}},
+		{"target": 1, "restore": 2},
+	} {
+		if err := resolved.Validate(instance); err == nil {
+			t.Errorf("%v accepted", instance)
+		}
+	}
+}
+`
+
+// protovalidateConstTest validates instances against the validate fixture's Envelope
+// schema.
+const protovalidateConstTest = `package validatev1
+
+import "testing"
+
+func TestEnvelopeConst(t *testing.T) {
+	resolved, err := (&Envelope{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() map[string]any {
+		return map[string]any{
+			"type": "envelope", "version": -2, "ratio": 0.1, "signed": true, "kind": 1,
+			"channel": "default", "markers": []any{"x", "x"},
+		}
+	}
+	if err := resolved.Validate(valid()); err != nil {
+		t.Errorf("valid envelope rejected: %v", err)
+	}
+	invalid := map[string]any{
+		"type": "letter", "version": 2, "signed": false, "kind": 0, "channel": "other",
+		"markers": []any{"x", "y"},
+	}
+	for key, value := range invalid {
+		instance := valid()
+		instance[key] = value
+		if err := resolved.Validate(instance); err == nil {
+			t.Errorf("%s=%v accepted", key, value)
+		}
+	}
+}
+`
+
+// protovalidateRulesTest validates instances against the validate fixture's Account
+// schema.
+const protovalidateRulesTest = `package validatev1
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAccountRules(t *testing.T) {
+	resolved, err := (&Account{}).JsonSchema().Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]any{
+		"handle": "ada_l", "email": "ada@example.com", "home_page": "https://example.com",
+		"country": "GB", "plan": "pro", "age": 36, "balance": 12.5, "offset": -3,
+		"tags": []any{"math"}, "labels": map[string]any{"team": "engines"}, "kind": 1,
+		"nickname": "ada", "aliases": []any{"countess"}, "title": "", "display_name": "Ada",
+	}
+	instance := func(key string, value any) map[string]any {
+		m := make(map[string]any, len(valid))
+		for k, v := range valid {
+			m[k] = v
+		}
+		if value == nil {
+			delete(m, key)
+		} else {
+			m[key] = value
+		}
+		var out map[string]any
+		b, _ := json.Marshal(m)
+		_ = json.Unmarshal(b, &out)
+		return out
+	}
+	if err := resolved.Validate(instance("", nil)); err != nil {
+		t.Errorf("valid account rejected: %v", err)
+	}
+	invalid := map[string]any{
+		"handle":  "Ad",
+		"country": "GBR",
+		"plan":    "enterprise",
+		"age":     150,
+		"balance": -1000.5,
+		"tags":    []any{"a", "a"},
+		"labels":  map[string]any{},
+		"aliases": []any{},
+	}
+	for key, value := range invalid {
+		if err := resolved.Validate(instance(key, value)); err == nil {
+			t.Errorf("%s=%v accepted", key, value)
+		}
+	}
+	if err := resolved.Validate(instance("nickname", nil)); err == nil {
+		t.Error("account without nickname accepted")
+	}
+}
+`
+
+// TestFieldDefaults tests that explicit proto2 default values become the default keyword,
+// encoded like the field's values, and that the defaults validate against their schemas.
+func (s *IntegrationTestSuite) TestFieldDefaults() {
+	contents := s.GenerateFixture("options", plugin.Options{})
+	content := contents["example.com/fixtures/options/v1/defaults_jsonschema.pb.go"]
+	s.Require().NotEmpty(content)
+
+	hasDefault := func(content, field, value string) {
+		s.Regexp(`schema\.Properties\["`+field+`"\] = &jsonschema\.Schema\{[^}]+Default:\s+json\.RawMessage\(`+regexp.QuoteMeta("`"+value+"`")+`\),`, content)
+	}
+	hasDefault(content, "name", `"untitled \"draft\""`)
+	hasDefault(content, "page_size", "25")
+	hasDefault(content, "timeout", "2.5")
+	// Float defaults keep their float32 value.
+	hasDefault(content, "ratio", "0.1")
+	hasDefault(content, "cached", "true")
+	hasDefault(content, "level", "1")
+	hasDefault(content, "salt", `"YWI="`)
+	// Infinite floats have no JSON number, and fields without a default get none.
+	s.NotRegexp(`schema\.Properties\["(max_delay|owner)"\] = &jsonschema\.Schema\{[^}]+Default:`, content)
+
+	named := s.GenerateFixture("options", plugin.Options{EnumNames: true, StripEnumPrefix: true})
+	hasDefault(named["example.com/fixtures/options/v1/defaults_jsonschema.pb.go"], "level", `"INFO"`)
+
+	if testing.Short() {
+		return
+	}
+	s.RunFixtureModule("options", contents, map[string]string{"defaults_test.go": fieldDefaultsTest})
+	s.RunFixtureModule("options", named, map[string]string{"defaults_test.go": fieldDefaultsTest})
+}
+
+// fieldDefaultsTest resolves the options fixture's Settings schema with its defaults
+// validated.
+const fieldDefaultsTest = `package optionsv1
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+func TestSettingsDefaults(t *testing.T) {
+	schema := (&Settings{}).JsonSchema()
+	if _, err := schema.Resolve(&jsonschema.ResolveOptions{ValidateDefaults: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+`
+
+// TestVerifyFixtures tests that verify accepts the schemas generated for every fixture
+// area and for chunked property helpers.
+func (s *IntegrationTestSuite) TestVerifyFixtures() {
+	for _, area := range fixtureAreas {
+		s.Run(area, func() {
+			s.NotEmpty(s.GenerateFixture(area, plugin.Options{Verify: true}))
+			s.NotEmpty(s.GenerateFixture(area, plugin.Options{Verify: true, EnumNames: true, EnumOneOf: true, NullableOptional: true, WellKnownTypes: "protojson"}))
+		})
+	}
+
+	largeProto := "large/v1/large.proto"
+	fds := s.compileProtos("large.pb", largeProto)
+	p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{largeProto}, ProtoFile: fds.File})
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{Verify: true}))
+	s.Empty(p.Response().GetError())
+}
@@ -0,0 +1,96 @@
+//go:build plugintest
+
+package plugintest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/jsonschematest"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/stretchr/testify/suite"
+)
+
+// JSONSchemaTestTestSuite contains tests for the jsonschematest golden helper package.
+type JSONSchemaTestTestSuite struct {
+	suite.Suite
+}
+
+// TestJSONSchemaTestSuite runs the JSONSchemaTestTestSuite.
+func TestJSONSchemaTestSuite(t *testing.T) {
+	suite.Run(t, new(JSONSchemaTestTestSuite))
+}
+
+// TestNormalize tests that generation timestamps and plugin versions are stripped.
+func (s *JSONSchemaTestTestSuite) TestNormalize() {
+	content := "// Code generated by protoc-gen-go-jsonschema. DO NOT EDIT.\n" +
+		"// Generated on: 2025-01-02 03:04:05 UTC\n" +
+		"// Plugin version: v1.2.3\n" +
+		"package userv1\n"
+
+	s.Equal("// Code generated by protoc-gen-go-jsonschema. DO NOT EDIT.\npackage userv1\n", jsonschematest.Normalize(content))
+	s.Equal(jsonschematest.Normalize(content),
+		jsonschematest.Normalize("// Code generated by protoc-gen-go-jsonschema. DO NOT EDIT.\n"+
+			"// Generated on: 2026-10-17 00:00:00 UTC\n"+
+			"// Plugin version: (devel)\n"+
+			"package userv1\n"))
+}
+
+// TestNormalizeMarkers tests that the version and descriptor hash marker constants are stripped.
+func (s *JSONSchemaTestTestSuite) TestNormalizeMarkers() {
+	content := "package userv1\n" +
+		"const JsonSchemaGenVersion_users_v1_user_proto = \"v1.2.3\"\n" +
+		"const JsonSchemaDescriptorHash_users_v1_user_proto = \"0123abcd\"\n"
+
+	s.Equal("package userv1\n", jsonschematest.Normalize(content))
+}
+
+// TestAssertGolden tests writing a golden file and comparing against it.
+func (s *JSONSchemaTestTestSuite) TestAssertGolden() {
+	goldenPath := filepath.Join(s.T().TempDir(), "nested", "user.go.golden")
+
+	s.Run("update creates the file", func() {
+		jsonschematest.AssertGolden(s.T(), "// Generated on: then\npackage userv1\n", goldenPath, true)
+		written, err := os.ReadFile(goldenPath)
+		s.Require().NoError(err)
+		s.Equal("// Generated on: then\npackage userv1\n", string(written))
+	})
+
+	s.Run("volatile lines are ignored", func() {
+		jsonschematest.AssertGolden(s.T(), "// Generated on: now\npackage userv1\n", goldenPath, false)
+	})
+}
+
+// TestSchemaJSON tests that the golden form of a schema is canonical and indented.
+func (s *JSONSchemaTestTestSuite) TestSchemaJSON() {
+	schema := &jsonschema.Schema{
+		Type:    "object",
+		Comment: "generated",
+		Properties: map[string]*jsonschema.Schema{
+			"name": {Type: "string"},
+			"id":   {Ref: "#/definitions/users.v1.Id"},
+		},
+	}
+
+	got, err := jsonschematest.SchemaJSON(schema)
+	s.Require().NoError(err)
+	s.Equal(`{
+  "properties": {
+    "id": {
+      "$ref": "#/$defs/users.v1.Id"
+    },
+    "name": {
+      "type": "string"
+    }
+  },
+  "type": "object"
+}
+`, got)
+
+	goldenPath := filepath.Join(s.T().TempDir(), "user.schema.json")
+	jsonschematest.AssertSchemaGolden(s.T(), schema, goldenPath, true)
+	written, err := os.ReadFile(goldenPath)
+	s.Require().NoError(err)
+	s.Equal(got, string(written))
+}
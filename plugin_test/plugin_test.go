@@ -3,13 +3,23 @@
 package plugintest
 
 import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	optionsPb "open.alis.services/protobuf/alis/open/options/v1"
 )
 
 // PluginGeneratorTestSuite contains tests for the Generator and Generate function.
@@ -291,6 +301,8 @@ func (s *PluginGeneratorTestSuite) TestGoogleTypesHandling() {
 func (s *PluginGeneratorTestSuite) TestBytesFieldHandling() {
 	content := s.GetGeneratedContent()
 	s.Contains(content, `ContentEncoding: "base64"`, "Expected base64 content encoding for bytes fields")
+	s.Regexp(`Pattern:\s+"\^\[A-Za-z0-9_-\]\*=\{0,2\}\$",\s+ContentEncoding:\s+"base64url",`, content, "Expected base64url pattern for bytes fields")
+	s.Regexp(`Pattern:\s+"\^\(\[0-9a-fA-F\]\{2\}\)\*\$",\s+ContentEncoding:\s+"hex",`, content, "Expected hex pattern for bytes fields")
 }
 
 // TestInt64FieldHandling tests int64 field handling.
@@ -298,3 +310,1706 @@ func (s *PluginGeneratorTestSuite) TestInt64FieldHandling() {
 	content := s.GetGeneratedContent()
 	s.Contains(content, `^-?[0-9]+$`, "Expected numeric string pattern for int64 fields")
 }
+
+// TestSharedEnumDefinitions tests that enums are emitted once as shared definitions
+// by their declaring file and referenced via $ref from every other file in the package.
+func (s *PluginGeneratorTestSuite) TestSharedEnumDefinitions() {
+	contents := s.RunGenerate()
+
+	var userContent, commonContent, adminContent string
+	for name, content := range contents {
+		switch {
+		case strings.HasSuffix(name, "user_jsonschema.pb.go"):
+			userContent = content
+		case strings.HasSuffix(name, "common_jsonschema.pb.go"):
+			commonContent = content
+		case strings.HasSuffix(name, "admin_jsonschema.pb.go"):
+			adminContent = content
+		}
+	}
+	s.Require().NotEmpty(userContent, "Expected user_jsonschema.pb.go")
+	s.Require().NotEmpty(commonContent, "Expected common_jsonschema.pb.go")
+	s.Require().NotEmpty(adminContent, "Expected admin_jsonschema.pb.go")
+
+	s.Run("enum helper emitted once by declaring file", func() {
+		definition := "func Region_JsonSchema_WithDefs(defs map[string]*jsonschema.Schema) *jsonschema.Schema {"
+		s.Contains(commonContent, definition, "common.proto declares Region and should emit its helper")
+		s.NotContains(adminContent, definition, "admin.proto should not duplicate the Region helper")
+		s.NotContains(userContent, definition, "user.proto should not emit the Region helper")
+		s.Contains(commonContent, `defs["users.v1.Region"] = &jsonschema.Schema{`)
+	})
+
+	s.Run("sibling file references shared enum", func() {
+		s.Contains(adminContent, "Region_JsonSchema_WithDefs(defs).Ref,", "admin.proto should reference the shared Region definition")
+		s.NotContains(adminContent, `defs["users.v1.Region"] =`, "admin.proto should not inline the Region definition")
+	})
+
+	s.Run("declaring file references its own enums", func() {
+		s.Contains(userContent, "func UserStatus_JsonSchema_WithDefs(defs map[string]*jsonschema.Schema) *jsonschema.Schema {")
+		s.Equal(1, strings.Count(userContent, `defs["users.v1.UserStatus"] = &jsonschema.Schema{`), "UserStatus enum values should be emitted once")
+		s.Contains(userContent, "UserStatus_JsonSchema_WithDefs(defs).Ref,")
+	})
+}
+
+// TestGoogleHelpersEmittedOncePerPackage tests that files sharing a go_package define
+// each Google type helper exactly once and reference the owning file's helper.
+func (s *PluginGeneratorTestSuite) TestGoogleHelpersEmittedOncePerPackage() {
+	contents := s.RunGenerate()
+
+	definitions := make(map[string]int)
+	var userContent string
+	for name, content := range contents {
+		if !strings.HasPrefix(name, "github.com/newtonnthiga/users/v1/") {
+			continue
+		}
+		if strings.HasSuffix(name, "user_jsonschema.pb.go") {
+			userContent = content
+		}
+		for _, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, "func ") && strings.Contains(line, "_google_") {
+				definitions[strings.TrimPrefix(strings.SplitN(line, "(", 2)[0], "func ")]++
+			}
+		}
+	}
+	s.Require().NotEmpty(definitions, "Expected Google type helpers in users/v1")
+	for name, count := range definitions {
+		s.Equal(1, count, "%s should be defined once in the package", name)
+	}
+
+	s.Contains(definitions, "common_google_protobuf_Timestamp_JsonSchema_WithDefs", "common.proto is the first file referencing Timestamp")
+	s.NotContains(definitions, "user_google_protobuf_Timestamp_JsonSchema_WithDefs")
+	s.NotContains(definitions, "admin_google_protobuf_Timestamp_JsonSchema_WithDefs")
+	s.Contains(userContent, "common_google_protobuf_Timestamp_JsonSchema_WithDefs(defs)", "user.proto should reference the package's Timestamp helper")
+}
+
+// TestMCPInputSchemaAccessor tests that regular messages get an MCPInputSchema accessor
+// with an object-typed root, and that Google types do not.
+func (s *PluginGeneratorTestSuite) TestMCPInputSchemaAccessor() {
+	content := s.GetGeneratedContent()
+	s.Contains(content, "func (x *User) MCPInputSchema() *jsonschema.Schema {")
+	s.Contains(content, `root := defs["users.v1.User"].CloneSchemas()`)
+	s.NotContains(content, "google_protobuf_Timestamp_MCPInputSchema", "Google types should not get an MCPInputSchema function")
+}
+
+// TestEnumValuesHelper tests that shared enums get a <Enum>_JsonSchemaEnum helper
+// listing value/name/description triples.
+func (s *PluginGeneratorTestSuite) TestEnumValuesHelper() {
+	content := s.GetGeneratedContent()
+	s.Contains(content, "func UserStatus_JsonSchemaEnum() []struct {")
+	s.Contains(content, `{Value: 1, Name: "USER_STATUS_ACTIVE", Description: "User account is active and can be used normally."},`)
+	s.NotContains(content, "google_protobuf_NullValue_JsonSchemaEnum", "Google enums should not get a values helper")
+}
+
+// TestDurationSecondsOption tests that duration_seconds renders every Duration field, or
+// the listed ones, as numbers, drops the Duration schema function once no field uses it,
+// and rejects fields that are missing or not Duration fields.
+func (s *PluginGeneratorTestSuite) TestDurationSecondsOption() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("all fields", func() {
+		contents := s.RunGenerateWithOptions(plugin.Options{DurationSeconds: []string{"true"}})
+		content := contents[userFile]
+		s.Require().NotEmpty(content)
+
+		s.Regexp(`schema\.Properties\["time_duration"\] = &jsonschema\.Schema\{\s+Type:\s+"number",`, content)
+		s.Regexp(`schema\.Properties\["session_duration"\] = &jsonschema\.Schema\{\s+Type:\s+"number",`, content)
+		s.NotContains(content, "google_protobuf_Duration_JsonSchema", "Duration schema function should not be generated when inlined")
+		s.Contains(content, "common_google_protobuf_Timestamp_JsonSchema_WithDefs(defs)", "Other Google types should still be referenced")
+	})
+
+	s.Run("listed fields", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{DurationSeconds: []string{"users.v1.WellKnownTypesDemo.time_duration"}})[userFile]
+		s.Require().NotEmpty(content)
+
+		s.Regexp(`schema\.Properties\["time_duration"\] = &jsonschema\.Schema\{\s+Type:\s+"number",`, content)
+		s.Contains(content, `schema.Properties["session_duration"] = user_google_protobuf_Duration_JsonSchema_WithDefs(defs)`,
+			"Duration fields not listed should keep the Duration schema")
+	})
+
+	s.Run("invalid fields", func() {
+		for value, want := range map[string]string{
+			"users.v1.WellKnownTypesDemo.missing":    "field users.v1.WellKnownTypesDemo.missing not found",
+			"users.v1.WellKnownTypesDemo.created_at": "field users.v1.WellKnownTypesDemo.created_at is not a google.protobuf.Duration field",
+			"users.v1.ComprehensiveUser.name":        "field users.v1.ComprehensiveUser.name is not a google.protobuf.Duration field",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{DurationSeconds: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestWellKnownTypesParameter tests that well_known_types=protojson renders Timestamp,
+// Duration and FieldMask fields as their protojson strings and wrapper fields as nullable
+// primitives, drops the unused schema functions, and that invalid values and
+// duration_seconds are rejected.
+func (s *PluginGeneratorTestSuite) TestWellKnownTypesParameter() {
+	s.Run("encoding_json keeps message schemas", func() {
+		contents := s.RunGenerateWithOptions(plugin.Options{WellKnownTypes: "encoding_json"})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Contains(content, `schema.Properties["created_at"] = common_google_protobuf_Timestamp_JsonSchema_WithDefs(defs)`)
+		s.Contains(content, `schema.Properties["update_mask"] = user_google_protobuf_FieldMask_JsonSchema_WithDefs(defs)`)
+	})
+
+	s.Run("protojson maps to strings", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{WellKnownTypes: "protojson"})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+
+		s.Regexp(`schema\.Properties\["created_at"\] = &jsonschema\.Schema\{\s+Type:\s+"string",\s+Title:\s+"",\s+Description:\s+"Timestamp representing creation time\.",\s+Format:\s+"date-time",`, content)
+		s.Regexp(`schema\.Properties\["time_duration"\] = &jsonschema\.Schema\{\s+Type:\s+"string",[^}]+Pattern:\s+"\^-\?\[0-9\]\+\(\\\\\.\[0-9\]\{1,9\}\)\?s\$",`, content)
+		s.Regexp(`schema\.Properties\["update_mask"\] = &jsonschema\.Schema\{\s+Type:\s+"string",[^}]+Pattern:\s+"\^\(\[a-z\]`, content)
+		s.Regexp(`schema\.Properties\["timestamps"\] = &jsonschema\.Schema\{\s+Type:\s+"array",[^}]+Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Format:\s+"date-time",`, content)
+		s.Regexp(`schema\.Properties\["timestamp_map"\] = &jsonschema\.Schema\{\s+Type:\s+"object",[^}]+AdditionalProperties: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Format:\s+"date-time",`, content)
+		for _, name := range []string{"Timestamp", "Duration", "FieldMask"} {
+			s.NotContains(content, "google_protobuf_"+name+"_JsonSchema", "%s schema function should not be generated when inlined", name)
+		}
+		s.Contains(content, "user_google_protobuf_Any_JsonSchema_WithDefs(defs)", "Other Google types should still be referenced")
+
+		admin := contents["github.com/newtonnthiga/users/v1/admin_jsonschema.pb.go"]
+		s.Require().NotEmpty(admin)
+		s.Regexp(`schema\.Properties\["optional_string_value"\] = &jsonschema\.Schema\{\s+Types:\s+\[\]string\{"string", "null"\},`, admin)
+		s.Regexp(`schema\.Properties\["optional_int64_value"\] = &jsonschema\.Schema\{\s+Types:\s+\[\]string\{"integer", "string", "null"\},[^}]+Pattern:\s+"\^-\?\[0-9\]\+\$",`, admin)
+		s.Regexp(`schema\.Properties\["optional_bytes_value"\] = &jsonschema\.Schema\{\s+Types:\s+\[\]string\{"string", "null"\},[^}]+ContentEncoding:\s+"base64",`, admin)
+		s.NotContains(admin, "google_protobuf_StringValue_JsonSchema", "Wrapper schema functions should not be generated when inlined")
+	})
+
+	s.Run("invalid values are rejected", func() {
+		for _, opts := range []plugin.Options{
+			{WellKnownTypes: "jsonpb"},
+			{WellKnownTypes: "protojson", DurationSeconds: []string{"true"}},
+			{WellKnownTypes: "protojson", DurationSeconds: []string{"users.v1.WellKnownTypesDemo.time_duration"}},
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", opts)
+			s.Require().Error(err)
+			s.Contains(err.Error(), "invalid well_known_types parameter")
+		}
+	})
+}
+
+// TestTimestampBoundsParameter tests that timestamp_utc adds a UTC pattern to Timestamp
+// strings, that timestamp_bounds emits x-formatMinimum and x-formatMaximum on singular
+// fields and on the elements and values of containers, and that invalid values and
+// fields, and both parameters without well_known_types=protojson, are rejected.
+func (s *PluginGeneratorTestSuite) TestTimestampBoundsParameter() {
+	s.Run("utc pattern", func() {
+		contents := s.RunGenerateWithOptions(plugin.Options{WellKnownTypes: "protojson", TimestampUTC: true})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Regexp(`schema\.Properties\["created_at"\] = &jsonschema\.Schema\{[^}]+Format:\s+"date-time",\s+Pattern:\s+"\^\[0-9\]\{4\}-[^"]+Z\$",`, content)
+		s.Regexp(`schema\.Properties\["timestamps"\] = &jsonschema\.Schema\{[^}]+Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Format:\s+"date-time",\s+Pattern:`, content)
+	})
+
+	s.Run("bounds", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{WellKnownTypes: "protojson", TimestampBounds: []string{
+			"users.v1.WellKnownTypesDemo.created_at=1900-01-01..2100-01-01T12:00:00+02:00",
+			"users.v1.WellKnownTypesDemo.timestamps=..2100-01-01",
+			"users.v1.WellKnownTypesDemo.timestamp_map=2000-01-01T00:00:00.5Z..",
+		}})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Regexp(`schema\.Properties\["created_at"\] = &jsonschema\.Schema\{[^}]+Extra:\s+map\[string\]any\{"x-formatMaximum": "2100-01-01T10:00:00Z", "x-formatMinimum": "1900-01-01T00:00:00Z"\},`, content)
+		s.Regexp(`Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Format:\s+"date-time",\s+Extra:\s+map\[string\]any\{"x-formatMaximum": "2100-01-01T00:00:00Z"\},`, content)
+		s.Regexp(`AdditionalProperties: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Format:\s+"date-time",\s+Extra:\s+map\[string\]any\{"x-formatMinimum": "2000-01-01T00:00:00.5Z"\},`, content)
+	})
+
+	s.Run("invalid values are rejected", func() {
+		for opts, want := range map[*plugin.Options]string{
+			{TimestampUTC: true}: "invalid timestamp_utc parameter: it requires well_known_types=protojson",
+			{TimestampBounds: []string{"users.v1.WellKnownTypesDemo.created_at=1900-01-01.."}}:                                        "invalid timestamp_bounds parameter: it requires well_known_types=protojson",
+			{WellKnownTypes: "protojson", TimestampBounds: []string{"users.v1.WellKnownTypesDemo.created_at=1900-01-01"}}:             "expected <field>=<min>..<max>",
+			{WellKnownTypes: "protojson", TimestampBounds: []string{"users.v1.WellKnownTypesDemo.created_at=.."}}:                     "with at least one bound",
+			{WellKnownTypes: "protojson", TimestampBounds: []string{"users.v1.WellKnownTypesDemo.created_at=1900..2000"}}:             `"1900" is not an RFC 3339 date-time or a YYYY-MM-DD date`,
+			{WellKnownTypes: "protojson", TimestampBounds: []string{"users.v1.WellKnownTypesDemo.created_at=2000-01-01..1900-01-01"}}: "the minimum is after the maximum",
+			{WellKnownTypes: "protojson", TimestampBounds: []string{"users.v1.WellKnownTypesDemo.missing=..2000-01-01"}}:              "field users.v1.WellKnownTypesDemo.missing not found",
+			{WellKnownTypes: "protojson", TimestampBounds: []string{"users.v1.WellKnownTypesDemo.time_duration=..2000-01-01"}}:        "is not a google.protobuf.Timestamp field",
+		} {
+			s.SetupTest()
+			s.ErrorContains(plugin.GenerateWithOptions(s.Plugin(), "test", *opts), want)
+		}
+	})
+}
+
+// TestDriftDirParameter tests that drift_dir summarizes the message schemas and properties
+// added or removed since the previously generated file in the regenerated file's header.
+func (s *PluginGeneratorTestSuite) TestDriftDirParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	driftDir := s.TempDir()
+
+	s.Run("no previous file", func() {
+		contents := s.RunGenerateWithOptions(plugin.Options{DriftDir: driftDir})
+		s.Require().NotEmpty(contents[userFile])
+		s.NotContains(contents[userFile], "Schema changes")
+		for name, content := range contents {
+			path := filepath.Join(driftDir, filepath.FromSlash(name))
+			s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+			s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+		}
+	})
+
+	s.Run("unchanged schemas", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{DriftDir: driftDir})
+		s.NotContains(contents[userFile], "Schema changes")
+	})
+
+	s.Run("changed schemas", func() {
+		previous, err := os.ReadFile(filepath.Join(driftDir, userFile))
+		s.Require().NoError(err)
+		edited := strings.Replace(string(previous), `schema.Properties["street"]`, `schema.Properties["legacy_street"]`, 1)
+		edited = strings.Replace(edited, `defs["users.v1.OneOfDemo"] = schema`, `defs["users.v1.LegacyDemo"] = schema`, 1)
+		s.Require().NoError(os.WriteFile(filepath.Join(driftDir, userFile), []byte(edited), 0o644))
+
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{DriftDir: driftDir, OptionsSnapshot: true})
+		content := contents[userFile]
+		s.Contains(content, "//\n"+
+			"// Schema changes since the previous generation:\n"+
+			"//   - users.v1.Address: added street; removed legacy_street\n"+
+			"//   - users.v1.OneOfDemo: new message\n"+
+			"//   - users.v1.LegacyDemo: removed message\n"+
+			"\npackage usersv1\n")
+		s.NotContains(contents["github.com/newtonnthiga/users/v1/admin_jsonschema.pb.go"], "Schema changes")
+		s.NotContains(content, "drift_dir", "drift_dir should not be part of the options snapshot")
+	})
+
+	s.Run("unparseable previous file", func() {
+		s.Require().NoError(os.WriteFile(filepath.Join(driftDir, userFile), []byte("package"), 0o644))
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{DriftDir: driftDir})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "drift_dir: parsing previously generated "+userFile)
+	})
+}
+
+// TestVerifyParameter tests that verify accepts the generated schemas under parameters
+// that change property names, types and layout, and reports definitions, properties and
+// types that do not match the descriptors.
+func (s *PluginGeneratorTestSuite) TestVerifyParameter() {
+	for _, opts := range []plugin.Options{
+		{Verify: true},
+		{Verify: true, FieldNames: "json_name", EnumNames: true, NullableOptional: true, PresenceMetadata: true},
+		{Verify: true, DiscriminatedOneofs: []string{"users.v1.OneOfDemo.field1=kind"}, FieldNames: "camel", Targets: []string{"gemini"}},
+		{Verify: true, WellKnownTypes: "protojson", EnumOneOf: true},
+		{Verify: true, DurationSeconds: []string{"true"}, ObjectRoot: true},
+	} {
+		s.SetupTest()
+		s.NotEmpty(s.RunGenerateWithOptions(opts))
+	}
+
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	s.SetupTest()
+	content := s.RunGenerate()[userFile]
+	s.Require().NotEmpty(content)
+	helper := s.TestingHelper()
+	s.NoError(helper.VerifySchemas(s.File(), userFile, []byte(content)))
+
+	edited := strings.Replace(content, `schema.Properties["street"]`, `schema.Properties["legacy_street"]`, 1)
+	edited = strings.Replace(edited, `defs["users.v1.OneOfDemo"] = schema`, `defs["users.v1.LegacyDemo"] = schema`, 1)
+	edited = regexp.MustCompile(`(schema\.Properties\["city"\] = &jsonschema\.Schema\{\s+Type:\s+)"string"`).ReplaceAllString(edited, `$1"integer"`)
+	err := helper.VerifySchemas(s.File(), userFile, []byte(edited))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "verify: generated "+userFile+" does not match the descriptors")
+	s.Contains(err.Error(), `users.v1.Address.street: no property "street"`)
+	s.Contains(err.Error(), `users.v1.Address: property "legacy_street" has no field`)
+	s.Contains(err.Error(), `users.v1.Address.city: property "city" has type integer, want string`)
+	s.Contains(err.Error(), "users.v1.OneOfDemo: no schema definition")
+}
+
+// TestAnyTypesParameter tests that any_types restricts an Any field to the protojson form
+// of the listed messages, and that malformed values, unknown fields and types, non-Any
+// fields, well-known types and messages whose schemas reject "@type" are rejected.
+func (s *PluginGeneratorTestSuite) TestAnyTypesParameter() {
+	s.Run("allowed types", func() {
+		contents := s.RunGenerateWithOptions(plugin.Options{AnyTypes: []string{
+			"users.v1.WellKnownTypesDemo.any_field=users.v1.GetUserRequest|.users.v1.DeleteUserResponse",
+		}})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+
+		s.Regexp(`schema\.Properties\["any_field"\] = &jsonschema\.Schema\{\s+Type:\s+"object",[^}]+Properties: map\[string\]\*jsonschema\.Schema\{\s+"@type": \{Type: "string", Enum: \[\]any\{\s+"type\.googleapis\.com/users\.v1\.GetUserRequest",\s+"type\.googleapis\.com/users\.v1\.DeleteUserResponse",\s+\}\},\s+\},\s+Required: \[\]string\{"@type"\},`, content)
+		s.Regexp(`OneOf: \[\]\*jsonschema\.Schema\{\s+\{\s+Properties: map\[string\]\*jsonschema\.Schema\{"@type": \{Const: jsonschema\.Ptr\[any\]\("type\.googleapis\.com/users\.v1\.GetUserRequest"\)\}\},\s+AllOf:\s+\[\]\*jsonschema\.Schema\{GetUserRequest_JsonSchema_WithDefs\(defs\)\},`, content)
+		s.NotContains(content, `schema.Properties["any_field"] = user_google_protobuf_Any_JsonSchema_WithDefs(defs)`)
+	})
+
+	s.Run("invalid values are rejected", func() {
+		for value, want := range map[string]string{
+			"users.v1.WellKnownTypesDemo.any_field":                            "expected <field>=<type>|<type>...",
+			"users.v1.WellKnownTypesDemo.any_field=users.v1.GetUserRequest|":   "empty type name",
+			"users.v1.WellKnownTypesDemo.missing=users.v1.GetUserRequest":      "field users.v1.WellKnownTypesDemo.missing not found",
+			"users.v1.WellKnownTypesDemo.struct_field=users.v1.GetUserRequest": "field users.v1.WellKnownTypesDemo.struct_field is not a google.protobuf.Any field",
+			"users.v1.WellKnownTypesDemo.any_field=users.v1.Missing":           "message users.v1.Missing (listed for users.v1.WellKnownTypesDemo.any_field) not found",
+			"users.v1.WellKnownTypesDemo.any_field=google.protobuf.Timestamp":  "well-known type google.protobuf.Timestamp",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{AnyTypes: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), "invalid any_types parameter", value)
+			s.Contains(err.Error(), want, value)
+		}
+	})
+
+	s.Run("closed allowed messages are rejected", func() {
+		anyTypes := []string{"users.v1.WellKnownTypesDemo.any_field=users.v1.GetUserRequest"}
+		for param, opts := range map[string]plugin.Options{
+			"closed_objects":         {AnyTypes: anyTypes, ClosedObjects: []string{"users/v1/user.proto"}},
+			"additional_properties":  {AnyTypes: anyTypes, AdditionalProperties: []string{"users.v1.GetUserRequest=integer"}},
+			"unevaluated_properties": {AnyTypes: anyTypes, UnevaluatedProperties: []string{"users.v1.GetUserRequest"}},
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", opts)
+			s.Require().Error(err, param)
+			s.Contains(err.Error(), `invalid any_types parameter: the schema of message users.v1.GetUserRequest (listed for users.v1.WellKnownTypesDemo.any_field) rejects the "@type" property of Any values, because of the `+param+" parameter", param)
+		}
+
+		// additionalProperties that accept strings, as "@type" is, take precedence over
+		// closed_objects.
+		s.SetupTest()
+		s.NoError(plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{
+			AnyTypes:             anyTypes,
+			ClosedObjects:        []string{"users/v1/user.proto"},
+			AdditionalProperties: []string{"users.v1.GetUserRequest=string"},
+		}))
+	})
+}
+
+// TestParamsRegistry tests that every plugin parameter is documented and that
+// RegisterFlags sets the corresponding options, appending to list parameters.
+func (s *PluginGeneratorTestSuite) TestParamsRegistry() {
+	params := plugin.Params()
+	s.Require().NotEmpty(params)
+	for _, p := range params {
+		s.NotEmpty(p.Type, p.Name)
+		s.NotEmpty(p.Usage, p.Name)
+		s.True(strings.HasPrefix(p.Example, p.Name+"="), "example of %s should set it: %s", p.Name, p.Example)
+	}
+
+	var opts plugin.Options
+	var flags flag.FlagSet
+	opts.RegisterFlags(&flags)
+	for _, p := range params {
+		s.NotNil(flags.Lookup(p.Name), "no flag for %s", p.Name)
+		_, value, _ := strings.Cut(p.Example, "=")
+		s.Require().NoError(flags.Set(p.Name, value), p.Name)
+	}
+	s.Require().NoError(flags.Set("draft", "2020-12"))
+	s.True(opts.ObjectRoot)
+	s.Equal("santhosh", opts.SchemaLib)
+	s.Equal([]string{"draft-07", "2020-12"}, opts.Drafts)
+	s.Equal([]string{"users.v1.Event.payload=users.v1.User|users.v1.Admin"}, opts.AnyTypes)
+}
+
+// TestDigestFormatPresets tests that digest format presets expand into exact-length patterns.
+func (s *PluginGeneratorTestSuite) TestDigestFormatPresets() {
+	content := s.GetGeneratedContent()
+	s.Regexp(`Format:\s+"sha256-hex",\s+Pattern:\s+"\^\[0-9a-fA-F\]\{64\}\$",`, content)
+	s.Regexp(`Format:\s+"md5-base64",\s+Pattern:\s+"\^\[A-Za-z0-9\+/\]\{22\}==\$",`, content)
+}
+
+// TestOptionsSnapshot tests that options_snapshot embeds the effective options as x-generation-options.
+func (s *PluginGeneratorTestSuite) TestOptionsSnapshot() {
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "x-generation-options")
+	})
+
+	s.Run("enabled", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{OptionsSnapshot: true, DurationSeconds: []string{"true"}})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `"plugin": map[string]any{"duration_seconds": []string{"true"}, "options_snapshot": true}`)
+		s.Contains(content, `"file": map[string]any{"generate": true}`)
+		s.Contains(content, `"short_name": map[string]any{"max_length": 32, "min_length": 2, "pattern": "^[a-z][a-z0-9-]*$"}`)
+	})
+}
+
+// TestProvenance tests that provenance embeds x-generator with the plugin version, the
+// options hash and the message's source, and that the hash follows the parameters.
+func (s *PluginGeneratorTestSuite) TestProvenance() {
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "x-generator")
+	})
+
+	s.Run("enabled", func() {
+		generator := regexp.MustCompile(`"x-generator": map\[string\]any\{"name": "protoc-gen-go-jsonschema", "options_hash": "([0-9a-f]{16})", "package": "users\.v1", "source": "users/v1/user\.proto", "version": "test"\}`)
+		hash := func(opts plugin.Options) string {
+			s.SetupTest()
+			content := s.RunGenerateWithOptions(opts)["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+			s.Require().NotEmpty(content)
+			m := generator.FindStringSubmatch(content)
+			s.Require().NotNil(m, "x-generator block")
+			return m[1]
+		}
+		plain := hash(plugin.Options{Provenance: true})
+		s.Equal(plain, hash(plugin.Options{Provenance: true, DriftDir: s.T().TempDir()}),
+			"parameters that do not affect the schemas should not change the hash")
+		s.NotEqual(plain, hash(plugin.Options{Provenance: true, DurationSeconds: []string{"true"}}))
+		s.Equal(hash(plugin.Options{Provenance: true, ClosedObjects: []string{"users.v1.User", "users.v1.Address"}}),
+			hash(plugin.Options{Provenance: true, ClosedObjects: []string{" users.v1.Address", "users.v1.User", "users.v1.User "}}),
+			"the order, spacing and repetition of set-like list values should not change the hash")
+		s.NotEqual(hash(plugin.Options{Provenance: true, AnyTypes: []string{"users.v1.WellKnownTypesDemo.any_field=users.v1.GetUserRequest", "users.v1.WellKnownTypesDemo.any_field=users.v1.DeleteUserResponse"}}),
+			hash(plugin.Options{Provenance: true, AnyTypes: []string{"users.v1.WellKnownTypesDemo.any_field=users.v1.DeleteUserResponse", "users.v1.WellKnownTypesDemo.any_field=users.v1.GetUserRequest"}}),
+			"the order of ordered list values should change the hash")
+	})
+}
+
+// TestVocabularyParameter tests that vocabulary declares the draft 2020-12 and custom
+// vocabularies in the $vocabulary of JsonSchema() roots, and that malformed values,
+// JSON Schema keywords and keywords declared twice are rejected.
+func (s *PluginGeneratorTestSuite) TestVocabularyParameter() {
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "root.Vocabulary")
+	})
+
+	s.Run("enabled", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{Vocabularies: []string{
+			"https://schemas.example.com/vocab/ui=x-ui-widget|x-sensitive",
+			"https://schemas.example.com/vocab/audit=x-audit",
+		}})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `root.Vocabulary = map[string]bool{"https://json-schema.org/draft/2020-12/vocab/core": true, `)
+		s.Contains(content, `"https://json-schema.org/draft/2020-12/vocab/content": true, "https://schemas.example.com/vocab/audit": false, "https://schemas.example.com/vocab/ui": false}`)
+		s.Equal(strings.Count(content, "JsonSchema() *jsonschema.Schema {"), strings.Count(content, "root.Vocabulary = "),
+			"every JsonSchema() root, and no MCPInputSchema() root, should declare the vocabularies")
+	})
+
+	for name, values := range map[string][]string{
+		"missing keywords":       {"https://schemas.example.com/vocab/ui"},
+		"relative URI":           {"vocab/ui=x-ui-widget"},
+		"JSON Schema keyword":    {"https://schemas.example.com/vocab/ui=format"},
+		"keyword declared twice": {"https://schemas.example.com/vocab/ui=x-ui-widget", "https://schemas.example.com/vocab/form=x-ui-widget"},
+	} {
+		s.Run(name, func() {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Vocabularies: values})
+			s.Require().Error(err)
+			s.Contains(err.Error(), "invalid vocabulary parameter")
+		})
+	}
+}
+
+// TestPresenceMetadata tests that presence_metadata annotates every property with the
+// field's presence, keeping message references.
+func (s *PluginGeneratorTestSuite) TestPresenceMetadata() {
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "x-proto-presence")
+	})
+
+	s.Run("enabled", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{PresenceMetadata: true})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Regexp(`schema\.Properties\["optional_address_details"\] = &jsonschema\.Schema\{\s+Ref:\s+AddressDetails_JsonSchema_WithDefs\(defs\)\.Ref,[^}]*"x-proto-presence": "explicit"`, content)
+		s.Regexp(`schema\.Properties\["street"\] = &jsonschema\.Schema\{[^}]*"x-proto-presence": "explicit"`, content)
+		s.Regexp(`schema\.Properties\["zip_code"\] = &jsonschema\.Schema\{[^}]*"x-proto-presence": "implicit"`, content)
+		s.NotRegexp(`schema\.Properties\["zip_code"\] = &jsonschema\.Schema\{[^}]*"x-proto-presence": "explicit"`, content)
+		s.NotContains(content, `schema.Properties["address_details"] = AddressDetails_JsonSchema_WithDefs(defs)`,
+			"message references should be annotated too")
+	})
+}
+
+// TestClosedObjects tests that closed_objects closes the schemas of all messages, of the
+// messages of a file or of single messages, and that unknown values are rejected.
+func (s *PluginGeneratorTestSuite) TestClosedObjects() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	// schemaOf returns the generated schema literal of a message.
+	schemaOf := func(content, message string) string {
+		m := regexp.MustCompile(`(?s)func ` + message + `_JsonSchema_WithDefs\(.*?\n\tdefs\[`).FindString(content)
+		s.Require().NotEmpty(m, message)
+		return m
+	}
+	const closed = "AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},"
+
+	s.Run("open by default", func() {
+		s.NotContains(schemaOf(s.RunGenerate()[userFile], "Address"), "AdditionalProperties")
+	})
+
+	s.Run("single message", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{ClosedObjects: []string{"users.v1.Address"}})[userFile]
+		s.Contains(schemaOf(content, "Address"), closed)
+		s.NotContains(schemaOf(content, "AddressDetails"), "AdditionalProperties")
+	})
+
+	s.Run("file and all", func() {
+		for _, value := range []string{"users/v1/user.proto", "all"} {
+			s.SetupTest()
+			content := s.RunGenerateWithOptions(plugin.Options{ClosedObjects: []string{value}})[userFile]
+			s.Contains(schemaOf(content, "Address"), closed, value)
+			s.Contains(schemaOf(content, "AddressDetails"), closed, value)
+		}
+	})
+
+	s.Run("unknown value is rejected", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{ClosedObjects: []string{"users.v1.Nope"}})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid closed_objects parameter: "users.v1.Nope"`)
+	})
+}
+
+// TestAdditionalPropertiesParameter tests that additional_properties sets the
+// additionalProperties of single messages to false, true or a type, taking precedence
+// over closed_objects, and that invalid values are rejected.
+func (s *PluginGeneratorTestSuite) TestAdditionalPropertiesParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	// schemaOf returns the generated schema literal of a message.
+	schemaOf := func(content, message string) string {
+		m := regexp.MustCompile(`(?s)func ` + message + `_JsonSchema_WithDefs\(.*?\n\tdefs\[`).FindString(content)
+		s.Require().NotEmpty(m, message)
+		return m
+	}
+
+	s.Run("listed messages", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{
+			AdditionalProperties: []string{"users.v1.Address=false", "users.v1.Metadata=string", "users.v1.ContactInfo=true"},
+			ClosedObjects:        []string{"all"},
+		})[userFile]
+		s.Contains(schemaOf(content, "Address"), "AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},")
+		s.Contains(schemaOf(content, "Metadata"), `AdditionalProperties: &jsonschema.Schema{Type: "string"},`)
+		s.Contains(schemaOf(content, "ContactInfo"), "AdditionalProperties: &jsonschema.Schema{},")
+		s.Contains(schemaOf(content, "AddressDetails"), "AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},")
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.Address":        `invalid additional_properties parameter "users.v1.Address"`,
+			"users.v1.Address=null":   `unsupported value "null"`,
+			"users.v1.Address=yes":    `unsupported value "yes"`,
+			"users.v1.Missing=string": "message users.v1.Missing not found",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{AdditionalProperties: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestPresetParameter tests that the money preset constrains only the messages the preset
+// parameter names, and that invalid values are rejected.
+func (s *PluginGeneratorTestSuite) TestPresetParameter() {
+	const commonFile = "github.com/newtonnthiga/users/v1/common_jsonschema.pb.go"
+
+	s.Run("opt-in", func() {
+		s.NotContains(s.RunGenerate()[commonFile], "Money preset")
+
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{Presets: []string{"users.v1.Price=money"}})[commonFile]
+		s.Contains(content, "// Money preset: nanos is bounded and has the same sign as units.")
+		s.Contains(content, `If:   &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"units": {ExclusiveMinimum: &[]float64{0}[0]}}, Required: []string{"units"}},`)
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.Price":          `invalid preset parameter "users.v1.Price"`,
+			"users.v1.Price=currency": `unsupported preset "currency"`,
+			"users.v1.Missing=money":  "message users.v1.Missing not found",
+			"users.v1.Address=money":  "message users.v1.Address has no singular int64 units and int32 nanos fields",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Presets: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestCELConstraintParameter tests that cel_constraint emits the expressions of a message,
+// inline or read from a .cel file, as its x-constraints, and that expressions that do not
+// compile to a boolean are rejected.
+func (s *PluginGeneratorTestSuite) TestCELConstraintParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("opt-in", func() {
+		s.NotContains(s.RunGenerate()[userFile], "x-constraints")
+
+		s.SetupTest()
+		path := filepath.Join(s.T().TempDir(), "tags.cel")
+		s.Require().NoError(os.WriteFile(path, []byte("this.tags.all(t, t != \"\")\n"), 0o644))
+		content := s.RunGenerateWithOptions(plugin.Options{CELConstraints: []string{
+			"users.v1.ComprehensiveUser=this.age >= 18 || !this.is_active",
+			"users.v1.ComprehensiveUser=" + path,
+		}})[userFile]
+		s.Contains(content, `Extra: map[string]any{"x-constraints": []string{"this.age >= 18 || !this.is_active", "this.tags.all(t, t != \"\")"}},`)
+		s.Equal(1, strings.Count(content, "x-constraints"))
+	})
+
+	s.Run("invalid values", func() {
+		empty := filepath.Join(s.T().TempDir(), "empty.cel")
+		s.Require().NoError(os.WriteFile(empty, nil, 0o644))
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser":             `invalid cel_constraint parameter "users.v1.ComprehensiveUser"`,
+			"users.v1.ComprehensiveUser=missing.cel": "missing.cel",
+			"users.v1.ComprehensiveUser=" + empty:    "the file holds no expression",
+			"users.v1.Missing=this.a":                "message users.v1.Missing not found",
+			"users.v1.ComprehensiveUser=this.age >":  `expression "this.age >" of message users.v1.ComprehensiveUser`,
+			`users.v1.ComprehensiveUser="text"`:      "instead of a boolean",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{CELConstraints: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestUnevaluatedPropertiesParameter tests that unevaluated_properties sets
+// unevaluatedProperties to false on the selected messages only, alongside their
+// additionalProperties, and that unknown values are rejected.
+func (s *PluginGeneratorTestSuite) TestUnevaluatedPropertiesParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	// schemaOf returns the generated schema literal of a message.
+	schemaOf := func(content, message string) string {
+		m := regexp.MustCompile(`(?s)func ` + message + `_JsonSchema_WithDefs\(.*?\n\tdefs\[`).FindString(content)
+		s.Require().NotEmpty(m, message)
+		return m
+	}
+	const unevaluated = "UnevaluatedProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},"
+
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "UnevaluatedProperties")
+	})
+
+	s.Run("selected messages", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{
+			UnevaluatedProperties: []string{"users.v1.OneOfDemo"},
+			ClosedObjects:         []string{"users.v1.Address"},
+		})[userFile]
+		s.Contains(schemaOf(content, "OneOfDemo"), unevaluated)
+		s.NotContains(schemaOf(content, "OneOfDemo"), "AdditionalProperties")
+		s.NotContains(schemaOf(content, "Address"), "UnevaluatedProperties")
+	})
+
+	s.Run("file", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{UnevaluatedProperties: []string{"users/v1/user.proto"}})[userFile]
+		s.Contains(schemaOf(content, "Address"), unevaluated)
+		s.Contains(schemaOf(content, "AddressDetails"), unevaluated)
+	})
+
+	s.Run("unknown value is rejected", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{UnevaluatedProperties: []string{"users.v1.Nope"}})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid unevaluated_properties parameter: "users.v1.Nope"`)
+	})
+}
+
+// TestPropertyOrderParameter tests that property_order records the proto field order of
+// message properties as x-property-order, alongside the propertyOrdering of
+// target=gemini.
+func (s *PluginGeneratorTestSuite) TestPropertyOrderParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "x-property-order")
+	})
+
+	s.Run("declaration order", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{PropertyOrder: true})[userFile]
+		s.Contains(content, `"x-property-order": []string{"id", "name", "email", "password", "status", "address"}`)
+		s.NotContains(content, "propertyOrdering")
+	})
+
+	s.Run("with target=gemini", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{PropertyOrder: true, Targets: []string{"gemini"}})[userFile]
+		s.Contains(content, `"propertyOrdering": []string{"id", "name", "email", "password", "status", "address"}`)
+		s.Contains(content, `"x-property-order": []string{"id", "name", "email", "password", "status", "address"}`)
+	})
+}
+
+// TestMaxRecursionDepthParameter tests that max_recursion_depth records the depth in the
+// message's schema and that malformed values and unknown messages are rejected.
+func (s *PluginGeneratorTestSuite) TestMaxRecursionDepthParameter() {
+	s.Run("disabled by default", func() {
+		s.NotContains(s.GetGeneratedContent(), "x-max-recursion-depth")
+	})
+
+	s.Run("depth recorded", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{MaxRecursionDepths: []string{"users.v1.AddressDetails=3"}})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Regexp(`(?s)func AddressDetails_JsonSchema_WithDefs\(.*?Extra:\s+map\[string\]any\{"x-max-recursion-depth": 3\},\n\t\}\n.*?defs\["users\.v1\.AddressDetails"\] = schema`, content)
+		s.Equal(1, strings.Count(content, "x-max-recursion-depth"))
+	})
+
+	for name, tc := range map[string]struct{ value, err string }{
+		"malformed value": {"users.v1.AddressDetails", `invalid max_recursion_depth parameter "users.v1.AddressDetails"`},
+		"negative depth":  {"users.v1.AddressDetails=-1", `invalid max_recursion_depth parameter "users.v1.AddressDetails=-1"`},
+		"unknown message": {"users.v1.Nope=2", "invalid max_recursion_depth parameter: message users.v1.Nope not found"},
+	} {
+		s.Run(name, func() {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{MaxRecursionDepths: []string{tc.value}})
+			s.Require().Error(err)
+			s.Contains(err.Error(), tc.err)
+		})
+	}
+}
+
+// TestNullableOptional tests that nullable_optional lets optional fields hold null and
+// leaves other fields unchanged.
+func (s *PluginGeneratorTestSuite) TestNullableOptional() {
+	s.Run("disabled by default", func() {
+		s.Regexp(`schema\.Properties\["street"\] = &jsonschema\.Schema\{\s+Type:\s+"string",`, s.GetGeneratedContent())
+	})
+
+	s.Run("enabled", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{NullableOptional: true})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Regexp(`schema\.Properties\["street"\] = &jsonschema\.Schema\{\s+Types:\s+\[\]string\{"string", "null"\},`, content)
+		s.Regexp(`schema\.Properties\["optional_address_details"\] = &jsonschema\.Schema\{\s+AnyOf:\s+\[\]\*jsonschema\.Schema\{\{Ref: AddressDetails_JsonSchema_WithDefs\(defs\)\.Ref\}, \{Type: "null"\}\},`, content)
+		s.Regexp(`schema\.Properties\["zip_code"\] = &jsonschema\.Schema\{\s+Type:\s+"string",`, content, "non-optional fields stay non-nullable")
+		s.Contains(content, `schema.Properties["address_details"] = Address_AddressDetails_JsonSchema_WithDefs(defs)`)
+	})
+}
+
+// TestDraftParameter tests that draft=2020-12,draft=draft-07 emits draft-07 accessors in
+// suffixed files next to the draft 2020-12 code, and that unknown drafts are rejected.
+func (s *PluginGeneratorTestSuite) TestDraftParameter() {
+	s.Run("default emits only 2020-12", func() {
+		for name := range s.RunGenerate() {
+			s.NotContains(name, "_draft07")
+		}
+	})
+
+	s.Run("draft-07 artifacts side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{Drafts: []string{"2020-12", "draft-07"}, OptionsSnapshot: true})
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go")
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_draft07.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `schemautil "github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"`)
+		s.Contains(content, "func (x *User) JsonSchemaDraft07() *jsonschema.Schema {")
+		s.Contains(content, "return schemautil.ToDraft07(x.JsonSchema())")
+		s.NotContains(content, "google_protobuf", "Google types should not get draft-07 accessors")
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/common_jsonschema_draft07.pb.go")
+		s.Contains(contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"], `"draft": []string{"2020-12", "draft-07"}`)
+	})
+
+	s.Run("unknown draft is rejected", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Drafts: []string{"draft-04"}})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `unsupported draft "draft-04"`)
+	})
+}
+
+// TestPruneParameter tests that prune emits PruneToSchema() methods in suffixed files.
+func (s *PluginGeneratorTestSuite) TestPruneParameter() {
+	s.Run("default emits no prune methods", func() {
+		for name := range s.RunGenerate() {
+			s.NotContains(name, "_prune")
+		}
+	})
+
+	s.Run("prune methods side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{Prune: true})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_prune.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `schemautil "github.com/alis-exchange/protoc-gen-go-jsonschema/schemautil"`)
+		s.Contains(content, "func (x *User) PruneToSchema(data map[string]any) map[string]any {")
+		s.Contains(content, "return schemautil.Prune(x.JsonSchema(), data)")
+		s.NotContains(content, "google_protobuf", "Google types should not get prune methods")
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/common_jsonschema_prune.pb.go")
+	})
+}
+
+// TestCachedAccessorsParameter tests that cached_accessors emits sync.OnceValue-backed
+// JsonSchemaCached() and JsonSchemaResolved() methods in suffixed files.
+func (s *PluginGeneratorTestSuite) TestCachedAccessorsParameter() {
+	s.Run("default emits no cached accessors", func() {
+		for name, content := range s.RunGenerate() {
+			s.NotContains(name, "_cached")
+			s.NotContains(content, "sync.Once", name)
+		}
+	})
+
+	s.Run("cached accessors side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{CachedAccessors: true})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_cached.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `sync "sync"`)
+		s.Regexp(`_User_jsonSchemaCached = sync\.OnceValue\(func\(\) \*jsonschema\.Schema \{\s+defs := _user_jsonSchemaDefsOf\(\s+"users\.v1\.Address",`, content)
+		s.Contains(content, `root := &jsonschema.Schema{Ref: "#/$defs/users.v1.User", Type: "object"}`)
+		// Definitions are built alone, referencing the others, and shared by the cached schemas.
+		s.Contains(content, `"users.v1.User": sync.OnceValue(func() map[string]*jsonschema.Schema {`)
+		s.Regexp(`return _user_jsonSchemaDef\(User_JsonSchema_WithDefs, "users\.v1\.Address"`, content)
+		s.Contains(content, `"users.v1.UserStatus": sync.OnceValue(func() map[string]*jsonschema.Schema { return _user_jsonSchemaDef(UserStatus_JsonSchema_WithDefs) }),`)
+		s.Contains(content, "func _user_jsonSchemaDef(build func(map[string]*jsonschema.Schema) *jsonschema.Schema, references ...string) map[string]*jsonschema.Schema {")
+		s.Contains(content, "_User_jsonSchemaResolved = sync.OnceValues(func() (*jsonschema.Resolved, error) { return _User_jsonSchemaCached().Resolve(nil) })")
+		s.Contains(content, "func (x *User) JsonSchemaCached() *jsonschema.Schema {")
+		s.Contains(content, "func (x *User) JsonSchemaResolved() (*jsonschema.Resolved, error) {")
+		s.Contains(content, "// It is safe for concurrent use; every call returns the same value, which callers must not modify.")
+		s.NotRegexp(`google_protobuf_\w+_jsonSchemaCached`, content, "Google types should not get cached accessors")
+		s.Contains(content, `"google.protobuf.Any": sync.OnceValue(`, "Google type definitions are shared like the others")
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/common_jsonschema_cached.pb.go")
+	})
+}
+
+// TestCoerceToolArgsParameter tests that coerce_tool_args emits CoerceToolArgs() methods
+// in suffixed files.
+func (s *PluginGeneratorTestSuite) TestCoerceToolArgsParameter() {
+	s.Run("default emits no coercion methods", func() {
+		for name := range s.RunGenerate() {
+			s.NotContains(name, "_toolargs")
+		}
+	})
+
+	s.Run("coercion methods side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{CoerceToolArgs: true})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_toolargs.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, "func (x *CreateUserRequest) CoerceToolArgs(args map[string]any) (map[string]any, error) {")
+		s.Contains(content, "return schemautil.CoerceToolArgs(x.MCPInputSchema(), args)")
+		s.NotContains(content, "google_protobuf", "Google types should not get coercion methods")
+	})
+}
+
+// TestSchemaLibParameter tests that schema_lib=santhosh, invopop and openapi3 emit their
+// library's accessors next to the jsonschema-go code, and that unknown libraries are rejected.
+func (s *PluginGeneratorTestSuite) TestSchemaLibParameter() {
+	s.Run("default targets jsonschema-go only", func() {
+		for name := range s.RunGenerateWithOptions(plugin.Options{SchemaLib: "google"}) {
+			s.NotContains(name, "_santhosh")
+		}
+	})
+
+	s.Run("santhosh accessors side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{SchemaLib: "santhosh"})
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go")
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_santhosh.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `jsonschema "github.com/google/jsonschema-go/jsonschema"`)
+		s.Contains(content, `v6 "github.com/santhosh-tekuri/jsonschema/v6"`)
+		s.Contains(content, "func (x *User) JsonSchemaSanthosh() (*v6.Schema, error) {")
+		s.Contains(content, `return user_compileSanthosh("users.v1.User", x.JsonSchema())`)
+		s.Contains(content, "func user_compileSanthosh(name string, schema *jsonschema.Schema) (*v6.Schema, error) {")
+		s.NotContains(content, "google_protobuf", "Google types should not get santhosh accessors")
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/common_jsonschema_santhosh.pb.go")
+	})
+
+	s.Run("invopop accessors side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{SchemaLib: "invopop"})
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go")
+		s.NotContains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema_santhosh.pb.go")
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_invopop.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `jsonschema1 "github.com/invopop/jsonschema"`)
+		s.Contains(content, "func (x *User) JsonSchemaInvopop() (*jsonschema1.Schema, error) {")
+		s.Contains(content, "return user_toInvopop(x.JsonSchema())")
+		s.Contains(content, "func user_toInvopop(schema *jsonschema.Schema) (*jsonschema1.Schema, error) {")
+		s.NotContains(content, "google_protobuf", "Google types should not get invopop accessors")
+	})
+
+	s.Run("openapi3 accessors side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{SchemaLib: "openapi3"})
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go")
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_openapi3.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, `openapi3 "github.com/getkin/kin-openapi/openapi3"`)
+		s.Contains(content, "func (x *User) JsonSchemaOpenAPI3() (*openapi3.SchemaRef, openapi3.Schemas) {")
+		s.Contains(content, `return user_toOpenAPI3("users.v1.User", x.JsonSchema())`)
+		s.Contains(content, "func user_toOpenAPI3(name string, schema *jsonschema.Schema) (*openapi3.SchemaRef, openapi3.Schemas) {")
+		s.NotContains(content, "OPENAPI3.", "placeholder qualifiers should be replaced")
+		s.NotContains(content, "google_protobuf", "Google types should not get openapi3 accessors")
+	})
+
+	s.Run("openapi3 accessors keep message and package names", func() {
+		// Names spelled like the converter's placeholders must not be rewritten.
+		namingProto := "naming/v1/naming.proto"
+		fds := s.compileProtos("naming.pb", namingProto)
+		for _, f := range fds.File {
+			if f.GetName() == namingProto {
+				f.Package = proto.String("JSON.v1")
+				f.MessageType[0].Name = proto.String("CONVERTRequest")
+			}
+		}
+		p, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{FileToGenerate: []string{namingProto}, ProtoFile: fds.File})
+		s.Require().NoError(err)
+		s.Require().NoError(plugin.GenerateWithOptions(p, "test", plugin.Options{SchemaLib: "openapi3"}))
+		resp := p.Response()
+		s.Require().Empty(resp.GetError())
+		var content string
+		for _, f := range resp.File {
+			if strings.HasSuffix(f.GetName(), "_jsonschema_openapi3.pb.go") {
+				content = f.GetContent()
+			}
+		}
+		s.Require().NotEmpty(content)
+		s.Contains(content, "func (x *CONVERTRequest) JsonSchemaOpenAPI3() (*openapi3.SchemaRef, openapi3.Schemas) {")
+		s.Contains(content, `return naming_toOpenAPI3("JSON.v1.CONVERTRequest", x.JsonSchema())`)
+	})
+
+	s.Run("unknown library is rejected", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{SchemaLib: "ajv"})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid schema_lib parameter "ajv"`)
+	})
+}
+
+// TestTargetParameter tests that each target profile emits its accessors in a suffixed
+// file next to the default code, that targets combine, and that unknown targets are
+// rejected.
+func (s *PluginGeneratorTestSuite) TestTargetParameter() {
+	s.Run("default emits no profile accessors", func() {
+		for name := range s.RunGenerate() {
+			s.NotContains(name, "_openai")
+		}
+	})
+
+	s.Run("openai accessors side-by-side", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{Targets: []string{"openai"}})
+		s.Contains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go")
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_openai.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, "func (x *User) JsonSchemaOpenAI() (*jsonschema.Schema, error) {")
+		s.Contains(content, "return schemautil.ToOpenAI(x.MCPInputSchema())")
+		s.NotContains(content, "google_protobuf", "Google types should not get OpenAI accessors")
+		s.NotContains(contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"], "propertyOrdering")
+	})
+
+	s.Run("gemini accessors and property ordering", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{Targets: []string{"gemini"}})
+		content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_gemini.pb.go"]
+		s.Require().NotEmpty(content)
+		s.Contains(content, "func (x *User) JsonSchemaGemini() (*jsonschema.Schema, error) {")
+		s.Contains(content, "return schemautil.ToGemini(x.MCPInputSchema())")
+		s.NotContains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema_openai.pb.go")
+		s.Regexp(`Extra:\s+map\[string\]any\{"propertyOrdering": \[\]string\{"id", `, contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"])
+	})
+
+	s.Run("repeated targets emit one file per profile", func() {
+		s.SetupTest()
+		contents := s.RunGenerateWithOptions(plugin.Options{Targets: []string{"plain", "claude", "mcp", "claude"}})
+		for method, call := range map[string]string{
+			"mcp":    "JsonSchemaMCP() (*jsonschema.Schema, error) {\n\treturn schemautil.ToMCP(x.MCPInputSchema())",
+			"claude": "JsonSchemaClaude() (*jsonschema.Schema, error) {\n\treturn schemautil.ToClaude(x.MCPInputSchema())",
+			"plain":  "JsonSchemaPlain() *jsonschema.Schema {\n\treturn schemautil.ToPlain(x.JsonSchema())",
+		} {
+			content := contents["github.com/newtonnthiga/users/v1/user_jsonschema_"+method+".pb.go"]
+			s.Require().NotEmpty(content, method)
+			s.Contains(content, "func (x *User) "+call, method)
+		}
+		s.NotContains(contents, "github.com/newtonnthiga/users/v1/user_jsonschema_openai.pb.go")
+		s.NotContains(contents["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"], "propertyOrdering")
+	})
+
+	s.Run("unknown target is rejected", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Targets: []string{"openai", "mistral"}})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid target parameter "mistral"`)
+	})
+}
+
+// TestDiscriminatedOneofParameter tests that discriminated_oneof replaces the selected
+// oneof's member properties and constraint by a discriminated union property.
+func (s *PluginGeneratorTestSuite) TestDiscriminatedOneofParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("union replaces members and constraint", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{DiscriminatedOneofs: []string{"users.v1.OneOfDemo.field1=kind"}})[userFile]
+		s.Contains(content, `schema.Properties["field1"] = &jsonschema.Schema{`)
+		s.Contains(content, `Description: "First oneof group - can be string, int, or bool value.",`)
+		s.Regexp(`Properties:\s+map\[string\]\*jsonschema\.Schema\{"kind": \{Const: jsonschema\.Ptr\[any\]\("int_value"\)\}, "int_value": schema\.Properties\["int_value"\]\},\s+Required:\s+\[\]string\{"kind", "int_value"\},`, content)
+		s.Contains(content, `delete(schema.Properties, "string_value")`)
+		s.NotContains(content, `{Required: []string{"string_value"}},`, "the union replaces the oneof constraint")
+		s.Contains(content, `{Required: []string{"address"}},`, "other oneofs keep their constraints")
+	})
+
+	s.Run("property keys follow field_names", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{DiscriminatedOneofs: []string{"users.v1.OneOfDemo.field1=kind"}, FieldNames: "camel", Targets: []string{"gemini"}})[userFile]
+		s.Contains(content, `"kind": {Const: jsonschema.Ptr[any]("stringValue")}, "stringValue": schema.Properties["stringValue"]`)
+		s.Regexp(`"propertyOrdering": \[\]string\{"field1", "address", `, content)
+	})
+
+	for name, value := range map[string]string{
+		"malformed value":        "users.v1.OneOfDemo.field1",
+		"unknown oneof":          "users.v1.OneOfDemo.field9=kind",
+		"discriminator conflict": "users.v1.OneOfDemo.field1=int_value",
+	} {
+		s.Run(name+" is rejected", func() {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{DiscriminatedOneofs: []string{value}})
+			s.Require().Error(err)
+			s.Contains(err.Error(), "invalid discriminated_oneof parameter")
+		})
+	}
+}
+
+// TestOneofTextParameters tests that oneof_title and oneof_description title and describe
+// oneOf constraints, moving a single group into an allOf entry so the message keeps its
+// own title, that they replace the comments of discriminated union properties, and that
+// unknown oneofs are rejected.
+func (s *PluginGeneratorTestSuite) TestOneofTextParameters() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	// schemaOf returns the generated schema function of a message.
+	schemaOf := func(content, message string) string {
+		m := regexp.MustCompile(`(?s)func ` + message + `_JsonSchema_WithDefs\(.*?\n}\n`).FindString(content)
+		s.Require().NotEmpty(m, message)
+		return m
+	}
+
+	s.Run("anonymous by default", func() {
+		content := s.GetGeneratedContent()
+		s.Contains(schemaOf(content, "UserProfile"), "schema.OneOf = []*jsonschema.Schema{")
+	})
+
+	s.Run("titled constraints", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{
+			OneofTitles:       []string{"users.v1.OneOfDemo.field2=Related record", "users.v1.UserProfile.profile_type=Profile"},
+			OneofDescriptions: []string{"users.v1.UserProfile.profile_type=Set at most one of personal and business."},
+		})[userFile]
+		s.Regexp(`\{\s+Title:\s+"Related record",\s+OneOf: \[\]\*jsonschema\.Schema\{\s+\{Required: \[\]string\{"address"\}\},`, schemaOf(content, "OneOfDemo"))
+		profile := schemaOf(content, "UserProfile")
+		s.NotContains(profile, "schema.OneOf =")
+		s.Regexp(`schema\.AllOf = \[\]\*jsonschema\.Schema\{\s+\{\s+Title:\s+"Profile",\s+Description: "Set at most one of personal and business\.",\s+OneOf: \[\]\*jsonschema\.Schema\{\s+\{Required: \[\]string\{"personal"\}\},`, profile)
+	})
+
+	s.Run("discriminated union property", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{
+			DiscriminatedOneofs: []string{"users.v1.OneOfDemo.field1=kind"},
+			OneofTitles:         []string{"users.v1.OneOfDemo.field1=Scalar value"},
+			OneofDescriptions:   []string{"users.v1.OneOfDemo.field1=A string or integer or boolean."},
+		})[userFile]
+		s.Regexp(`schema\.Properties\["field1"\] = &jsonschema\.Schema\{\s+Title:\s+"Scalar value",\s+Description: "A string or integer or boolean\.",`, content)
+		s.NotContains(content, "First oneof group - can be string, int, or bool value.")
+	})
+
+	for name, opts := range map[string]plugin.Options{
+		"malformed title":     {OneofTitles: []string{"users.v1.OneOfDemo.field1"}},
+		"unknown title":       {OneofTitles: []string{"users.v1.OneOfDemo.field9=Value"}},
+		"unknown description": {OneofDescriptions: []string{"users.v1.User.id=Value"}},
+	} {
+		s.Run(name+" is rejected", func() {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", opts)
+			s.Require().Error(err)
+			s.Contains(err.Error(), "invalid oneof_")
+		})
+	}
+}
+
+// TestStripEnumPrefixParameter tests that strip_enum_prefix removes the enum name prefix
+// from emitted value names and maps them back to numbers.
+func (s *PluginGeneratorTestSuite) TestStripEnumPrefixParameter() {
+	s.Run("stripped names and mapping", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, StripEnumPrefix: true})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Regexp(`defs\["users\.v1\.UserStatus"\] = &jsonschema\.Schema\{(?s:.)*?Enum: \[\]any\{\s+"UNSPECIFIED",\s+"ACTIVE",`, content)
+		s.NotContains(content, `"USER_STATUS_ACTIVE",`)
+		s.Contains(content, "func UserStatus_JsonSchemaValue(name string) (int32, bool) {")
+		s.Regexp(`case "ACTIVE":\s+return 1, true`, content)
+		s.Contains(content, `{Value: 1, Name: "ACTIVE", Description: `, "the values helper lists the schema names")
+	})
+
+	s.Run("enum_oneof consts are stripped", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, EnumOneOf: true, StripEnumPrefix: true})["github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"]
+		s.Contains(content, `{Const: jsonschema.Ptr[any]("ACTIVE")`)
+	})
+
+	s.Run("requires enum_names", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{StripEnumPrefix: true})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "invalid strip_enum_prefix parameter: it requires enum_names")
+	})
+}
+
+// TestEnumCaseParameter tests that enum_case rewrites the casing of enum value names,
+// after strip_enum_prefix, and maps them back to value numbers.
+func (s *PluginGeneratorTestSuite) TestEnumCaseParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("kebab", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, EnumCase: "kebab"})[userFile]
+		s.Regexp(`defs\["users\.v1\.UserStatus"\] = &jsonschema\.Schema\{(?s:.)*?Enum: \[\]any\{\s+"user-status-unspecified",\s+"user-status-active",`, content)
+		s.NotContains(content, `"USER_STATUS_ACTIVE",`)
+		s.Contains(content, "func UserStatus_JsonSchemaValue(name string) (int32, bool) {")
+		s.Regexp(`case "user-status-active":\s+return 1, true`, content)
+	})
+
+	s.Run("lower after strip_enum_prefix", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, StripEnumPrefix: true, EnumCase: "lower", EnumOneOf: true})[userFile]
+		s.Contains(content, `{Const: jsonschema.Ptr[any]("active")`)
+		s.Contains(content, `{Value: 1, Name: "active", Description: `)
+	})
+
+	s.Run("original emits no mapping", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, EnumCase: "original"})[userFile]
+		s.Contains(content, `"USER_STATUS_ACTIVE",`)
+		s.NotContains(content, "_JsonSchemaValue(")
+	})
+
+	s.Run("requires enum_names", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{EnumCase: "upper"})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "invalid enum_case parameter: upper requires enum_names")
+	})
+
+	s.Run("unsupported value", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{EnumNames: true, EnumCase: "camel"})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid enum_case parameter "camel"`)
+	})
+}
+
+// TestEnumAliasParameter tests that enum_alias accepts alternative strings for enum
+// values next to the enum's schema and generates a normalization function.
+func (s *PluginGeneratorTestSuite) TestEnumAliasParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("singular and repeated fields", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, EnumAliases: []string{
+			"users.v1.ComprehensiveUser.status=USER_STATUS_ACTIVE=active|enabled",
+			"users.v1.ComprehensiveUser.status=USER_STATUS_DELETED=removed",
+			"users.v1.ComprehensiveUser.status_history=USER_STATUS_ACTIVE=active",
+		}})[userFile]
+		s.Regexp(`schema\.Properties\["status"\] = &jsonschema\.Schema\{(?s:.)*?AnyOf: \[\]\*jsonschema\.Schema\{\s+\{\s+Ref:\s+UserStatus_JsonSchema_WithDefs\(defs\)\.Ref,\s+\},\s+\{Enum: \[\]any\{"active", "enabled"\}, Description: "Aliases of USER_STATUS_ACTIVE\."\},\s+\{Enum: \[\]any\{"removed"\}, Description: "Aliases of USER_STATUS_DELETED\."\},`, content)
+		s.Regexp(`schema\.Properties\["status_history"\] = &jsonschema\.Schema\{(?s:.)*?Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+AnyOf:`, content)
+		s.Contains(content, "func ComprehensiveUser_Status_JsonSchemaNormalize(name string) string {")
+		s.Regexp(`case "active", "enabled":\s+return "USER_STATUS_ACTIVE"\s+case "removed":\s+return "USER_STATUS_DELETED"`, content)
+		s.Contains(content, "func ComprehensiveUser_StatusHistory_JsonSchemaNormalize(name string) string {")
+	})
+
+	s.Run("aliases follow strip_enum_prefix", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{EnumNames: true, StripEnumPrefix: true, EnumAliases: []string{"users.v1.ComprehensiveUser.status=USER_STATUS_ACTIVE=enabled"}})[userFile]
+		s.Regexp(`case "enabled":\s+return "ACTIVE"`, content)
+	})
+
+	errorCases := map[string]struct {
+		opts plugin.Options
+		want string
+	}{
+		"requires enum_names": {plugin.Options{EnumAliases: []string{"users.v1.ComprehensiveUser.status=USER_STATUS_ACTIVE=active"}}, "invalid enum_alias parameter: it requires enum_names"},
+		"malformed value":     {plugin.Options{EnumNames: true, EnumAliases: []string{"users.v1.ComprehensiveUser.status=active"}}, "expected <field>=<value>=<alias>|<alias>..."},
+		"unknown field":       {plugin.Options{EnumNames: true, EnumAliases: []string{"users.v1.ComprehensiveUser.state=USER_STATUS_ACTIVE=active"}}, "field users.v1.ComprehensiveUser.state not found"},
+		"not an enum field":   {plugin.Options{EnumNames: true, EnumAliases: []string{"users.v1.ComprehensiveUser.name=USER_STATUS_ACTIVE=active"}}, "is not an enum field"},
+		"unknown value":       {plugin.Options{EnumNames: true, EnumAliases: []string{"users.v1.ComprehensiveUser.status=ACTIVE=active"}}, "has no value ACTIVE"},
+		"alias is a name":     {plugin.Options{EnumNames: true, EnumAliases: []string{"users.v1.ComprehensiveUser.status=USER_STATUS_ACTIVE=USER_STATUS_DELETED"}}, "is already a value name or alias"},
+	}
+	for name, tc := range errorCases {
+		s.Run(name, func() {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", tc.opts)
+			s.Require().Error(err)
+			s.Contains(err.Error(), tc.want)
+		})
+	}
+}
+
+// TestOneofPresenceParameter tests that oneof_presence=exactly_one drops the branch that
+// lets a message leave a oneof unset.
+func (s *PluginGeneratorTestSuite) TestOneofPresenceParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("default allows no member", func() {
+		content := s.RunGenerate()[userFile]
+		s.Regexp(`\{Required: \[\]string\{"bool_value"\}\},\s+\{Not: &jsonschema\.Schema\{AnyOf: \[\]\*jsonschema\.Schema\{`, content)
+	})
+
+	s.Run("exactly_one", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{OneofPresence: "exactly_one"})[userFile]
+		s.Regexp(`OneOf: \[\]\*jsonschema\.Schema\{\s+\{Required: \[\]string\{"string_value"\}\},\s+\{Required: \[\]string\{"int_value"\}\},\s+\{Required: \[\]string\{"bool_value"\}\},\s+\},`, content)
+		s.NotContains(content, "{Not: &jsonschema.Schema{AnyOf:")
+	})
+
+	s.Run("unsupported value", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{OneofPresence: "any"})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid oneof_presence parameter "any"`)
+	})
+}
+
+// TestCoerceParameter tests that coerce annotates the listed scalar fields with x-coerce,
+// next to their unchanged types.
+func (s *PluginGeneratorTestSuite) TestCoerceParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("default emits no hints", func() {
+		s.NotContains(s.RunGenerate()[userFile], "x-coerce")
+	})
+
+	s.Run("listed fields", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{Coercions: []string{
+			"users.v1.ComprehensiveUser.age=string",
+			"users.v1.ComprehensiveUser.is_active=string|integer",
+		}})[userFile]
+		s.Regexp(`schema\.Properties\["age"\] = &jsonschema\.Schema\{\s+Type:\s+"integer",[^}]*Extra:\s+map\[string\]any\{"x-coerce": \[\]string\{"string"\}\},`, content)
+		s.Regexp(`schema\.Properties\["is_active"\] = &jsonschema\.Schema\{\s+Type:\s+"boolean",[^}]*Extra:\s+map\[string\]any\{"x-coerce": \[\]string\{"string", "integer"\}\},`, content)
+		s.Equal(2, strings.Count(content, "x-coerce"))
+	})
+
+	s.Run("merged with presence metadata", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{PresenceMetadata: true, Coercions: []string{"users.v1.ComprehensiveUser.age=string"}})[userFile]
+		s.Contains(content, `map[string]any{"x-coerce": []string{"string"}, "x-proto-presence": "implicit"}`)
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.age":            `invalid coerce parameter "users.v1.ComprehensiveUser.age"`,
+			"users.v1.ComprehensiveUser.age=object":     `unsupported type "object"`,
+			"users.v1.ComprehensiveUser.missing=string": "field users.v1.ComprehensiveUser.missing not found",
+			"users.v1.ComprehensiveUser.address=string": "field users.v1.ComprehensiveUser.address is not a scalar field",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Coercions: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestMultipleOfParameter tests that multiple_of constrains the listed numeric fields,
+// and the elements of repeated fields, with multipleOf.
+func (s *PluginGeneratorTestSuite) TestMultipleOfParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("default emits no steps", func() {
+		s.NotContains(s.RunGenerate()[userFile], "MultipleOf")
+	})
+
+	s.Run("listed fields", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{MultipleOfs: []string{
+			"users.v1.ComprehensiveUser.age=5",
+			"users.v1.ComprehensiveUser.balance=0.01",
+			"users.v1.ComprehensiveUser.ratings=0.5",
+		}})[userFile]
+		s.Regexp(`schema\.Properties\["age"\] = &jsonschema\.Schema\{\s+Type:\s+"integer",[^}]*MultipleOf:\s+&\[\]float64\{5\}\[0\],`, content)
+		s.Regexp(`schema\.Properties\["balance"\] = &jsonschema\.Schema\{\s+Type:\s+"number",[^}]*MultipleOf:\s+&\[\]float64\{0\.01\}\[0\],`, content)
+		s.Regexp(`schema\.Properties\["ratings"\] = &jsonschema\.Schema\{[^}]+Items: &jsonschema\.Schema\{\s+Type:\s+"number",\s+MultipleOf:\s+&\[\]float64\{0\.5\}\[0\],`, content)
+		s.Equal(3, strings.Count(content, "MultipleOf"))
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.age":       `invalid multiple_of parameter "users.v1.ComprehensiveUser.age"`,
+			"users.v1.ComprehensiveUser.age=0":     `"0" is not a positive number`,
+			"users.v1.ComprehensiveUser.age=ten":   `"ten" is not a positive number`,
+			"users.v1.ComprehensiveUser.missing=1": "field users.v1.ComprehensiveUser.missing not found",
+			"users.v1.ComprehensiveUser.name=1":    "field users.v1.ComprehensiveUser.name is not a numeric field",
+			"users.v1.ComprehensiveUser.address=1": "field users.v1.ComprehensiveUser.address is not a numeric field",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{MultipleOfs: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestDefaultParameter tests that default emits the given JSON values as the default
+// keyword of fields, inline or read from a file, and that values not matching the field's
+// type are rejected.
+func (s *PluginGeneratorTestSuite) TestDefaultParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("default emits no defaults", func() {
+		s.NotContains(s.RunGenerate()[userFile], "Default:")
+	})
+
+	s.Run("listed fields", func() {
+		s.SetupTest()
+		path := filepath.Join(s.T().TempDir(), "attributes.json")
+		s.Require().NoError(os.WriteFile(path, []byte("{\n  \"team\": \"core\",\n  \"tier\": \"gold\"\n}\n"), 0o644))
+		content := s.RunGenerateWithOptions(plugin.Options{Defaults: []string{
+			`users.v1.ComprehensiveUser.name="anonymous"`,
+			"users.v1.ComprehensiveUser.age=18",
+			"users.v1.ComprehensiveUser.is_active=true",
+			`users.v1.ComprehensiveUser.status="USER_STATUS_ACTIVE"`,
+			`users.v1.ComprehensiveUser.tags=["new"]`,
+			"users.v1.ComprehensiveUser.attributes=" + path,
+		}})[userFile]
+		hasDefault := func(field, value string) {
+			s.Regexp(`schema\.Properties\["`+field+`"\] = &jsonschema\.Schema\{[^}]+Default:\s+json\.RawMessage\(`+regexp.QuoteMeta("`"+value+"`")+`\),`, content)
+		}
+		hasDefault("name", `"anonymous"`)
+		hasDefault("age", "18")
+		hasDefault("is_active", "true")
+		hasDefault("status", `"USER_STATUS_ACTIVE"`)
+		hasDefault("tags", `["new"]`)
+		hasDefault("attributes", `{"team":"core","tier":"gold"}`)
+		s.Equal(6, strings.Count(content, "Default:"))
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.age":              `invalid default parameter "users.v1.ComprehensiveUser.age"`,
+			"users.v1.ComprehensiveUser.age={":            `invalid default parameter "users.v1.ComprehensiveUser.age={"`,
+			"users.v1.ComprehensiveUser.age=missing.json": "missing.json",
+			"users.v1.ComprehensiveUser.missing=1":        "field users.v1.ComprehensiveUser.missing not found",
+			"users.v1.ComprehensiveUser.age=1.5":          "1.5 is not a valid value of field users.v1.ComprehensiveUser.age",
+			"users.v1.ComprehensiveUser.name=true":        "true is not a valid value of field users.v1.ComprehensiveUser.name",
+			`users.v1.ComprehensiveUser.status="NOPE"`:    `"NOPE" is not a valid value of field users.v1.ComprehensiveUser.status`,
+			"users.v1.ComprehensiveUser.tags=[1]":         "[1] is not a valid value of field users.v1.ComprehensiveUser.tags",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Defaults: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestConstParameter tests that const emits the given JSON values as the const keyword of
+// fields, with enum values as the schema lists them, and that values not matching the
+// field's type are rejected.
+func (s *PluginGeneratorTestSuite) TestConstParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	consts := []string{
+		`users.v1.ComprehensiveUser.name="anonymous"`,
+		"users.v1.ComprehensiveUser.age=18",
+		"users.v1.ComprehensiveUser.status=1",
+		`users.v1.ComprehensiveUser.status_history=["USER_STATUS_ACTIVE"]`,
+		`users.v1.ComprehensiveUser.attributes={"team":"core"}`,
+	}
+	hasConst := func(content, field, literal string) {
+		s.Regexp(`schema\.Properties\["`+field+`"\] = &jsonschema\.Schema\{(?s:.*?)Const:\s+jsonschema\.Ptr\[any\]\(`+regexp.QuoteMeta(literal)+`\),`, content)
+	}
+
+	s.Run("listed fields", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{Consts: consts})[userFile]
+		hasConst(content, "name", `"anonymous"`)
+		hasConst(content, "age", "18")
+		hasConst(content, "status", "1")
+		hasConst(content, "status_history", "[]any{1}")
+		hasConst(content, "attributes", `map[string]any{"team": "core"}`)
+		s.Len(regexp.MustCompile(`Const:\s+jsonschema\.Ptr\[any\]\(`).FindAllString(content, -1), 5)
+	})
+
+	s.Run("enum names", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{Consts: consts, EnumNames: true})[userFile]
+		hasConst(content, "status", `"USER_STATUS_ACTIVE"`)
+		hasConst(content, "status_history", `[]any{"USER_STATUS_ACTIVE"}`)
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.age":           `invalid const parameter "users.v1.ComprehensiveUser.age"`,
+			"users.v1.ComprehensiveUser.missing=1":     "field users.v1.ComprehensiveUser.missing not found",
+			"users.v1.ComprehensiveUser.age=1.5":       "1.5 is not a valid value of field users.v1.ComprehensiveUser.age",
+			"users.v1.ComprehensiveUser.name=true":     "true is not a valid value of field users.v1.ComprehensiveUser.name",
+			`users.v1.ComprehensiveUser.status="NOPE"`: `"NOPE" is not a valid value of field users.v1.ComprehensiveUser.status`,
+			"users.v1.ComprehensiveUser.status=42":     "42 is not a value of enum users.v1.UserStatus",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Consts: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestAllowedValuesParameter tests that allowed_values narrows the enum of fields to the
+// listed values, given by proto name, schema name or number, and that values of other
+// enums are rejected.
+func (s *PluginGeneratorTestSuite) TestAllowedValuesParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+	allowedValues := []string{
+		"users.v1.ComprehensiveUser.status=USER_STATUS_SUSPENDED|1",
+		"users.v1.ComprehensiveUser.status_history=2",
+	}
+	hasEnum := func(content, field, literals string) {
+		s.Regexp(`schema\.Properties\["`+field+`"\] = &jsonschema\.Schema\{(?s:.*?)Enum:\s+\[\]any\{`+regexp.QuoteMeta(literals)+`\},`, content)
+	}
+
+	s.Run("listed fields", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{AllowedValues: allowedValues})[userFile]
+		hasEnum(content, "status", "1, 3")
+		hasEnum(content, "status_history", "2")
+	})
+
+	s.Run("schema names", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{
+			AllowedValues:   []string{"users.v1.ComprehensiveUser.status=ACTIVE|USER_STATUS_SUSPENDED"},
+			EnumNames:       true,
+			StripEnumPrefix: true,
+		})[userFile]
+		hasEnum(content, "status", `"ACTIVE", "SUSPENDED"`)
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.status":        `invalid allowed_values parameter "users.v1.ComprehensiveUser.status"`,
+			"users.v1.ComprehensiveUser.status=1||2":   "empty value",
+			"users.v1.ComprehensiveUser.missing=1":     "field users.v1.ComprehensiveUser.missing not found",
+			"users.v1.ComprehensiveUser.age=1":         "field users.v1.ComprehensiveUser.age is not an enum field",
+			"users.v1.ComprehensiveUser.status=ACTIVE": "ACTIVE is not a value of enum users.v1.UserStatus",
+			"users.v1.ComprehensiveUser.status=42":     "42 is not a value of enum users.v1.UserStatus",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{AllowedValues: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestExtensionParameter tests that extension emits the listed keywords on the schemas of
+// the fields, with JSON scalar values and unquoted strings.
+func (s *PluginGeneratorTestSuite) TestExtensionParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("listed fields", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{
+			Extensions: []string{
+				"users.v1.ComprehensiveUser.name:x-ui-widget=textarea",
+				"users.v1.ComprehensiveUser.name:x-ui-order=2",
+				`users.v1.ComprehensiveUser.id:x-ui-widget="read only"`,
+				"users.v1.ComprehensiveUser.address:x-sensitive=true",
+			},
+			Coercions: []string{"users.v1.ComprehensiveUser.id=string"},
+		})[userFile]
+		s.Regexp(`schema\.Properties\["name"\] = &jsonschema\.Schema\{[^}]*Extra:\s+map\[string\]any\{"x-ui-order": 2, "x-ui-widget": "textarea"\},`, content)
+		s.Regexp(`Extra:\s+map\[string\]any\{"x-coerce": \[\]string\{"string"\}, "x-ui-widget": "read only"\},`, content)
+		s.Regexp(`schema\.Properties\["address"\] = &jsonschema\.Schema\{[^}]*Extra:\s+map\[string\]any\{"x-sensitive": true\},`, content)
+	})
+
+	s.Run("element targets", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{Verify: true, Extensions: []string{
+			"users.v1.ComprehensiveUser.tags[items]:x-ui-widget=chip",
+			"users.v1.ComprehensiveUser.addresses[items]:x-ui-widget=card",
+			"users.v1.ComprehensiveUser.attributes[values]:x-sensitive=true",
+		}})[userFile]
+		s.Regexp(`schema\.Properties\["tags"\] = &jsonschema\.Schema\{[^}]*Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Extra: map\[string\]any\{"x-ui-widget": "chip"\},`, content)
+		s.Regexp(`Items:\s+&jsonschema\.Schema\{Ref: Address_JsonSchema_WithDefs\(defs\)\.Ref, Extra: map\[string\]any\{"x-ui-widget": "card"\}\},`, content)
+		s.Regexp(`AdditionalProperties: &jsonschema\.Schema\{\s+Type:\s+"string",\s+Extra: map\[string\]any\{"x-sensitive": true\},`, content)
+		s.NotRegexp(`schema\.Properties\["tags"\] = &jsonschema\.Schema\{\s+Type:\s+"array",[^}]*\n\t\tExtra:`, content)
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.name=textarea":            `invalid extension parameter "users.v1.ComprehensiveUser.name=textarea"`,
+			"users.v1.ComprehensiveUser.name:ui-widget=textarea":  `keyword "ui-widget" does not start with x-`,
+			"users.v1.ComprehensiveUser.name:x-ui-widget=null":    "value null is not a string, number or boolean",
+			"users.v1.ComprehensiveUser.name:x-ui-widget=[1]":     "value [1] is not a string, number or boolean",
+			"users.v1.ComprehensiveUser.missing:x-ui-widget=text": "field users.v1.ComprehensiveUser.missing not found",
+			"users.v1.ComprehensiveUser.name[items]:x-ui=chip":    "field users.v1.ComprehensiveUser.name is not a repeated field",
+			"users.v1.ComprehensiveUser.tags[values]:x-ui=chip":   "field users.v1.ComprehensiveUser.tags is not a map field",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{Extensions: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestRawSchemaParameter tests that raw_schema merges inline and file patches into the
+// generated schemas of fields at generation time, and that invalid patches are rejected.
+func (s *PluginGeneratorTestSuite) TestRawSchemaParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("inline and file patches", func() {
+		file := filepath.Join(s.T().TempDir(), "address.json")
+		s.Require().NoError(os.WriteFile(file, []byte("{\n  \"description\": \"Where the user lives.\",\n  \"x-ui-widget\": \"map\"\n}\n"), 0o644))
+		content := s.RunGenerateWithOptions(plugin.Options{Verify: true, RawSchemas: []string{
+			`users.v1.ComprehensiveUser.name={"maxLength":120}`,
+			"users.v1.ComprehensiveUser.address=" + file,
+		}})[userFile]
+		s.NotContains(content, "schemautil")
+		s.Contains(content, "\tschema.Properties[\"name\"] = &jsonschema.Schema{\n\t\tType:        \"string\",\n\t\tTitle:       \"\",\n\t\tDescription: \"Full name of the user.\",\n\t\tMaxLength:   &[]int{120}[0],\n\t}\n")
+		s.Contains(content, "\tschema.Properties[\"address\"] = &jsonschema.Schema{\n\t\tRef:         Address_JsonSchema_WithDefs(defs).Ref,\n\t\tDescription: \"Where the user lives.\",\n\t\tExtra: map[string]any{\n\t\t\t\"x-ui-widget\": \"map\",\n\t\t},\n\t}\n")
+	})
+
+	s.Run("nested patches and removals", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{Verify: true, RawSchemas: []string{
+			`users.v1.ComprehensiveUser.tags={"items":{"pattern":"^[a-z]+$"},"title":null,"type":["array","null"]}`,
+			`users.v1.ComprehensiveUser.address={"properties":{"city":{"minLength":2}},"x-ui":{"widget":"map"}}`,
+		}})[userFile]
+		s.Contains(content, "\tschema.Properties[\"tags\"] = &jsonschema.Schema{\n\t\tDescription: \"List of tags associated with the user.\",\n\t\tItems: &jsonschema.Schema{\n\t\t\tType:    \"string\",\n\t\t\tPattern: \"^[a-z]+$\",\n\t\t},\n\t\tTypes: []string{\"array\", \"null\"},\n\t}\n")
+		s.Contains(content, `Properties: map[string]*jsonschema.Schema{"city": {MinLength: &[]int{2}[0]}},`)
+		s.Contains(content, `"x-ui": map[string]any{"widget": "map"},`)
+	})
+
+	s.Run("element targets", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{Verify: true, RawSchemas: []string{
+			`users.v1.ComprehensiveUser.tags={"minItems":1}`,
+			`users.v1.ComprehensiveUser.tags[items]={"maxLength":20}`,
+			`users.v1.ComprehensiveUser.attributes[values]={"minLength":1}`,
+		}})[userFile]
+		s.Regexp(`schema\.Properties\["tags"\] = &jsonschema\.Schema\{[^}]*Items: &jsonschema\.Schema\{\s+Type:\s+"string",\s+MaxLength: &\[\]int\{20\}\[0\],\s+\},\s+MinItems:\s+&\[\]int\{1\}\[0\],`, content)
+		s.Regexp(`AdditionalProperties: &jsonschema\.Schema\{\s+Type:\s+"string",\s+MinLength: &\[\]int\{1\}\[0\],`, content)
+	})
+
+	s.Run("invalid values", func() {
+		for value, want := range map[string]string{
+			"users.v1.ComprehensiveUser.name":                       `invalid raw_schema parameter "users.v1.ComprehensiveUser.name"`,
+			`users.v1.ComprehensiveUser.name={"maxLength":`:         "unexpected end of JSON input",
+			`users.v1.ComprehensiveUser.name={"maxLength":"ten"}`:   "cannot be unmarshaled into an int",
+			"users.v1.ComprehensiveUser.name=missing.json":          "missing.json",
+			`users.v1.ComprehensiveUser.missing={"title":"x"}`:      "field users.v1.ComprehensiveUser.missing not found",
+			`users.v1.ComprehensiveUser.tags={"maxLength":5}`:       "maxLength does not apply to the container schema of field users.v1.ComprehensiveUser.tags; patch its elements with users.v1.ComprehensiveUser.tags[items]",
+			`users.v1.ComprehensiveUser.attributes={"pattern":"a"}`: "patch its elements with users.v1.ComprehensiveUser.attributes[values]",
+			`users.v1.ComprehensiveUser.name[items]={"title":"x"}`:  "field users.v1.ComprehensiveUser.name is not a repeated field",
+		} {
+			s.SetupTest()
+			err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{RawSchemas: []string{value}})
+			s.Require().Error(err, value)
+			s.Contains(err.Error(), want)
+		}
+	})
+}
+
+// TestInferFormatsParameter tests that infer_formats gives string fields named like
+// emails, URLs and timestamps a format, reports it, and leaves other fields alone.
+func (s *PluginGeneratorTestSuite) TestInferFormatsParameter() {
+	const userFile = "github.com/newtonnthiga/users/v1/user_jsonschema.pb.go"
+
+	s.Run("disabled by default", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{})[userFile]
+		s.NotContains(content, "infer_formats")
+	})
+
+	s.Run("inferred formats", func() {
+		content := s.RunGenerateWithOptions(plugin.Options{InferFormats: true})[userFile]
+		s.Regexp(`schema\.Properties\["email"\] = &jsonschema\.Schema\{[^}]*Format:\s+"email",`, content)
+		s.Contains(content, "// Formats inferred from field names (infer_formats).")
+		s.Contains(content, "//   - users.v1.User.email: email\n")
+		s.Contains(content, "//   - users.v1.CreateUserRequest.email: email\n")
+		// Timestamps already have a format, and "emails" and "email_query" do not match.
+		s.NotContains(content, "created_at: date-time")
+		s.NotContains(content, "emails: email")
+		s.NotContains(content, "email_query: email")
+	})
+}
+
+// TestStreamFramingParameter tests that stream_framing describes the response streams of
+// server-streaming methods, and only those.
+func (s *PluginGeneratorTestSuite) TestStreamFramingParameter() {
+	const streamFile = "github.com/newtonnthiga/users/v1/user_jsonschema_stream.pb.go"
+
+	s.Run("default emits no stream schemas", func() {
+		s.NotContains(s.RunGenerate(), streamFile)
+	})
+
+	s.Run("ndjson", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{StreamFraming: "ndjson"})[streamFile]
+		s.Require().NotEmpty(content)
+		s.Contains(content, "func UserService_StreamUsers_StreamJsonSchema() *jsonschema.Schema {")
+		s.Regexp(`Description:\s+"StreamUsers streams the requested users as they are retrieved\.\\n\\nUserService provides operations for managing users\.",\s+Ref:\s+User_JsonSchema_WithDefs\(defs\)\.Ref,\s+Defs:\s+defs,`, content)
+		s.Contains(content, `"x-stream-framing": "ndjson", "x-stream-method": "/users.v1.UserService/StreamUsers"`)
+		s.NotContains(content, "UserService_GetUser_", "unary methods get no stream schema")
+	})
+
+	s.Run("array", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{StreamFraming: "array"})[streamFile]
+		s.Require().NotEmpty(content)
+		s.Regexp(`Type:\s+"array",\s+Items:\s+User_JsonSchema_WithDefs\(defs\),\s+Defs:\s+defs,`, content)
+		s.Contains(content, `"x-stream-framing": "array"`)
+	})
+
+	s.Run("method description override", func() {
+		s.SetupTest()
+		content := s.RunGenerateWithOptions(plugin.Options{
+			StreamFraming:      "ndjson",
+			MethodDescriptions: []string{"users.v1.UserService.StreamUsers=Streams users for the SSE gateway."},
+		})[streamFile]
+		s.Contains(content, `Description: "Streams users for the SSE gateway.\n\nUserService provides operations for managing users.",`)
+
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{MethodDescriptions: []string{"users.v1.UserService.ListUsers=Lists users."}})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "invalid method_description parameter: method users.v1.UserService.ListUsers not found")
+	})
+
+	s.Run("unknown framing is rejected", func() {
+		s.SetupTest()
+		err := plugin.GenerateWithOptions(s.Plugin(), "test", plugin.Options{StreamFraming: "sse"})
+		s.Require().Error(err)
+		s.Contains(err.Error(), `invalid stream_framing parameter "sse"`)
+	})
+}
+
+// TestWarnings tests that requests which silently generate less than their files ask for
+// are reported: no file importing the options, option extensions that were not resolved
+// and option fields unknown to the plugin.
+func (s *PluginGeneratorTestSuite) TestWarnings() {
+	s.Run("well-formed request", func() {
+		s.Empty(plugin.Warnings(s.Plugin()))
+	})
+
+	s.Run("no file imports the options", func() {
+		fds := loadDescriptorSet(s.T(), filepath.Join(descriptorsDir(), "no_options.pb"))
+		warnings := plugin.Warnings(createTestPlugin(s.T(), fds, []string{"no_options/v1/no_options.proto"}))
+		s.Require().Len(warnings, 1)
+		s.Contains(warnings[0], "no file to generate imports alis/open/options/v1/options.proto")
+	})
+
+	// userFile returns a copy of the descriptor set and its users/v1/user.proto file.
+	userFile := func() (*descriptorpb.FileDescriptorSet, *descriptorpb.FileDescriptorProto) {
+		fds := proto.Clone(s.FileDescriptorSet()).(*descriptorpb.FileDescriptorSet)
+		for _, f := range fds.File {
+			if f.GetName() == "users/v1/user.proto" {
+				return fds, f
+			}
+		}
+		s.FailNow("users/v1/user.proto not found")
+		return nil, nil
+	}
+
+	s.Run("unresolved option extension", func() {
+		fds, file := userFile()
+		data, err := proto.Marshal(file.Options)
+		s.Require().NoError(err)
+		file.Options = &descriptorpb.FileOptions{}
+		s.Require().NoError(proto.UnmarshalOptions{Resolver: new(protoregistry.Types)}.Unmarshal(data, file.Options))
+
+		warnings := plugin.Warnings(createTestPlugin(s.T(), fds, []string{"users/v1/user.proto"}))
+		s.Require().Len(warnings, 1)
+		s.Contains(warnings[0], "users/v1/user.proto: option (alis.open.options.v1.file) could not be resolved")
+	})
+
+	s.Run("unknown option field", func() {
+		fds, file := userFile()
+		jsonSchema := proto.GetExtension(file.Options, optionsPb.E_File).(*optionsPb.FileOptions).GetJsonSchema()
+		jsonSchema.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 99, protowire.VarintType), 1))
+
+		warnings := plugin.Warnings(createTestPlugin(s.T(), fds, []string{"users/v1/user.proto"}))
+		s.Require().Len(warnings, 1)
+		s.Contains(warnings[0], "users/v1/user.proto: option (alis.open.options.v1.file) sets field 99 of alis.open.options.v1.FileOptions.JsonSchema")
+	})
+}
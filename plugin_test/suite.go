@@ -9,17 +9,20 @@ import (
 	"strings"
 
 	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/testsupport"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
 // PluginTestSuite is the base test suite that provides common setup and teardown
 // functionality for all plugin tests. It handles:
 // - Finding the workspace root
-// - Regenerating descriptor sets from proto files
+// - Loading descriptor sets, checked against the proto files
 // - Loading FileDescriptorSet and creating protogen.Plugin instances
 // - Finding target files and messages for testing
 type PluginTestSuite struct {
@@ -42,7 +45,7 @@ type PluginTestSuite struct {
 }
 
 // SetupSuite runs once before all tests in the suite.
-// It finds the workspace root and regenerates the descriptor set.
+// It finds the workspace root and loads the descriptor set.
 func (s *PluginTestSuite) SetupSuite() {
 	s.workspaceRoot = s.findWorkspaceRoot()
 	s.regenerateDescriptorSet()
@@ -92,27 +95,38 @@ func (s *PluginTestSuite) findWorkspaceRoot() string {
 	}
 }
 
-// regenerateDescriptorSet generates the FileDescriptorSet from the proto files.
-// This ensures tests always use fresh descriptors matching the current protos.
+// regenerateDescriptorSet loads the FileDescriptorSet of the users/v1 protos, checking it
+// against the protos when protoc is available (see compileProtos).
 // Includes all proto files in the users/v1 package to support multi-file scenarios.
 func (s *PluginTestSuite) regenerateDescriptorSet() {
-	protoPath := filepath.Join(s.workspaceRoot, "testdata", "protos")
 	// Include all proto files in the package - user.proto imports common.proto
-	protoFiles := []string{"users/v1/user.proto", "users/v1/common.proto", "users/v1/admin.proto"}
-	outputPath := filepath.Join(s.workspaceRoot, "testdata", "descriptors", "user.pb")
+	s.fds = s.compileProtos("user.pb", "users/v1/user.proto", "users/v1/common.proto", "users/v1/admin.proto")
+	s.T().Logf("Loaded descriptor set with %d files", len(s.fds.File))
+}
+
+// compileProtos returns the embedded descriptor set testsupport/descriptors/<name> of the
+// given files (relative to testdata/protos). Outside hermetic mode it first runs protoc on
+// them into a temporary directory and fails if a file under testdata/protos differs from
+// its embedded copy, so that a proto change without a descriptor update does not go
+// unnoticed; with -update-descriptors it writes the compiled set to
+// testsupport/descriptors and returns it instead. Imports from other include paths are
+// not compared, because their sources depend on the environment.
+func (s *PluginTestSuite) compileProtos(name string, files ...string) *descriptorpb.FileDescriptorSet {
+	embedded, embeddedErr := testsupport.DescriptorSet(name)
+	if hermeticMode() {
+		s.Require().NoError(embeddedErr, "No embedded descriptor set for %v", files)
+		return embedded
+	}
 
-	// Create output directory if it doesn't exist
-	err := os.MkdirAll(filepath.Dir(outputPath), 0o755)
-	s.Require().NoError(err, "Failed to create descriptor output directory")
+	protoPath := filepath.Join(s.workspaceRoot, "testdata", "protos")
+	outputPath := filepath.Join(s.TempDir(), name)
 
-	// Build protoc command arguments
 	args := []string{
 		"--descriptor_set_out=" + outputPath,
 		"--include_imports",
 		"--include_source_info",
 		"--proto_path=" + protoPath,
 	}
-
 	// Find alis proto path if available (for custom options)
 	// Use home directory to make path portable across systems
 	if homeDir, err := os.UserHomeDir(); err == nil {
@@ -121,18 +135,59 @@ func (s *PluginTestSuite) regenerateDescriptorSet() {
 			args = append(args, "--proto_path="+alisPath)
 		}
 	}
+	args = append(args, files...)
 
-	// Add all proto files
-	args = append(args, protoFiles...)
-
-	// Run protoc
 	cmd := exec.Command("protoc", args...)
 	output, err := cmd.CombinedOutput()
 	s.Require().NoError(err, "Failed to run protoc: %s\nArgs: %v", string(output), args)
 
-	// Load the generated descriptor set
-	s.fds = s.loadDescriptorSetFromPath(outputPath)
-	s.T().Logf("Regenerated descriptor set with %d files", len(s.fds.File))
+	compiled := s.loadDescriptorSetFromPath(outputPath)
+	if *updateDescriptors {
+		data, err := os.ReadFile(outputPath)
+		s.Require().NoError(err)
+		s.Require().NoError(os.WriteFile(filepath.Join(s.workspaceRoot, "testsupport", "descriptors", name), data, 0o644))
+		return compiled
+	}
+	s.Require().NoError(embeddedErr, "No embedded descriptor set for %v; run the tests with -update-descriptors", files)
+	s.requireSameFixtures(name, protoPath, compiled, embedded)
+	return embedded
+}
+
+// requireSameFixtures fails the test if a file of the compiled descriptor set that is
+// declared under protoPath is missing from the embedded set or differs from its copy there.
+// Custom options are compared by value, since compilers serialize them in different orders.
+func (s *PluginTestSuite) requireSameFixtures(name, protoPath string, compiled, embedded *descriptorpb.FileDescriptorSet) {
+	files, err := protodesc.NewFiles(compiled)
+	s.Require().NoError(err)
+	resolve := func(f *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorProto {
+		if f == nil {
+			return nil
+		}
+		data, err := proto.Marshal(f)
+		s.Require().NoError(err)
+		resolved := &descriptorpb.FileDescriptorProto{}
+		s.Require().NoError(proto.UnmarshalOptions{Resolver: dynamicpb.NewTypes(files)}.Unmarshal(data, resolved))
+		return resolved
+	}
+	embeddedFiles := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, f := range embedded.File {
+		embeddedFiles[f.GetName()] = f
+	}
+	for _, f := range compiled.File {
+		if _, err := os.Stat(filepath.Join(protoPath, f.GetName())); err != nil {
+			continue
+		}
+		s.Require().True(proto.Equal(resolve(f), resolve(embeddedFiles[f.GetName()])),
+			"testsupport/descriptors/%s is out of date for %s; run the tests with -update-descriptors and commit the result", name, f.GetName())
+	}
+}
+
+// requireProtoc skips the current test in hermetic mode, for tests that run protoc
+// themselves.
+func (s *PluginTestSuite) requireProtoc() {
+	if hermeticMode() {
+		s.T().Skip("hermetic mode: skipping test that runs protoc")
+	}
 }
 
 // loadDescriptorSetFromPath loads a FileDescriptorSet from a .pb file.
@@ -224,6 +279,23 @@ func (s *PluginTestSuite) RunGenerate() map[string]string {
 	return result
 }
 
+// RunGenerateWithOptions runs GenerateWithOptions and returns the generated content.
+func (s *PluginTestSuite) RunGenerateWithOptions(opts plugin.Options) map[string]string {
+	err := plugin.GenerateWithOptions(s.plugin, "test", opts)
+	s.Require().NoError(err, "Generate failed")
+
+	resp := s.plugin.Response()
+	s.Require().Empty(resp.GetError(), "Generate response error: %s", resp.GetError())
+
+	result := make(map[string]string)
+	for _, file := range resp.File {
+		if file.Content != nil {
+			result[file.GetName()] = file.GetContent()
+		}
+	}
+	return result
+}
+
 // GetGeneratedContent is a convenience method that returns the user.proto generated file's content.
 // This is the primary test file that contains most message types.
 func (s *PluginTestSuite) GetGeneratedContent() string {
@@ -0,0 +1,71 @@
+//go:build plugintest
+
+package plugintest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/plugin"
+	"github.com/alis-exchange/protoc-gen-go-jsonschema/testsupport"
+	"github.com/stretchr/testify/suite"
+)
+
+// TestSupportTestSuite contains tests for the testsupport package.
+type TestSupportTestSuite struct {
+	suite.Suite
+}
+
+// TestTestSupportSuite runs the TestSupportTestSuite.
+func TestTestSupportSuite(t *testing.T) {
+	suite.Run(t, new(TestSupportTestSuite))
+}
+
+// TestNames tests that the descriptor sets of the test protos and of every fixture area
+// are embedded.
+func (s *TestSupportTestSuite) TestNames() {
+	names := testsupport.Names()
+	s.Contains(names, "user.pb")
+	s.Contains(names, "editions.pb")
+	for _, area := range fixtureAreas {
+		s.Contains(names, "fixtures_"+area+".pb")
+	}
+}
+
+// TestDescriptorSet tests that embedded descriptor sets include their imports and that
+// each call returns a new copy.
+func (s *TestSupportTestSuite) TestDescriptorSet() {
+	fds, err := testsupport.DescriptorSet("user.pb")
+	s.Require().NoError(err)
+
+	var paths []string
+	for _, f := range fds.File {
+		paths = append(paths, f.GetName())
+	}
+	s.Contains(paths, "users/v1/user.proto")
+	s.Contains(paths, "google/protobuf/descriptor.proto")
+	s.Contains(paths, "alis/open/options/v1/options.proto")
+
+	fds.File = nil
+	again, err := testsupport.DescriptorSet("user.pb")
+	s.Require().NoError(err)
+	s.NotEmpty(again.File)
+
+	_, err = testsupport.DescriptorSet("missing.pb")
+	s.Require().Error(err)
+	s.Contains(err.Error(), `unknown descriptor set "missing.pb"`)
+}
+
+// TestPlugin tests that plugins built from embedded descriptor sets generate schemas
+// without protoc.
+func (s *TestSupportTestSuite) TestPlugin() {
+	p, err := testsupport.Plugin("library.pb", "library/v1/library.proto")
+	s.Require().NoError(err)
+	s.Require().NoError(plugin.Generate(p, "test"))
+
+	resp := p.Response()
+	s.Require().Empty(resp.GetError())
+	s.Require().Len(resp.File, 1)
+	s.True(strings.HasSuffix(resp.File[0].GetName(), "library_jsonschema.pb.go"))
+	s.Contains(resp.File[0].GetContent(), "func (x *Book) JsonSchema() *jsonschema.Schema {")
+}
@@ -30,6 +30,28 @@ const (
 // Usage: go test -update
 var updateGolden = flag.Bool("update", false, "update golden files")
 
+// hermetic is a flag to run the tests without protoc, using the descriptor sets embedded
+// by the testsupport package.
+// Usage: go test -hermetic
+var hermetic = flag.Bool("hermetic", false, "use embedded descriptor sets instead of running protoc")
+
+// updateDescriptors is a flag to write the descriptor sets compiled by protoc to
+// testsupport/descriptors instead of checking the embedded ones against them. Run it with
+// the full googleapis and alis sources on the include path, since the sets embed every
+// import with its comments.
+// Usage: go test -update-descriptors
+var updateDescriptors = flag.Bool("update-descriptors", false, "write freshly compiled descriptor sets to testsupport/descriptors")
+
+// hermeticMode reports whether tests use the embedded descriptor sets: with the -hermetic
+// flag, or when protoc is not in PATH.
+func hermeticMode() bool {
+	if *hermetic {
+		return true
+	}
+	_, err := exec.LookPath("protoc")
+	return err != nil
+}
+
 // testdataDir returns the path to the testdata directory relative to the plugin_test package.
 func testdataDir() string {
 	return filepath.Join("..", "testdata")
@@ -40,9 +62,10 @@ func protosDir() string {
 	return filepath.Join(testdataDir(), "protos")
 }
 
-// descriptorsDir returns the path to the descriptors directory within testdata.
+// descriptorsDir returns the path to the descriptor sets embedded by the testsupport
+// package.
 func descriptorsDir() string {
-	return filepath.Join(testdataDir(), "descriptors")
+	return filepath.Join("..", "testsupport", "descriptors")
 }
 
 // goldenDir returns the path to the golden files directory within testdata.
@@ -120,57 +143,6 @@ func generateDescriptorSet(t *testing.T, protoPath, protoFile, outputPath string
 	return loadDescriptorSet(t, outputPath)
 }
 
-// assertGoldenFile compares actual content against a golden file.
-// If the -update flag is set, it updates the golden file instead.
-// It strips timestamp lines from comparison to avoid false failures.
-func assertGoldenFile(t *testing.T, actual, goldenPath string, update bool) {
-	t.Helper()
-
-	if update {
-		// Create directory if it doesn't exist
-		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
-			t.Fatalf("Failed to create golden file directory: %v", err)
-		}
-		if err := os.WriteFile(goldenPath, []byte(actual), 0o644); err != nil {
-			t.Fatalf("Failed to update golden file %s: %v", goldenPath, err)
-		}
-		t.Logf("Updated golden file: %s", goldenPath)
-		return
-	}
-
-	expected, err := os.ReadFile(goldenPath)
-	if err != nil {
-		t.Fatalf("Failed to read golden file %s: %v\nRun with -update to create it", goldenPath, err)
-	}
-
-	// Normalize both contents by removing timestamp lines for comparison
-	actualNorm := normalizeGeneratedContent(actual)
-	expectedNorm := normalizeGeneratedContent(string(expected))
-
-	if actualNorm != expectedNorm {
-		t.Errorf("Output does not match golden file %s.\nRun with -update to update it.\n\nExpected:\n%s\n\nActual:\n%s",
-			goldenPath, string(expected), actual)
-	}
-}
-
-// normalizeGeneratedContent removes variable content like timestamps for comparison.
-func normalizeGeneratedContent(content string) string {
-	lines := strings.Split(content, "\n")
-	var normalized []string
-	for _, line := range lines {
-		// Skip the "Generated on:" line as it contains a timestamp
-		if strings.Contains(line, "Generated on:") {
-			continue
-		}
-		// Skip the "Plugin version:" line as it may vary
-		if strings.Contains(line, "Plugin version:") {
-			continue
-		}
-		normalized = append(normalized, line)
-	}
-	return strings.Join(normalized, "\n")
-}
-
 // findWorkspaceRoot finds the root of the Go module by looking for go.mod.
 func findWorkspaceRoot(t *testing.T) string {
 	t.Helper()
@@ -0,0 +1,110 @@
+package schemautil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// VolatileKeys are the schema keywords removed by canonicalization. They carry
+// annotations about how a schema was produced rather than what it accepts, so they
+// may differ between otherwise identical schemas.
+var VolatileKeys = []string{
+	"$comment",
+	"x-generation-options",
+	"x-generator",
+}
+
+// Canonical returns a stable byte representation of the schema: compact JSON with
+// object keys sorted, $ref values normalized and VolatileKeys removed at every level.
+// Schemas that are structurally equal always produce identical bytes, so the result is
+// suitable for hashing and golden comparisons. A nil schema yields "null".
+//
+// $ref values are normalized by decoding percent-encoding in their fragment, rewriting
+// legacy "#/definitions/" pointers to "#/$defs/", and writing "#" for a bare "" or "#/".
+func Canonical(schema *jsonschema.Schema) ([]byte, error) {
+	value, err := canonicalValue(schema)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// encoding/json writes map keys in sorted order.
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Hash returns the hex-encoded SHA-256 digest of the schema's canonical representation.
+func Hash(schema *jsonschema.Schema) (string, error) {
+	data, err := Canonical(schema)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalValue returns the schema's JSON representation decoded into maps, slices
+// and scalars, with $ref values normalized and VolatileKeys removed. A nil schema
+// yields nil.
+func canonicalValue(schema *jsonschema.Schema) (any, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	canonicalize(value)
+	return value, nil
+}
+
+// canonicalize normalizes a decoded JSON value in place.
+func canonicalize(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range VolatileKeys {
+			delete(v, key)
+		}
+		if ref, ok := v["$ref"].(string); ok {
+			v["$ref"] = normalizeRef(ref)
+		}
+		for _, child := range v {
+			canonicalize(child)
+		}
+	case []any:
+		for _, child := range v {
+			canonicalize(child)
+		}
+	}
+}
+
+// normalizeRef returns the canonical form of a $ref value.
+func normalizeRef(ref string) string {
+	base, fragment, ok := strings.Cut(ref, "#")
+	if !ok {
+		return ref
+	}
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+	if rest, ok := strings.CutPrefix(fragment, "/definitions/"); ok {
+		fragment = "/$defs/" + rest
+	}
+	if fragment == "/" {
+		fragment = ""
+	}
+	return base + "#" + fragment
+}
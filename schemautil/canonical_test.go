@@ -0,0 +1,49 @@
+package schemautil
+
+import "github.com/google/jsonschema-go/jsonschema"
+
+// TestCanonical tests that canonicalization sorts keys, normalizes refs and strips
+// volatile keywords at every level.
+func (s *SchemaUtilTestSuite) TestCanonical() {
+	schema := &jsonschema.Schema{
+		Ref:     "#/definitions/users.v1.User",
+		Comment: "generated",
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.User": {
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"self": {Ref: "#/%24defs/users.v1.User"}, "root": {Ref: "#/"}},
+				Extra:      map[string]any{"x-generation-options": map[string]any{"plugin": "object_root"}, "x-generator": map[string]any{"version": "v1"}, "x-keep": true},
+			},
+		},
+	}
+
+	data, err := Canonical(schema)
+	s.Require().NoError(err)
+	s.Equal(`{"$defs":{"users.v1.User":{"properties":{"root":{"$ref":"#"},"self":{"$ref":"#/$defs/users.v1.User"}},"type":"object","x-keep":true}},"$ref":"#/$defs/users.v1.User"}`, string(data))
+
+	data, err = Canonical(nil)
+	s.Require().NoError(err)
+	s.Equal("null", string(data))
+}
+
+// TestHash tests that hashes are stable across construction and ignore volatile keywords.
+func (s *SchemaUtilTestSuite) TestHash() {
+	annotated := userSchema()
+	annotated.Comment = "regenerated"
+	annotated.Ref = "#/definitions/users.v1.User"
+
+	want, err := Hash(userSchema())
+	s.Require().NoError(err)
+	s.Len(want, 64)
+
+	got, err := Hash(annotated)
+	s.Require().NoError(err)
+	s.Equal(want, got)
+	s.True(Equal(userSchema(), annotated), "Equal should compare canonical forms")
+
+	changed := userSchema()
+	changed.Defs["users.v1.User"].Required = nil
+	got, err = Hash(changed)
+	s.Require().NoError(err)
+	s.NotEqual(want, got)
+}
@@ -0,0 +1,199 @@
+package schemautil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// CoerceToolArgs returns a copy of args, the decoded arguments of a tool call, with the
+// mistakes language models commonly make in them fixed as far as the schema allows, so
+// that they pass validation against it:
+//
+//   - strings holding numbers or booleans ("42", "0.5", "true") become numbers and
+//     booleans where the schema expects them, and numbers and booleans become strings
+//     where it expects a string; integer strings become int64 values (uint64 above
+//     math.MaxInt64), so 64-bit identifiers keep all their digits, and other numbers
+//     become float64 values;
+//   - strings holding JSON objects or arrays are decoded where the schema expects them;
+//   - a single value becomes a one-element array where the schema expects an array, and a
+//     one-element array becomes its element where it does not allow arrays.
+//
+// The types a value must have are those of the type keywords of its schema, followed
+// through properties, items, additionalProperties, "#/$defs/" references of the root and
+// allOf, anyOf and oneOf branches; values without them are kept. Values of another type
+// that cannot be converted, such as "ten" for an integer, are reported with the JSON
+// Pointer of their location, and unknown properties are kept (see Prune). The input is
+// not modified.
+func CoerceToolArgs(schema *jsonschema.Schema, args map[string]any) (map[string]any, error) {
+	if schema == nil || args == nil {
+		return args, nil
+	}
+	c := coercer{root: schema}
+	out := c.coerce(schema, args, "")
+	return out.(map[string]any), errors.Join(c.errs...)
+}
+
+// coercer converts the values of tool call arguments to the types of their schemas,
+// recording the values it cannot convert.
+type coercer struct {
+	root *jsonschema.Schema
+	errs []error
+}
+
+// coerce returns the converted copy of the value at the JSON Pointer path validated by s.
+func (c *coercer) coerce(s *jsonschema.Schema, value any, path string) any {
+	schemas := applicable(c.root, s)
+	var types []string
+	for _, s := range schemas {
+		if s.Type != "" {
+			types = append(types, s.Type)
+		}
+		types = append(types, s.Types...)
+	}
+	if len(types) > 0 && !acceptsType(types, jsonType(value)) {
+		converted, ok := convertValue(types, value)
+		if !ok {
+			c.errs = append(c.errs, fmt.Errorf("at %q: cannot convert %s %s to %s", path, jsonType(value), describeValue(value), strings.Join(types, " or ")))
+			return value
+		}
+		value = converted
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = value
+			if subschemas := propertySchemas(schemas, key); len(subschemas) > 0 {
+				out[key] = c.coerce(&jsonschema.Schema{AllOf: subschemas}, value, path+"/"+escapePointerToken(key))
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = value
+			if subschemas := itemSchemas(schemas, i); len(subschemas) > 0 {
+				out[i] = c.coerce(&jsonschema.Schema{AllOf: subschemas}, value, path+"/"+strconv.Itoa(i))
+			}
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// convertValue returns value converted to one of the JSON types, and whether it could be.
+func convertValue(types []string, value any) (any, bool) {
+	if v, ok := value.(string); ok && (slices.Contains(types, "object") || slices.Contains(types, "array")) {
+		var decoded any
+		if err := json.Unmarshal([]byte(v), &decoded); err == nil && acceptsType(types, jsonType(decoded)) {
+			return decoded, true
+		}
+	}
+	if v, ok := value.([]any); ok && len(v) == 1 && !slices.Contains(types, "array") {
+		if acceptsType(types, jsonType(v[0])) {
+			return v[0], true
+		}
+		return convertValue(types, v[0])
+	}
+	if slices.Contains(types, "array") {
+		if _, ok := value.([]any); !ok {
+			return []any{value}, true
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		if slices.Contains(types, "integer") || slices.Contains(types, "number") {
+			// Integer strings are parsed exactly: a float64 loses the digits of 64-bit
+			// identifiers above 2^53.
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i, true
+			}
+			if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+				return u, true
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				if f == math.Trunc(f) || slices.Contains(types, "number") {
+					return f, true
+				}
+			}
+		}
+		if slices.Contains(types, "boolean") {
+			switch strings.ToLower(s) {
+			case "true":
+				return true, true
+			case "false":
+				return false, true
+			}
+		}
+	case bool:
+		if slices.Contains(types, "string") {
+			return strconv.FormatBool(v), true
+		}
+	case nil, map[string]any, []any:
+	default:
+		if slices.Contains(types, "string") {
+			return describeValue(v), true
+		}
+	}
+	return nil, false
+}
+
+// acceptsType reports whether a value of the JSON type t has one of the types.
+func acceptsType(types []string, t string) bool {
+	return slices.Contains(types, t) || (t == "integer" && slices.Contains(types, "number"))
+}
+
+// jsonType returns the JSON type of a decoded JSON value: integral numbers are "integer".
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case float32:
+		return jsonType(float64(v))
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// describeValue returns the JSON text of a decoded JSON value, or its Go form if it has
+// none.
+func describeValue(value any) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
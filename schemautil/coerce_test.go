@@ -0,0 +1,62 @@
+package schemautil
+
+import (
+	"math"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestCoerceToolArgs tests that mistyped values are converted to the types of their
+// schemas at every depth and that values that cannot be converted are reported.
+func (s *SchemaUtilTestSuite) TestCoerceToolArgs() {
+	schema := userSchema()
+	user := schema.Defs["users.v1.User"]
+	user.Properties["age"] = &jsonschema.Schema{Type: "integer"}
+	user.Properties["score"] = &jsonschema.Schema{Types: []string{"number", "null"}}
+	user.Properties["active"] = &jsonschema.Schema{Type: "boolean"}
+	user.Properties["friend"] = &jsonschema.Schema{AnyOf: []*jsonschema.Schema{{Ref: "#/$defs/users.v1.User"}, {Type: "null"}}}
+	user.Properties["primary_tag"] = &jsonschema.Schema{Type: "string"}
+
+	args := map[string]any{
+		"id":          42.0,
+		"age":         " 30 ",
+		"score":       "0.5",
+		"active":      "TRUE",
+		"tags":        "solo",
+		"primary_tag": []any{"first"},
+		"friend":      `{"id": "u2", "tags": ["a"], "age": "7"}`,
+		"unknown":     "kept",
+	}
+	coerced, err := CoerceToolArgs(schema, args)
+	s.Require().NoError(err)
+	s.Equal(map[string]any{
+		"id":          "42",
+		"age":         int64(30),
+		"score":       0.5,
+		"active":      true,
+		"tags":        []any{"solo"},
+		"primary_tag": "first",
+		"friend":      map[string]any{"id": "u2", "tags": []any{"a"}, "age": int64(7)},
+		"unknown":     "kept",
+	}, coerced)
+	s.Equal(" 30 ", args["age"], "input must not be modified")
+
+	_, err = CoerceToolArgs(schema, map[string]any{"age": "ten", "tags": []any{"a", map[string]any{}}, "active": "maybe", "score": 1.5})
+	s.Require().Error(err)
+	s.Contains(err.Error(), `at "/age": cannot convert string "ten" to integer`)
+	s.Contains(err.Error(), `at "/tags/1": cannot convert object {} to string`)
+	s.Contains(err.Error(), `at "/active": cannot convert string "maybe" to boolean`)
+	s.NotContains(err.Error(), "/score")
+
+	// Integer strings above 2^53 keep their digits, up to the uint64 range.
+	coerced, err = CoerceToolArgs(schema, map[string]any{"age": "9007199254740993", "score": "18446744073709551615", "friend": map[string]any{"age": "-9223372036854775808"}})
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"age": int64(9007199254740993), "score": uint64(18446744073709551615), "friend": map[string]any{"age": int64(math.MinInt64)}}, coerced)
+	resolved, err := schema.Resolve(nil)
+	s.Require().NoError(err)
+	s.NoError(resolved.Validate(map[string]any{"id": "u1", "tags": []any{}, "age": coerced["age"], "score": coerced["score"]}))
+
+	coerced, err = CoerceToolArgs(schema, nil)
+	s.NoError(err)
+	s.Nil(coerced)
+}
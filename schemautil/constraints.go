@@ -0,0 +1,230 @@
+package schemautil
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// Extension keywords EvaluateConstraints checks, which JSON Schema validators ignore.
+const (
+	// ConstraintsKeyword holds the CEL expressions of a message schema (the
+	// cel_constraint plugin parameter), as a list of strings.
+	ConstraintsKeyword = "x-constraints"
+	// FormatMinimumKeyword and FormatMaximumKeyword hold the inclusive bounds of the RFC
+	// 3339 date-time strings of a schema (the timestamp_bounds plugin parameter).
+	FormatMinimumKeyword = "x-formatMinimum"
+	FormatMaximumKeyword = "x-formatMaximum"
+)
+
+// ConstraintError reports an x-constraints expression, or an x-formatMinimum or
+// x-formatMaximum bound, an instance does not satisfy.
+type ConstraintError struct {
+	// Path is the JSON pointer of the value the constraint was evaluated against ("" for
+	// the instance itself).
+	Path string
+	// Keyword is the keyword holding the constraint, e.g. ConstraintsKeyword.
+	Keyword string
+	// Expression is the CEL expression, or the bound of x-formatMinimum and
+	// x-formatMaximum.
+	Expression string
+	// Err is set when the constraint could not be evaluated, instead of not being
+	// satisfied.
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	location := e.Path
+	if location == "" {
+		location = "the instance"
+	}
+	constraint := fmt.Sprintf("constraint %q", e.Expression)
+	if e.Keyword != ConstraintsKeyword {
+		constraint = fmt.Sprintf("%s %q", e.Keyword, e.Expression)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s at %s: %v", constraint, location, e.Err)
+	}
+	return fmt.Sprintf("%s not satisfied at %s", constraint, location)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// EvaluateConstraints evaluates the CEL expressions of the x-constraints keywords of the
+// schema and its subschemas against a decoded JSON instance, such as a map[string]any,
+// and checks the string values of subschemas with x-formatMinimum or x-formatMaximum
+// bounds, as RFC 3339 date-times within them. It returns a *ConstraintError for each
+// expression that does not evaluate to true and each bound that is not met, joined with
+// errors.Join. Each expression sees the value its schema applies to as this, so
+// that this.start_time < this.end_time compares two properties of the message it is
+// declared on; properties the value lacks must be tested with has(this.name) before use.
+// Subschemas are found as Prune finds them, through properties, items,
+// additionalProperties, "#/$defs/" references of the root and allOf, anyOf and oneOf
+// branches. Call it after validating the instance against the schema: the expressions
+// assume the property types the schema checks. Compiled expressions are cached, so
+// evaluating many instances compiles each expression once.
+func EvaluateConstraints(schema *jsonschema.Schema, instance any) error {
+	if schema == nil {
+		return nil
+	}
+	e := constraintEvaluator{root: schema}
+	e.evaluate(schema, instance, "")
+	return errors.Join(e.errs...)
+}
+
+// constraintEvaluator evaluates the constraints of values, resolving references against
+// the root schema.
+type constraintEvaluator struct {
+	root *jsonschema.Schema
+	errs []error
+}
+
+// evaluate evaluates the constraints that apply to a value validated by s, at path, and
+// those of its properties, elements and map values.
+func (e *constraintEvaluator) evaluate(s *jsonschema.Schema, value any, path string) {
+	schemas := applicable(e.root, s)
+	for _, s := range schemas {
+		for _, expression := range constraintExpressions(s) {
+			if ok, err := evaluateConstraint(expression, value); !ok {
+				e.errs = append(e.errs, &ConstraintError{Path: path, Keyword: ConstraintsKeyword, Expression: expression, Err: err})
+			}
+		}
+		if str, ok := value.(string); ok {
+			e.errs = append(e.errs, checkFormatBounds(s, str, path)...)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if subschemas := propertySchemas(schemas, key); len(subschemas) > 0 {
+				e.evaluate(&jsonschema.Schema{AllOf: subschemas}, value, path+"/"+escapePointerToken(key))
+			}
+		}
+	case []any:
+		for i, value := range v {
+			if subschemas := itemSchemas(schemas, i); len(subschemas) > 0 {
+				e.evaluate(&jsonschema.Schema{AllOf: subschemas}, value, path+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+}
+
+// constraintExpressions returns the expressions of the x-constraints keyword of s, as
+// generated ([]string) or decoded from JSON ([]any).
+func constraintExpressions(s *jsonschema.Schema) []string {
+	switch v := s.Extra[ConstraintsKeyword].(type) {
+	case []string:
+		return v
+	case []any:
+		expressions := make([]string, 0, len(v))
+		for _, e := range v {
+			if expression, ok := e.(string); ok {
+				expressions = append(expressions, expression)
+			}
+		}
+		return expressions
+	}
+	return nil
+}
+
+// checkFormatBounds checks a date-time string against the x-formatMinimum and
+// x-formatMaximum bounds of s, comparing instants so that offsets other than Z compare
+// correctly.
+func checkFormatBounds(s *jsonschema.Schema, value, path string) []error {
+	var errs []error
+	for _, keyword := range []string{FormatMinimumKeyword, FormatMaximumKeyword} {
+		bound, ok := s.Extra[keyword].(string)
+		if !ok {
+			continue
+		}
+		err := &ConstraintError{Path: path, Keyword: keyword, Expression: bound}
+		limit, parseErr := time.Parse(time.RFC3339Nano, bound)
+		if parseErr != nil {
+			err.Err = fmt.Errorf("the bound is not an RFC 3339 date-time: %w", parseErr)
+			errs = append(errs, err)
+			continue
+		}
+		switch t, parseErr := time.Parse(time.RFC3339Nano, value); {
+		case parseErr != nil:
+			err.Err = fmt.Errorf("%q is not an RFC 3339 date-time", value)
+		case keyword == FormatMinimumKeyword && !t.Before(limit), keyword == FormatMaximumKeyword && !t.After(limit):
+			continue
+		}
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// hasConstraints reports whether the schema or a subschema has a keyword
+// EvaluateConstraints checks.
+func hasConstraints(schema *jsonschema.Schema) bool {
+	found := false
+	walkSchemas(schema, make(map[*jsonschema.Schema]bool), func(s *jsonschema.Schema) {
+		for _, keyword := range []string{ConstraintsKeyword, FormatMinimumKeyword, FormatMaximumKeyword} {
+			if _, ok := s.Extra[keyword]; ok {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
+// evaluateConstraint evaluates a CEL expression with this bound to value.
+func evaluateConstraint(expression string, value any) (bool, error) {
+	program, err := compileConstraint(expression)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := program.Eval(map[string]any{"this": value})
+	if err != nil {
+		return false, err
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("evaluated to %v instead of a boolean", out.Value())
+	}
+	return result, nil
+}
+
+var (
+	constraintEnv      = sync.OnceValues(func() (*cel.Env, error) { return cel.NewEnv(cel.Variable("this", cel.DynType)) })
+	constraintPrograms sync.Map // expression -> cel.Program
+)
+
+// CompileConstraint checks that a CEL expression compiles with this as its only variable
+// and evaluates to a boolean, as EvaluateConstraints requires.
+func CompileConstraint(expression string) error {
+	_, err := compileConstraint(expression)
+	return err
+}
+
+// compileConstraint returns the program of a CEL expression, compiled on first use.
+func compileConstraint(expression string) (cel.Program, error) {
+	if program, ok := constraintPrograms.Load(expression); ok {
+		return program.(cel.Program), nil
+	}
+	env, err := constraintEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if t := ast.OutputType(); !t.IsAssignableType(cel.BoolType) {
+		return nil, fmt.Errorf("evaluates to %s instead of a boolean", t)
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	constraintPrograms.Store(expression, program)
+	return program, nil
+}
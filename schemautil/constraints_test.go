@@ -0,0 +1,79 @@
+package schemautil
+
+import (
+	"context"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestEvaluateConstraints tests that x-constraints expressions are evaluated against the
+// values their schemas apply to, through references, arrays and maps, and that failing,
+// erroring and non-boolean expressions are reported with their paths.
+func (s *SchemaUtilTestSuite) TestEvaluateConstraints() {
+	schema := userSchema()
+	user := schema.Defs["users.v1.User"]
+	user.Properties["start"] = &jsonschema.Schema{Type: "integer"}
+	user.Properties["end"] = &jsonschema.Schema{Type: "integer"}
+	user.Properties["friends"] = &jsonschema.Schema{Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/users.v1.User"}}
+	user.Extra = map[string]any{ConstraintsKeyword: []string{"!has(this.start) || !has(this.end) || this.start <= this.end"}}
+
+	valid := map[string]any{
+		"id": "u1", "tags": []any{}, "start": 1, "end": 2,
+		"friends": []any{map[string]any{"id": "u2", "tags": []any{}}},
+	}
+	s.NoError(EvaluateConstraints(schema, valid))
+	s.NoError(EvaluateConstraints(nil, valid))
+
+	invalid := map[string]any{
+		"id": "u1", "tags": []any{}, "start": 3.0, "end": 2,
+		"friends": []any{map[string]any{"id": "u2", "tags": []any{}, "start": 5, "end": 4}},
+	}
+	err := EvaluateConstraints(schema, invalid)
+	s.Require().Error(err)
+	s.Contains(err.Error(), `constraint "!has(this.start) || !has(this.end) || this.start <= this.end" not satisfied at the instance`)
+	s.Contains(err.Error(), "not satisfied at /friends/0")
+
+	// Constraints decoded from JSON are []any; evaluation errors are reported with the
+	// expression.
+	user.Extra = map[string]any{ConstraintsKeyword: []any{"this.start < this.missing"}}
+	err = EvaluateConstraints(schema, valid)
+	var constraintErr *ConstraintError
+	s.Require().ErrorAs(err, &constraintErr)
+	s.Equal("this.start < this.missing", constraintErr.Expression)
+	s.Error(constraintErr.Err)
+
+	s.NoError(CompileConstraint("this.a == this.b"))
+	s.ErrorContains(CompileConstraint("this.a +"), "Syntax error")
+	s.ErrorContains(CompileConstraint(`"text"`), "instead of a boolean")
+}
+
+// TestFormatBounds tests that x-formatMinimum and x-formatMaximum bound date-time
+// strings as instants, that unparseable values are reported, and that a Validator checks
+// them after schema validation.
+func (s *SchemaUtilTestSuite) TestFormatBounds() {
+	schema := userSchema()
+	user := schema.Defs["users.v1.User"]
+	user.Properties["born"] = &jsonschema.Schema{Type: "string", Extra: map[string]any{
+		FormatMinimumKeyword: "1900-01-01T00:00:00Z",
+		FormatMaximumKeyword: "2000-01-01T00:00:00Z",
+	}}
+
+	for _, born := range []string{"1900-01-01T00:00:00Z", "1999-12-31T23:00:00-01:00", "2000-01-01T00:00:00Z"} {
+		s.NoError(EvaluateConstraints(schema, map[string]any{"id": "u1", "tags": []any{}, "born": born}), born)
+	}
+	err := EvaluateConstraints(schema, map[string]any{"id": "u1", "tags": []any{}, "born": "2000-01-01T00:00:00.5Z"})
+	s.ErrorContains(err, `x-formatMaximum "2000-01-01T00:00:00Z" not satisfied at /born`)
+	err = EvaluateConstraints(schema, map[string]any{"id": "u1", "tags": []any{}, "born": "1899-12-31T23:59:59Z"})
+	s.ErrorContains(err, `x-formatMinimum "1900-01-01T00:00:00Z" not satisfied at /born`)
+	err = EvaluateConstraints(schema, map[string]any{"id": "u1", "tags": []any{}, "born": "yesterday"})
+	var constraintErr *ConstraintError
+	s.Require().ErrorAs(err, &constraintErr)
+	s.Equal(FormatMinimumKeyword, constraintErr.Keyword)
+	s.ErrorContains(constraintErr.Err, "not an RFC 3339 date-time")
+
+	v, err := NewValidator("users.v1.User", schema, nil)
+	s.Require().NoError(err)
+	s.NoError(v.Validate(map[string]any{"id": "u1", "tags": []any{}, "born": "1950-06-01T12:00:00Z"}))
+	s.ErrorContains(v.Validate(map[string]any{"id": "u1", "tags": []any{}, "born": "2001-01-01T00:00:00Z"}), "x-formatMaximum")
+	s.ErrorContains(v.ValidateContext(context.Background(), map[string]any{"id": "u1", "tags": []any{}, "born": "2001-01-01T00:00:00Z"}), "x-formatMaximum")
+}
@@ -0,0 +1,149 @@
+// Package schemautil provides runtime helpers for working with the JSON schemas returned
+// by code generated by protoc-gen-go-jsonschema.
+//
+// Schemas are compared by their canonical JSON representation (see Canonical), which is
+// what validators and other consumers see. Paths in reported changes are JSON Pointers
+// (RFC 6901) into that representation, e.g. "/$defs/users.v1.User/properties/name/type".
+package schemautil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// ChangeKind describes how a value differs between two schemas.
+type ChangeKind int
+
+const (
+	// Added means the value is present only in the new schema.
+	Added ChangeKind = iota
+	// Removed means the value is present only in the old schema.
+	Removed
+	// Changed means the value is present in both schemas with different values.
+	Changed
+)
+
+// String returns the lower-case name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	default:
+		return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Change is a single structural difference between two schemas.
+type Change struct {
+	// Path is the JSON Pointer of the differing value ("" for the whole schema).
+	Path string
+	// Kind is how the value differs.
+	Kind ChangeKind
+	// Old is the value in the old schema, decoded from JSON (nil when Added).
+	Old any
+	// New is the value in the new schema, decoded from JSON (nil when Removed).
+	New any
+}
+
+// String formats the change as "<kind> <path>".
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	return c.Kind.String() + " " + path
+}
+
+// Equal reports whether a and b are structurally equal, i.e. they have the same canonical
+// JSON representation regardless of how they were constructed. Two nil schemas are equal.
+// Schemas that cannot be marshaled to JSON are never equal.
+func Equal(a, b *jsonschema.Schema) bool {
+	changes, err := Diff(a, b)
+	return err == nil && len(changes) == 0
+}
+
+// Diff returns the structural differences from old to new, sorted by path.
+//
+// Objects (properties, $defs, ...) are compared key by key and arrays element by
+// element, so a new property is reported as a single Added change at its path. Values
+// that differ in JSON type are reported as one Changed change. Volatile keywords and
+// $ref spelling differences are ignored (see Canonical). A nil schema is treated as
+// absent: diffing it against a non-nil schema reports the whole schema.
+func Diff(old, new *jsonschema.Schema) ([]Change, error) {
+	oldValue, err := canonicalValue(old)
+	if err != nil {
+		return nil, fmt.Errorf("old schema: %w", err)
+	}
+	newValue, err := canonicalValue(new)
+	if err != nil {
+		return nil, fmt.Errorf("new schema: %w", err)
+	}
+
+	var changes []Change
+	diffValues("", oldValue, newValue, &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// diffValues appends the changes from old to new at path to changes.
+func diffValues(path string, old, new any, changes *[]Change) {
+	switch {
+	case old == nil && new == nil:
+		return
+	case old == nil:
+		*changes = append(*changes, Change{Path: path, Kind: Added, New: new})
+		return
+	case new == nil:
+		*changes = append(*changes, Change{Path: path, Kind: Removed, Old: old})
+		return
+	}
+
+	switch oldValue := old.(type) {
+	case map[string]any:
+		if newValue, ok := new.(map[string]any); ok {
+			keys := make(map[string]bool, len(oldValue)+len(newValue))
+			for k := range oldValue {
+				keys[k] = true
+			}
+			for k := range newValue {
+				keys[k] = true
+			}
+			for k := range keys {
+				diffValues(path+"/"+escapePointerToken(k), oldValue[k], newValue[k], changes)
+			}
+			return
+		}
+	case []any:
+		if newValue, ok := new.([]any); ok {
+			for i := 0; i < max(len(oldValue), len(newValue)); i++ {
+				var o, n any
+				if i < len(oldValue) {
+					o = oldValue[i]
+				}
+				if i < len(newValue) {
+					n = newValue[i]
+				}
+				diffValues(path+"/"+strconv.Itoa(i), o, n, changes)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Path: path, Kind: Changed, Old: old, New: new})
+	}
+}
+
+// escapePointerToken escapes a JSON Pointer reference token (RFC 6901).
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
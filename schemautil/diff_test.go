@@ -0,0 +1,60 @@
+package schemautil
+
+import "github.com/google/jsonschema-go/jsonschema"
+
+// TestEqual tests structural equality of schemas.
+func (s *SchemaUtilTestSuite) TestEqual() {
+	s.True(Equal(userSchema(), userSchema()))
+	s.True(Equal(nil, nil))
+	s.False(Equal(userSchema(), nil))
+
+	changed := userSchema()
+	changed.Defs["users.v1.User"].Properties["id"].Format = "uuid"
+	s.False(Equal(userSchema(), changed))
+}
+
+// TestDiff tests that structural differences are reported as sorted JSON Pointer paths.
+func (s *SchemaUtilTestSuite) TestDiff() {
+	old := userSchema()
+	new := userSchema()
+	user := new.Defs["users.v1.User"]
+	user.Properties["name"] = &jsonschema.Schema{Type: "string"}
+	delete(user.Properties, "tags")
+	user.Required = []string{"id"}
+	user.Properties["id"].Type = "integer"
+	new.Defs["a/b~c"] = &jsonschema.Schema{Type: "string"}
+
+	changes, err := Diff(old, new)
+	s.Require().NoError(err)
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	s.Equal([]string{
+		"added /$defs/a~1b~0c",
+		"changed /$defs/users.v1.User/properties/id/type",
+		"added /$defs/users.v1.User/properties/name",
+		"removed /$defs/users.v1.User/properties/tags",
+		"removed /$defs/users.v1.User/required/1",
+	}, got)
+
+	s.Equal("string", changes[1].Old)
+	s.Equal("integer", changes[1].New)
+	s.Equal(map[string]any{"type": "string"}, changes[2].New)
+	s.Nil(changes[2].Old)
+}
+
+// TestDiffNilSchemas tests that a nil schema is treated as absent.
+func (s *SchemaUtilTestSuite) TestDiffNilSchemas() {
+	changes, err := Diff(nil, userSchema())
+	s.Require().NoError(err)
+	s.Require().Len(changes, 1)
+	s.Equal(Added, changes[0].Kind)
+	s.Equal("", changes[0].Path)
+	s.Equal("added /", changes[0].String())
+
+	changes, err = Diff(nil, nil)
+	s.Require().NoError(err)
+	s.Empty(changes)
+}
@@ -0,0 +1,182 @@
+package schemautil
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// Draft07URI is the $schema value of JSON Schema draft-07 documents.
+const Draft07URI = "http://json-schema.org/draft-07/schema#"
+
+// ToDraft07 returns a draft-07 form of a draft 2020-12 schema produced by generated
+// code, leaving the input unmodified, for validators and toolchains that do not support
+// draft 2020-12:
+//
+//   - $defs become definitions and "#/$defs/" references become "#/definitions/"
+//     references;
+//   - a $ref with sibling keywords other than annotations and definitions (such as the
+//     type of a ref-as-root wrapper) moves into an allOf, since draft-07 ignores the
+//     siblings of $ref;
+//   - prefixItems becomes the array form of items, and items next to it additionalItems;
+//   - dependentRequired and dependentSchemas become dependencies;
+//   - unevaluatedProperties and unevaluatedItems become additionalProperties and items
+//     when the schema has no other keywords that evaluate properties or items, and are
+//     removed otherwise, so such schemas accept more instances in draft-07;
+//   - $anchor becomes a "#<anchor>" $id and $dynamicRef a $ref; $dynamicAnchor is removed;
+//   - $vocabulary, which draft-07 does not have, is removed;
+//   - $schema is set to Draft07URI.
+//
+// exclusiveMinimum and exclusiveMaximum are numbers in both drafts (the boolean form is
+// draft-04), so bounds are unchanged.
+func ToDraft07(schema *jsonschema.Schema) *jsonschema.Schema {
+	if schema == nil {
+		return nil
+	}
+	out := schema.CloneSchemas()
+	walkSchemas(out, make(map[*jsonschema.Schema]bool), func(s *jsonschema.Schema) {
+		if len(s.Defs) > 0 {
+			if s.Definitions == nil {
+				s.Definitions = make(map[string]*jsonschema.Schema, len(s.Defs))
+			}
+			for name, def := range s.Defs {
+				s.Definitions[name] = def
+			}
+			s.Defs = nil
+		}
+		if name, ok := strings.CutPrefix(s.Ref, "#/$defs/"); ok {
+			s.Ref = "#/definitions/" + name
+		}
+
+		if s.DynamicRef != "" && s.Ref == "" {
+			s.Ref = s.DynamicRef
+		}
+		s.DynamicRef = ""
+		s.DynamicAnchor = ""
+		if s.Anchor != "" && s.ID == "" {
+			s.ID = "#" + s.Anchor
+		}
+		s.Anchor = ""
+		s.Vocabulary = nil
+
+		if s.PrefixItems != nil {
+			s.ItemsArray = s.PrefixItems
+			s.AdditionalItems = s.Items
+			s.PrefixItems = nil
+			s.Items = nil
+		}
+		if s.UnevaluatedItems != nil {
+			if s.Items == nil && s.ItemsArray == nil && s.Contains == nil && !hasApplicators(s) {
+				s.Items = s.UnevaluatedItems
+			}
+			s.UnevaluatedItems = nil
+		}
+		if s.UnevaluatedProperties != nil {
+			if s.AdditionalProperties == nil && s.PatternProperties == nil && s.DependentSchemas == nil && !hasApplicators(s) {
+				s.AdditionalProperties = s.UnevaluatedProperties
+			}
+			s.UnevaluatedProperties = nil
+		}
+		for name, required := range s.DependentRequired {
+			if s.DependencyStrings == nil {
+				s.DependencyStrings = make(map[string][]string, len(s.DependentRequired))
+			}
+			s.DependencyStrings[name] = required
+		}
+		s.DependentRequired = nil
+		for name, dep := range s.DependentSchemas {
+			if s.DependencySchemas == nil {
+				s.DependencySchemas = make(map[string]*jsonschema.Schema, len(s.DependentSchemas))
+			}
+			s.DependencySchemas[name] = dep
+		}
+		s.DependentSchemas = nil
+
+		if s.Ref != "" && hasRefSiblings(s) {
+			s.AllOf = append([]*jsonschema.Schema{{Ref: s.Ref}}, s.AllOf...)
+			s.Ref = ""
+		}
+	})
+	out.Schema = Draft07URI
+	return out
+}
+
+// MarshalWithDefinitions returns the JSON encoding of a schema with its $defs also under
+// definitions, for artifacts read both by consumers that only know the draft-07 keyword and
+// by draft 2020-12 validators while they migrate. Both keys hold the same definitions, and
+// references keep pointing into $defs. Schemas without $defs are encoded unchanged.
+//
+// jsonschema.Schema cannot hold both keywords, so the encoding is not meant to be
+// unmarshaled back into one; ToDraft07 converts a schema for consumers that only read
+// draft-07.
+func MarshalWithDefinitions(schema *jsonschema.Schema) ([]byte, error) {
+	data, err := json.Marshal(schema)
+	if err != nil || schema == nil || len(schema.Defs) == 0 {
+		return data, err
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil, err
+	}
+	data = append(data[:len(data)-1], `,"definitions":`...)
+	data = append(data, keywords["$defs"]...)
+	return append(data, '}'), nil
+}
+
+// hasApplicators reports whether s has keywords that apply subschemas to the instance
+// itself, whose evaluated properties and items unevaluatedProperties and
+// unevaluatedItems take into account.
+func hasApplicators(s *jsonschema.Schema) bool {
+	return s.Ref != "" || s.AllOf != nil || s.AnyOf != nil || s.OneOf != nil || s.If != nil || s.Then != nil || s.Else != nil
+}
+
+// hasRefSiblings reports whether s has keywords next to $ref that draft-07 would ignore
+// and that are not annotations. Definitions are left next to $ref, where draft-07
+// validators resolve them as usual.
+func hasRefSiblings(s *jsonschema.Schema) bool {
+	rest := *s
+	rest.Schema, rest.ID, rest.Ref, rest.Comment, rest.Definitions = "", "", "", "", nil
+	rest.Title, rest.Description, rest.Default, rest.Examples = "", "", nil, nil
+	rest.Deprecated, rest.ReadOnly, rest.WriteOnly = false, false, false
+	rest.Extra, rest.PropertyOrder = nil, nil
+	return !reflect.ValueOf(rest).IsZero()
+}
+
+var (
+	schemaType      = reflect.TypeFor[*jsonschema.Schema]()
+	schemaSliceType = reflect.TypeFor[[]*jsonschema.Schema]()
+	schemaMapType   = reflect.TypeFor[map[string]*jsonschema.Schema]()
+)
+
+// walkSchemas calls f on s and then on every subschema reachable from it, once each.
+// Subschemas are found by reflection so that every keyword of the library's Schema
+// type is covered.
+func walkSchemas(s *jsonschema.Schema, visited map[*jsonschema.Schema]bool, f func(*jsonschema.Schema)) {
+	if s == nil || visited[s] {
+		return
+	}
+	visited[s] = true
+	f(s)
+
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Type() {
+		case schemaType:
+			walkSchemas(field.Interface().(*jsonschema.Schema), visited, f)
+		case schemaSliceType:
+			for _, child := range field.Interface().([]*jsonschema.Schema) {
+				walkSchemas(child, visited, f)
+			}
+		case schemaMapType:
+			for _, child := range field.Interface().(map[string]*jsonschema.Schema) {
+				walkSchemas(child, visited, f)
+			}
+		}
+	}
+}
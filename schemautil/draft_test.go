@@ -0,0 +1,129 @@
+package schemautil
+
+import (
+	"encoding/json"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestToDraft07 tests conversion of a generated-style schema to draft-07 and that the
+// result resolves and validates under draft-07 semantics.
+func (s *SchemaUtilTestSuite) TestToDraft07() {
+	original := userSchema()
+	original.Defs["users.v1.User"].Properties["friend"] = &jsonschema.Schema{Ref: "#/$defs/users.v1.User"}
+	original.Defs["users.v1.User"].Properties["tags"].Items = &jsonschema.Schema{Ref: "#/$defs/users.v1.Tag"}
+	original.Defs["users.v1.Tag"] = &jsonschema.Schema{Type: "string", MinLength: jsonschema.Ptr(1)}
+	original.Vocabulary = map[string]bool{"https://json-schema.org/draft/2020-12/vocab/core": true}
+
+	converted := ToDraft07(original)
+	s.Equal(Draft07URI, converted.Schema)
+	s.Nil(converted.Defs)
+	s.Nil(converted.Vocabulary)
+	s.Equal("#/definitions/users.v1.User", converted.Ref)
+	user := converted.Definitions["users.v1.User"]
+	s.Require().NotNil(user)
+	s.Equal("#/definitions/users.v1.User", user.Properties["friend"].Ref)
+	s.Equal("#/definitions/users.v1.Tag", user.Properties["tags"].Items.Ref)
+
+	s.Equal("#/$defs/users.v1.User", original.Ref, "input must not be modified")
+	s.NotNil(original.Defs)
+	s.Nil(ToDraft07(nil))
+
+	resolved, err := converted.Resolve(nil)
+	s.Require().NoError(err)
+	s.NoError(resolved.Validate(map[string]any{"id": "u1", "tags": []any{"a"}}))
+	s.Error(resolved.Validate(map[string]any{"id": "u1", "tags": []any{""}}), "referenced definition should apply")
+}
+
+// TestMarshalWithDefinitions tests that the encoding repeats $defs under definitions and
+// leaves schemas without $defs unchanged.
+func (s *SchemaUtilTestSuite) TestMarshalWithDefinitions() {
+	original := userSchema()
+	data, err := MarshalWithDefinitions(original)
+	s.Require().NoError(err)
+	var keywords map[string]json.RawMessage
+	s.Require().NoError(json.Unmarshal(data, &keywords))
+	s.Require().Contains(keywords, "$defs")
+	s.JSONEq(string(keywords["$defs"]), string(keywords["definitions"]))
+	s.JSONEq(`"#/$defs/users.v1.User"`, string(keywords["$ref"]))
+	s.Nil(original.Definitions, "input must not be modified")
+
+	plain := &jsonschema.Schema{Type: "string"}
+	data, err = MarshalWithDefinitions(plain)
+	s.Require().NoError(err)
+	s.JSONEq(`{"type": "string"}`, string(data))
+}
+
+// TestToDraft07Keywords tests that ToDraft07 rewrites the draft 2020-12 keywords draft-07
+// lacks or treats differently, and that the result validates like the original.
+func (s *SchemaUtilTestSuite) TestToDraft07Keywords() {
+	original := &jsonschema.Schema{
+		Ref:  "#/$defs/users.v1.User",
+		Type: "object",
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.User": {
+				Type: "object",
+				Properties: map[string]*jsonschema.Schema{
+					"id":    {Type: "string", Description: "The id."},
+					"pair":  {Type: "array", PrefixItems: []*jsonschema.Schema{{Type: "string"}, {Type: "integer"}}, Items: &jsonschema.Schema{Not: &jsonschema.Schema{}}},
+					"score": {Type: "number", ExclusiveMinimum: jsonschema.Ptr(0.0)},
+					"email": {Type: "string"},
+					"phone": {Type: "string"},
+					"tag":   {Ref: "#/$defs/users.v1.Tag", Description: "A tag."},
+					"alias": {Ref: "#/$defs/users.v1.Tag", MaxLength: jsonschema.Ptr(3)},
+				},
+				DependentRequired:     map[string][]string{"email": {"phone"}},
+				UnevaluatedProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},
+			},
+			"users.v1.Tag": {Type: "string", MinLength: jsonschema.Ptr(1), Anchor: "tag"},
+		},
+	}
+
+	converted := ToDraft07(original)
+	s.Equal("", converted.Ref, "a $ref with a type sibling should move into allOf")
+	s.Require().Len(converted.AllOf, 1)
+	s.Equal("#/definitions/users.v1.User", converted.AllOf[0].Ref)
+	s.Equal("object", converted.Type)
+	user := converted.Definitions["users.v1.User"]
+	s.Require().NotNil(user)
+	s.Nil(user.UnevaluatedProperties)
+	s.NotNil(user.AdditionalProperties, "unevaluatedProperties without applicators should become additionalProperties")
+	s.Nil(user.DependentRequired)
+	s.Equal(map[string][]string{"email": {"phone"}}, user.DependencyStrings)
+	pair := user.Properties["pair"]
+	s.Nil(pair.PrefixItems)
+	s.Nil(pair.Items)
+	s.Len(pair.ItemsArray, 2)
+	s.NotNil(pair.AdditionalItems)
+	s.Equal(0.0, *user.Properties["score"].ExclusiveMinimum, "exclusive bounds are numbers in draft-07")
+	s.Equal("#/definitions/users.v1.Tag", user.Properties["tag"].Ref, "annotations may stay next to $ref")
+	s.Equal("", user.Properties["alias"].Ref)
+	s.Equal("#/definitions/users.v1.Tag", user.Properties["alias"].AllOf[0].Ref)
+	s.Equal("#tag", converted.Definitions["users.v1.Tag"].ID)
+	s.Equal("", converted.Definitions["users.v1.Tag"].Anchor)
+
+	data, err := json.Marshal(converted)
+	s.Require().NoError(err)
+	for _, keyword := range []string{"$defs", "prefixItems", "unevaluatedProperties", "dependentRequired", "$anchor"} {
+		s.NotContains(string(data), `"`+keyword+`"`)
+	}
+	s.Contains(string(data), `"dependencies":{"email":["phone"]}`)
+	s.Equal("#/$defs/users.v1.User", original.Ref, "input must not be modified")
+	s.NotNil(original.Defs["users.v1.User"].UnevaluatedProperties)
+
+	resolved, err := converted.Resolve(nil)
+	s.Require().NoError(err)
+	valid := map[string]any{"id": "u1", "pair": []any{"a", 1}, "score": 1, "tag": "t", "alias": "abc"}
+	s.NoError(resolved.Validate(valid))
+	for name, invalid := range map[string]map[string]any{
+		"unknown property":     {"id": "u1", "nickname": "x"},
+		"extra pair item":      {"pair": []any{"a", 1, true}},
+		"mistyped pair item":   {"pair": []any{1, 1}},
+		"exclusive bound":      {"score": 0},
+		"missing dependency":   {"email": "a@b.c"},
+		"referenced minLength": {"tag": ""},
+		"sibling maxLength":    {"alias": "abcd"},
+	} {
+		s.Error(resolved.Validate(invalid), name)
+	}
+}
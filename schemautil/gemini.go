@@ -0,0 +1,328 @@
+package schemautil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// ToGemini returns a form of a schema produced by generated code that Gemini (and Vertex
+// AI) function declarations accept as parameters, leaving the input unmodified. Pass an
+// object-typed root, such as the one returned by the generated MCPInputSchema() methods.
+//
+// Gemini accepts a subset of OpenAPI 3.0 schemas without references, so every $ref is
+// replaced by a copy of its definition and $defs is dropped; recursive messages cannot be
+// inlined and are reported as an error, unless their schema records how many levels to
+// expand (x-max-recursion-depth, emitted by the max_recursion_depth plugin parameter):
+// references to such a message within itself are inlined that many levels deep, counting
+// a root generated for the message, and then replaced by a free-form object stub. Union
+// types with null become "nullable": true,
+// string enums get "format": "enum", oneOf becomes anyOf and proto oneof presence groups
+// are dropped. Objects list their properties in "propertyOrdering", in proto field order
+// when the schema was generated with target=gemini or property_order. Keywords Gemini does not support, such
+// as const (kept as a one-value string enum), non-string enums, additionalProperties (maps
+// become free-form objects), exclusive bounds, multipleOf and formats other than
+// date-time, are dropped, and so are the properties the server sets (see
+// WithoutIdentifiers and WithoutOutputOnlyResources).
+func ToGemini(schema *jsonschema.Schema) (*jsonschema.Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	schema = inputSchema(schema)
+	c := &geminiConverter{root: schema, defs: schema.Defs}
+	if name := rootDefinition(schema); name != "" {
+		c.inlining = []string{name}
+	}
+	return c.convert(schema, "#")
+}
+
+// maxRecursionDepthKeyword is the keyword generated message schemas record their
+// max_recursion_depth in.
+const maxRecursionDepthKeyword = "x-max-recursion-depth"
+
+// maxRecursionDepth returns the depth recorded in s by max_recursion_depth, if any.
+func maxRecursionDepth(s *jsonschema.Schema) (int, bool) {
+	switch v := s.Extra[maxRecursionDepthKeyword].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// rootDefinition returns the name of the definition an object root with a recursion
+// depth was copied from, as by the generated MCPInputSchema() methods, so that the root
+// counts as the first level of the message. It returns "" for other roots.
+func rootDefinition(schema *jsonschema.Schema) string {
+	if _, ok := maxRecursionDepth(schema); !ok || schema.Ref != "" {
+		return ""
+	}
+	root := *schema
+	root.Defs = nil
+	want, err := json.Marshal(&root)
+	if err != nil {
+		return ""
+	}
+	for name, def := range schema.Defs {
+		if _, ok := maxRecursionDepth(def); !ok {
+			continue
+		}
+		if got, err := json.Marshal(def); err == nil && bytes.Equal(got, want) {
+			return name
+		}
+	}
+	return ""
+}
+
+// geminiConverter inlines the definitions of one root schema.
+type geminiConverter struct {
+	root *jsonschema.Schema
+	defs map[string]*jsonschema.Schema
+
+	// inlining lists the definitions being inlined, outermost first, to detect recursion.
+	inlining []string
+}
+
+// convert returns the Gemini form of s, found at path.
+func (c *geminiConverter) convert(s *jsonschema.Schema, path string) (*jsonschema.Schema, error) {
+	if s.Ref != "" {
+		return c.inline(s, path)
+	}
+	for i, branch := range s.AllOf {
+		if branch.Ref != "" {
+			return nil, fmt.Errorf("%s/allOf/%d: a schema combining other schemas (allOf) cannot be expressed for Gemini", path, i)
+		}
+	}
+
+	out := &jsonschema.Schema{
+		Title:         s.Title,
+		Description:   s.Description,
+		Default:       s.Default,
+		MinLength:     s.MinLength,
+		MaxLength:     s.MaxLength,
+		Pattern:       s.Pattern,
+		Minimum:       s.Minimum,
+		Maximum:       s.Maximum,
+		MinItems:      s.MinItems,
+		MaxItems:      s.MaxItems,
+		MinProperties: s.MinProperties,
+		MaxProperties: s.MaxProperties,
+	}
+
+	nullable := false
+	types := s.Types
+	if s.Type != "" {
+		types = []string{s.Type}
+	}
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+		} else if out.Type == "" {
+			out.Type = t
+		} else {
+			// Gemini takes a single type; unions of several use anyOf.
+			if len(out.AnyOf) == 0 {
+				out.AnyOf = append(out.AnyOf, &jsonschema.Schema{Type: out.Type})
+			}
+			out.AnyOf = append(out.AnyOf, &jsonschema.Schema{Type: t})
+		}
+	}
+	if len(out.AnyOf) > 0 {
+		out.Type = ""
+	}
+	if out.Type == "string" && s.Format == "date-time" {
+		out.Format = s.Format
+	}
+
+	values := s.Enum
+	if s.Const != nil {
+		values = []any{*s.Const}
+	}
+	if !isPresenceOneOf(s.OneOf) {
+		if consts := oneOfConsts(s.OneOf); consts != nil {
+			values = consts
+		} else {
+			for i, branch := range s.OneOf {
+				converted, err := c.convert(branch, fmt.Sprintf("%s/oneOf/%d", path, i))
+				if err != nil {
+					return nil, err
+				}
+				out.AnyOf = append(out.AnyOf, converted)
+			}
+		}
+	}
+	if enum, hasNull, ok := stringEnum(values); ok {
+		out.Type = "string"
+		out.Format = "enum"
+		out.Enum = enum
+		nullable = nullable || hasNull
+	}
+
+	for i, branch := range s.AnyOf {
+		if isNullBranch(branch) {
+			nullable = true
+			continue
+		}
+		converted, err := c.convert(branch, fmt.Sprintf("%s/anyOf/%d", path, i))
+		if err != nil {
+			return nil, err
+		}
+		out.AnyOf = append(out.AnyOf, converted)
+	}
+
+	if s.Items != nil {
+		var err error
+		if out.Items, err = c.convert(s.Items, path+"/items"); err != nil {
+			return nil, err
+		}
+	}
+
+	var ordering []string
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*jsonschema.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			converted, err := c.convert(prop, path+"/properties/"+escapePointerToken(name))
+			if err != nil {
+				return nil, err
+			}
+			out.Properties[name] = converted
+		}
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; ok {
+				out.Required = append(out.Required, name)
+			}
+		}
+		ordering = propertyOrdering(s)
+	}
+
+	if nullable || ordering != nil {
+		out.Extra = make(map[string]any)
+		if nullable {
+			out.Extra["nullable"] = true
+		}
+		if ordering != nil {
+			out.Extra["propertyOrdering"] = ordering
+		}
+	}
+	return out, nil
+}
+
+// inline returns the Gemini form of the definition that s references, with the title and
+// description of the reference, if any, replacing those of the definition.
+func (c *geminiConverter) inline(s *jsonschema.Schema, path string) (*jsonschema.Schema, error) {
+	name, ok := definitionName(c.root, s.Ref)
+	def := c.defs[name]
+	if !ok || def == nil {
+		return nil, fmt.Errorf("%s: reference %q cannot be inlined: only #/$defs/ references of the root and $id references to its definitions are supported", path, s.Ref)
+	}
+	var out *jsonschema.Schema
+	if levels := countName(c.inlining, name); levels > 0 {
+		depth, ok := maxRecursionDepth(def)
+		if !ok {
+			return nil, fmt.Errorf("%s: recursive reference to %s cannot be inlined for Gemini (set max_recursion_depth to expand it)", path, name)
+		}
+		if levels > depth {
+			// The message's structure is not described past its depth.
+			out = &jsonschema.Schema{Type: "object", Title: def.Title, Description: def.Description}
+		}
+	}
+	if out == nil {
+		c.inlining = append(c.inlining, name)
+		var err error
+		out, err = c.convert(def, path)
+		c.inlining = c.inlining[:len(c.inlining)-1]
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.Title != "" {
+		out.Title = s.Title
+	}
+	if s.Description != "" {
+		out.Description = s.Description
+	}
+	return out, nil
+}
+
+// countName returns the number of times name occurs in names.
+func countName(names []string, name string) int {
+	n := 0
+	for _, v := range names {
+		if v == name {
+			n++
+		}
+	}
+	return n
+}
+
+// oneOfConsts returns the values of a oneOf whose branches are all consts, as generated
+// by enum_oneof, or nil if it has other branches.
+func oneOfConsts(branches []*jsonschema.Schema) []any {
+	var values []any
+	for _, b := range branches {
+		if b.Const == nil {
+			return nil
+		}
+		values = append(values, *b.Const)
+	}
+	return values
+}
+
+// stringEnum returns the string values of an enum and whether it also allows null. It
+// reports false if values is empty or has other values, which Gemini enums cannot hold.
+func stringEnum(values []any) (enum []any, hasNull, ok bool) {
+	for _, v := range values {
+		switch v.(type) {
+		case string:
+			enum = append(enum, v)
+		case nil:
+			hasNull = true
+		default:
+			return nil, false, false
+		}
+	}
+	return enum, hasNull, len(enum) > 0
+}
+
+// propertyOrdering returns the property names of s in the order recorded by generated
+// code (the "propertyOrdering" keyword emitted with target=gemini, the "x-property-order"
+// keyword emitted with property_order, or PropertyOrder), followed by the remaining
+// properties in sorted order.
+func propertyOrdering(s *jsonschema.Schema) []string {
+	recorded := s.PropertyOrder
+	for _, keyword := range []string{"x-property-order", "propertyOrdering"} {
+		switch v := s.Extra[keyword].(type) {
+		case []string:
+			recorded = v
+		case []any:
+			recorded = nil
+			for _, name := range v {
+				if name, ok := name.(string); ok {
+					recorded = append(recorded, name)
+				}
+			}
+		}
+	}
+
+	ordering := make([]string, 0, len(s.Properties))
+	for _, name := range recorded {
+		if _, ok := s.Properties[name]; ok && !slices.Contains(ordering, name) {
+			ordering = append(ordering, name)
+		}
+	}
+	var rest []string
+	for name := range s.Properties {
+		if !slices.Contains(ordering, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(ordering, rest...)
+}
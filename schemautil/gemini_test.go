@@ -0,0 +1,148 @@
+package schemautil
+
+import (
+	"encoding/json"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestToGemini tests conversion of a generated-style object root to the Gemini function
+// declaration form and that recursive schemas are rejected.
+func (s *SchemaUtilTestSuite) TestToGemini() {
+	original := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":       {Type: "string", Format: "uuid"},
+			"owner":    {Ref: "#/$defs/users.v1.Owner", Description: "The owner."},
+			"nickname": {Types: []string{"string", "null"}},
+			"status":   {Type: "string", OneOf: []*jsonschema.Schema{{Const: jsonschema.Ptr[any]("ACTIVE")}, {Const: jsonschema.Ptr[any]("INACTIVE")}}},
+			"labels":   {Type: "object", AdditionalProperties: &jsonschema.Schema{Type: "string"}},
+		},
+		Required: []string{"id", "owner"},
+		Extra:    map[string]any{"propertyOrdering": []string{"owner", "id", "status", "nickname", "labels"}},
+		OneOf: []*jsonschema.Schema{
+			{Required: []string{"id"}},
+			{Required: []string{"nickname"}},
+		},
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.Owner": {
+				Type:        "object",
+				Description: "An owner.",
+				Properties:  map[string]*jsonschema.Schema{"email": {Type: "string", Format: "email"}},
+				Required:    []string{"email"},
+			},
+		},
+	}
+
+	converted, err := ToGemini(original)
+	s.Require().NoError(err)
+	s.Nil(converted.Defs)
+	s.Empty(converted.OneOf, "proto oneof presence constraints should be dropped")
+	s.Equal([]string{"id", "owner"}, converted.Required)
+	s.Equal([]string{"owner", "id", "status", "nickname", "labels"}, converted.Extra["propertyOrdering"])
+
+	original.Extra = map[string]any{"x-property-order": []any{"status", "id"}}
+	converted, err = ToGemini(original)
+	s.Require().NoError(err)
+	s.Equal([]string{"status", "id", "labels", "nickname", "owner"}, converted.Extra["propertyOrdering"], "x-property-order should be followed")
+	s.Nil(converted.Extra["x-property-order"])
+	s.Equal("", converted.Properties["id"].Format)
+	owner := converted.Properties["owner"]
+	s.Equal("", owner.Ref, "references should be inlined")
+	s.Equal("object", owner.Type)
+	s.Equal("The owner.", owner.Description)
+	s.Equal([]string{"email"}, owner.Required)
+	s.Equal([]string{"email"}, owner.Extra["propertyOrdering"])
+	s.Equal("string", converted.Properties["nickname"].Type)
+	s.Equal(true, converted.Properties["nickname"].Extra["nullable"])
+	s.Equal([]any{"ACTIVE", "INACTIVE"}, converted.Properties["status"].Enum)
+	s.Equal("enum", converted.Properties["status"].Format)
+	s.Nil(converted.Properties["labels"].AdditionalProperties)
+
+	data, err := json.Marshal(converted)
+	s.Require().NoError(err)
+	s.NotContains(string(data), "$ref")
+	s.NotContains(string(data), "$defs")
+	s.Contains(string(data), `"nullable":true`)
+
+	s.Equal("#/$defs/users.v1.Owner", original.Properties["owner"].Ref, "input must not be modified")
+
+	_, err = ToGemini(&jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{"node": {Ref: "#/$defs/users.v1.Node"}},
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.Node": {Type: "object", Properties: map[string]*jsonschema.Schema{"next": {Ref: "#/$defs/users.v1.Node"}}},
+		},
+	})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "#/properties/node/properties/next: recursive reference to users.v1.Node")
+
+	converted, err = ToGemini(nil)
+	s.NoError(err)
+	s.Nil(converted)
+}
+
+// TestToGeminiRecursionDepth tests that ToGemini expands a message recording
+// x-max-recursion-depth within itself that many levels, counting a root copied from its
+// definition, before stubbing it.
+func (s *SchemaUtilTestSuite) TestToGeminiRecursionDepth() {
+	comment := func(depth any) *jsonschema.Schema {
+		return &jsonschema.Schema{
+			Type:        "object",
+			Description: "A comment.",
+			Properties: map[string]*jsonschema.Schema{
+				"text":    {Type: "string"},
+				"replies": {Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/comments.v1.Comment"}},
+			},
+			Extra: map[string]any{"x-max-recursion-depth": depth},
+		}
+	}
+	// levels returns the number of reply levels described below s, excluding the stub.
+	levels := func(s *jsonschema.Schema) int {
+		n := 0
+		for s.Properties["replies"].Items.Properties != nil {
+			s = s.Properties["replies"].Items
+			n++
+		}
+		return n
+	}
+
+	for _, depth := range []int{0, 2} {
+		// An MCPInputSchema()-style root: a copy of the definition, with the definitions.
+		root := comment(depth)
+		root.Defs = map[string]*jsonschema.Schema{"comments.v1.Comment": comment(depth)}
+		converted, err := ToGemini(root)
+		s.Require().NoError(err, depth)
+		s.Equal(depth, levels(converted), "root copied from the definition")
+
+		// A reference from another message.
+		thread := &jsonschema.Schema{
+			Type:       "object",
+			Properties: map[string]*jsonschema.Schema{"first": {Ref: "#/$defs/comments.v1.Comment"}},
+			Defs:       map[string]*jsonschema.Schema{"comments.v1.Comment": comment(depth)},
+		}
+		converted, err = ToGemini(thread)
+		s.Require().NoError(err, depth)
+		first := converted.Properties["first"]
+		s.Equal(depth, levels(first), "referenced definition")
+
+		stub := first
+		for stub.Properties != nil {
+			stub = stub.Properties["replies"].Items
+		}
+		s.Equal("object", stub.Type)
+		s.Equal("A comment.", stub.Description)
+		s.Nil(stub.Properties, "the stub should not describe the message")
+	}
+
+	// Depths read back from JSON are numbers.
+	root := comment(float64(1))
+	root.Defs = map[string]*jsonschema.Schema{"comments.v1.Comment": comment(float64(1))}
+	data, err := json.Marshal(root)
+	s.Require().NoError(err)
+	var decoded jsonschema.Schema
+	s.Require().NoError(json.Unmarshal(data, &decoded))
+	converted, err := ToGemini(&decoded)
+	s.Require().NoError(err)
+	s.Equal(1, levels(converted))
+}
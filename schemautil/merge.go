@@ -0,0 +1,77 @@
+package schemautil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// MergePatch returns a copy of schema with a JSON merge patch (RFC 7386) applied to its
+// JSON form: the keywords of the patch object replace those of the schema, keywords whose
+// patch value is null are removed, and keywords holding objects, such as properties, are
+// merged recursively. Arrays are replaced as a whole. It is the escape hatch for keywords
+// the generator does not produce; the raw_schema parameter applies the same merge to the
+// generated code.
+func MergePatch(schema *jsonschema.Schema, patch string) (*jsonschema.Schema, error) {
+	var p any
+	if err := json.Unmarshal([]byte(patch), &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if _, ok := p.(map[string]any); !ok {
+		return nil, fmt.Errorf("invalid merge patch: %s is not a JSON object", patch)
+	}
+
+	var target any
+	if schema != nil {
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &target); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(mergePatch(target, p))
+	if err != nil {
+		return nil, err
+	}
+	var out jsonschema.Schema
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("merge patch %s: %w", patch, err)
+	}
+	return &out, nil
+}
+
+// MustMergePatch is like MergePatch but panics if the patch cannot be applied, for patches
+// known to be valid.
+func MustMergePatch(schema *jsonschema.Schema, patch string) *jsonschema.Schema {
+	out, err := MergePatch(schema, patch)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// mergePatch applies the merge patch p to the decoded JSON value target, as the
+// MergePatch algorithm of RFC 7386 section 2 does, without modifying target.
+func mergePatch(target, p any) any {
+	patch, ok := p.(map[string]any)
+	if !ok {
+		return p
+	}
+	out := make(map[string]any)
+	if t, ok := target.(map[string]any); ok {
+		for key, value := range t {
+			out[key] = value
+		}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(out, key)
+		} else {
+			out[key] = mergePatch(out[key], value)
+		}
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package schemautil
+
+import "github.com/google/jsonschema-go/jsonschema"
+
+// TestMergePatch tests that merge patches replace, remove and recursively merge keywords
+// of a copy of the schema.
+func (s *SchemaUtilTestSuite) TestMergePatch() {
+	original := &jsonschema.Schema{
+		Type:        "object",
+		Description: "A user.",
+		Properties: map[string]*jsonschema.Schema{
+			"id":   {Type: "string", MinLength: jsonschema.Ptr(1)},
+			"tags": {Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		},
+		Required: []string{"id"},
+	}
+	merged, err := MergePatch(original, `{"description": null, "required": ["id", "tags"], "properties": {"id": {"format": "uuid", "minLength": null}}, "x-owner": "identity"}`)
+	s.Require().NoError(err)
+	s.Equal(&jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":   {Type: "string", Format: "uuid"},
+			"tags": {Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		},
+		Required: []string{"id", "tags"},
+		Extra:    map[string]any{"x-owner": "identity"},
+	}, merged)
+	s.Equal("A user.", original.Description, "input must not be modified")
+	s.Equal(1, *original.Properties["id"].MinLength, "input must not be modified")
+
+	_, err = MergePatch(original, `["not", "an", "object"]`)
+	s.ErrorContains(err, "is not a JSON object")
+	_, err = MergePatch(original, `{"minLength": "one"}`)
+	s.Error(err)
+	s.Panics(func() { MustMergePatch(original, `{`) })
+}
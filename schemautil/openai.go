@@ -0,0 +1,184 @@
+package schemautil
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// ToOpenAI returns a form of a schema produced by generated code that OpenAI structured
+// outputs accept in strict mode, leaving the input unmodified. Pass an object-typed root,
+// such as the one returned by the generated MCPInputSchema() methods.
+//
+// Every object lists all of its properties in required and sets additionalProperties to
+// false; properties that were not required become nullable instead. oneOf becomes anyOf,
+// and oneOf groups that only constrain which properties are present (proto oneofs) are
+// dropped, since every property is now present. Keywords that strict mode does not
+// support, such as format, pattern, minLength, allOf, not and if/then/else, are dropped,
+// and so are the properties the server sets (see WithoutIdentifiers and
+// WithoutOutputOnlyResources).
+//
+// Maps, google.protobuf.Struct and google.protobuf.Value, and Any fields restricted by
+// the any_types parameter, have no strict-mode form, so schemas containing them are
+// reported as an error naming their location.
+func ToOpenAI(schema *jsonschema.Schema) (*jsonschema.Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	return toOpenAI(inputSchema(schema), "#")
+}
+
+// toOpenAI returns the strict-mode form of s, found at path.
+func toOpenAI(s *jsonschema.Schema, path string) (*jsonschema.Schema, error) {
+	out := &jsonschema.Schema{
+		Ref:              s.Ref,
+		Title:            s.Title,
+		Description:      s.Description,
+		Type:             s.Type,
+		Types:            slices.Clone(s.Types),
+		Enum:             slices.Clone(s.Enum),
+		Const:            s.Const,
+		MultipleOf:       s.MultipleOf,
+		Minimum:          s.Minimum,
+		Maximum:          s.Maximum,
+		ExclusiveMinimum: s.ExclusiveMinimum,
+		ExclusiveMaximum: s.ExclusiveMaximum,
+		MinItems:         s.MinItems,
+		MaxItems:         s.MaxItems,
+	}
+
+	for i, branch := range s.AllOf {
+		if branch.Ref != "" {
+			return nil, fmt.Errorf("%s/allOf/%d: a schema combining other schemas (allOf) cannot be expressed in OpenAI strict mode", path, i)
+		}
+	}
+
+	var err error
+	if len(s.Defs) > 0 {
+		out.Defs = make(map[string]*jsonschema.Schema, len(s.Defs))
+		for name, def := range s.Defs {
+			if out.Defs[name], err = toOpenAI(def, path+"/$defs/"+escapePointerToken(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.Items != nil {
+		if out.Items, err = toOpenAI(s.Items, path+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	for i, branch := range s.AnyOf {
+		converted, err := toOpenAI(branch, fmt.Sprintf("%s/anyOf/%d", path, i))
+		if err != nil {
+			return nil, err
+		}
+		out.AnyOf = append(out.AnyOf, converted)
+	}
+	if !isPresenceOneOf(s.OneOf) {
+		for i, branch := range s.OneOf {
+			converted, err := toOpenAI(branch, fmt.Sprintf("%s/oneOf/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out.AnyOf = append(out.AnyOf, converted)
+		}
+	}
+
+	if !isObject(s) {
+		if s.Ref == "" && s.Type == "" && len(s.Types) == 0 && len(out.AnyOf) == 0 && s.Enum == nil && s.Const == nil {
+			return nil, fmt.Errorf("%s: a value of any type cannot be expressed in OpenAI strict mode", path)
+		}
+		return out, nil
+	}
+
+	if s.AdditionalProperties != nil && !isFalseSchema(s.AdditionalProperties) || s.Properties == nil && s.AdditionalProperties == nil {
+		return nil, fmt.Errorf("%s: a map or free-form object cannot be expressed in OpenAI strict mode", path)
+	}
+	out.AdditionalProperties = &jsonschema.Schema{Not: &jsonschema.Schema{}}
+	out.Properties = make(map[string]*jsonschema.Schema, len(s.Properties))
+	out.Required = []string{}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, err := toOpenAI(s.Properties[name], path+"/properties/"+escapePointerToken(name))
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(s.Required, name) {
+			prop = nullable(prop)
+		}
+		out.Properties[name] = prop
+	}
+	// Originally required properties keep their order and come first.
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; ok && !slices.Contains(out.Required, name) {
+			out.Required = append(out.Required, name)
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(out.Required, name) {
+			out.Required = append(out.Required, name)
+		}
+	}
+	return out, nil
+}
+
+// isObject reports whether s describes an object.
+func isObject(s *jsonschema.Schema) bool {
+	return s.Ref == "" && (s.Type == "object" || slices.Contains(s.Types, "object") || s.Properties != nil)
+}
+
+// isFalseSchema reports whether s is the false schema, which the library represents as
+// {"not": {}}.
+func isFalseSchema(s *jsonschema.Schema) bool {
+	return reflect.DeepEqual(*s, jsonschema.Schema{Not: &jsonschema.Schema{}})
+}
+
+// isPresenceOneOf reports whether the branches of a oneOf only constrain which
+// properties are present, as generated for proto oneofs.
+func isPresenceOneOf(branches []*jsonschema.Schema) bool {
+	if len(branches) == 0 {
+		return false
+	}
+	for _, b := range branches {
+		switch {
+		case b.Not != nil && len(b.Not.AnyOf) > 0:
+			// The "none present" branch.
+		case len(b.Required) > 0 && b.Type == "" && len(b.Types) == 0 && b.Ref == "" && b.Properties == nil:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// nullable returns a form of s that also accepts null.
+func nullable(s *jsonschema.Schema) *jsonschema.Schema {
+	switch {
+	case slices.Contains(s.Types, "null") || s.Type == "null" || slices.ContainsFunc(s.AnyOf, isNullBranch):
+		// Already nullable, e.g. generated with nullable_optional.
+		return s
+	case s.Ref != "" || s.Const != nil || len(s.AnyOf) > 0 || s.Type == "" && len(s.Types) == 0:
+		return &jsonschema.Schema{AnyOf: []*jsonschema.Schema{s, {Type: "null"}}}
+	case s.Type != "":
+		s.Types = []string{s.Type, "null"}
+		s.Type = ""
+	default:
+		s.Types = append(s.Types, "null")
+	}
+	if s.Enum != nil {
+		s.Enum = append(s.Enum, nil)
+	}
+	return s
+}
+
+// isNullBranch reports whether s is a {"type": "null"} branch of a union.
+func isNullBranch(s *jsonschema.Schema) bool {
+	return s.Type == "null" && s.Ref == ""
+}
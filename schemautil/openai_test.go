@@ -0,0 +1,70 @@
+package schemautil
+
+import "github.com/google/jsonschema-go/jsonschema"
+
+// TestToOpenAI tests conversion of a generated-style object root to the OpenAI strict
+// structured-outputs form and that schemas strict mode cannot express are rejected.
+func (s *SchemaUtilTestSuite) TestToOpenAI() {
+	original := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":       {Type: "string", Pattern: "^u"},
+			"email":    {Type: "string", Format: "email"},
+			"nickname": {Type: "string"},
+			"friend":   {Ref: "#/$defs/users.v1.User"},
+			"status":   {Type: "integer", OneOf: []*jsonschema.Schema{{Const: jsonschema.Ptr[any](0)}, {Const: jsonschema.Ptr[any](1)}}},
+		},
+		Required: []string{"id", "email"},
+		OneOf: []*jsonschema.Schema{
+			{Required: []string{"email"}},
+			{Required: []string{"nickname"}},
+		},
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.User": {
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"id": {Type: "string"}},
+			},
+		},
+	}
+
+	converted, err := ToOpenAI(original)
+	s.Require().NoError(err)
+	s.Equal([]string{"id", "email", "friend", "nickname", "status"}, converted.Required)
+	s.Empty(converted.OneOf, "proto oneof presence constraints should be dropped")
+	s.Equal("", converted.Properties["id"].Pattern)
+	s.Equal("", converted.Properties["email"].Format)
+	s.Equal("string", converted.Properties["email"].Type)
+	s.Equal([]string{"string", "null"}, converted.Properties["nickname"].Types)
+	s.Require().Len(converted.Properties["friend"].AnyOf, 2)
+	s.Equal("#/$defs/users.v1.User", converted.Properties["friend"].AnyOf[0].Ref)
+	s.Equal("null", converted.Properties["friend"].AnyOf[1].Type)
+	s.Len(converted.Properties["status"].AnyOf, 2, "oneOf should become anyOf")
+	s.NotNil(converted.AdditionalProperties)
+	user := converted.Defs["users.v1.User"]
+	s.Equal([]string{"id"}, user.Required)
+	s.Equal([]string{"string", "null"}, user.Properties["id"].Types)
+
+	s.Equal("^u", original.Properties["id"].Pattern, "input must not be modified")
+	s.Equal([]string{"id", "email"}, original.Required)
+
+	resolved, err := converted.Resolve(nil)
+	s.Require().NoError(err)
+	valid := map[string]any{"id": "u1", "email": "a@b.c", "friend": nil, "nickname": nil, "status": 1}
+	s.NoError(resolved.Validate(valid))
+	s.Error(resolved.Validate(map[string]any{"id": "u1", "email": "a@b.c"}), "every property should be required")
+	valid["extra"] = true
+	s.Error(resolved.Validate(valid), "additional properties should be rejected")
+
+	_, err = ToOpenAI(&jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"labels": {Type: "object", AdditionalProperties: &jsonschema.Schema{Type: "string"}},
+		},
+	})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "#/properties/labels: a map or free-form object")
+
+	converted, err = ToOpenAI(nil)
+	s.NoError(err)
+	s.Nil(converted)
+}
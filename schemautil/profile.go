@@ -0,0 +1,179 @@
+package schemautil
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// The profiles ToProfile shapes schemas for, one per schema consumer. They are also the
+// values of the plugin's target parameter.
+const (
+	ProfileMCP    = "mcp"
+	ProfileOpenAI = "openai"
+	ProfileGemini = "gemini"
+	ProfileClaude = "claude"
+	ProfilePlain  = "plain"
+)
+
+// Profiles lists the supported profiles.
+var Profiles = []string{ProfileMCP, ProfileOpenAI, ProfileGemini, ProfileClaude, ProfilePlain}
+
+// ToProfile returns the form of a schema produced by generated code that the consumer
+// named by profile accepts, leaving the input unmodified: it calls ToMCP, ToOpenAI,
+// ToGemini, ToClaude or ToPlain. Unknown profiles are reported as an error.
+func ToProfile(schema *jsonschema.Schema, profile string) (*jsonschema.Schema, error) {
+	switch profile {
+	case ProfileMCP:
+		return ToMCP(schema)
+	case ProfileOpenAI:
+		return ToOpenAI(schema)
+	case ProfileGemini:
+		return ToGemini(schema)
+	case ProfileClaude:
+		return ToClaude(schema)
+	case ProfilePlain:
+		return ToPlain(schema), nil
+	}
+	return nil, fmt.Errorf("unknown schema profile %q (supported: %s)", profile, strings.Join(Profiles, ", "))
+}
+
+// ToPlain returns a copy of a schema with only JSON Schema keywords, leaving the input
+// unmodified: $comment and the keywords generated code adds outside the JSON Schema
+// vocabulary (Extra, such as x-generation-options and propertyOrdering) are removed.
+func ToPlain(schema *jsonschema.Schema) *jsonschema.Schema {
+	if schema == nil {
+		return nil
+	}
+	out := schema.CloneSchemas()
+	walkSchemas(out, make(map[*jsonschema.Schema]bool), func(s *jsonschema.Schema) {
+		s.Comment = ""
+		s.Extra = nil
+	})
+	return out
+}
+
+// ToMCP returns the form of a schema that MCP tools accept as their inputSchema, leaving
+// the input unmodified: the ToPlain form without the properties the server sets (see
+// WithoutIdentifiers and WithoutOutputOnlyResources), whose root must be an object schema
+// such as the one returned by the generated MCPInputSchema() methods.
+func ToMCP(schema *jsonschema.Schema) (*jsonschema.Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	if !isObject(schema) {
+		return nil, fmt.Errorf("#: an MCP input schema must be an object schema, not a reference or %s", describeType(schema))
+	}
+	return ToPlain(inputSchema(schema)), nil
+}
+
+// Keywords generated code marks the schemas of properties the server sets with, which
+// the input profiles leave out.
+const (
+	// identifierKeyword marks identifier fields (google.api.field_behavior IDENTIFIER or
+	// the identifier plugin parameter).
+	identifierKeyword = "x-identifier"
+
+	// outputOnlyResourceKeyword marks OUTPUT_ONLY fields holding messages annotated with
+	// google.api.resource.
+	outputOnlyResourceKeyword = "x-output-only-resource"
+)
+
+// WithoutIdentifiers returns a copy of a schema without the properties of identifier
+// fields, leaving the input unmodified. The server assigns resource names, so the input
+// profiles (ToMCP, ToOpenAI, ToGemini and ToClaude) leave them out of the schemas they
+// return; the properties are removed from required too.
+func WithoutIdentifiers(schema *jsonschema.Schema) *jsonschema.Schema {
+	return withoutMarked(schema, identifierKeyword)
+}
+
+// WithoutOutputOnlyResources returns a copy of a schema without the properties of
+// OUTPUT_ONLY fields holding resources, at any depth, and without the definitions only
+// they referenced, leaving the input unmodified. The server fills in such nested
+// resources, so the input profiles leave them out too.
+func WithoutOutputOnlyResources(schema *jsonschema.Schema) *jsonschema.Schema {
+	return withoutMarked(schema, outputOnlyResourceKeyword)
+}
+
+// inputSchema returns the copy of a schema the input profiles start from, without the
+// properties the server sets.
+func inputSchema(schema *jsonschema.Schema) *jsonschema.Schema {
+	return withoutMarked(schema, identifierKeyword, outputOnlyResourceKeyword)
+}
+
+// withoutMarked returns a copy of schema without the properties whose schemas set one of
+// the keywords to true, removed from required too, and without the definitions of the
+// root that only those properties referenced.
+func withoutMarked(schema *jsonschema.Schema, keywords ...string) *jsonschema.Schema {
+	if schema == nil {
+		return nil
+	}
+	out := schema.CloneSchemas()
+	reachable := reachableDefinitions(out)
+	walkSchemas(out, make(map[*jsonschema.Schema]bool), func(s *jsonschema.Schema) {
+		for name, property := range s.Properties {
+			if property != nil && slices.ContainsFunc(keywords, func(keyword string) bool { return property.Extra[keyword] == true }) {
+				delete(s.Properties, name)
+				s.Required = slices.DeleteFunc(slices.Clone(s.Required), func(required string) bool { return required == name })
+			}
+		}
+	})
+	still := reachableDefinitions(out)
+	for name := range reachable {
+		if !still[name] {
+			delete(out.Defs, name)
+		}
+	}
+	return out
+}
+
+// reachableDefinitions returns the names of the definitions of root that its schema
+// references, directly or through other definitions.
+func reachableDefinitions(root *jsonschema.Schema) map[string]bool {
+	reached := make(map[string]bool)
+	visited := make(map[*jsonschema.Schema]bool)
+	var visit func(s *jsonschema.Schema)
+	visit = func(s *jsonschema.Schema) {
+		walkSchemas(s, visited, func(s *jsonschema.Schema) {
+			if s.Ref == "" {
+				return
+			}
+			if name, ok := definitionName(root, s.Ref); ok && !reached[name] {
+				reached[name] = true
+				visit(root.Defs[name])
+			}
+		})
+	}
+	withoutDefs := *root
+	withoutDefs.Defs = nil
+	visit(&withoutDefs)
+	return reached
+}
+
+// ToClaude returns the form of a schema that Claude tool definitions accept as their
+// input_schema, leaving the input unmodified: the ToMCP form without oneOf, anyOf and
+// allOf at the root, which tool input schemas do not support. Those only carry proto
+// oneof presence constraints and message presets, so the schema accepts more instances.
+func ToClaude(schema *jsonschema.Schema) (*jsonschema.Schema, error) {
+	out, err := ToMCP(schema)
+	if out == nil || err != nil {
+		return out, err
+	}
+	out.OneOf = nil
+	out.AnyOf = nil
+	out.AllOf = nil
+	return out, nil
+}
+
+// describeType returns the type keyword of s for error messages.
+func describeType(s *jsonschema.Schema) string {
+	switch {
+	case s.Type != "":
+		return fmt.Sprintf("type %q", s.Type)
+	case len(s.Types) > 0:
+		return fmt.Sprintf("types %q", s.Types)
+	}
+	return "an untyped schema"
+}
@@ -0,0 +1,156 @@
+package schemautil
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestToProfile tests that each profile removes the keywords its consumer does not support
+// without modifying the input, and that unknown profiles and non-object MCP roots are
+// rejected.
+func (s *SchemaUtilTestSuite) TestToProfile() {
+	original := &jsonschema.Schema{
+		Type:    "object",
+		Comment: "generated",
+		Properties: map[string]*jsonschema.Schema{
+			"id":    {Type: "string", Comment: "field id"},
+			"owner": {Ref: "#/$defs/users.v1.Owner"},
+		},
+		Required: []string{"id"},
+		Extra:    map[string]any{"x-generation-options": map[string]any{"draft": "2020-12"}, "propertyOrdering": []string{"id", "owner"}},
+		OneOf: []*jsonschema.Schema{
+			{Required: []string{"id"}},
+			{Required: []string{"owner"}},
+		},
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.Owner": {Type: "object", Properties: map[string]*jsonschema.Schema{"email": {Type: "string"}}, Extra: map[string]any{"x-kind": "owner"}},
+		},
+	}
+
+	plain, err := ToProfile(original, ProfilePlain)
+	s.Require().NoError(err)
+	s.Equal("", plain.Comment)
+	s.Nil(plain.Extra)
+	s.Equal("", plain.Properties["id"].Comment)
+	s.Nil(plain.Defs["users.v1.Owner"].Extra)
+	s.Len(plain.OneOf, 2, "plain keeps every JSON Schema keyword")
+
+	mcp, err := ToProfile(original, ProfileMCP)
+	s.Require().NoError(err)
+	s.Nil(mcp.Extra)
+	s.Len(mcp.OneOf, 2)
+
+	claude, err := ToProfile(original, ProfileClaude)
+	s.Require().NoError(err)
+	s.Nil(claude.Extra)
+	s.Empty(claude.OneOf, "root oneOf should be removed for Claude")
+	s.Equal("#/$defs/users.v1.Owner", claude.Properties["owner"].Ref)
+	resolved, err := claude.Resolve(nil)
+	s.Require().NoError(err)
+	s.NoError(resolved.Validate(map[string]any{"id": "u1", "owner": map[string]any{"email": "a@b.c"}}))
+
+	for _, profile := range []string{ProfileOpenAI, ProfileGemini} {
+		converted, err := ToProfile(original, profile)
+		s.Require().NoError(err, profile)
+		s.NotContains(converted.Extra, "x-generation-options", profile)
+	}
+
+	s.Equal("generated", original.Comment, "input must not be modified")
+	s.Contains(original.Extra, "x-generation-options")
+	s.Equal("owner", original.Defs["users.v1.Owner"].Extra["x-kind"])
+	s.Len(original.OneOf, 2)
+
+	_, err = ToProfile(original, "mistral")
+	s.Require().Error(err)
+	s.Contains(err.Error(), `unknown schema profile "mistral" (supported: mcp, openai, gemini, claude, plain)`)
+
+	_, err = ToMCP(&jsonschema.Schema{Ref: "#/$defs/users.v1.Owner"})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "an MCP input schema must be an object schema")
+	s.Nil(ToPlain(nil))
+}
+
+// TestWithoutIdentifiers tests that the input profiles leave out identifier properties,
+// at the root and in definitions, along with their required entries, without modifying
+// the input.
+func (s *SchemaUtilTestSuite) TestWithoutIdentifiers() {
+	identifier := map[string]any{"x-identifier": true}
+	original := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"name":  {Type: "string", ReadOnly: true, Extra: identifier},
+			"title": {Type: "string"},
+			"shelf": {Ref: "#/$defs/library.v1.Shelf"},
+		},
+		Required: []string{"name", "title"},
+		Defs: map[string]*jsonschema.Schema{
+			"library.v1.Shelf": {Type: "object", Properties: map[string]*jsonschema.Schema{"name": {Type: "string", Extra: identifier}, "theme": {Type: "string"}}},
+		},
+	}
+
+	stripped := WithoutIdentifiers(original)
+	s.NotContains(stripped.Properties, "name")
+	s.Contains(stripped.Properties, "title")
+	s.Equal([]string{"title"}, stripped.Required)
+	s.NotContains(stripped.Defs["library.v1.Shelf"].Properties, "name")
+	s.Contains(stripped.Defs["library.v1.Shelf"].Properties, "theme")
+
+	for _, profile := range []string{ProfileMCP, ProfileOpenAI, ProfileGemini, ProfileClaude} {
+		converted, err := ToProfile(original, profile)
+		s.Require().NoError(err, profile)
+		s.NotContains(converted.Properties, "name", profile)
+		s.Contains(converted.Properties, "title", profile)
+	}
+	plain, err := ToProfile(original, ProfilePlain)
+	s.Require().NoError(err)
+	s.Contains(plain.Properties, "name", "plain is not an input profile")
+
+	s.Contains(original.Properties, "name", "input must not be modified")
+	s.Equal([]string{"name", "title"}, original.Required)
+	s.Nil(WithoutIdentifiers(nil))
+}
+
+// TestWithoutOutputOnlyResources tests that the input profiles leave out output-only
+// resource properties at any depth, and the definitions only they referenced, without
+// modifying the input.
+func (s *SchemaUtilTestSuite) TestWithoutOutputOnlyResources() {
+	outputOnly := map[string]any{"x-output-only-resource": true}
+	original := &jsonschema.Schema{
+		Ref: "#/$defs/library.v1.Review",
+		Defs: map[string]*jsonschema.Schema{
+			"library.v1.Review": {Type: "object", Properties: map[string]*jsonschema.Schema{
+				"text":     {Type: "string"},
+				"book":     {Ref: "#/$defs/library.v1.Book", ReadOnly: true, Extra: outputOnly},
+				"editions": {Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/library.v1.Book"}, ReadOnly: true, Extra: outputOnly},
+				"reviewer": {Ref: "#/$defs/library.v1.Reader"},
+			}, Required: []string{"book", "text"}},
+			"library.v1.Book":   {Type: "object", Properties: map[string]*jsonschema.Schema{"format": {Ref: "#/$defs/library.v1.Format"}, "author": {Ref: "#/$defs/library.v1.Reader"}}},
+			"library.v1.Format": {Type: "integer"},
+			"library.v1.Reader": {Type: "object", Properties: map[string]*jsonschema.Schema{
+				"favorite": {Ref: "#/$defs/library.v1.Book", ReadOnly: true, Extra: outputOnly},
+				"name":     {Type: "string"},
+			}},
+		},
+	}
+
+	stripped := WithoutOutputOnlyResources(original)
+	review := stripped.Defs["library.v1.Review"]
+	s.ElementsMatch([]string{"text", "reviewer"}, slices.Collect(maps.Keys(review.Properties)))
+	s.Equal([]string{"text"}, review.Required)
+	s.NotContains(stripped.Defs["library.v1.Reader"].Properties, "favorite", "nested resources are dropped at any depth")
+	s.ElementsMatch([]string{"library.v1.Review", "library.v1.Reader"}, slices.Collect(maps.Keys(stripped.Defs)), "definitions only the dropped properties referenced are removed")
+
+	// An object root copied from a definition, as MCPInputSchema() returns.
+	root := original.Defs["library.v1.Review"].CloneSchemas()
+	root.Defs = original.Defs
+	mcp, err := ToMCP(root)
+	s.Require().NoError(err)
+	s.NotContains(mcp.Properties, "book")
+	s.NotContains(mcp.Defs, "library.v1.Book")
+
+	s.Contains(original.Defs, "library.v1.Book", "input must not be modified")
+	s.Contains(original.Defs["library.v1.Review"].Properties, "book")
+	s.Nil(WithoutOutputOnlyResources(nil))
+}
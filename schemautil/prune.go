@@ -0,0 +1,158 @@
+package schemautil
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// Prune returns a copy of data without the properties the schema does not declare, for
+// example to clean up arguments produced by a language model before passing them to an
+// API that rejects unknown fields. It descends into nested objects, arrays and map values
+// by following properties, items, additionalProperties and "#/$defs/" references of the
+// root, and into the properties of every allOf, anyOf and oneOf branch, so a property
+// declared by any branch is kept. Objects whose schema declares no properties and is not
+// closed (such as google.protobuf.Struct values) and values of other types are kept
+// unchanged. The input is not modified, and invalid values are not removed: validate the
+// result to reject them.
+func Prune(schema *jsonschema.Schema, data map[string]any) map[string]any {
+	if schema == nil || data == nil {
+		return data
+	}
+	p := pruner{root: schema}
+	return p.prune(schema, data).(map[string]any)
+}
+
+// pruner removes the undeclared properties of values, resolving references against the
+// root schema.
+type pruner struct {
+	root *jsonschema.Schema
+}
+
+// prune returns the pruned copy of a value validated by s.
+func (p pruner) prune(s *jsonschema.Schema, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		schemas := applicable(p.root, s)
+		declared := false
+		for _, s := range schemas {
+			if s.Properties != nil || s.PatternProperties != nil || s.AdditionalProperties != nil {
+				declared = true
+			}
+		}
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			if subschemas := propertySchemas(schemas, key); len(subschemas) > 0 {
+				out[key] = p.prune(&jsonschema.Schema{AllOf: subschemas}, value)
+			} else if !declared {
+				out[key] = value
+			}
+		}
+		return out
+	case []any:
+		schemas := applicable(p.root, s)
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = value
+			if subschemas := itemSchemas(schemas, i); len(subschemas) > 0 {
+				out[i] = p.prune(&jsonschema.Schema{AllOf: subschemas}, value)
+			}
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// applicable returns s and the schemas that apply to the same value through references
+// to the root and its definitions and through allOf, anyOf and oneOf branches, each once.
+func applicable(root, s *jsonschema.Schema) []*jsonschema.Schema {
+	var schemas []*jsonschema.Schema
+	visited := make(map[*jsonschema.Schema]bool)
+	var visit func(s *jsonschema.Schema)
+	visit = func(s *jsonschema.Schema) {
+		if s == nil || visited[s] {
+			return
+		}
+		visited[s] = true
+		schemas = append(schemas, s)
+		if s.Ref != "" {
+			visit(resolveLocal(root, s.Ref))
+		}
+		for _, branches := range [][]*jsonschema.Schema{s.AllOf, s.AnyOf, s.OneOf} {
+			for _, branch := range branches {
+				visit(branch)
+			}
+		}
+	}
+	visit(s)
+	return schemas
+}
+
+// resolveLocal returns the schema a local reference of the root points at: the root
+// itself or one of its definitions, and nil for other references.
+func resolveLocal(root *jsonschema.Schema, ref string) *jsonschema.Schema {
+	if normalizeRef(ref) == "#" || (root.ID != "" && ref == root.ID) {
+		return root
+	}
+	if name, ok := definitionName(root, ref); ok {
+		return root.Defs[name]
+	}
+	return nil
+}
+
+// definitionName returns the name of the definition of the root that a reference
+// points at, by a "#/$defs/" pointer or by the $id of the definition (as with the
+// schema_base_uri plugin parameter), and whether it is one.
+func definitionName(root *jsonschema.Schema, ref string) (string, bool) {
+	if name, ok := strings.CutPrefix(normalizeRef(ref), "#/$defs/"); ok {
+		return name, true
+	}
+	for name, def := range root.Defs {
+		if def != nil && def.ID != "" && def.ID == ref {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// propertySchemas returns the subschemas the schemas apply to the property key of an
+// object: those of properties and matching patternProperties, or else the
+// additionalProperties schemas other than false.
+func propertySchemas(schemas []*jsonschema.Schema, key string) []*jsonschema.Schema {
+	var subschemas []*jsonschema.Schema
+	for _, s := range schemas {
+		if property, ok := s.Properties[key]; ok {
+			subschemas = append(subschemas, property)
+		}
+		for pattern, property := range s.PatternProperties {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(key) {
+				subschemas = append(subschemas, property)
+			}
+		}
+	}
+	if len(subschemas) == 0 {
+		for _, s := range schemas {
+			if s.AdditionalProperties != nil && !isFalseSchema(s.AdditionalProperties) {
+				subschemas = append(subschemas, s.AdditionalProperties)
+			}
+		}
+	}
+	return subschemas
+}
+
+// itemSchemas returns the subschemas the schemas apply to the element at index i of an
+// array: the prefixItems entry at i or else items.
+func itemSchemas(schemas []*jsonschema.Schema, i int) []*jsonschema.Schema {
+	var subschemas []*jsonschema.Schema
+	for _, s := range schemas {
+		switch {
+		case i < len(s.PrefixItems):
+			subschemas = append(subschemas, s.PrefixItems[i])
+		case s.Items != nil:
+			subschemas = append(subschemas, s.Items)
+		}
+	}
+	return subschemas
+}
@@ -0,0 +1,80 @@
+package schemautil
+
+import "github.com/google/jsonschema-go/jsonschema"
+
+// TestPrune tests that pruning drops undeclared properties at every depth, following
+// references, maps, arrays and oneOf branches, and keeps free-form objects.
+func (s *SchemaUtilTestSuite) TestPrune() {
+	schema := userSchema()
+	user := schema.Defs["users.v1.User"]
+	user.AdditionalProperties = &jsonschema.Schema{Not: &jsonschema.Schema{}}
+	user.Properties["friends"] = &jsonschema.Schema{Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/users.v1.User"}}
+	user.Properties["labels"] = &jsonschema.Schema{Type: "object", AdditionalProperties: &jsonschema.Schema{Ref: "#/$defs/users.v1.Label"}}
+	user.Properties["metadata"] = &jsonschema.Schema{Type: "object"}
+	user.OneOf = []*jsonschema.Schema{
+		{Properties: map[string]*jsonschema.Schema{"email": {Type: "string"}}},
+		{Properties: map[string]*jsonschema.Schema{"phone": {Type: "string"}}},
+	}
+	schema.Defs["users.v1.Label"] = &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{"value": {Type: "string"}}}
+
+	data := map[string]any{
+		"id":      "u1",
+		"unknown": 1,
+		"email":   "a@example.com",
+		"friends": []any{map[string]any{"id": "u2", "extra": true}, "not an object"},
+		"labels": map[string]any{
+			"team": map[string]any{"value": "core", "color": "red"},
+		},
+		"metadata": map[string]any{"anything": map[string]any{"goes": 1}},
+	}
+	s.Equal(map[string]any{
+		"id":      "u1",
+		"email":   "a@example.com",
+		"friends": []any{map[string]any{"id": "u2"}, "not an object"},
+		"labels": map[string]any{
+			"team": map[string]any{"value": "core"},
+		},
+		"metadata": map[string]any{"anything": map[string]any{"goes": 1}},
+	}, Prune(schema, data))
+	s.Contains(data, "unknown", "input must not be modified")
+	s.Contains(data["friends"].([]any)[0], "extra", "input must not be modified")
+	s.Nil(Prune(schema, nil))
+}
+
+// TestIDReferences tests that Prune, CoerceToolArgs and ToGemini follow references to
+// definitions by their $id, as generated with the schema_base_uri plugin parameter.
+func (s *SchemaUtilTestSuite) TestIDReferences() {
+	const owner = "https://schemas.example.com/users/v1/Owner.json"
+	schema := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":    {Type: "string"},
+			"owner": {Ref: owner},
+		},
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.Owner": {
+				ID:         owner,
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"age": {Type: "integer"}},
+			},
+		},
+	}
+
+	s.Equal(map[string]any{"id": "u1", "owner": map[string]any{"age": 7}},
+		Prune(schema, map[string]any{"id": "u1", "extra": true, "owner": map[string]any{"age": 7, "extra": true}}))
+
+	coerced, err := CoerceToolArgs(schema, map[string]any{"owner": `{"age": "7"}`})
+	s.Require().NoError(err)
+	s.Equal(map[string]any{"owner": map[string]any{"age": int64(7)}}, coerced)
+
+	gemini, err := ToGemini(schema)
+	s.Require().NoError(err)
+	s.Require().Contains(gemini.Properties, "owner")
+	s.Equal("object", gemini.Properties["owner"].Type)
+	s.Contains(gemini.Properties["owner"].Properties, "age")
+	s.Empty(gemini.Defs)
+
+	schema.Properties["owner"].Ref = "https://schemas.example.com/users/v1/Missing.json"
+	_, err = ToGemini(schema)
+	s.ErrorContains(err, "cannot be inlined")
+}
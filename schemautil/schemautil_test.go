@@ -0,0 +1,35 @@
+package schemautil
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/stretchr/testify/suite"
+)
+
+// SchemaUtilTestSuite contains tests for the schemautil runtime package.
+type SchemaUtilTestSuite struct {
+	suite.Suite
+}
+
+// TestSchemaUtilSuite runs the SchemaUtilTestSuite.
+func TestSchemaUtilSuite(t *testing.T) {
+	suite.Run(t, new(SchemaUtilTestSuite))
+}
+
+// userSchema returns a ref-as-root schema shaped like generated output.
+func userSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Ref: "#/$defs/users.v1.User",
+		Defs: map[string]*jsonschema.Schema{
+			"users.v1.User": {
+				Type: "object",
+				Properties: map[string]*jsonschema.Schema{
+					"id":   {Type: "string"},
+					"tags": {Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+				},
+				Required: []string{"id", "tags"},
+			},
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package schemautil
+
+import (
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// SplitDefsDir is the directory, relative to the root document, that Split places the
+// documents of definitions in.
+const SplitDefsDir = "defs"
+
+// DefinitionFile returns the path, relative to the root document, of the document Split
+// produces for the definition with the given name, e.g. "defs/users.v1.User.json".
+func DefinitionFile(name string) string {
+	return path.Join(SplitDefsDir, name+".json")
+}
+
+// Split returns a bundled schema as separate documents, one per definition, for schemas
+// too large to publish or load as a single file, leaving the input unmodified. The
+// documents are keyed by slash-separated path relative to the root document: the schema
+// without its $defs under root, a file name such as "users.v1.User.json", and each
+// definition under DefinitionFile.
+//
+// Local references become relative file references: "#/$defs/<name>" points at the
+// definition's document ("defs/<name>.json" from the root document and "<name>.json" from
+// other definitions), keeping the rest of the pointer as the fragment, and other local
+// references in definitions point into the root document ("../<root>#..."). Definitions
+// that are a bare reference to the root, like those object_root generates for
+// self-references, get no document, and references to them point at the root.
+func Split(schema *jsonschema.Schema, root string) map[string]*jsonschema.Schema {
+	if schema == nil {
+		return nil
+	}
+	out := schema.CloneSchemas()
+	defs := out.Defs
+	out.Defs = nil
+
+	toRoot := make(map[string]bool)
+	for name, def := range defs {
+		rest := *def
+		rest.Ref = ""
+		if normalizeRef(def.Ref) == "#" && reflect.ValueOf(rest).IsZero() {
+			toRoot[name] = true
+		}
+	}
+	rewrite := func(doc *jsonschema.Schema, fromRoot bool) {
+		walkSchemas(doc, make(map[*jsonschema.Schema]bool), func(s *jsonschema.Schema) {
+			if s.Ref != "" {
+				s.Ref = splitRef(s.Ref, root, fromRoot, toRoot)
+			}
+		})
+	}
+
+	docs := map[string]*jsonschema.Schema{root: out}
+	rewrite(out, true)
+	for name, def := range defs {
+		if toRoot[name] {
+			continue
+		}
+		rewrite(def, false)
+		docs[DefinitionFile(name)] = def
+	}
+	return docs
+}
+
+// splitRef returns the form of a $ref value in a document produced by Split: a reference
+// to a definition's document or to the root document for local references, and ref
+// itself otherwise. fromRoot reports whether the reference is in the root document.
+func splitRef(ref, root string, fromRoot bool, toRoot map[string]bool) string {
+	fragment, ok := strings.CutPrefix(normalizeRef(ref), "#")
+	if !ok {
+		return ref
+	}
+	if rest, ok := strings.CutPrefix(fragment, "/$defs/"); ok {
+		name, pointer, _ := strings.Cut(rest, "/")
+		if !toRoot[name] {
+			file := url.PathEscape(name) + ".json"
+			if fromRoot {
+				file = SplitDefsDir + "/" + file
+			}
+			if pointer != "" {
+				file += "#/" + pointer
+			}
+			return file
+		}
+		fragment = ""
+		if pointer != "" {
+			fragment = "/" + pointer
+		}
+	}
+	if fromRoot {
+		return "#" + fragment
+	}
+	target := "../" + url.PathEscape(root)
+	if fragment != "" {
+		target += "#" + fragment
+	}
+	return target
+}
@@ -0,0 +1,66 @@
+package schemautil
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestSplit tests that splitting moves definitions into their own documents with relative
+// file references, which resolve to the validation behavior of the bundled schema.
+func (s *SchemaUtilTestSuite) TestSplit() {
+	original := userSchema()
+	user := original.Defs["users.v1.User"]
+	user.Properties["friend"] = &jsonschema.Schema{Ref: "#/$defs/users.v1.User"}
+	user.Properties["tags"].Items = &jsonschema.Schema{Ref: "#/$defs/users.v1.Tag"}
+	user.Properties["first_tag"] = &jsonschema.Schema{Ref: "#/$defs/users.v1.User/properties/tags/items"}
+	original.Defs["users.v1.Tag"] = &jsonschema.Schema{Type: "string", MinLength: jsonschema.Ptr(1)}
+
+	docs := Split(original, "users.v1.User.json")
+	s.Len(docs, 3)
+	s.Equal("defs/users.v1.User.json", docs["users.v1.User.json"].Ref)
+	s.Nil(docs["users.v1.User.json"].Defs)
+	split := docs[DefinitionFile("users.v1.User")]
+	s.Require().NotNil(split)
+	s.Equal("users.v1.User.json", split.Properties["friend"].Ref)
+	s.Equal("users.v1.Tag.json", split.Properties["tags"].Items.Ref)
+	s.Equal("users.v1.User.json#/properties/tags/items", split.Properties["first_tag"].Ref)
+	s.Equal("#/$defs/users.v1.User", original.Ref, "input must not be modified")
+	s.Equal("#/$defs/users.v1.User", user.Properties["friend"].Ref, "input must not be modified")
+	s.Nil(Split(nil, "users.v1.User.json"))
+
+	// Documents are loaded relative to the root's URI.
+	loader := func(uri *url.URL) (*jsonschema.Schema, error) {
+		doc, ok := docs[strings.TrimPrefix(uri.Path, "/schemas/")]
+		if !ok {
+			return nil, fmt.Errorf("no document %s", uri)
+		}
+		return doc, nil
+	}
+	resolved, err := docs["users.v1.User.json"].Resolve(&jsonschema.ResolveOptions{BaseURI: "file:///schemas/users.v1.User.json", Loader: loader})
+	s.Require().NoError(err)
+	s.NoError(resolved.Validate(map[string]any{"id": "u1", "tags": []any{"a"}, "friend": map[string]any{"id": "u2", "tags": []any{}}}))
+	s.Error(resolved.Validate(map[string]any{"id": "u1", "tags": []any{""}}), "referenced definition should apply")
+	s.Error(resolved.Validate(map[string]any{"id": "u1", "tags": []any{}, "friend": map[string]any{"id": 2, "tags": []any{}}}), "self-reference should apply")
+	s.Error(resolved.Validate(map[string]any{"id": "u1", "tags": []any{}, "first_tag": ""}), "pointer into a definition should apply")
+
+	s.Run("object root", func() {
+		root := userSchema().Defs["users.v1.User"]
+		root.Properties["friend"] = &jsonschema.Schema{Ref: "#/$defs/users.v1.User"}
+		root.Properties["address"] = &jsonschema.Schema{Ref: "#/$defs/users.v1.Address"}
+		root.Defs = map[string]*jsonschema.Schema{
+			"users.v1.User":    {Ref: "#"},
+			"users.v1.Address": {Type: "object", Properties: map[string]*jsonschema.Schema{"owner": {Ref: "#/$defs/users.v1.User"}, "street": {Ref: "#/properties/id"}}},
+		}
+		docs := Split(root, "users.v1.User.json")
+		s.Len(docs, 2, "the definition referencing the root should get no document")
+		s.Equal("#", docs["users.v1.User.json"].Properties["friend"].Ref)
+		s.Equal("defs/users.v1.Address.json", docs["users.v1.User.json"].Properties["address"].Ref)
+		address := docs[DefinitionFile("users.v1.Address")]
+		s.Require().NotNil(address)
+		s.Equal("../users.v1.User.json", address.Properties["owner"].Ref)
+		s.Equal("../users.v1.User.json#/properties/id", address.Properties["street"].Ref)
+	})
+}
@@ -0,0 +1,203 @@
+package schemautil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// Stats receives the outcome of every validation a Validator performs, for example to
+// export counters of validations and failures and a histogram of their durations to a
+// monitoring system. Implementations must be safe for concurrent use.
+type Stats interface {
+	// ObserveValidation is called after each validation against the schema named name,
+	// with the validation error (nil when the instance is valid) and the time it took.
+	ObserveValidation(name string, err error, duration time.Duration)
+}
+
+// StatsFunc adapts a function to the Stats interface.
+type StatsFunc func(name string, err error, duration time.Duration)
+
+// ObserveValidation calls f(name, err, duration).
+func (f StatsFunc) ObserveValidation(name string, err error, duration time.Duration) {
+	f(name, err, duration)
+}
+
+// Validator validates instances against a schema resolved once, such as the schema of a
+// generated JsonSchema() method, and reports each validation to its Stats, if any. When
+// the schema has x-constraints, x-formatMinimum or x-formatMaximum keywords, instances
+// that pass validation are also checked by EvaluateConstraints. It is safe for concurrent
+// use, so servers can keep one per message for their lifetime.
+type Validator struct {
+	name     string
+	resolved *jsonschema.Resolved
+	stats    Stats
+
+	// constraints is the schema when EvaluateConstraints has keywords to check in it,
+	// and nil otherwise.
+	constraints *jsonschema.Schema
+
+	// shell and properties split the validation of object instances for ValidateContext:
+	// properties validates the values of the top-level properties one at a time, and
+	// shell the rest of the instance, with those properties accepting any value. Both are
+	// nil when the schema cannot be split (see splitTopLevel).
+	shell      *jsonschema.Resolved
+	properties map[string]*jsonschema.Resolved
+}
+
+// NewValidator resolves the schema and returns a Validator for it. The name identifies
+// the schema to stats, for example the message's full name; stats may be nil. The schema
+// must not be modified afterwards.
+func NewValidator(name string, schema *jsonschema.Schema, stats Stats) (*Validator, error) {
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("resolving the schema of %s: %w", name, err)
+	}
+	v := &Validator{name: name, resolved: resolved, stats: stats}
+	if hasConstraints(schema) {
+		v.constraints = schema
+	}
+	if shell, properties := splitTopLevel(schema); shell != nil {
+		v.shell, err = shell.Resolve(nil)
+		v.properties = make(map[string]*jsonschema.Resolved, len(properties))
+		for key, property := range properties {
+			if err != nil {
+				break
+			}
+			v.properties[key], err = property.Resolve(nil)
+		}
+		if err != nil {
+			v.shell, v.properties = nil, nil
+		}
+	}
+	return v, nil
+}
+
+// Name returns the name the Validator reports validations under.
+func (v *Validator) Name() string {
+	return v.name
+}
+
+// Validate validates a decoded JSON instance, such as a map[string]any, against the
+// schema and reports the outcome to the Validator's Stats.
+func (v *Validator) Validate(instance any) error {
+	start := time.Now()
+	err := v.resolved.Validate(instance)
+	if err == nil && v.constraints != nil {
+		err = EvaluateConstraints(v.constraints, instance)
+	}
+	if v.stats != nil {
+		v.stats.ObserveValidation(v.name, err, time.Since(start))
+	}
+	return err
+}
+
+// ValidateContext is like Validate, but returns the context's error instead of finishing
+// the validation once ctx is done, so request deadlines stay effective for large payloads.
+// The context is checked before validating and, for objects (map[string]any) of schemas
+// with top-level properties, between validating the value of each of them; the rest of
+// the instance is validated last. Validation itself cannot be interrupted, so a single
+// large property value is still validated to the end. The outcome, including a context
+// error, is reported to the Validator's Stats.
+func (v *Validator) ValidateContext(ctx context.Context, instance any) error {
+	start := time.Now()
+	err := v.validateContext(ctx, instance)
+	if err == nil && v.constraints != nil {
+		err = EvaluateConstraints(v.constraints, instance)
+	}
+	if v.stats != nil {
+		v.stats.ObserveValidation(v.name, err, time.Since(start))
+	}
+	return err
+}
+
+// validateContext implements ValidateContext.
+func (v *Validator) validateContext(ctx context.Context, instance any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	object, ok := instance.(map[string]any)
+	if !ok || v.shell == nil {
+		return v.resolved.Validate(instance)
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		if v.properties[key] != nil {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := v.properties[key].Validate(object[key]); err != nil {
+			return fmt.Errorf("property %q: %w", key, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return v.shell.Validate(instance)
+}
+
+// splitTopLevel returns the schemas ValidateContext validates an object instance with:
+// the schema of each top-level property, with the root's definitions, and a shell that
+// is the object schema with every top-level property accepting any value. The object
+// schema is the root, or the definition a root with only a reference refers to, as in the
+// JsonSchema() output of generated code. It returns nils for other schemas and when a
+// subschema refers to the root, which the shell would replace.
+//
+// The returned schemas share the root's definitions, which resolving does not modify, so
+// only the object schema is copied rather than every definition once per property. The
+// input is not modified.
+func splitTopLevel(root *jsonschema.Schema) (*jsonschema.Schema, map[string]*jsonschema.Schema) {
+	object := root
+	if root.Ref != "" {
+		object = nil
+		if name, ok := definitionName(root, root.Ref); ok && onlyReference(root) {
+			object = root.Defs[name]
+		}
+	}
+	if object == nil || len(object.Properties) == 0 {
+		return nil, nil
+	}
+	refersToRoot := false
+	walkSchemas(root, make(map[*jsonschema.Schema]bool), func(s *jsonschema.Schema) {
+		if s != root && s.Ref != "" && resolveLocal(root, s.Ref) == root {
+			refersToRoot = true
+		}
+	})
+	if refersToRoot {
+		return nil, nil
+	}
+
+	// Each property schema is copied, since it is also reachable through the definitions
+	// (or the root) and a resolved schema must be a tree.
+	properties := make(map[string]*jsonschema.Schema, len(object.Properties))
+	for key, property := range object.Properties {
+		properties[key] = &jsonschema.Schema{Defs: root.Defs, AllOf: []*jsonschema.Schema{property.CloneSchemas()}}
+	}
+	rest := *object
+	rest.Properties, rest.Defs = nil, nil
+	shell := rest.CloneSchemas()
+	shell.Properties = make(map[string]*jsonschema.Schema, len(object.Properties))
+	for key := range object.Properties {
+		shell.Properties[key] = &jsonschema.Schema{}
+	}
+	if object != root {
+		shell.ID = ""
+	}
+	shell.Defs = root.Defs
+	return shell, properties
+}
+
+// onlyReference reports whether a schema with a reference has no other keywords than its
+// definitions and annotations, so that it validates as the schema it refers to.
+func onlyReference(s *jsonschema.Schema) bool {
+	rest := *s
+	rest.Ref, rest.Defs, rest.Schema, rest.ID = "", nil, "", ""
+	rest.Title, rest.Description, rest.Comment, rest.Extra = "", "", "", nil
+	return reflect.DeepEqual(rest, jsonschema.Schema{})
+}
@@ -0,0 +1,123 @@
+package schemautil
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TestValidator tests that a Validator validates instances against its resolved schema
+// and reports every validation, valid or not, to its Stats.
+func (s *SchemaUtilTestSuite) TestValidator() {
+	type observation struct {
+		name  string
+		valid bool
+	}
+	var observed []observation
+	stats := StatsFunc(func(name string, err error, duration time.Duration) {
+		s.GreaterOrEqual(duration, time.Duration(0))
+		observed = append(observed, observation{name, err == nil})
+	})
+
+	v, err := NewValidator("users.v1.User", userSchema(), stats)
+	s.Require().NoError(err)
+	s.Equal("users.v1.User", v.Name())
+	s.NoError(v.Validate(map[string]any{"id": "u1", "tags": []any{"a"}}))
+	s.Error(v.Validate(map[string]any{"id": 1}))
+	s.Equal([]observation{{"users.v1.User", true}, {"users.v1.User", false}}, observed)
+
+	s.Run("without stats", func() {
+		v, err := NewValidator("users.v1.User", userSchema(), nil)
+		s.Require().NoError(err)
+		s.Error(v.Validate(map[string]any{}))
+	})
+
+	s.Run("unresolvable schema", func() {
+		_, err := NewValidator("users.v1.User", &jsonschema.Schema{Ref: "#/$defs/missing"}, stats)
+		s.ErrorContains(err, "users.v1.User")
+	})
+}
+
+// TestValidatorContext tests that ValidateContext agrees with Validate, for schemas split
+// into top-level properties and for those it validates whole, and that it stops with the
+// context's error once the context is done.
+func (s *SchemaUtilTestSuite) TestValidatorContext() {
+	recursive := userSchema()
+	user := recursive.Defs["users.v1.User"]
+	user.AdditionalProperties = &jsonschema.Schema{Not: &jsonschema.Schema{}}
+	user.Properties["friends"] = &jsonschema.Schema{Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/users.v1.User"}}
+	user.Properties["labels"] = &jsonschema.Schema{Type: "object", AdditionalProperties: &jsonschema.Schema{Ref: "#/$defs/users.v1.Label"}}
+	recursive.Defs["users.v1.Label"] = &jsonschema.Schema{Type: "string", MinLength: jsonschema.Ptr(1)}
+
+	selfReferencing := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":     {Type: "string"},
+			"parent": {Ref: "#"},
+		},
+		Required: []string{"id"},
+	}
+	constrainedRoot := userSchema()
+	constrainedRoot.MinProperties = jsonschema.Ptr(3)
+	objectRoot := &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":     {Type: "string"},
+			"labels": {Type: "object", AdditionalProperties: &jsonschema.Schema{Ref: "#/$defs/users.v1.Label"}},
+		},
+		Defs: map[string]*jsonschema.Schema{"users.v1.Label": {Type: "string", MinLength: jsonschema.Ptr(1)}},
+	}
+
+	instances := []map[string]any{
+		{"id": "u1", "tags": []any{"a"}},
+		{"id": "u1", "tags": []any{"a"}, "friends": []any{map[string]any{"id": "u2", "tags": []any{}}}},
+		{"id": "u1", "tags": []any{"a"}, "friends": []any{map[string]any{"id": "u2"}}},
+		{"id": "u1", "tags": []any{"a"}, "labels": map[string]any{"team": ""}},
+		{"id": "u1", "tags": []any{"a"}, "unknown": true},
+		{"id": 1, "tags": []any{"a"}},
+		{"tags": []any{"a"}},
+		{"id": "u1", "parent": map[string]any{"id": "u0"}},
+		{"id": "u1", "parent": map[string]any{"parent": map[string]any{}}},
+	}
+	for name, schema := range map[string]*jsonschema.Schema{
+		"reference root":        recursive,
+		"self-referencing root": selfReferencing,
+		"constrained root":      constrainedRoot,
+		"object root":           objectRoot,
+	} {
+		v, err := NewValidator(name, schema, nil)
+		s.Require().NoError(err)
+		for _, instance := range instances {
+			s.Equal(v.Validate(instance) == nil, v.ValidateContext(context.Background(), instance) == nil, "%s: %v", name, instance)
+		}
+		s.Error(v.ValidateContext(context.Background(), "not an object"), name)
+	}
+
+	// Schemas split into top-level properties report the property that failed, with the
+	// definitions shared by the property schemas.
+	for name, schema := range map[string]*jsonschema.Schema{"reference root": recursive, "object root": objectRoot} {
+		v, err := NewValidator(name, schema, nil)
+		s.Require().NoError(err)
+		s.ErrorContains(v.ValidateContext(context.Background(), instances[3]), `property "labels"`, name)
+	}
+	s.Len(recursive.Defs, 2, "the input must not be modified")
+
+	s.Run("done context", func() {
+		var observed []error
+		stats := StatsFunc(func(name string, err error, duration time.Duration) {
+			observed = append(observed, err)
+		})
+		v, err := NewValidator("users.v1.User", recursive, stats)
+		s.Require().NoError(err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.ErrorIs(v.ValidateContext(ctx, instances[0]), context.Canceled)
+
+		ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		s.ErrorIs(v.ValidateContext(ctx, instances[0]), context.DeadlineExceeded)
+		s.Len(observed, 2, "context errors are reported to stats")
+	})
+}
@@ -0,0 +1,74 @@
+// Package testsupport provides pre-built descriptor sets of the plugin's test protos, so
+// that tests can build protoc plugin requests without protoc or the alis options include
+// path. Each set is a FileDescriptorSet compiled with --include_imports and
+// --include_source_info, named after its fixture (e.g. "user.pb" for users/v1).
+//
+// The plugin's own test suite uses the sets, and when it runs with protoc checks them
+// against the test protos (go test -update-descriptors refreshes them). Downstream plugin developers can use them as realistic inputs:
+//
+//	p, err := testsupport.Plugin("user.pb", "users/v1/user.proto")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	err = plugin.Generate(p, "test")
+package testsupport
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+//go:embed descriptors/*.pb
+var descriptors embed.FS
+
+// Names returns the names of the embedded descriptor sets, sorted.
+func Names() []string {
+	entries, _ := fs.ReadDir(descriptors, "descriptors")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DescriptorSet returns a new copy of the embedded descriptor set with the given name.
+func DescriptorSet(name string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := descriptors.ReadFile(path.Join("descriptors", name))
+	if err != nil {
+		return nil, fmt.Errorf("testsupport: unknown descriptor set %q", name)
+	}
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("testsupport: descriptor set %q: %w", name, err)
+	}
+	return &fds, nil
+}
+
+// Request returns a code generator request for the files of the named descriptor set,
+// generating filesToGenerate (paths relative to testdata/protos).
+func Request(name string, filesToGenerate ...string) (*pluginpb.CodeGeneratorRequest, error) {
+	fds, err := DescriptorSet(name)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginpb.CodeGeneratorRequest{FileToGenerate: filesToGenerate, ProtoFile: fds.File}, nil
+}
+
+// Plugin returns a protogen.Plugin for the files of the named descriptor set, generating
+// filesToGenerate (paths relative to testdata/protos).
+func Plugin(name string, filesToGenerate ...string) (*protogen.Plugin, error) {
+	req, err := Request(name, filesToGenerate...)
+	if err != nil {
+		return nil, err
+	}
+	return protogen.Options{}.New(req)
+}